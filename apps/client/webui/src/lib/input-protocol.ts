@@ -0,0 +1,296 @@
+// Binary input protocol of the host data channel. The host offers the
+// versions it supports on the control channel, the client answers with the
+// version it sends. v1 frames are 10 bytes, v2 frames carry a sequence,
+// a timestamp and the modifiers, see apps/host/internal/input/protocol_v2.go.
+
+export const PROTOCOL_V1 = 1;
+export const PROTOCOL_V2 = 2;
+export const PROTOCOL_MESSAGE_TYPE = "input_protocol";
+
+export type ProtocolMessage = {
+  type: typeof PROTOCOL_MESSAGE_TYPE;
+  versions?: number[];
+  version?: number;
+};
+
+export const isProtocolMessage = (
+  message: unknown
+): message is ProtocolMessage =>
+  typeof message === "object" &&
+  message !== null &&
+  (message as {type?: unknown}).type === PROTOCOL_MESSAGE_TYPE;
+
+const V2_MARKER = 0x80 | PROTOCOL_V2;
+const V2_HEADER_SIZE = 18;
+
+export const FrameType = {
+  Key: 0x01,
+  PointerMove: 0x02,
+  PointerButton: 0x03,
+  Wheel: 0x04,
+  PointerDelta: 0x05,
+} as const;
+
+export const Action = {
+  Press: 0,
+  Release: 1,
+  // v1 pointer moves
+  Move: 2,
+  MoveRelative: 4,
+} as const;
+
+// Pointer modes of the pointer control command, relative while the pointer
+// is locked
+export const PointerMode = {
+  Absolute: "absolute",
+  Relative: "relative",
+} as const;
+
+// Buttons of the protocol, MouseEvent.button 0..4 is left, middle, right,
+// back and forward
+export const mapMouseButton = (button: number) =>
+  [1, 3, 2, 4, 5][button] ?? 1;
+
+// Modifiers of v2 frames
+export const Modifier = {
+  Shift: 1 << 0,
+  Ctrl: 1 << 1,
+  Alt: 1 << 2,
+  Meta: 1 << 3,
+  CapsLock: 1 << 4,
+} as const;
+
+type ModifierEvent = {
+  shiftKey: boolean;
+  ctrlKey: boolean;
+  altKey: boolean;
+  metaKey: boolean;
+  getModifierState(key: string): boolean;
+};
+
+export const modifiersOf = (e: ModifierEvent) =>
+  (e.shiftKey ? Modifier.Shift : 0) |
+  (e.ctrlKey ? Modifier.Ctrl : 0) |
+  (e.altKey ? Modifier.Alt : 0) |
+  (e.metaKey ? Modifier.Meta : 0) |
+  (e.getModifierState("CapsLock") ? Modifier.CapsLock : 0);
+
+// Normalized coordinates are 0..65535 across the captured area
+export const toU16 = (n: number) =>
+  Math.max(0, Math.min(65535, Math.round(n * 65535)));
+
+export const toI16 = (n: number) =>
+  Math.max(-32768, Math.min(32767, Math.round(n)));
+
+// USB HID usages of the physical keys by KeyboardEvent.code, the page in
+// the high 16 bits. The keyboard layout of the host decides the typed
+// character. Keep in sync with keyTable in apps/host/internal/input/keys.go.
+export const KEY_HID: Record<string, number> = {
+  // letters
+  KeyA: 0x070004,
+  KeyB: 0x070005,
+  KeyC: 0x070006,
+  KeyD: 0x070007,
+  KeyE: 0x070008,
+  KeyF: 0x070009,
+  KeyG: 0x07000a,
+  KeyH: 0x07000b,
+  KeyI: 0x07000c,
+  KeyJ: 0x07000d,
+  KeyK: 0x07000e,
+  KeyL: 0x07000f,
+  KeyM: 0x070010,
+  KeyN: 0x070011,
+  KeyO: 0x070012,
+  KeyP: 0x070013,
+  KeyQ: 0x070014,
+  KeyR: 0x070015,
+  KeyS: 0x070016,
+  KeyT: 0x070017,
+  KeyU: 0x070018,
+  KeyV: 0x070019,
+  KeyW: 0x07001a,
+  KeyX: 0x07001b,
+  KeyY: 0x07001c,
+  KeyZ: 0x07001d,
+  // digits
+  Digit1: 0x07001e,
+  Digit2: 0x07001f,
+  Digit3: 0x070020,
+  Digit4: 0x070021,
+  Digit5: 0x070022,
+  Digit6: 0x070023,
+  Digit7: 0x070024,
+  Digit8: 0x070025,
+  Digit9: 0x070026,
+  Digit0: 0x070027,
+  // writing system and functional keys
+  Enter: 0x070028,
+  Escape: 0x070029,
+  Backspace: 0x07002a,
+  Tab: 0x07002b,
+  Space: 0x07002c,
+  Minus: 0x07002d,
+  Equal: 0x07002e,
+  BracketLeft: 0x07002f,
+  BracketRight: 0x070030,
+  Backslash: 0x070031,
+  Semicolon: 0x070033,
+  Quote: 0x070034,
+  Backquote: 0x070035,
+  Comma: 0x070036,
+  Period: 0x070037,
+  Slash: 0x070038,
+  CapsLock: 0x070039,
+  IntlBackslash: 0x070064,
+  ContextMenu: 0x070065,
+  // function keys
+  F1: 0x07003a,
+  F2: 0x07003b,
+  F3: 0x07003c,
+  F4: 0x07003d,
+  F5: 0x07003e,
+  F6: 0x07003f,
+  F7: 0x070040,
+  F8: 0x070041,
+  F9: 0x070042,
+  F10: 0x070043,
+  F11: 0x070044,
+  F12: 0x070045,
+  F13: 0x070068,
+  F14: 0x070069,
+  F15: 0x07006a,
+  F16: 0x07006b,
+  F17: 0x07006c,
+  F18: 0x07006d,
+  F19: 0x07006e,
+  F20: 0x07006f,
+  F21: 0x070070,
+  F22: 0x070071,
+  F23: 0x070072,
+  F24: 0x070073,
+  // control pad and arrows
+  PrintScreen: 0x070046,
+  ScrollLock: 0x070047,
+  Pause: 0x070048,
+  Insert: 0x070049,
+  Home: 0x07004a,
+  PageUp: 0x07004b,
+  Delete: 0x07004c,
+  End: 0x07004d,
+  PageDown: 0x07004e,
+  ArrowRight: 0x07004f,
+  ArrowLeft: 0x070050,
+  ArrowDown: 0x070051,
+  ArrowUp: 0x070052,
+  // numpad
+  NumLock: 0x070053,
+  NumpadDivide: 0x070054,
+  NumpadMultiply: 0x070055,
+  NumpadSubtract: 0x070056,
+  NumpadAdd: 0x070057,
+  NumpadEnter: 0x070058,
+  Numpad1: 0x070059,
+  Numpad2: 0x07005a,
+  Numpad3: 0x07005b,
+  Numpad4: 0x07005c,
+  Numpad5: 0x07005d,
+  Numpad6: 0x07005e,
+  Numpad7: 0x07005f,
+  Numpad8: 0x070060,
+  Numpad9: 0x070061,
+  Numpad0: 0x070062,
+  NumpadDecimal: 0x070063,
+  // modifiers
+  ControlLeft: 0x0700e0,
+  ShiftLeft: 0x0700e1,
+  AltLeft: 0x0700e2,
+  MetaLeft: 0x0700e3,
+  ControlRight: 0x0700e4,
+  ShiftRight: 0x0700e5,
+  AltRight: 0x0700e6,
+  MetaRight: 0x0700e7,
+  // media keys, on the consumer page
+  MediaTrackNext: 0x0c00b5,
+  MediaTrackPrevious: 0x0c00b6,
+  MediaStop: 0x0c00b7,
+  MediaPlayPause: 0x0c00cd,
+  AudioVolumeMute: 0x0c00e2,
+  AudioVolumeDown: 0x0c00ea,
+  AudioVolumeUp: 0x0c00e9,
+};
+
+// FrameEncoder numbers the v2 frames of one data channel
+export class FrameEncoder {
+  private sequence = 0;
+
+  // frame returns a frame with a payload of the given size, written by
+  // fill at offset 0
+  frame(
+    type: number,
+    modifiers: number,
+    size: number,
+    fill: (payload: DataView) => void
+  ): ArrayBuffer {
+    const buf = new ArrayBuffer(V2_HEADER_SIZE + size);
+    const dv = new DataView(buf);
+    dv.setUint8(0, V2_MARKER);
+    dv.setUint8(1, type);
+    dv.setUint8(2, modifiers);
+    dv.setUint8(3, 0);
+    dv.setUint32(4, this.sequence, true);
+    dv.setBigUint64(8, BigInt(Math.floor(performance.now() * 1000)), true);
+    dv.setUint16(16, size, true);
+    fill(new DataView(buf, V2_HEADER_SIZE));
+    this.sequence = (this.sequence + 1) >>> 0;
+    return buf;
+  }
+
+  pointerMove(modifiers: number, x: number, y: number) {
+    return this.frame(FrameType.PointerMove, modifiers, 4, (payload) => {
+      payload.setUint16(0, toU16(x), true);
+      payload.setUint16(2, toU16(y), true);
+    });
+  }
+
+  pointerButton(
+    modifiers: number,
+    action: number,
+    button: number,
+    x: number,
+    y: number
+  ) {
+    return this.frame(FrameType.PointerButton, modifiers, 6, (payload) => {
+      payload.setUint8(0, action);
+      payload.setUint8(1, mapMouseButton(button));
+      payload.setUint16(2, toU16(x), true);
+      payload.setUint16(4, toU16(y), true);
+    });
+  }
+
+  // key returns the frame of a key, null when the key has no HID usage
+  key(modifiers: number, action: number, code: string) {
+    const usage = KEY_HID[code];
+    if (usage === undefined) return null;
+    return this.frame(FrameType.Key, modifiers, 4, (payload) => {
+      payload.setUint8(0, action);
+      payload.setUint8(1, usage >>> 16);
+      payload.setUint16(2, usage & 0xffff, true);
+    });
+  }
+
+  // pointerDelta moves the pointer by the movement of a locked pointer
+  pointerDelta(modifiers: number, dx: number, dy: number) {
+    return this.frame(FrameType.PointerDelta, modifiers, 4, (payload) => {
+      payload.setInt16(0, toI16(dx), true);
+      payload.setInt16(2, toI16(dy), true);
+    });
+  }
+
+  wheel(modifiers: number, dx: number, dy: number) {
+    return this.frame(FrameType.Wheel, modifiers, 4, (payload) => {
+      payload.setInt16(0, toI16(dx), true);
+      payload.setInt16(2, toI16(dy), true);
+    });
+  }
+}
@@ -5,6 +5,19 @@ import {
   CardHeader,
   CardTitle,
 } from "@/components/ui/card";
+import {
+  Action,
+  FrameEncoder,
+  isProtocolMessage,
+  mapMouseButton,
+  modifiersOf,
+  PROTOCOL_MESSAGE_TYPE,
+  PROTOCOL_V1,
+  PointerMode,
+  PROTOCOL_V2,
+  toI16,
+  toU16,
+} from "@/lib/input-protocol";
 import sessionService from "@/services/session.service";
 import {useSessionStore} from "@/stores/session.store";
 import type {Session} from "@/types/models/session";
@@ -37,11 +50,16 @@ const SessionPage: React.FC = () => {
   // DataChannel for input
   const dataChannelRef = useRef<RTCDataChannel | null>(null);
   const [dcConnected, setDcConnected] = useState(false);
-
-  const toU16 = (n: number) =>
-    Math.max(0, Math.min(65535, Math.round(n * 65535)));
-  const mapMouseButton = (btn: number) => (btn === 1 ? 3 : btn === 2 ? 2 : 1);
-
+  // Reliable, ordered DataChannel for control commands and host messages
+  const controlChannelRef = useRef<RTCDataChannel | null>(null);
+  // Input protocol confirmed by the host, v1 until it answers
+  const protocolVersionRef = useRef(PROTOCOL_V1);
+  const frameEncoderRef = useRef(new FrameEncoder());
+  // While the pointer is locked to the video its movement is sent as
+  // relative moves
+  const [pointerLocked, setPointerLocked] = useState(false);
+
+  // Virtual key codes of v1 frames, only sent to hosts without v2
   const VK: Record<string, number> = {
     Escape: 0x1b,
     Enter: 0x0d,
@@ -87,18 +105,17 @@ const SessionPage: React.FC = () => {
     Digit8: 0x38,
     Digit9: 0x39,
     Digit0: 0x30,
-    Semicolon: 0x3a,
-    Equals: 0x3b,
-    Comma: 0x3c,
-    Minus: 0x3d,
-    Period: 0x3e,
-    Slash: 0x3f,
-    Backquote: 0x60,
-    Openbracket: 0x5b,
-    Backslash: 0x5c,
-    Closebracket: 0x5d,
-    Caret: 0x5e,
-    Underscore: 0x5f,
+    Semicolon: 0xba,
+    Equal: 0xbb,
+    Comma: 0xbc,
+    Minus: 0xbd,
+    Period: 0xbe,
+    Slash: 0xbf,
+    Backquote: 0xc0,
+    BracketLeft: 0xdb,
+    Backslash: 0xdc,
+    BracketRight: 0xdd,
+    Quote: 0xde,
   };
   const getVK = (e: React.KeyboardEvent) => VK[e.code] ?? 0;
 
@@ -166,17 +183,80 @@ const SessionPage: React.FC = () => {
     return {x, y};
   };
 
-  const sendMouseMove = (xNorm: number, yNorm: number) =>
-    sendFrame10(1, 2, 0, 0, toU16(xNorm), toU16(yNorm));
-  const sendMouseDown = (button: number, xNorm: number, yNorm: number) =>
-    sendFrame10(2, 0, mapMouseButton(button), 0, toU16(xNorm), toU16(yNorm));
-  const sendMouseUp = (button: number, xNorm: number, yNorm: number) =>
-    sendFrame10(2, 1, mapMouseButton(button), 0, toU16(xNorm), toU16(yNorm));
-  const sendMouseClick = (button: number, xNorm: number, yNorm: number) =>
-    sendFrame10(2, 3, mapMouseButton(button), 0, toU16(xNorm), toU16(yNorm));
-  const sendWheel = (deltaY: number) => {
+  const sendBinary = (buf: ArrayBuffer) => {
     const dc = dataChannelRef.current;
     if (!dc || dc.readyState !== "open") return;
+    dc.send(buf);
+  };
+  const isV2 = () => protocolVersionRef.current === PROTOCOL_V2;
+
+  const sendMouseMove = (
+    e: React.MouseEvent,
+    xNorm: number,
+    yNorm: number
+  ) => {
+    if (isV2()) {
+      sendBinary(
+        frameEncoderRef.current.pointerMove(modifiersOf(e), xNorm, yNorm)
+      );
+      return;
+    }
+    sendFrame10(1, Action.Move, 0, 0, toU16(xNorm), toU16(yNorm));
+  };
+  const sendMouseDelta = (e: React.MouseEvent) => {
+    if (isV2()) {
+      sendBinary(
+        frameEncoderRef.current.pointerDelta(
+          modifiersOf(e),
+          e.movementX,
+          e.movementY
+        )
+      );
+      return;
+    }
+    sendFrame10(
+      1,
+      Action.MoveRelative,
+      0,
+      0,
+      toI16(e.movementX) & 0xffff,
+      toI16(e.movementY) & 0xffff
+    );
+  };
+  const sendMouseButton = (
+    e: React.MouseEvent,
+    action: number,
+    xNorm: number,
+    yNorm: number
+  ) => {
+    if (isV2()) {
+      sendBinary(
+        frameEncoderRef.current.pointerButton(
+          modifiersOf(e),
+          action,
+          e.button,
+          xNorm,
+          yNorm
+        )
+      );
+      return;
+    }
+    sendFrame10(
+      2,
+      action,
+      mapMouseButton(e.button),
+      0,
+      toU16(xNorm),
+      toU16(yNorm)
+    );
+  };
+  const sendWheel = (e: React.WheelEvent) => {
+    if (isV2()) {
+      sendBinary(
+        frameEncoderRef.current.wheel(modifiersOf(e), e.deltaX, e.deltaY)
+      );
+      return;
+    }
     const buf = new ArrayBuffer(10);
     const dv = new DataView(buf);
     dv.setUint8(0, 3); // wheel
@@ -185,41 +265,116 @@ const SessionPage: React.FC = () => {
     dv.setUint8(3, 0);
     dv.setUint16(4, 0, true);
     dv.setUint16(6, 0, true);
-    dv.setInt16(8, Math.max(-32768, Math.min(32767, deltaY)), true);
-    dc.send(buf);
+    dv.setInt16(8, Math.max(-32768, Math.min(32767, e.deltaY)), true);
+    sendBinary(buf);
   };
 
   // ---- Input handlers (video element) ----
   const handleMouseMove = (e: React.MouseEvent<HTMLVideoElement>) => {
     if (!videoRef.current) return;
+    if (pointerLocked) {
+      sendMouseDelta(e);
+      return;
+    }
     const {x, y} = rel(e);
-    sendMouseMove(x, y);
+    sendMouseMove(e, x, y);
   };
   const handleMouseDown = (e: React.MouseEvent<HTMLVideoElement>) => {
     if (!videoRef.current) return;
     const {x, y} = rel(e);
-    sendMouseDown(e.button, x, y);
+    sendMouseButton(e, Action.Press, x, y);
   };
   const handleMouseUp = (e: React.MouseEvent<HTMLVideoElement>) => {
     if (!videoRef.current) return;
     const {x, y} = rel(e);
-    sendMouseUp(e.button, x, y);
+    sendMouseButton(e, Action.Release, x, y);
   };
   const handleMouseClick = (e: React.MouseEvent<HTMLVideoElement>) => {
-    if (!videoRef.current) return;
+    // v2 has no clicks, the press and the release were sent already
+    if (!videoRef.current || isV2()) return;
     const {x, y} = rel(e);
-    sendMouseClick(e.button, x, y);
+    sendFrame10(2, 3, mapMouseButton(e.button), 0, toU16(x), toU16(y));
   };
   const handleWheel = (e: React.WheelEvent<HTMLVideoElement>) => {
-    sendWheel(e.deltaY);
+    sendWheel(e);
+  };
+  const sendKey = (e: React.KeyboardEvent, action: number) => {
+    if (!isV2()) {
+      sendFrame10(0, action, 0, getVK(e), 0, 0);
+      return;
+    }
+    // v2 sends the physical key as its HID usage
+    const frame = frameEncoderRef.current.key(modifiersOf(e), action, e.code);
+    if (!frame) {
+      console.log("[DC] key without HID usage", e.code);
+      return;
+    }
+    sendBinary(frame);
   };
   const handleKeyDown = (e: React.KeyboardEvent<HTMLVideoElement>) => {
     e.preventDefault();
-    sendFrame10(0, 0, 0, getVK(e), 0, 0);
+    sendKey(e, Action.Press);
   };
   const handleKeyUp = (e: React.KeyboardEvent<HTMLVideoElement>) => {
     e.preventDefault();
-    sendFrame10(0, 1, 0, getVK(e), 0, 0);
+    sendKey(e, Action.Release);
+  };
+
+  // ---- Pointer lock ----
+  const togglePointerLock = () => {
+    if (document.pointerLockElement) {
+      document.exitPointerLock();
+    } else {
+      videoRef.current?.requestPointerLock();
+    }
+  };
+  useEffect(() => {
+    const onLockChange = () => {
+      const locked =
+        !!videoRef.current && document.pointerLockElement === videoRef.current;
+      setPointerLocked(locked);
+      // the host ignores absolute moves in relative mode, their positions
+      // are stale while the pointer is locked
+      sendControl({
+        type: "pointer",
+        pointer: {mode: locked ? PointerMode.Relative : PointerMode.Absolute},
+      });
+    };
+    document.addEventListener("pointerlockchange", onLockChange);
+    return () =>
+      document.removeEventListener("pointerlockchange", onLockChange);
+  }, []);
+
+  // ---- Control channel ----
+  const sendControl = (command: object) => {
+    const dc = controlChannelRef.current;
+    if (!dc || dc.readyState !== "open") return;
+    dc.send(JSON.stringify(command));
+  };
+  const handleControlMessage = (dc: RTCDataChannel, data: unknown) => {
+    let message: unknown;
+    try {
+      message = JSON.parse(String(data));
+    } catch {
+      console.log("[CTRL] unexpected message", data);
+      return;
+    }
+
+    if (isProtocolMessage(message)) {
+      if (message.versions) {
+        // the host offers its versions, ask for v2 when it has it
+        if (message.versions.includes(PROTOCOL_V2)) {
+          dc.send(
+            JSON.stringify({type: PROTOCOL_MESSAGE_TYPE, version: PROTOCOL_V2})
+          );
+        }
+      } else if (message.version) {
+        protocolVersionRef.current = message.version;
+        console.log("[CTRL] input protocol v" + message.version);
+      }
+      return;
+    }
+    console.log("[CTRL] message", message);
   };
 
   // ---- WebRTC setup ----
@@ -230,6 +385,16 @@ const SessionPage: React.FC = () => {
 
     pc.ondatachannel = (e) => {
       const dc = e.channel;
+      if (dc.label === "control") {
+        controlChannelRef.current = dc;
+        // a new connection starts with v1 until the host confirms v2
+        protocolVersionRef.current = PROTOCOL_V1;
+        frameEncoderRef.current = new FrameEncoder();
+        dc.onopen = () => console.log("[CTRL] open", {id: dc.id});
+        dc.onclose = () => console.log("[CTRL] close", {id: dc.id});
+        dc.onmessage = (msg) => handleControlMessage(dc, msg.data);
+        return;
+      }
       if (dc.label !== "input") {
         console.log("Ignoring unexpected DataChannel:", dc.label);
         return;
@@ -253,13 +418,6 @@ const SessionPage: React.FC = () => {
         setDcConnected(false);
         console.log("[DC] error", err);
       };
-      dc.onmessage = (msg) => {
-        // Optional: handle acks/status if host sends any
-        console.log(
-          "[DC] message",
-          msg.data instanceof ArrayBuffer ? new Uint8Array(msg.data) : msg.data
-        );
-      };
     };
 
     pc.ontrack = (event) => {
@@ -303,10 +461,12 @@ const SessionPage: React.FC = () => {
 
       try {
         dataChannelRef.current?.close();
+        controlChannelRef.current?.close();
       } catch {
         console.error("Failed to close data channel");
       }
       dataChannelRef.current = null;
+      controlChannelRef.current = null;
 
       try {
         await pcRef.current?.close();
@@ -455,10 +615,12 @@ const SessionPage: React.FC = () => {
     return () => {
       try {
         dataChannelRef.current?.close();
+        controlChannelRef.current?.close();
       } catch {
         console.error("Failed to close data channel");
       }
       dataChannelRef.current = null;
+      controlChannelRef.current = null;
       try {
         pcRef.current?.close();
       } catch {
@@ -519,6 +681,13 @@ const SessionPage: React.FC = () => {
                 End Session
               </button>
             )}
+            <button
+              onClick={togglePointerLock}
+              disabled={!connected}
+              className="px-3 py-1 bg-gray-600 text-white text-xs rounded hover:bg-gray-700"
+            >
+              {pointerLocked ? "Release Mouse" : "Lock Mouse"}
+            </button>
             <button
               onClick={toggleFullscreen}
               className="px-3 py-1 bg-gray-600 text-white text-xs rounded hover:bg-gray-700"
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
+)
+
+// runInspect implements the inspect subcommand, which checks a raw H.264
+// (Annex-B) file for problems browsers cannot decode. It returns the exit
+// code: 1 when the stream has warnings, 2 on errors.
+func runInspect(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	frames := flags.Int("frames", 0, "number of frames to inspect, 0 inspects the whole file")
+	asJSON := flags.Bool("json", false, "print the result as JSON")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s inspect [-frames N] [-json] FILE.h264\n", AppName)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	info, err := video.InspectH264(file, *frames)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to inspect %s: %v\n", flags.Arg(0), err)
+		return 2
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(info)
+	} else {
+		fmt.Fprintln(stdout, info)
+		fmt.Fprintf(stdout, "GOPs: %v\n", info.GOPs)
+		for _, warning := range info.Warnings {
+			fmt.Fprintf(stdout, "warning: %s\n", warning)
+		}
+	}
+
+	if len(info.Warnings) > 0 {
+		return 1
+	}
+	return 0
+}
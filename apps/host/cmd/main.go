@@ -15,6 +15,13 @@ const (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "inspect" {
+		os.Exit(runInspect(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	_ = os.Setenv("LC_ALL", "C")
 	_ = os.Setenv("FYNE_LANGUAGE", "en")
 
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/m1thrandir225/imperium/apps/host/internal/input"
+)
+
+// runReplay implements the replay subcommand, which injects the input of a
+// recorded session again, or prints it with -dry-run. It returns the exit
+// code: 1 when the replay stopped early, 2 on usage errors.
+func runReplay(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	speed := flags.Float64("speed", 1, "replay speed, 2 replays twice as fast")
+	wait := flags.Duration("wait", 3*time.Second, "time to focus the target window before the replay starts")
+	dryRun := flags.Bool("dry-run", false, "print the commands instead of injecting them")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s replay [-speed N] [-wait D] [-dry-run] FILE%s\n", AppName, input.RecordingExt)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	defer file.Close()
+
+	reader, err := input.NewRecordingReader(file)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to read %s: %v\n", flags.Arg(0), err)
+		return 2
+	}
+	header := reader.Header()
+	fmt.Fprintf(stderr, "Replaying session %s recorded at %s at %.2gx speed\n",
+		header.SessionID, header.StartedAt.Format(time.RFC3339), *speed)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	inject := input.HandleCommand
+	if *dryRun {
+		encoder := json.NewEncoder(stdout)
+		inject = func(cmd input.InputCommand) { _ = encoder.Encode(cmd) }
+	} else {
+		// release anything the replay leaves pressed, also on an interrupt
+		held := input.NewInputTracker()
+		inject = func(cmd input.InputCommand) {
+			held.Track(cmd)
+			input.HandleCommand(cmd)
+		}
+		defer func() {
+			for _, cmd := range held.Release() {
+				input.HandleCommand(cmd)
+			}
+			if err := input.Close(); err != nil {
+				fmt.Fprintf(stderr, "failed to close the input devices: %v\n", err)
+			}
+		}()
+		select {
+		case <-ctx.Done():
+			return 1
+		case <-time.After(*wait):
+		}
+	}
+
+	replayed, err := input.Replay(ctx, reader, *speed, inject)
+	fmt.Fprintf(stderr, "Replayed %d commands\n", replayed)
+	switch {
+	case errors.Is(err, context.Canceled):
+		fmt.Fprintln(stderr, "replay interrupted")
+		return 1
+	case err != nil:
+		fmt.Fprintf(stderr, "replay stopped: %v\n", err)
+		return 1
+	}
+	return 0
+}
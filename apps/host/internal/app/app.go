@@ -5,6 +5,7 @@ import (
 	"context"
 	"log"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/m1thrandir225/imperium/apps/host/internal/auth"
@@ -32,6 +33,11 @@ type App struct {
 	tokenRefresher tokenrefresher.Refresher
 	SessionService session.Service
 	HTTPServer     *httpserver.Server
+
+	// probers are the encoder probers by ffmpeg path, each looks up the
+	// version of its ffmpeg once
+	probersMu sync.Mutex
+	probers   map[string]*video.EncoderProber
 }
 
 func New(name string) (*App, error) {
@@ -172,33 +178,100 @@ func (a *App) stopTokenRefresher() {
 	}
 }
 
+// videoConfig returns the recorder config for the current settings. An
+// invalid encoder profile is logged and replaced by the default profile.
+func (a *App) videoConfig() *video.Config {
+	settings := a.State.Get().Settings
+
+	cfg := &video.Config{
+		Encoder:    settings.Encoder,
+		FPS:        settings.Framerate,
+		FFMPEGPath: settings.FFmpegPath,
+		Source:     video.SourceModeFromEnv(video.SourceMode(settings.CaptureSource)),
+		SourcePath: video.ReplayFileFromEnv(""),
+		Profile:    settings.ActiveEncoderProfile(),
+	}
+	if cfg.Encoder == "" {
+		cfg.Encoder = "libx264"
+	}
+	if cfg.FPS == 0 {
+		cfg.FPS = 30
+	}
+	cfg.FallbackEncoders = a.fallbackEncoders(cfg.FFMPEGPath, cfg.Encoder)
+
+	if err := cfg.Validate(); err != nil {
+		log.Printf("video config is invalid, using the screen and the default profile: %v", err)
+		cfg.Source = video.SourceModeScreen
+		cfg.Profile = video.NewDefaultProfile()
+	}
+
+	return cfg
+}
+
+// encoderEventTopics maps the supervised ffmpeg lifecycle to bus topics
+var encoderEventTopics = map[video.ProcessEventType]string{
+	video.ProcessStarted:         EventEncoderStarted,
+	video.ProcessProgress:        EventEncoderProgress,
+	video.ProcessExited:          EventEncoderExited,
+	video.ProcessRestarting:      EventEncoderRestarting,
+	video.ProcessEncoderFallback: EventEncoderFallback,
+	video.ProcessFailed:          EventEncoderFailed,
+	video.ProcessStopped:         EventEncoderStopped,
+}
+
+// publishEncoderEvent publishes the lifecycle events of the encoder process
+// on the bus
+func (a *App) publishEncoderEvent(evt video.ProcessEvent) {
+	topic, ok := encoderEventTopics[evt.Type]
+	if !ok {
+		return
+	}
+	a.Bus.Publish(topic, EncoderProcessPayload{Event: evt})
+}
+
 func (a *App) buildSessionService() {
-	recorder, err := video.NewRecorder(
-		&video.Config{
-			Encoder:    a.State.Get().Settings.Encoder,
-			FPS:        a.State.Get().Settings.Framerate,
-			FFMPEGPath: a.State.Get().Settings.FFmpegPath,
-		},
-	)
+	encoder, source, err := video.NewEncoderForConfig(a.videoConfig())
 	if err != nil {
 		panic(err)
 	}
+	encoder.SetEventHandler(a.publishEncoderEvent)
 
 	sessionService, err := session.NewService(
 		a.AuthBaseURL,
 		a.State.Get().UserSession.AccessToken,
 		a.AuthService,
 		a.ProgramService,
-		recorder,
+		encoder,
+		source,
 		nil,
 	)
 	if err != nil {
 		panic(err) // should panic invalid SessionService
 	}
 
+	sessionService.SetPreviewEnabled(!a.State.Get().Settings.DisablePreview)
+	if configDir, err := util.GetConfigDir(a.Name); err == nil {
+		sessionService.SetScreenshotDir(filepath.Join(configDir, "screenshots"))
+	}
+	sessionService.SetInputRecordingDir(a.inputRecordingDir())
+
 	a.SessionService = sessionService
 }
 
+// inputRecordingDir returns the directory the input of sessions is
+// recorded in, empty when the recording is disabled
+func (a *App) inputRecordingDir() string {
+	if !a.State.Get().Settings.RecordInput {
+		return ""
+	}
+	configDir, err := util.GetConfigDir(a.Name)
+	if err != nil {
+		log.Printf("not recording input, no config directory: %v", err)
+		return ""
+	}
+	return filepath.Join(configDir, "recordings")
+}
+
 func (a *App) checkAndRefreshTokensAtStartup() {
 	st := a.State.Get()
 
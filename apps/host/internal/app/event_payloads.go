@@ -1,6 +1,10 @@
 package app
 
-import "github.com/m1thrandir225/imperium/apps/host/internal/state"
+import (
+	"github.com/m1thrandir225/imperium/apps/host/internal/input"
+	"github.com/m1thrandir225/imperium/apps/host/internal/state"
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
+)
 
 type UIShowScreenPayload struct {
 	Name string
@@ -18,6 +22,21 @@ type LoginSucceededPayload struct {
 
 type SettingsSavedPayload struct {
 	Settings state.Settings
+	// DisablePreview changes Settings.DisablePreview when set, a false
+	// value cannot be told apart from an unset one in Settings
+	DisablePreview *bool
+	// RecordInput changes Settings.RecordInput when set
+	RecordInput *bool
+}
+
+type EncodersProbeRequestedPayload struct {
+	FFmpegPath string
+	Force      bool
+}
+
+type EncodersProbedPayload struct {
+	Probe *video.EncoderProbe
+	Err   error
 }
 
 type SetupRequestedPayload struct {
@@ -31,14 +50,40 @@ type SetupCompletedPayload struct {
 }
 
 type ProgramItem struct {
-	ID          string
-	Name        string
-	Path        string
-	Description string
+	ID             string
+	Name           string
+	Path           string
+	Description    string
+	DefaultMonitor string
+	CaptureRegion  video.CaptureRegion
+	// InputPolicy is nil when the program uses the default policy
+	InputPolicy  *input.Policy
+	RemapProfile input.RemapProfile
 }
 
 type ProgramsDiscoveredPayload struct {
 	Programs []ProgramItem
+	Monitors []*video.MonitorInfo
+}
+
+type ProgramMonitorRequestedPayload struct {
+	ProgramID string
+	Monitor   string
+}
+
+type ProgramRegionRequestedPayload struct {
+	ProgramID string
+	Region    video.CaptureRegion
+}
+
+type ProgramInputPolicyRequestedPayload struct {
+	ProgramID string
+	Policy    *input.Policy
+}
+
+type ProgramRemapRequestedPayload struct {
+	ProgramID string
+	Profile   input.RemapProfile
 }
 
 type ProgramRegisterRequestedPayload struct {
@@ -73,3 +118,7 @@ type RegisterRequestedPayload struct {
 	FirstName string
 	LastName  string
 }
+
+type EncoderProcessPayload struct {
+	Event video.ProcessEvent
+}
@@ -33,13 +33,19 @@ const (
 	EventUIShowScreen = "ui.show_screen"
 
 	// Settings
-	EventSettingsSaved = "settings.saved"
+	EventSettingsSaved          = "settings.saved"
+	EventEncodersProbeRequested = "settings.encoders.probe.requested"
+	EventEncodersProbed         = "settings.encoders.probed"
 
 	//Programs
 	EventProgramsDiscoverRequested = "programs.discover.requested"
 	EventProgramsDisocvered        = "programs.discovered"
 	EventProgramRegisterRequested  = "programs.register.requested"
 	EventProgramRegistered         = "programs.registered"
+	EventProgramMonitorRequested   = "programs.monitor.requested"
+	EventProgramRegionRequested    = "programs.region.requested"
+	EventProgramInputRequested     = "programs.input.requested"
+	EventProgramRemapRequested     = "programs.remap.requested"
 
 	//Host
 	EventHostInitRequested = "host.init.requested"
@@ -49,4 +55,13 @@ const (
 	//Session
 	EventSessionStarted = "session.started"
 	EventSessionEnded   = "session.ended"
+
+	//Video encoder process
+	EventEncoderStarted    = "video.encoder.started"
+	EventEncoderProgress   = "video.encoder.progress"
+	EventEncoderExited     = "video.encoder.exited"
+	EventEncoderRestarting = "video.encoder.restarting"
+	EventEncoderFallback   = "video.encoder.fallback"
+	EventEncoderFailed     = "video.encoder.failed"
+	EventEncoderStopped    = "video.encoder.stopped"
 )
@@ -4,6 +4,7 @@ import (
 	"log"
 
 	"github.com/m1thrandir225/imperium/apps/host/internal/programs"
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
 )
 
 func (a *App) WireProgramsHandlers() {
@@ -25,15 +26,25 @@ func (a *App) WireProgramsHandlers() {
 			items := make([]ProgramItem, 0, len(list))
 			for _, p := range list {
 				items = append(items, ProgramItem{
-					ID:          p.ID,
-					Name:        p.Name,
-					Path:        p.Path,
-					Description: p.Description,
+					ID:             p.ID,
+					Name:           p.Name,
+					Path:           p.Path,
+					Description:    p.Description,
+					DefaultMonitor: p.DefaultMonitor,
+					CaptureRegion:  p.CaptureRegion,
+					InputPolicy:    p.InputPolicy,
+					RemapProfile:   p.RemapProfile,
 				})
 			}
 
+			monitors, err := video.ListMonitors()
+			if err != nil {
+				log.Printf("Failed to list monitors: %v", err)
+			}
+
 			a.Bus.Publish(EventProgramsDisocvered, ProgramsDiscoveredPayload{
 				Programs: items,
+				Monitors: monitors,
 			})
 		}
 	}()
@@ -75,4 +86,76 @@ func (a *App) WireProgramsHandlers() {
 			a.Bus.Publish(EventProgramsDiscoverRequested, nil)
 		}
 	}()
+
+	monitorCh := a.Bus.Subscribe(EventProgramMonitorRequested)
+	go func() {
+		for evt := range monitorCh {
+			payload, ok := evt.(ProgramMonitorRequestedPayload)
+			if !ok {
+				continue
+			}
+
+			if a.ProgramService == nil {
+				a.buildClients()
+			}
+
+			if err := a.ProgramService.SetProgramMonitor(payload.ProgramID, payload.Monitor); err != nil {
+				log.Printf("Failed to set the default monitor of program %s: %v", payload.ProgramID, err)
+			}
+		}
+	}()
+
+	regionCh := a.Bus.Subscribe(EventProgramRegionRequested)
+	go func() {
+		for evt := range regionCh {
+			payload, ok := evt.(ProgramRegionRequestedPayload)
+			if !ok {
+				continue
+			}
+
+			if a.ProgramService == nil {
+				a.buildClients()
+			}
+
+			if err := a.ProgramService.SetProgramCaptureRegion(payload.ProgramID, payload.Region); err != nil {
+				log.Printf("Failed to set the capture region of program %s: %v", payload.ProgramID, err)
+			}
+		}
+	}()
+
+	inputCh := a.Bus.Subscribe(EventProgramInputRequested)
+	go func() {
+		for evt := range inputCh {
+			payload, ok := evt.(ProgramInputPolicyRequestedPayload)
+			if !ok {
+				continue
+			}
+
+			if a.ProgramService == nil {
+				a.buildClients()
+			}
+
+			if err := a.ProgramService.SetProgramInputPolicy(payload.ProgramID, payload.Policy); err != nil {
+				log.Printf("Failed to set the input policy of program %s: %v", payload.ProgramID, err)
+			}
+		}
+	}()
+
+	remapCh := a.Bus.Subscribe(EventProgramRemapRequested)
+	go func() {
+		for evt := range remapCh {
+			payload, ok := evt.(ProgramRemapRequestedPayload)
+			if !ok {
+				continue
+			}
+
+			if a.ProgramService == nil {
+				a.buildClients()
+			}
+
+			if err := a.ProgramService.SetProgramRemapProfile(payload.ProgramID, payload.Profile); err != nil {
+				log.Printf("Failed to set the remap profile of program %s: %v", payload.ProgramID, err)
+			}
+		}
+	}()
 }
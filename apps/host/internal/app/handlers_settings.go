@@ -2,8 +2,10 @@ package app
 
 import (
 	"log"
+	"path/filepath"
 
 	"github.com/m1thrandir225/imperium/apps/host/internal/state"
+	"github.com/m1thrandir225/imperium/apps/host/internal/util"
 	"github.com/m1thrandir225/imperium/apps/host/internal/video"
 )
 
@@ -31,6 +33,10 @@ func (a *App) WireSettingsHandlers() {
 					s.Settings.Framerate = payload.Settings.Framerate
 				}
 
+				if payload.Settings.CaptureSource != "" {
+					s.Settings.CaptureSource = payload.Settings.CaptureSource
+				}
+
 				if payload.Settings.Bitrate != "" {
 					s.Settings.Bitrate = payload.Settings.Bitrate
 				}
@@ -38,6 +44,23 @@ func (a *App) WireSettingsHandlers() {
 				if len(payload.Settings.CustomProgramPaths) > 0 {
 					s.Settings.CustomProgramPaths = payload.Settings.CustomProgramPaths
 				}
+
+				for _, profile := range payload.Settings.EncoderProfiles {
+					if err := profile.WithDefaults(s.Settings.Framerate).Validate(); err != nil {
+						log.Printf("not saving encoder profile %q: %v", profile.Name, err)
+						continue
+					}
+					s.Settings.SetEncoderProfile(profile)
+				}
+				if payload.Settings.EncoderProfile != "" {
+					s.Settings.EncoderProfile = payload.Settings.EncoderProfile
+				}
+				if payload.DisablePreview != nil {
+					s.Settings.DisablePreview = *payload.DisablePreview
+				}
+				if payload.RecordInput != nil {
+					s.Settings.RecordInput = *payload.RecordInput
+				}
 			})
 			if err != nil {
 				log.Printf("failed to update state: %v", err)
@@ -47,14 +70,82 @@ func (a *App) WireSettingsHandlers() {
 
 			a.buildClients()
 			if a.SessionService != nil {
-				st := a.State.Get()
-				a.SessionService.UpdateVideoConfig(&video.Config{
-					Encoder:    st.Settings.Encoder,
-					FPS:        st.Settings.Framerate,
-					FFMPEGPath: st.Settings.FFmpegPath,
-				})
+				a.SessionService.UpdateVideoConfig(a.videoConfig())
+				a.SessionService.SetPreviewEnabled(!a.State.Get().Settings.DisablePreview)
+				a.SessionService.SetInputRecordingDir(a.inputRecordingDir())
 			}
 			a.Bus.Publish(EventStateSaved, a.State.Get())
 		}
 	}()
+
+	probeCh := a.Bus.Subscribe(EventEncodersProbeRequested)
+	go func() {
+		for evt := range probeCh {
+			payload, ok := evt.(EncodersProbeRequestedPayload)
+			if !ok {
+				continue
+			}
+
+			probe, err := a.probeEncoders(payload.FFmpegPath, payload.Force)
+			if err != nil {
+				log.Printf("failed to probe encoders: %v", err)
+			}
+
+			a.Bus.Publish(EventEncodersProbed, EncodersProbedPayload{
+				Probe: probe,
+				Err:   err,
+			})
+		}
+	}()
+}
+
+// probeEncoders returns the encoder probe for the given ffmpeg path, the
+// results are cached next to the config file
+func (a *App) probeEncoders(ffmpegPath string, force bool) (*video.EncoderProbe, error) {
+	prober, err := a.encoderProber(ffmpegPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return prober.Probe(force)
+}
+
+// fallbackEncoders returns the encoders tried when the given one fails. It
+// uses the cached probe only, without one the software encoder is the only
+// fallback.
+func (a *App) fallbackEncoders(ffmpegPath, encoder string) []string {
+	var probe *video.EncoderProbe
+	if prober, err := a.encoderProber(ffmpegPath); err == nil {
+		probe, _ = prober.Cached()
+	}
+	return probe.FallbackChain(encoder)
+}
+
+// encoderProber returns the encoder prober for the given ffmpeg path, an
+// empty path uses the one of the settings. The prober of a path is reused.
+func (a *App) encoderProber(ffmpegPath string) (*video.EncoderProber, error) {
+	if ffmpegPath == "" {
+		ffmpegPath = a.State.Get().Settings.FFmpegPath
+	}
+
+	a.probersMu.Lock()
+	defer a.probersMu.Unlock()
+	if prober, ok := a.probers[ffmpegPath]; ok {
+		return prober, nil
+	}
+
+	cachePath := ""
+	if configDir, err := util.GetConfigDir(a.Name); err == nil {
+		cachePath = filepath.Join(configDir, "encoders.json")
+	}
+
+	prober, err := video.NewEncoderProber(ffmpegPath, cachePath)
+	if err != nil {
+		return nil, err
+	}
+	if a.probers == nil {
+		a.probers = make(map[string]*video.EncoderProber)
+	}
+	a.probers[ffmpegPath] = prober
+	return prober, nil
 }
@@ -2,10 +2,12 @@ package httpserver
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
 	"github.com/m1thrandir225/imperium/apps/host/internal/session"
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
 )
 
 // handleStartSessionRequest represents the http handler for starting a session
@@ -45,6 +47,15 @@ func (s *Server) handleStartSessionRequest(w http.ResponseWriter, r *http.Reques
 		WebrtcOffer:  req.WebrtcOffer,
 		StartedAt:    req.StartedAt,
 		CreatedAt:    req.CreatedAt,
+		Monitor:      req.Monitor,
+		Stream: video.StreamParams{
+			Width:      req.Width,
+			Height:     req.Height,
+			FPS:        req.FPS,
+			MaxBitrate: req.MaxBitrate,
+		},
+		Region:    req.Region,
+		Spectator: req.Spectator,
 	})
 	if err != nil {
 		log.Printf("Failed to start session: %v", err)
@@ -80,6 +91,9 @@ func (s *Server) handleStartSessionRequest(w http.ResponseWriter, r *http.Reques
 	response := AuthServerSessionResponse{
 		Success:      true,
 		WebrtcAnswer: webrtcAnswer,
+		Monitor:      session.Monitor,
+		Stream:       &session.Stream,
+		Encoder:      session.Encoder,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(response)
@@ -135,3 +149,187 @@ func (s *Server) handleGetPrograms(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(programs)
 }
+
+// handleGetMonitors represents the http handler for returning the monitors
+// that can be selected when starting a session
+func (s *Server) handleGetMonitors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	monitors, err := s.sessionService.GetMonitors()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(monitors)
+}
+
+// handleInputState represents the http handler for the keys, buttons and
+// fingers held down by the session, for debugging stuck input
+func (s *Server) handleInputState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.sessionService.InputState())
+}
+
+// handleReconfigureStream represents the http handler for changing the
+// resolution, frame rate and bitrate of the running session
+func (s *Server) handleReconfigureStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req video.StreamParams
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+
+	stream, err := s.sessionService.ReconfigureStream(req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, session.ErrNoActiveSession) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(stream)
+}
+
+// handleSetCaptureRegion represents the http handler for changing the crop
+// and the masks of the running session
+func (s *Server) handleSetCaptureRegion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req video.CaptureRegion
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+
+	stream, err := s.sessionService.SetCaptureRegion(req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, session.ErrInvalidCaptureRegion):
+			status = http.StatusBadRequest
+		case errors.Is(err, session.ErrNoActiveSession), errors.Is(err, session.ErrCaptureRegionNotSupported):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(stream)
+}
+
+// handleControlCommand represents the http handler for the control commands
+// of the running session, the same commands are accepted on the control data
+// channel
+func (s *Server) handleControlCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req session.ControlCommand
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+
+	response, err := s.sessionService.HandleControlCommand(req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, session.ErrUnknownControlCommand):
+			status = http.StatusBadRequest
+		case errors.Is(err, session.ErrNoActiveSession), errors.Is(err, session.ErrPointerConfined):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// handleHostPreview represents the http handler for the low resolution
+// preview of the host screen
+func (s *Server) handleHostPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	image, err := s.sessionService.Preview(r.Context())
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, session.ErrPreviewDisabled) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "private, max-age=5")
+	_, _ = w.Write(image)
+}
+
+// handleGetScreenshots represents the http handler for listing the stored
+// screenshots
+func (s *Server) handleGetScreenshots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	screenshots, err := s.sessionService.ListScreenshots()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(screenshots)
+}
+
+// handleGetScreenshot represents the http handler for downloading a stored
+// screenshot
+func (s *Server) handleGetScreenshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	path, err := s.sessionService.ScreenshotPath(r.PathValue("name"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, session.ErrScreenshotNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	http.ServeFile(w, r, path)
+}
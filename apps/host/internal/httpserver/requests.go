@@ -1,6 +1,10 @@
 package httpserver
 
-import "time"
+import (
+	"time"
+
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
+)
 
 // Deprecated: StartSessionRequest is the request the host sends to the AuthServer when the
 // host initiates a new session (rarely used, as the client usually starts the
@@ -36,6 +40,21 @@ type AuthServerSessionRequest struct {
 	HostID       string    `json:"host_id"`
 	HostName     string    `json:"host_name"`
 	StartedAt    time.Time `json:"started_at"`
+	// Monitor selects the captured monitor by index or name, empty for the
+	// default monitor of the program
+	Monitor string `json:"monitor,omitempty"`
+	// Width, Height, FPS and MaxBitrate (kbit/s) are requested by the
+	// client for its display, zero leaves the choice to the host
+	Width      int `json:"width,omitempty"`
+	Height     int `json:"height,omitempty"`
+	FPS        int `json:"fps,omitempty"`
+	MaxBitrate int `json:"max_bitrate,omitempty"`
+	// Region crops and masks the captured monitor, the capture region of
+	// the program is used when it is not set
+	Region *video.CaptureRegion `json:"region,omitempty"`
+	// Spectator starts a read only session, the input of the client is
+	// dropped
+	Spectator bool `json:"spectator,omitempty"`
 }
 
 // AuthServerSessionResponse represents the response from the AuthServer when
@@ -44,4 +63,12 @@ type AuthServerSessionResponse struct {
 	Success      bool   `json:"success"`
 	WebrtcAnswer string `json:"webrtc_answer,omitempty"`
 	Error        string `json:"error,omitempty"`
+	// Monitor is the monitor captured for the session
+	Monitor string `json:"monitor,omitempty"`
+	// Stream holds the effective stream parameters after clamping the
+	// requested ones to the limits of the host
+	Stream *video.StreamParams `json:"stream,omitempty"`
+	// Encoder is the encoder the stream started with, a fallback later on
+	// is sent on the control data channel
+	Encoder string `json:"encoder,omitempty"`
 }
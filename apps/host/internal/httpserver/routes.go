@@ -9,6 +9,16 @@ func (s *Server) routes() {
 	s.mux.HandleFunc("/api/session/end", s.handleStop)
 	s.mux.HandleFunc("/api/session/status", s.handleStatus)
 	s.mux.HandleFunc("/api/session/programs", s.handleGetPrograms)
+	s.mux.HandleFunc("/api/session/monitors", s.handleGetMonitors)
+	s.mux.HandleFunc("/api/session/stream", s.handleReconfigureStream)
+	s.mux.HandleFunc("/api/session/region", s.handleSetCaptureRegion)
+	s.mux.HandleFunc("/api/session/control", s.handleControlCommand)
+	s.mux.HandleFunc("/api/session/input", s.handleInputState)
+
+	// host endpoints
+	s.mux.HandleFunc("/api/host/preview.jpg", s.handleHostPreview)
+	s.mux.HandleFunc("/api/host/screenshots", s.handleGetScreenshots)
+	s.mux.HandleFunc("/api/host/screenshots/{name}", s.handleGetScreenshot)
 
 	// client endpoints
 	webrtc.RegisterSignalingHandlers(s.mux, s.sessionService.WebRTCStreamer)
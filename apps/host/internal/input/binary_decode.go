@@ -12,16 +12,32 @@ const (
 	actRelease = 1
 	actMove    = 2
 	actClick   = 3
+	// actMoveRelative moves the pointer by the signed x and y of a move
+	actMoveRelative = 4
 
-	btnNone   = 0
-	btnLeft   = 1
-	btnRight  = 2
-	btnMiddle = 3
+	btnNone    = 0
+	btnLeft    = 1
+	btnRight   = 2
+	btnMiddle  = 3
+	btnBack    = 4
+	btnForward = 5
+
+	frameSizeV1 = 10
 )
 
-// DecodeInputCommand decodes a binary input command to the InputCommand struct
+// DecodeInputCommand decodes a binary input command to the InputCommand
+// struct. Frames of protocol v2 are told apart by their first byte, see
+// protocol_v2.go, everything else is decoded as the 10 byte frames of v1.
 func DecodeInputCommand(b []byte) (InputCommand, bool) {
-	if len(b) < 10 {
+	if isFrameV2(b) {
+		return decodeFrameV2(b)
+	}
+	return decodeFrameV1(b)
+}
+
+// decodeFrameV1 decodes a frame of protocol v1
+func decodeFrameV1(b []byte) (InputCommand, bool) {
+	if len(b) < frameSizeV1 {
 		return InputCommand{}, false
 	}
 
@@ -47,6 +63,12 @@ func DecodeInputCommand(b []byte) (InputCommand, bool) {
 		cmd.Key = keyCodeToString(key)
 	case inpTypeMouseMove:
 		cmd.Type = "mouse"
+		if a == actMoveRelative {
+			cmd.Action = "move_relative"
+			cmd.X = int(int16(ux))
+			cmd.Y = int(int16(uy))
+			break
+		}
 		cmd.Action = "move"
 		cmd.X = int(ux)
 		cmd.Y = int(uy)
@@ -79,11 +101,18 @@ func buttonToString(b byte) string {
 		return "right"
 	case btnMiddle:
 		return "middle"
+	case btnBack:
+		return "back"
+	case btnForward:
+		return "forward"
 	default:
 		return "none"
 	}
 }
 
+// keyCodeToString returns the KeyboardEvent.code of the virtual key code of
+// a v1 frame, empty when it is not known
 func keyCodeToString(code uint16) string {
-	return vkToName[code]
+	key, _ := keyCodeFromVK(code)
+	return key
 }
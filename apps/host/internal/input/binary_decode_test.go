@@ -0,0 +1,93 @@
+package input
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeInputCommand_V1(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+		want  InputCommand
+	}{
+		{
+			name:  "key press",
+			frame: []byte{inpTypeKeyboard, actPress, 0, 0, 0x41, 0x00, 0, 0, 0, 0},
+			want:  InputCommand{Type: "keyboard", Action: "press", Key: "KeyA"},
+		},
+		{
+			name:  "key release",
+			frame: []byte{inpTypeKeyboard, actRelease, 0, 0, 0x0D, 0x00, 0, 0, 0, 0},
+			want:  InputCommand{Type: "keyboard", Action: "release", Key: "Enter"},
+		},
+		{
+			name:  "mouse move",
+			frame: []byte{inpTypeMouseMove, actMove, 0, 0, 0, 0, 0xff, 0xff, 0x00, 0x80},
+			want:  InputCommand{Type: "mouse", Action: "move", X: 65535, Y: 32768},
+		},
+		{
+			name:  "relative mouse move",
+			frame: []byte{inpTypeMouseMove, actMoveRelative, 0, 0, 0, 0, 0xfb, 0xff, 0x07, 0x00},
+			want:  InputCommand{Type: "mouse", Action: "move_relative", X: -5, Y: 7},
+		},
+		{
+			name:  "mouse press",
+			frame: []byte{inpTypeMouseButton, actPress, btnRight, 0, 0, 0, 0x10, 0x00, 0x20, 0x00},
+			want:  InputCommand{Type: "mouse", Action: "press", Button: "right", X: 16, Y: 32},
+		},
+		{
+			name:  "wheel",
+			frame: []byte{inpTypeWheel, 0, 0, 0, 0, 0, 0, 0, 0x9c, 0xff},
+			want:  InputCommand{Type: "mouse", Action: "scroll", Y: -100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, ok := DecodeInputCommand(tt.frame)
+			require.True(t, ok)
+			require.Equal(t, tt.want, cmd)
+		})
+	}
+
+	_, ok := DecodeInputCommand([]byte{inpTypeMouseMove, actMove, 0, 0})
+	require.False(t, ok)
+
+	_, ok = DecodeInputCommand([]byte{0x7f, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	require.False(t, ok)
+}
+
+// FuzzDecodeInputCommand checks that no frame makes the decoder panic and
+// that every v2 frame it accepts survives encoding and decoding again
+func FuzzDecodeInputCommand(f *testing.F) {
+	f.Add([]byte{inpTypeKeyboard, actPress, 0, 0, 0x41, 0x00, 0, 0, 0, 0})
+	f.Add([]byte{inpTypeWheel, 0, 0, 0, 0, 0, 0, 0, 0x9c, 0xff})
+	for _, cmd := range []InputCommand{
+		{Type: "keyboard", Action: "press", Key: "KeyZ", Modifiers: ModShift, Sequence: 1, Timestamp: time.Second},
+		{Type: "mouse", Action: "move", X: 100, Y: 200, Sequence: 2},
+		{Type: "mouse", Action: "release", Button: "middle", X: 1, Y: 2},
+		{Type: "mouse", Action: "scroll", X: -5, Y: 120},
+		{Type: "mouse", Action: "move_relative", X: -7, Y: 3},
+	} {
+		frame, err := EncodeInputCommand(cmd)
+		require.NoError(f, err)
+		f.Add(frame)
+		f.Add(append(frame, 0x01, 0x02, 0xaa, 0xbb))
+	}
+
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		cmd, ok := DecodeInputCommand(frame)
+		if !ok || !isFrameV2(frame) {
+			return
+		}
+
+		encoded, err := EncodeInputCommand(cmd)
+		require.NoError(t, err)
+		decoded, ok := DecodeInputCommand(encoded)
+		require.True(t, ok)
+		require.Equal(t, cmd, decoded)
+	})
+}
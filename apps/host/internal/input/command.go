@@ -1,10 +1,32 @@
 package input
 
+import "time"
+
 type InputCommand struct {
-	Type   string `json:"type"`   // "keyboard", "mouse"
-	Action string `json:"action"` // "press", "release", "move", "click"
+	Type   string `json:"type"`   // "keyboard", "mouse", "touch"
+	Action string `json:"action"` // "press", "release", "move", "move_relative", "click", "scroll", "start", "end", "cancel"
 	Key    string `json:"key,omitempty"`
 	X      int    `json:"x,omitempty"`
 	Y      int    `json:"y,omitempty"`
 	Button string `json:"button,omitempty"`
+	// PointerID tells the fingers of touch commands apart, it is the
+	// PointerEvent.pointerId of the client
+	PointerID int `json:"pointer_id,omitempty"`
+
+	// Modifiers, Sequence and Timestamp are only sent by protocol v2
+	Modifiers Modifiers `json:"modifiers,omitempty"`
+	Sequence  uint32    `json:"sequence,omitempty"`
+	// Timestamp is the time of the event on the clock of the client
+	Timestamp time.Duration `json:"timestamp,omitempty"`
 }
+
+// Modifiers is the state of the modifier keys when an event happened
+type Modifiers uint8
+
+const (
+	ModShift Modifiers = 1 << iota
+	ModCtrl
+	ModAlt
+	ModMeta
+	ModCapsLock
+)
@@ -0,0 +1,174 @@
+package input
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
+)
+
+const (
+	// normalizedMax is the maximum of the normalized coordinates the client
+	// sends, 0..normalizedMax spans the captured monitor or window
+	normalizedMax = 65535
+	// screenGeometryTTL is how long the layout is cached, moves arrive far
+	// more often than monitors change or windows are moved
+	screenGeometryTTL = time.Second
+)
+
+// captureArea holds the capture source of the active session, the client
+// sees its area so the input is mapped to it
+var captureArea struct {
+	mu     sync.Mutex
+	source video.CaptureSource
+	// generation changes with the source, cached layouts are read again
+	generation uint64
+}
+
+// SetCaptureSource maps the input to the area captured by the source, a
+// monitor, a crop of it or a window. Nil maps it to the primary monitor.
+func SetCaptureSource(source video.CaptureSource) {
+	captureArea.mu.Lock()
+	defer captureArea.mu.Unlock()
+	captureArea.source = source
+	captureArea.generation++
+}
+
+// currentCaptureSource returns the capture source and its generation
+func currentCaptureSource() (video.CaptureSource, uint64) {
+	captureArea.mu.Lock()
+	defer captureArea.mu.Unlock()
+	return captureArea.source, captureArea.generation
+}
+
+// screenRect is a rectangle on the desktop in pixels
+type screenRect struct {
+	X, Y, Width, Height int
+}
+
+// screenGeometry maps the normalized coordinates of the protocol, which
+// are relative to the captured area, to the desktop
+type screenGeometry struct {
+	mu         sync.Mutex
+	updatedAt  time.Time
+	generation uint64
+	// capture is the captured area on the desktop
+	capture screenRect
+	desktop screenRect
+}
+
+// refresh reads the layout when the cached one expired or the capture
+// source changed, the caller holds g.mu
+func (g *screenGeometry) refresh() {
+	source, generation := currentCaptureSource()
+	if !g.updatedAt.IsZero() && generation == g.generation && time.Since(g.updatedAt) < screenGeometryTTL {
+		return
+	}
+	g.updatedAt = time.Now()
+	g.generation = generation
+
+	monitors, err := video.ListMonitors()
+	if err != nil || len(monitors) == 0 {
+		log.Printf("failed to read the monitor layout for input: %v", err)
+		return
+	}
+
+	var bounds *video.Rect
+	if source != nil {
+		rect, err := video.CaptureBounds(source)
+		if err != nil {
+			log.Printf("failed to read the captured area of %s for input, using the primary monitor: %v", source.Name(), err)
+		} else {
+			bounds = &rect
+		}
+	}
+	g.desktop = desktopBounds(monitors)
+	g.capture = captureRect(monitors, bounds)
+}
+
+// captureRect returns the captured area, or the primary monitor without
+// one
+func captureRect(monitors []*video.MonitorInfo, bounds *video.Rect) screenRect {
+	if bounds != nil && !bounds.Empty() {
+		return screenRect{X: bounds.X, Y: bounds.Y, Width: bounds.Width, Height: bounds.Height}
+	}
+
+	var primary screenRect
+	for _, monitor := range monitors {
+		if monitor.IsPrimary || primary.Width == 0 {
+			primary = screenRect{X: monitor.OffsetX, Y: monitor.OffsetY, Width: monitor.Width, Height: monitor.Height}
+		}
+	}
+	return primary
+}
+
+// screenPosition returns the pixel position of normalized coordinates,
+// without a known layout they are only clamped
+func (g *screenGeometry) screenPosition(nx, ny int) (int, int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.refresh()
+
+	if g.capture.Width == 0 || g.capture.Height == 0 {
+		return clampNormalized(nx), clampNormalized(ny)
+	}
+	return monitorPoint(nx, ny, g.capture)
+}
+
+// desktopPosition returns the position of normalized coordinates on an
+// axis range of 0..maximum that spans the whole desktop, the way absolute
+// input devices are mapped
+func (g *screenGeometry) desktopPosition(nx, ny, maximum int) (int, int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.refresh()
+
+	if g.capture.Width == 0 || g.desktop.Width == 0 {
+		return clampNormalized(nx) * maximum / normalizedMax, clampNormalized(ny) * maximum / normalizedMax
+	}
+	x, y := monitorPoint(nx, ny, g.capture)
+	return scaleToDesktop(x, y, g.desktop, maximum)
+}
+
+// monitorPoint returns the pixel position of normalized coordinates in the
+// captured monitor or window
+func monitorPoint(nx, ny int, monitor screenRect) (int, int) {
+	x := monitor.X + clampNormalized(nx)*(monitor.Width-1)/normalizedMax
+	y := monitor.Y + clampNormalized(ny)*(monitor.Height-1)/normalizedMax
+	return x, y
+}
+
+// scaleToDesktop returns a pixel position on an axis range of 0..maximum
+// spanning the desktop
+func scaleToDesktop(x, y int, desktop screenRect, maximum int) (int, int) {
+	scale := func(value, offset, size int) int {
+		if size <= 1 {
+			return 0
+		}
+		return min(max(value-offset, 0), size-1) * maximum / (size - 1)
+	}
+	return scale(x, desktop.X, desktop.Width), scale(y, desktop.Y, desktop.Height)
+}
+
+// desktopBounds returns the bounding box of all monitors
+func desktopBounds(monitors []*video.MonitorInfo) screenRect {
+	if len(monitors) == 0 {
+		return screenRect{}
+	}
+
+	minX, minY := monitors[0].OffsetX, monitors[0].OffsetY
+	maxX, maxY := minX+monitors[0].Width, minY+monitors[0].Height
+	for _, monitor := range monitors[1:] {
+		minX = min(minX, monitor.OffsetX)
+		minY = min(minY, monitor.OffsetY)
+		maxX = max(maxX, monitor.OffsetX+monitor.Width)
+		maxY = max(maxY, monitor.OffsetY+monitor.Height)
+	}
+	return screenRect{X: minX, Y: minY, Width: maxX - minX, Height: maxY - minY}
+}
+
+// clampNormalized clamps a normalized coordinate to 0..normalizedMax
+func clampNormalized(v int) int {
+	return min(max(v, 0), normalizedMax)
+}
@@ -0,0 +1,91 @@
+package input
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitorPoint(t *testing.T) {
+	monitor := screenRect{X: 1920, Y: 0, Width: 2560, Height: 1440}
+
+	x, y := monitorPoint(0, 0, monitor)
+	require.Equal(t, 1920, x)
+	require.Equal(t, 0, y)
+
+	x, y = monitorPoint(normalizedMax, normalizedMax, monitor)
+	require.Equal(t, 1920+2559, x)
+	require.Equal(t, 1439, y)
+
+	// out of range coordinates stay on the monitor
+	x, y = monitorPoint(-10, 70000, monitor)
+	require.Equal(t, 1920, x)
+	require.Equal(t, 1439, y)
+}
+
+func TestDesktopBounds(t *testing.T) {
+	desktop := desktopBounds([]*video.MonitorInfo{
+		{Width: 1920, Height: 1080, OffsetX: 0, OffsetY: 360},
+		{Width: 2560, Height: 1440, OffsetX: 1920, OffsetY: 0},
+	})
+	require.Equal(t, screenRect{X: 0, Y: 0, Width: 4480, Height: 1440}, desktop)
+
+	require.Equal(t, screenRect{}, desktopBounds(nil))
+}
+
+func TestScaleToDesktop(t *testing.T) {
+	desktop := screenRect{X: 0, Y: 0, Width: 4480, Height: 1440}
+
+	x, y := scaleToDesktop(0, 0, desktop, 65535)
+	require.Equal(t, 0, x)
+	require.Equal(t, 0, y)
+
+	x, y = scaleToDesktop(4479, 1439, desktop, 65535)
+	require.Equal(t, 65535, x)
+	require.Equal(t, 65535, y)
+
+	x, _ = scaleToDesktop(1920, 0, desktop, 65535)
+	require.Equal(t, 1920*65535/4479, x)
+}
+
+func TestCaptureRect(t *testing.T) {
+	monitors := []*video.MonitorInfo{
+		{Width: 2560, Height: 1440, OffsetX: 1920, OffsetY: 0},
+		{Width: 1920, Height: 1080, OffsetX: 0, OffsetY: 360, IsPrimary: true},
+	}
+
+	require.Equal(t, screenRect{X: 0, Y: 360, Width: 1920, Height: 1080}, captureRect(monitors, nil))
+	require.Equal(t, screenRect{X: 2000, Y: 100, Width: 800, Height: 600},
+		captureRect(monitors, &video.Rect{X: 2000, Y: 100, Width: 800, Height: 600}))
+	require.Equal(t, screenRect{X: 0, Y: 360, Width: 1920, Height: 1080}, captureRect(monitors, &video.Rect{}))
+	require.Equal(t, screenRect{}, captureRect(nil, nil))
+}
+
+func TestScreenGeometry_CaptureArea(t *testing.T) {
+	_, generation := currentCaptureSource()
+	// a window on the secondary monitor, right of the primary one
+	g := &screenGeometry{
+		updatedAt:  time.Now(),
+		generation: generation,
+		capture:    screenRect{X: 2000, Y: 100, Width: 801, Height: 601},
+		desktop:    screenRect{X: 0, Y: 0, Width: 4480, Height: 1440},
+	}
+
+	x, y := g.screenPosition(0, 0)
+	require.Equal(t, 2000, x)
+	require.Equal(t, 100, y)
+	x, y = g.screenPosition(normalizedMax/2, normalizedMax)
+	require.Equal(t, 2000+400-1, x)
+	require.Equal(t, 100+600, y)
+
+	x, y = g.desktopPosition(normalizedMax, normalizedMax, 65535)
+	require.Equal(t, 2800*65535/4479, x)
+	require.Equal(t, 700*65535/1439, y)
+
+	// a new capture source is read again
+	SetCaptureSource(nil)
+	_, changed := currentCaptureSource()
+	require.NotEqual(t, generation, changed)
+}
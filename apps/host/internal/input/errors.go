@@ -0,0 +1,25 @@
+package input
+
+import "errors"
+
+var (
+	// ErrUinputNotWritable is returned when the virtual input devices cannot
+	// be created, the user needs write access to /dev/uinput
+	ErrUinputNotWritable = errors.New("/dev/uinput is not writable, add the user to the input group or allow it with a udev rule")
+	// ErrNoInputBackend is returned when no way to inject input is available
+	ErrNoInputBackend = errors.New("no input backend available")
+	// ErrInvalidInputCommand is returned when a command cannot be encoded
+	ErrInvalidInputCommand = errors.New("invalid input command")
+	// ErrInvalidPointerSettings is returned for an unknown pointer mode or
+	// a scaling out of range
+	ErrInvalidPointerSettings = errors.New("invalid pointer settings")
+	// ErrInvalidPolicy is returned for an unknown key in a blocked
+	// combination or a confine area out of range
+	ErrInvalidPolicy = errors.New("invalid input policy")
+	// ErrInvalidRemapProfile is returned for an unknown key or button, a
+	// key remapped twice or a macro that is too long
+	ErrInvalidRemapProfile = errors.New("invalid remap profile")
+	// ErrInvalidRecording is returned for a file that is not an input
+	// recording or is corrupted
+	ErrInvalidRecording = errors.New("invalid input recording")
+)
@@ -0,0 +1,80 @@
+package input
+
+import "strings"
+
+// Event types and codes of the Linux input subsystem, see
+// linux/input-event-codes.h
+const (
+	evSyn = 0x00
+	evKey = 0x01
+	evRel = 0x02
+	evAbs = 0x03
+
+	synReport = 0
+
+	relX             = 0x00
+	relY             = 0x01
+	relHWheel        = 0x06
+	relWheel         = 0x08
+	relWheelHiRes    = 0x0b
+	relHWheelHiRes   = 0x0c
+	absX             = 0x00
+	absY             = 0x01
+	absMTSlot        = 0x2f
+	absMTPositionX   = 0x35
+	absMTPositionY   = 0x36
+	absMTTrackingID  = 0x39
+	evdevBtnTouch    = 0x14a
+	inputPropDirect  = 0x01
+	evdevBtnLeft     = 0x110
+	evdevBtnRight    = 0x111
+	evdevBtnMiddle   = 0x112
+	evdevBtnSide     = 0x113
+	evdevBtnExtra    = 0x114
+	evdevMaxKeyboard = 0xff // the last key code of a keyboard
+)
+
+// evdevButtonCode returns the evdev code of a mouse button name
+func evdevButtonCode(name string) (uint16, bool) {
+	switch strings.ToLower(name) {
+	case "left":
+		return evdevBtnLeft, true
+	case "right":
+		return evdevBtnRight, true
+	case "middle":
+		return evdevBtnMiddle, true
+	case "back":
+		return evdevBtnSide, true
+	case "forward":
+		return evdevBtnExtra, true
+	default:
+		return 0, false
+	}
+}
+
+// wheelPixelsPerStep is the scroll distance of one wheel step in the pixel
+// deltas browsers report
+const wheelPixelsPerStep = 100
+
+// wheelSteps converts a browser wheel delta to wheel steps and high
+// resolution steps in 1/120 of a step. Browsers scroll down for positive
+// deltas, the wheel scrolls up for positive steps. A delta below one step
+// still scrolls by one step.
+func wheelSteps(delta int) (steps, hiRes int) {
+	if delta == 0 {
+		return 0, 0
+	}
+
+	hiRes = -delta * 120 / wheelPixelsPerStep
+	steps = -delta / wheelPixelsPerStep
+	if steps == 0 {
+		steps = 1
+		if delta > 0 {
+			steps = -1
+		}
+	}
+	if hiRes == 0 {
+		hiRes = steps
+	}
+	return steps, hiRes
+}
@@ -0,0 +1,41 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvdevButtonCode(t *testing.T) {
+	code, ok := evdevButtonCode("left")
+	require.True(t, ok)
+	require.Equal(t, uint16(evdevBtnLeft), code)
+
+	code, ok = evdevButtonCode("middle")
+	require.True(t, ok)
+	require.Equal(t, uint16(evdevBtnMiddle), code)
+
+	_, ok = evdevButtonCode("none")
+	require.False(t, ok)
+}
+
+func TestWheelSteps(t *testing.T) {
+	tests := []struct {
+		delta     int
+		wantSteps int
+		wantHiRes int
+	}{
+		{delta: 0, wantSteps: 0, wantHiRes: 0},
+		{delta: 100, wantSteps: -1, wantHiRes: -120},
+		{delta: -300, wantSteps: 3, wantHiRes: 360},
+		// touchpads send small deltas
+		{delta: 4, wantSteps: -1, wantHiRes: -4},
+		{delta: -1, wantSteps: 1, wantHiRes: 1},
+	}
+
+	for _, tt := range tests {
+		steps, hiRes := wheelSteps(tt.delta)
+		require.Equal(t, tt.wantSteps, steps, "delta %d", tt.delta)
+		require.Equal(t, tt.wantHiRes, hiRes, "delta %d", tt.delta)
+	}
+}
@@ -9,3 +9,8 @@ import "context"
 func HandleCommand(cmd InputCommand) {
 	context.TODO()
 }
+
+// Close releases the input devices, there are none on darwin
+func Close() error {
+	return nil
+}
@@ -3,9 +3,162 @@
 
 package input
 
-import "context"
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
 
-// TODO: fix for linux distributions
+// backendRetryInterval is how long to wait before trying to create the
+// backend again after it failed, so every event does not try and log
+const backendRetryInterval = 5 * time.Second
+
+// linuxBackend injects input events on Linux
+type linuxBackend interface {
+	Name() string
+	Key(name string, pressed bool) error
+	Button(name string, pressed bool) error
+	// MoveAbsolute moves the pointer to normalized coordinates of the
+	// captured monitor, crop or window, see SetCaptureSource
+	MoveAbsolute(nx, ny int) error
+	MoveRelative(dx, dy int) error
+	// Scroll scrolls by browser wheel deltas
+	Scroll(dx, dy int) error
+	// Touch injects a finger of a touch command at normalized coordinates
+	// of the captured area, errNoTouchDevice when the backend cannot inject
+	// touch
+	Touch(action string, id, nx, ny int) error
+	Close() error
+}
+
+// errNoTouchDevice is returned by backends without touch injection, touch
+// is emulated with the pointer then
+var errNoTouchDevice = errors.New("no touch device")
+
+var (
+	backendMu       sync.Mutex
+	backend         linuxBackend
+	backendFailedAt time.Time
+
+	// touchFallback emulates the mouse for backends without touch
+	touchFallback touchEmulator
+)
+
+// HandleCommand injects the command through uinput, or through XTest when
+// uinput is not available. The devices are created on the first command.
 func HandleCommand(cmd InputCommand) {
-	context.TODO()
+	b, err := currentBackend()
+	if err != nil {
+		return
+	}
+
+	if err := handleCommand(b, cmd); err != nil {
+		log.Printf("failed to inject %s %s with %s: %v", cmd.Type, cmd.Action, b.Name(), err)
+	}
+}
+
+// Close removes the virtual input devices, anything they hold pressed is
+// released. The next command creates them again.
+func Close() error {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+
+	backendFailedAt = time.Time{}
+	touchFallback.reset()
+	if backend == nil {
+		return nil
+	}
+	log.Printf("Closing the %s input backend", backend.Name())
+	err := backend.Close()
+	backend = nil
+	return err
+}
+
+// currentBackend returns the backend, creating it when there is none
+func currentBackend() (linuxBackend, error) {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+
+	if backend != nil {
+		return backend, nil
+	}
+	if !backendFailedAt.IsZero() && time.Since(backendFailedAt) < backendRetryInterval {
+		return nil, ErrNoInputBackend
+	}
+
+	b, err := newBackend()
+	if err != nil {
+		log.Printf("input injection is not available: %v", err)
+		backendFailedAt = time.Now()
+		return nil, err
+	}
+
+	log.Printf("Injecting input with %s", b.Name())
+	backend = b
+	return b, nil
+}
+
+// newBackend creates the uinput backend, or the XTest backend when uinput
+// fails
+func newBackend() (linuxBackend, error) {
+	uinput, err := newUinputBackend()
+	if err == nil {
+		return uinput, nil
+	}
+	if errors.Is(err, ErrUinputNotWritable) {
+		log.Printf("%v, falling back to XTest", err)
+	} else {
+		log.Printf("failed to create the uinput devices, falling back to XTest: %v", err)
+	}
+
+	xtest, xtestErr := newXTestBackend()
+	if xtestErr != nil {
+		return nil, fmt.Errorf("%w: %w, XTest: %v", ErrNoInputBackend, err, xtestErr)
+	}
+	return xtest, nil
+}
+
+// handleCommand applies the command to the backend
+func handleCommand(b linuxBackend, cmd InputCommand) error {
+	switch cmd.Type {
+	case "keyboard":
+		switch cmd.Action {
+		case "press":
+			return b.Key(cmd.Key, true)
+		case "release":
+			return b.Key(cmd.Key, false)
+		}
+	case "mouse":
+		switch cmd.Action {
+		case "move":
+			return b.MoveAbsolute(cmd.X, cmd.Y)
+		case "move_relative":
+			return b.MoveRelative(cmd.X, cmd.Y)
+		case "press":
+			return b.Button(cmd.Button, true)
+		case "release":
+			return b.Button(cmd.Button, false)
+		case "click":
+			if err := b.Button(cmd.Button, true); err != nil {
+				return err
+			}
+			return b.Button(cmd.Button, false)
+		case "scroll":
+			return b.Scroll(cmd.X, cmd.Y)
+		}
+	case "touch":
+		err := b.Touch(cmd.Action, cmd.PointerID, cmd.X, cmd.Y)
+		if !errors.Is(err, errNoTouchDevice) {
+			return err
+		}
+		for _, mouse := range touchFallback.mouseCommands(cmd) {
+			if err := handleCommand(b, mouse); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown input command %s %s", cmd.Type, cmd.Action)
 }
@@ -5,7 +5,6 @@ import (
 	"log"
 	"strings"
 
-	"github.com/m1thrandir225/imperium/apps/host/internal/video"
 	"github.com/stephen-fox/user32util"
 )
 
@@ -29,6 +28,8 @@ func HandleCommand(cmd InputCommand) {
 		handleMouseCommand(cmd)
 	case "keyboard":
 		handleKeyboardCommand(cmd)
+	case "touch":
+		handleTouchCommand(cmd)
 	default:
 		log.Printf("❌ Unknown input type: %s", cmd.Type)
 	}
@@ -44,6 +45,10 @@ func handleMouseCommand(cmd InputCommand) {
 		} else {
 			log.Printf("Mouse moved successfully")
 		}
+	case "move_relative":
+		if err := moveMouseRelative(cmd.X, cmd.Y); err != nil {
+			log.Printf("Failed to move mouse: %v", err)
+		}
 	case "press":
 		log.Printf("Mouse press: %s", cmd.Button)
 		if err := pressMouseButton(cmd.Button); err != nil {
@@ -58,6 +63,10 @@ func handleMouseCommand(cmd InputCommand) {
 		} else {
 			log.Printf("Mouse button released successfully")
 		}
+	case "scroll":
+		if err := scrollMouse(cmd.X, cmd.Y); err != nil {
+			log.Printf("Failed to scroll: %v", err)
+		}
 	case "click":
 		log.Printf("Mouse click: %s", cmd.Button)
 		if err := clickMouseButton(cmd.Button); err != nil {
@@ -102,8 +111,30 @@ func moveMouse(x, y int) error {
 	return nil
 }
 
+// moveMouseRelative moves the cursor by a delta, games reading raw input
+// see the motion like that of a real mouse
+func moveMouseRelative(dx, dy int) error {
+	mouseInput := user32util.MouseInput{
+		Dx:      int32(dx),
+		Dy:      int32(dy),
+		DwFlags: user32util.MouseEventFMove,
+	}
+
+	if err := user32util.SendMouseInput(mouseInput, user32DLL); err != nil {
+		return fmt.Errorf("SendMouseInput failed: %w", err)
+	}
+
+	return nil
+}
+
+// XBUTTON1 and XBUTTON2, the mouse data of the back and forward buttons
+const (
+	xButton1 = 0x0001
+	xButton2 = 0x0002
+)
+
 func pressMouseButton(button string) error {
-	var flags uint32
+	var flags, data uint32
 	switch strings.ToLower(button) {
 	case "left":
 		flags = user32util.MouseEventFLeftDown
@@ -111,12 +142,17 @@ func pressMouseButton(button string) error {
 		flags = user32util.MouseEventFRightDown
 	case "middle":
 		flags = user32util.MouseEventFMiddleDown
+	case "back":
+		flags, data = user32util.MouseEventFXDown, xButton1
+	case "forward":
+		flags, data = user32util.MouseEventFXDown, xButton2
 	default:
 		return fmt.Errorf("unknown mouse button: %s", button)
 	}
 
 	mouseInput := user32util.MouseInput{
-		DwFlags: flags,
+		MouseData: data,
+		DwFlags:   flags,
 	}
 
 	log.Printf("🔍 Sending mouse button press: %s (flags: %v)", button, flags)
@@ -130,7 +166,7 @@ func pressMouseButton(button string) error {
 }
 
 func releaseMouseButton(button string) error {
-	var flags uint32
+	var flags, data uint32
 	switch strings.ToLower(button) {
 	case "left":
 		flags = user32util.MouseEventFLeftUp
@@ -138,12 +174,17 @@ func releaseMouseButton(button string) error {
 		flags = user32util.MouseEventFRightUp
 	case "middle":
 		flags = user32util.MouseEventFMiddleUp
+	case "back":
+		flags, data = user32util.MouseEventFXUp, xButton1
+	case "forward":
+		flags, data = user32util.MouseEventFXUp, xButton2
 	default:
 		return fmt.Errorf("unknown mouse button: %s", button)
 	}
 
 	mouseInput := user32util.MouseInput{
-		DwFlags: flags,
+		MouseData: data,
+		DwFlags:   flags,
 	}
 
 	log.Printf("🔍 Sending mouse button release: %s (flags: %v)", button, flags)
@@ -156,29 +197,39 @@ func releaseMouseButton(button string) error {
 	return nil
 }
 
-// normalizeCoordsToScreen converts 0..65535 normalized coords to asbolute screen pixels
-// god knows why this works or is needed...
-func normalizeCoordsToScreen(nx, ny int) (int, int) {
-	mi, err := video.GetPrimaryMonitorInfo()
-	if err != nil {
-		return clamp16(nx), clamp16(ny)
+// scrollMouse turns the browser wheel deltas into wheel movements in
+// 1/120 of a step, WHEEL_DELTA. Like the deltas, the horizontal wheel is
+// positive to the right while the vertical wheel is positive upwards.
+func scrollMouse(dx, dy int) error {
+	if _, hiRes := wheelSteps(dy); hiRes != 0 {
+		mouseInput := user32util.MouseInput{
+			MouseData: uint32(int32(hiRes)),
+			DwFlags:   user32util.MouseEventFWheel,
+		}
+		if err := user32util.SendMouseInput(mouseInput, user32DLL); err != nil {
+			return fmt.Errorf("SendMouseInput failed: %w", err)
+		}
 	}
-
-	x := int(float64(nx)/65535.0*float64(mi.Width)) + mi.OffsetX
-	y := int(float64(ny)/65535.0*float64(mi.Height)) + mi.OffsetY
-	return x, y
+	if _, hiRes := wheelSteps(dx); hiRes != 0 {
+		mouseInput := user32util.MouseInput{
+			MouseData: uint32(int32(-hiRes)),
+			DwFlags:   user32util.MouseEventFHWheel,
+		}
+		if err := user32util.SendMouseInput(mouseInput, user32DLL); err != nil {
+			return fmt.Errorf("SendMouseInput failed: %w", err)
+		}
+	}
+	return nil
 }
 
-// clamp16 clamps a value between 0 and 65535
-func clamp16(v int) int {
-	if v < 0 {
-		return 0
-	}
+// screen maps the normalized coordinates to the captured area on the
+// virtual desktop, SetCursorPos takes virtual desktop pixels
+var screen = &screenGeometry{}
 
-	if v > 65535 {
-		return 65535
-	}
-	return v
+// normalizeCoordsToScreen converts 0..65535 normalized coords to pixels on
+// the virtual desktop, inside the captured monitor or window
+func normalizeCoordsToScreen(nx, ny int) (int, int) {
+	return screen.screenPosition(nx, ny)
 }
 
 func clickMouseButton(button string) error {
@@ -191,38 +242,39 @@ func clickMouseButton(button string) error {
 	return nil
 }
 
-func pressKey(key string) error {
-	vkCode, exists := keyMap[strings.ToLower(key)]
-	if !exists {
-		return fmt.Errorf("unknown key: %s", key)
-	}
-
-	keyInput := user32util.KeybdInput{
-		WVK: uint16(vkCode),
-	}
-
-	log.Printf("🔍 Sending key press: %s (VK: 0x%02X)", key, vkCode)
-
-	err := user32util.SendKeydbInput(keyInput, user32DLL)
-	if err != nil {
-		return fmt.Errorf("SendKeydbInput failed: %w", err)
-	}
+// extendedKeys are sent with KEYEVENTF_EXTENDEDKEY, the flag tells them
+// apart from the numpad keys of the same virtual key code
+var extendedKeys = map[string]bool{
+	"Insert": true, "Delete": true, "Home": true, "End": true, "PageUp": true, "PageDown": true,
+	"ArrowLeft": true, "ArrowUp": true, "ArrowRight": true, "ArrowDown": true,
+	"NumpadEnter": true, "NumpadDivide": true, "ControlRight": true, "AltRight": true,
+	"MetaLeft": true, "MetaRight": true, "ContextMenu": true, "PrintScreen": true, "NumLock": true,
+}
 
-	return nil
+func pressKey(key string) error {
+	return sendKey(key, 0)
 }
 
 func releaseKey(key string) error {
-	vkCode, exists := keyMap[strings.ToLower(key)]
+	return sendKey(key, user32util.KeyEventFKeyUp)
+}
+
+// sendKey sends the virtual key code of a KeyboardEvent.code
+func sendKey(key string, flags uint32) error {
+	vkCode, exists := windowsVK(key)
 	if !exists {
 		return fmt.Errorf("unknown key: %s", key)
 	}
+	if extendedKeys[key] {
+		flags |= user32util.KeyEventFExtendedKey
+	}
 
 	keyInput := user32util.KeybdInput{
 		WVK:     vkCode,
-		DwFlags: 0x0002, // KEYEVENTF_KEYUP
+		DwFlags: flags,
 	}
 
-	log.Printf("🔍 Sending key release: %s (VK: 0x%02X)", key, vkCode)
+	log.Printf("🔍 Sending key: %s (VK: 0x%02X, flags: 0x%X)", key, vkCode, flags)
 
 	err := user32util.SendKeydbInput(keyInput, user32DLL)
 	if err != nil {
@@ -231,3 +283,8 @@ func releaseKey(key string) error {
 
 	return nil
 }
+
+// Close releases the input devices, SendInput needs none
+func Close() error {
+	return nil
+}
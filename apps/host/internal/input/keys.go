@@ -0,0 +1,240 @@
+package input
+
+// Keys are named by KeyboardEvent.code, which names the physical key
+// independent of the keyboard layout, and translated to the codes of the
+// platforms through keyTable.
+
+// keyUnmapped marks a key that has no code on a platform
+const keyUnmapped = 0xffff
+
+// HID usage pages of the keys, the usage of a key is its page shifted by
+// 16 bits with the usage id
+const (
+	hidPageKeyboard = 0x07
+	hidPageConsumer = 0x0c
+)
+
+// keyInfo is a physical key with its USB HID usage and its codes on every
+// platform
+type keyInfo struct {
+	code  string // KeyboardEvent.code
+	hid   uint32 // USB HID usage page and id
+	vk    uint16 // Windows virtual key code
+	evdev uint16 // Linux input event code
+	mac   uint16 // macOS virtual key code
+}
+
+// keyTable lists the supported keys. When keys share a Windows virtual key
+// code, like Enter and NumpadEnter, the first one is the key of that code.
+var keyTable = []keyInfo{
+	// letters
+	{code: "KeyA", hid: 0x070004, vk: 0x41, evdev: 30, mac: 0x00},
+	{code: "KeyB", hid: 0x070005, vk: 0x42, evdev: 48, mac: 0x0B},
+	{code: "KeyC", hid: 0x070006, vk: 0x43, evdev: 46, mac: 0x08},
+	{code: "KeyD", hid: 0x070007, vk: 0x44, evdev: 32, mac: 0x02},
+	{code: "KeyE", hid: 0x070008, vk: 0x45, evdev: 18, mac: 0x0E},
+	{code: "KeyF", hid: 0x070009, vk: 0x46, evdev: 33, mac: 0x03},
+	{code: "KeyG", hid: 0x07000A, vk: 0x47, evdev: 34, mac: 0x05},
+	{code: "KeyH", hid: 0x07000B, vk: 0x48, evdev: 35, mac: 0x04},
+	{code: "KeyI", hid: 0x07000C, vk: 0x49, evdev: 23, mac: 0x22},
+	{code: "KeyJ", hid: 0x07000D, vk: 0x4A, evdev: 36, mac: 0x26},
+	{code: "KeyK", hid: 0x07000E, vk: 0x4B, evdev: 37, mac: 0x28},
+	{code: "KeyL", hid: 0x07000F, vk: 0x4C, evdev: 38, mac: 0x25},
+	{code: "KeyM", hid: 0x070010, vk: 0x4D, evdev: 50, mac: 0x2E},
+	{code: "KeyN", hid: 0x070011, vk: 0x4E, evdev: 49, mac: 0x2D},
+	{code: "KeyO", hid: 0x070012, vk: 0x4F, evdev: 24, mac: 0x1F},
+	{code: "KeyP", hid: 0x070013, vk: 0x50, evdev: 25, mac: 0x23},
+	{code: "KeyQ", hid: 0x070014, vk: 0x51, evdev: 16, mac: 0x0C},
+	{code: "KeyR", hid: 0x070015, vk: 0x52, evdev: 19, mac: 0x0F},
+	{code: "KeyS", hid: 0x070016, vk: 0x53, evdev: 31, mac: 0x01},
+	{code: "KeyT", hid: 0x070017, vk: 0x54, evdev: 20, mac: 0x11},
+	{code: "KeyU", hid: 0x070018, vk: 0x55, evdev: 22, mac: 0x20},
+	{code: "KeyV", hid: 0x070019, vk: 0x56, evdev: 47, mac: 0x09},
+	{code: "KeyW", hid: 0x07001A, vk: 0x57, evdev: 17, mac: 0x0D},
+	{code: "KeyX", hid: 0x07001B, vk: 0x58, evdev: 45, mac: 0x07},
+	{code: "KeyY", hid: 0x07001C, vk: 0x59, evdev: 21, mac: 0x10},
+	{code: "KeyZ", hid: 0x07001D, vk: 0x5A, evdev: 44, mac: 0x06},
+	// digits
+	{code: "Digit1", hid: 0x07001E, vk: 0x31, evdev: 2, mac: 0x12},
+	{code: "Digit2", hid: 0x07001F, vk: 0x32, evdev: 3, mac: 0x13},
+	{code: "Digit3", hid: 0x070020, vk: 0x33, evdev: 4, mac: 0x14},
+	{code: "Digit4", hid: 0x070021, vk: 0x34, evdev: 5, mac: 0x15},
+	{code: "Digit5", hid: 0x070022, vk: 0x35, evdev: 6, mac: 0x17},
+	{code: "Digit6", hid: 0x070023, vk: 0x36, evdev: 7, mac: 0x16},
+	{code: "Digit7", hid: 0x070024, vk: 0x37, evdev: 8, mac: 0x1A},
+	{code: "Digit8", hid: 0x070025, vk: 0x38, evdev: 9, mac: 0x1C},
+	{code: "Digit9", hid: 0x070026, vk: 0x39, evdev: 10, mac: 0x19},
+	{code: "Digit0", hid: 0x070027, vk: 0x30, evdev: 11, mac: 0x1D},
+	// writing system and functional keys
+	{code: "Enter", hid: 0x070028, vk: 0x0D, evdev: 28, mac: 0x24},
+	{code: "Escape", hid: 0x070029, vk: 0x1B, evdev: 1, mac: 0x35},
+	{code: "Backspace", hid: 0x07002A, vk: 0x08, evdev: 14, mac: 0x33},
+	{code: "Tab", hid: 0x07002B, vk: 0x09, evdev: 15, mac: 0x30},
+	{code: "Space", hid: 0x07002C, vk: 0x20, evdev: 57, mac: 0x31},
+	{code: "Minus", hid: 0x07002D, vk: 0xBD, evdev: 12, mac: 0x1B},
+	{code: "Equal", hid: 0x07002E, vk: 0xBB, evdev: 13, mac: 0x18},
+	{code: "BracketLeft", hid: 0x07002F, vk: 0xDB, evdev: 26, mac: 0x21},
+	{code: "BracketRight", hid: 0x070030, vk: 0xDD, evdev: 27, mac: 0x1E},
+	{code: "Backslash", hid: 0x070031, vk: 0xDC, evdev: 43, mac: 0x2A},
+	{code: "Semicolon", hid: 0x070033, vk: 0xBA, evdev: 39, mac: 0x29},
+	{code: "Quote", hid: 0x070034, vk: 0xDE, evdev: 40, mac: 0x27},
+	{code: "Backquote", hid: 0x070035, vk: 0xC0, evdev: 41, mac: 0x32},
+	{code: "Comma", hid: 0x070036, vk: 0xBC, evdev: 51, mac: 0x2B},
+	{code: "Period", hid: 0x070037, vk: 0xBE, evdev: 52, mac: 0x2F},
+	{code: "Slash", hid: 0x070038, vk: 0xBF, evdev: 53, mac: 0x2C},
+	{code: "CapsLock", hid: 0x070039, vk: 0x14, evdev: 58, mac: 0x39},
+	{code: "IntlBackslash", hid: 0x070064, vk: 0xE2, evdev: 86, mac: 0x0A},
+	{code: "ContextMenu", hid: 0x070065, vk: 0x5D, evdev: 127, mac: 0x6E},
+	// function keys
+	{code: "F1", hid: 0x07003A, vk: 0x70, evdev: 59, mac: 0x7A},
+	{code: "F2", hid: 0x07003B, vk: 0x71, evdev: 60, mac: 0x78},
+	{code: "F3", hid: 0x07003C, vk: 0x72, evdev: 61, mac: 0x63},
+	{code: "F4", hid: 0x07003D, vk: 0x73, evdev: 62, mac: 0x76},
+	{code: "F5", hid: 0x07003E, vk: 0x74, evdev: 63, mac: 0x60},
+	{code: "F6", hid: 0x07003F, vk: 0x75, evdev: 64, mac: 0x61},
+	{code: "F7", hid: 0x070040, vk: 0x76, evdev: 65, mac: 0x62},
+	{code: "F8", hid: 0x070041, vk: 0x77, evdev: 66, mac: 0x64},
+	{code: "F9", hid: 0x070042, vk: 0x78, evdev: 67, mac: 0x65},
+	{code: "F10", hid: 0x070043, vk: 0x79, evdev: 68, mac: 0x6D},
+	{code: "F11", hid: 0x070044, vk: 0x7A, evdev: 87, mac: 0x67},
+	{code: "F12", hid: 0x070045, vk: 0x7B, evdev: 88, mac: 0x6F},
+	{code: "F13", hid: 0x070068, vk: 0x7C, evdev: 183, mac: 0x69},
+	{code: "F14", hid: 0x070069, vk: 0x7D, evdev: 184, mac: 0x6B},
+	{code: "F15", hid: 0x07006A, vk: 0x7E, evdev: 185, mac: 0x71},
+	{code: "F16", hid: 0x07006B, vk: 0x7F, evdev: 186, mac: 0x6A},
+	{code: "F17", hid: 0x07006C, vk: 0x80, evdev: 187, mac: 0x40},
+	{code: "F18", hid: 0x07006D, vk: 0x81, evdev: 188, mac: 0x4F},
+	{code: "F19", hid: 0x07006E, vk: 0x82, evdev: 189, mac: 0x50},
+	{code: "F20", hid: 0x07006F, vk: 0x83, evdev: 190, mac: 0x5A},
+	{code: "F21", hid: 0x070070, vk: 0x84, evdev: 191, mac: keyUnmapped},
+	{code: "F22", hid: 0x070071, vk: 0x85, evdev: 192, mac: keyUnmapped},
+	{code: "F23", hid: 0x070072, vk: 0x86, evdev: 193, mac: keyUnmapped},
+	{code: "F24", hid: 0x070073, vk: 0x87, evdev: 194, mac: keyUnmapped},
+	// control pad and arrows
+	{code: "PrintScreen", hid: 0x070046, vk: 0x2C, evdev: 99, mac: keyUnmapped},
+	{code: "ScrollLock", hid: 0x070047, vk: 0x91, evdev: 70, mac: keyUnmapped},
+	{code: "Pause", hid: 0x070048, vk: 0x13, evdev: 119, mac: keyUnmapped},
+	{code: "Insert", hid: 0x070049, vk: 0x2D, evdev: 110, mac: 0x72},
+	{code: "Home", hid: 0x07004A, vk: 0x24, evdev: 102, mac: 0x73},
+	{code: "PageUp", hid: 0x07004B, vk: 0x21, evdev: 104, mac: 0x74},
+	{code: "Delete", hid: 0x07004C, vk: 0x2E, evdev: 111, mac: 0x75},
+	{code: "End", hid: 0x07004D, vk: 0x23, evdev: 107, mac: 0x77},
+	{code: "PageDown", hid: 0x07004E, vk: 0x22, evdev: 109, mac: 0x79},
+	{code: "ArrowRight", hid: 0x07004F, vk: 0x27, evdev: 106, mac: 0x7C},
+	{code: "ArrowLeft", hid: 0x070050, vk: 0x25, evdev: 105, mac: 0x7B},
+	{code: "ArrowDown", hid: 0x070051, vk: 0x28, evdev: 108, mac: 0x7D},
+	{code: "ArrowUp", hid: 0x070052, vk: 0x26, evdev: 103, mac: 0x7E},
+	// numpad
+	{code: "NumLock", hid: 0x070053, vk: 0x90, evdev: 69, mac: 0x47},
+	{code: "NumpadDivide", hid: 0x070054, vk: 0x6F, evdev: 98, mac: 0x4B},
+	{code: "NumpadMultiply", hid: 0x070055, vk: 0x6A, evdev: 55, mac: 0x43},
+	{code: "NumpadSubtract", hid: 0x070056, vk: 0x6D, evdev: 74, mac: 0x4E},
+	{code: "NumpadAdd", hid: 0x070057, vk: 0x6B, evdev: 78, mac: 0x45},
+	{code: "NumpadEnter", hid: 0x070058, vk: 0x0D, evdev: 96, mac: 0x4C},
+	{code: "Numpad1", hid: 0x070059, vk: 0x61, evdev: 79, mac: 0x53},
+	{code: "Numpad2", hid: 0x07005A, vk: 0x62, evdev: 80, mac: 0x54},
+	{code: "Numpad3", hid: 0x07005B, vk: 0x63, evdev: 81, mac: 0x55},
+	{code: "Numpad4", hid: 0x07005C, vk: 0x64, evdev: 75, mac: 0x56},
+	{code: "Numpad5", hid: 0x07005D, vk: 0x65, evdev: 76, mac: 0x57},
+	{code: "Numpad6", hid: 0x07005E, vk: 0x66, evdev: 77, mac: 0x58},
+	{code: "Numpad7", hid: 0x07005F, vk: 0x67, evdev: 71, mac: 0x59},
+	{code: "Numpad8", hid: 0x070060, vk: 0x68, evdev: 72, mac: 0x5B},
+	{code: "Numpad9", hid: 0x070061, vk: 0x69, evdev: 73, mac: 0x5C},
+	{code: "Numpad0", hid: 0x070062, vk: 0x60, evdev: 82, mac: 0x52},
+	{code: "NumpadDecimal", hid: 0x070063, vk: 0x6E, evdev: 83, mac: 0x41},
+	// modifiers
+	{code: "ControlLeft", hid: 0x0700E0, vk: 0xA2, evdev: 29, mac: 0x3B},
+	{code: "ShiftLeft", hid: 0x0700E1, vk: 0xA0, evdev: 42, mac: 0x38},
+	{code: "AltLeft", hid: 0x0700E2, vk: 0xA4, evdev: 56, mac: 0x3A},
+	{code: "MetaLeft", hid: 0x0700E3, vk: 0x5B, evdev: 125, mac: 0x37},
+	{code: "ControlRight", hid: 0x0700E4, vk: 0xA3, evdev: 97, mac: 0x3E},
+	{code: "ShiftRight", hid: 0x0700E5, vk: 0xA1, evdev: 54, mac: 0x3C},
+	{code: "AltRight", hid: 0x0700E6, vk: 0xA5, evdev: 100, mac: 0x3D},
+	{code: "MetaRight", hid: 0x0700E7, vk: 0x5C, evdev: 126, mac: 0x36},
+	// media keys, on the consumer page
+	{code: "MediaTrackNext", hid: 0x0C00B5, vk: 0xB0, evdev: 163, mac: keyUnmapped},
+	{code: "MediaTrackPrevious", hid: 0x0C00B6, vk: 0xB1, evdev: 165, mac: keyUnmapped},
+	{code: "MediaStop", hid: 0x0C00B7, vk: 0xB2, evdev: 166, mac: keyUnmapped},
+	{code: "MediaPlayPause", hid: 0x0C00CD, vk: 0xB3, evdev: 164, mac: keyUnmapped},
+	{code: "AudioVolumeMute", hid: 0x0C00E2, vk: 0xAD, evdev: 113, mac: 0x4A},
+	{code: "AudioVolumeDown", hid: 0x0C00EA, vk: 0xAE, evdev: 114, mac: 0x49},
+	{code: "AudioVolumeUp", hid: 0x0C00E9, vk: 0xAF, evdev: 115, mac: 0x48},
+}
+
+// legacyVKs are the codes of v1 clients that are not the virtual key code
+// of the key. The generic modifiers stand for the left one, 0x3A to 0x3F
+// are the punctuation codes of early clients, they are unassigned on
+// Windows.
+var legacyVKs = map[uint16]string{
+	0x10: "ShiftLeft",
+	0x11: "ControlLeft",
+	0x12: "AltLeft",
+	0x3A: "Semicolon",
+	0x3B: "Equal",
+	0x3C: "Comma",
+	0x3D: "Minus",
+	0x3E: "Period",
+	0x3F: "Slash",
+}
+
+var (
+	keysByCode = indexKeys(func(k keyInfo) (string, bool) { return k.code, true })
+	keysByHID  = indexKeys(func(k keyInfo) (uint32, bool) { return k.hid, true })
+	keysByVK   = indexKeys(func(k keyInfo) (uint16, bool) { return k.vk, k.vk != keyUnmapped })
+)
+
+// indexKeys maps the keys by a code, the first key of a code wins
+func indexKeys[T comparable](key func(k keyInfo) (T, bool)) map[T]keyInfo {
+	index := make(map[T]keyInfo, len(keyTable))
+	for _, k := range keyTable {
+		v, ok := key(k)
+		if !ok {
+			continue
+		}
+		if _, exists := index[v]; !exists {
+			index[v] = k
+		}
+	}
+	return index
+}
+
+// lookupKey returns the key of a KeyboardEvent.code
+func lookupKey(code string) (keyInfo, bool) {
+	k, ok := keysByCode[code]
+	return k, ok
+}
+
+// keyCodeFromVK returns the KeyboardEvent.code of a Windows virtual key
+// code, as sent by v1 clients
+func keyCodeFromVK(vk uint16) (string, bool) {
+	if k, ok := keysByVK[vk]; ok {
+		return k.code, true
+	}
+	code, ok := legacyVKs[vk]
+	return code, ok
+}
+
+// keyCodeFromHID returns the KeyboardEvent.code of a HID usage
+func keyCodeFromHID(hid uint32) (string, bool) {
+	k, ok := keysByHID[hid]
+	return k.code, ok
+}
+
+// windowsVK returns the Windows virtual key code of a key
+func windowsVK(code string) (uint16, bool) {
+	k, ok := lookupKey(code)
+	return k.vk, ok && k.vk != keyUnmapped
+}
+
+// evdevKeyCode returns the Linux input event code of a key
+func evdevKeyCode(code string) (uint16, bool) {
+	k, ok := lookupKey(code)
+	return k.evdev, ok && k.evdev != keyUnmapped
+}
+
+// macKeyCode returns the macOS virtual key code of a key, for the darwin
+// backend
+func macKeyCode(code string) (uint16, bool) {
+	k, ok := lookupKey(code)
+	return k.mac, ok && k.mac != keyUnmapped
+}
@@ -0,0 +1,151 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyTable_Unique(t *testing.T) {
+	codes := map[string]bool{}
+	hids := map[uint32]string{}
+	evdevs := map[uint16]string{}
+	macs := map[uint16]string{}
+
+	for _, k := range keyTable {
+		require.False(t, codes[k.code], "duplicate key %s", k.code)
+		codes[k.code] = true
+
+		page := k.hid >> 16
+		require.True(t, page == hidPageKeyboard || page == hidPageConsumer, "%s has HID page %#x", k.code, page)
+		require.Empty(t, hids[k.hid], "%s and %s share HID usage %#x", k.code, hids[k.hid], k.hid)
+		hids[k.hid] = k.code
+
+		require.NotEqual(t, keyUnmapped, k.evdev, "%s has no evdev code", k.code)
+		require.LessOrEqual(t, k.evdev, uint16(evdevMaxKeyboard), "%s is not a keyboard key", k.code)
+		require.Empty(t, evdevs[k.evdev], "%s and %s share evdev code %d", k.code, evdevs[k.evdev], k.evdev)
+		evdevs[k.evdev] = k.code
+
+		if k.mac != keyUnmapped {
+			require.Empty(t, macs[k.mac], "%s and %s share macOS code %#x", k.code, macs[k.mac], k.mac)
+			macs[k.mac] = k.code
+		}
+	}
+}
+
+func TestKeyTable_RoundTrip(t *testing.T) {
+	for _, k := range keyTable {
+		t.Run(k.code, func(t *testing.T) {
+			code, ok := keyCodeFromHID(k.hid)
+			require.True(t, ok)
+			require.Equal(t, k.code, code)
+
+			evdev, ok := evdevKeyCode(k.code)
+			require.True(t, ok)
+			require.Equal(t, k.evdev, evdev)
+
+			// a key that shares its virtual key code translates to the
+			// first key of the code, which has the same code
+			vk, ok := windowsVK(k.code)
+			require.True(t, ok)
+			code, ok = keyCodeFromVK(vk)
+			require.True(t, ok)
+			back, _ := windowsVK(code)
+			require.Equal(t, vk, back)
+
+			frame, err := EncodeInputCommand(InputCommand{Type: "keyboard", Action: "press", Key: k.code})
+			require.NoError(t, err)
+			cmd, ok := DecodeInputCommand(frame)
+			require.True(t, ok)
+			require.Equal(t, k.code, cmd.Key)
+		})
+	}
+}
+
+func TestKeyCodeFromVK(t *testing.T) {
+	tests := []struct {
+		vk   uint16
+		want string
+	}{
+		{vk: 0x41, want: "KeyA"},
+		{vk: 0x5A, want: "KeyZ"},
+		{vk: 0x30, want: "Digit0"},
+		{vk: 0x0D, want: "Enter"},
+		{vk: 0x21, want: "PageUp"},
+		{vk: 0x22, want: "PageDown"},
+		{vk: 0x2D, want: "Insert"},
+		{vk: 0x2E, want: "Delete"},
+		{vk: 0x60, want: "Numpad0"},
+		{vk: 0x61, want: "Numpad1"},
+		{vk: 0x69, want: "Numpad9"},
+		{vk: 0x6D, want: "NumpadSubtract"},
+		{vk: 0x6B, want: "NumpadAdd"},
+		{vk: 0x70, want: "F1"},
+		{vk: 0x7B, want: "F12"},
+		{vk: 0x87, want: "F24"},
+		{vk: 0x5B, want: "MetaLeft"},
+		{vk: 0x5C, want: "MetaRight"},
+		{vk: 0xB3, want: "MediaPlayPause"},
+		{vk: 0xBD, want: "Minus"},
+		// codes of v1 clients
+		{vk: 0x10, want: "ShiftLeft"},
+		{vk: 0x11, want: "ControlLeft"},
+		{vk: 0x12, want: "AltLeft"},
+		{vk: 0x3A, want: "Semicolon"},
+		{vk: 0x3D, want: "Minus"},
+	}
+
+	for _, tt := range tests {
+		code, ok := keyCodeFromVK(tt.vk)
+		require.True(t, ok, "%#x", tt.vk)
+		require.Equal(t, tt.want, code, "%#x", tt.vk)
+	}
+
+	_, ok := keyCodeFromVK(0xFF)
+	require.False(t, ok)
+}
+
+func TestPlatformKeyCodes(t *testing.T) {
+	tests := []struct {
+		code  string
+		vk    uint16
+		evdev uint16
+		mac   uint16
+	}{
+		{code: "KeyA", vk: 0x41, evdev: 30, mac: 0x00},
+		{code: "KeyQ", vk: 0x51, evdev: 16, mac: 0x0C},
+		{code: "Digit1", vk: 0x31, evdev: 2, mac: 0x12},
+		{code: "Enter", vk: 0x0D, evdev: 28, mac: 0x24},
+		{code: "NumpadEnter", vk: 0x0D, evdev: 96, mac: 0x4C},
+		{code: "Backquote", vk: 0xC0, evdev: 41, mac: 0x32},
+		{code: "F1", vk: 0x70, evdev: 59, mac: 0x7A},
+		{code: "F11", vk: 0x7A, evdev: 87, mac: 0x67},
+		{code: "ArrowLeft", vk: 0x25, evdev: 105, mac: 0x7B},
+		{code: "MetaLeft", vk: 0x5B, evdev: 125, mac: 0x37},
+		{code: "AltRight", vk: 0xA5, evdev: 100, mac: 0x3D},
+		{code: "AudioVolumeUp", vk: 0xAF, evdev: 115, mac: 0x48},
+	}
+
+	for _, tt := range tests {
+		vk, ok := windowsVK(tt.code)
+		require.True(t, ok)
+		require.Equal(t, tt.vk, vk, tt.code)
+
+		evdev, ok := evdevKeyCode(tt.code)
+		require.True(t, ok)
+		require.Equal(t, tt.evdev, evdev, tt.code)
+
+		mac, ok := macKeyCode(tt.code)
+		require.True(t, ok)
+		require.Equal(t, tt.mac, mac, tt.code)
+	}
+
+	// keys macOS has no virtual key code for
+	_, ok := macKeyCode("MediaPlayPause")
+	require.False(t, ok)
+	_, ok = macKeyCode("PrintScreen")
+	require.False(t, ok)
+
+	_, ok = evdevKeyCode("a")
+	require.False(t, ok)
+}
@@ -0,0 +1,141 @@
+package input
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// PointerMode is how the pointer of a session is moved
+type PointerMode string
+
+const (
+	// PointerAbsolute moves the pointer to the positions of the client
+	PointerAbsolute PointerMode = "absolute"
+	// PointerRelative moves the pointer by the deltas of the client, used
+	// with pointer lock in games. Absolute moves are ignored, their
+	// positions are stale while the browser locks the pointer.
+	PointerRelative PointerMode = "relative"
+)
+
+const (
+	maxSensitivity  = 10
+	maxAcceleration = 4
+	// accelerationSpeed is the delta, in pixels per event, at which the
+	// acceleration doubles the gain when it is 1
+	accelerationSpeed = 10
+)
+
+// PointerSettings holds the pointer mode of a session and the scaling of
+// relative moves
+type PointerSettings struct {
+	Mode PointerMode `json:"mode"`
+	// Sensitivity multiplies relative deltas, 1 when zero
+	Sensitivity float64 `json:"sensitivity,omitempty"`
+	// Acceleration increases the gain of fast relative moves, 0 keeps the
+	// gain constant
+	Acceleration float64 `json:"acceleration,omitempty"`
+}
+
+// DefaultPointerSettings returns the settings a session starts with
+func DefaultPointerSettings() PointerSettings {
+	return PointerSettings{Mode: PointerAbsolute, Sensitivity: 1}
+}
+
+// withDefaults fills the zero values
+func (s PointerSettings) withDefaults() PointerSettings {
+	if s.Mode == "" {
+		s.Mode = PointerAbsolute
+	}
+	if s.Sensitivity == 0 {
+		s.Sensitivity = 1
+	}
+	return s
+}
+
+// Validate checks the mode and the ranges of the scaling
+func (s PointerSettings) Validate() error {
+	s = s.withDefaults()
+	if s.Mode != PointerAbsolute && s.Mode != PointerRelative {
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidPointerSettings, s.Mode)
+	}
+	if s.Sensitivity <= 0 || s.Sensitivity > maxSensitivity || math.IsNaN(s.Sensitivity) {
+		return fmt.Errorf("%w: sensitivity must be above 0 and at most %d", ErrInvalidPointerSettings, maxSensitivity)
+	}
+	if s.Acceleration < 0 || s.Acceleration > maxAcceleration || math.IsNaN(s.Acceleration) {
+		return fmt.Errorf("%w: acceleration must be between 0 and %d", ErrInvalidPointerSettings, maxAcceleration)
+	}
+	return nil
+}
+
+// Pointer applies the pointer settings of a session to the moves of the
+// client
+type Pointer struct {
+	mu       sync.Mutex
+	settings PointerSettings
+	// remainders of the scaled deltas, so slow moves with a sensitivity
+	// below 1 are not lost
+	remX, remY float64
+}
+
+// NewPointer creates a pointer with the default settings
+func NewPointer() *Pointer {
+	return &Pointer{settings: DefaultPointerSettings()}
+}
+
+// Settings returns the current settings
+func (p *Pointer) Settings() PointerSettings {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.settings
+}
+
+// SetSettings validates and applies the settings, zero values are filled
+// with the defaults
+func (p *Pointer) SetSettings(settings PointerSettings) (PointerSettings, error) {
+	if err := settings.Validate(); err != nil {
+		return PointerSettings{}, err
+	}
+	settings = settings.withDefaults()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.settings = settings
+	p.remX, p.remY = 0, 0
+	return settings, nil
+}
+
+// Apply returns the command to inject, relative moves are scaled. ok is
+// false when the command does not apply in the current mode.
+func (p *Pointer) Apply(cmd InputCommand) (InputCommand, bool) {
+	if cmd.Type != "mouse" {
+		return cmd, true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch cmd.Action {
+	case "move":
+		return cmd, p.settings.Mode != PointerRelative
+	case "move_relative":
+		cmd.X, cmd.Y = p.scale(cmd.X, cmd.Y)
+		return cmd, cmd.X != 0 || cmd.Y != 0
+	}
+	return cmd, true
+}
+
+// scale applies the sensitivity and the acceleration to a delta, the
+// caller holds p.mu
+func (p *Pointer) scale(dx, dy int) (int, int) {
+	gain := p.settings.Sensitivity
+	if p.settings.Acceleration > 0 {
+		gain *= 1 + p.settings.Acceleration*math.Hypot(float64(dx), float64(dy))/accelerationSpeed
+	}
+
+	x := float64(dx)*gain + p.remX
+	y := float64(dy)*gain + p.remY
+	outX, outY := math.Trunc(x), math.Trunc(y)
+	p.remX, p.remY = x-outX, y-outY
+	return int(outX), int(outY)
+}
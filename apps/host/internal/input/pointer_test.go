@@ -0,0 +1,101 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPointer_Apply(t *testing.T) {
+	p := NewPointer()
+	move := InputCommand{Type: "mouse", Action: "move", X: 100, Y: 200}
+	delta := InputCommand{Type: "mouse", Action: "move_relative", X: 5, Y: -3}
+	key := InputCommand{Type: "keyboard", Action: "press", Key: "w"}
+
+	cmd, ok := p.Apply(move)
+	require.True(t, ok)
+	require.Equal(t, move, cmd)
+
+	// relative moves are applied in both modes
+	cmd, ok = p.Apply(delta)
+	require.True(t, ok)
+	require.Equal(t, delta, cmd)
+
+	_, err := p.SetSettings(PointerSettings{Mode: PointerRelative})
+	require.NoError(t, err)
+
+	_, ok = p.Apply(move)
+	require.False(t, ok)
+
+	cmd, ok = p.Apply(delta)
+	require.True(t, ok)
+	require.Equal(t, delta, cmd)
+
+	cmd, ok = p.Apply(key)
+	require.True(t, ok)
+	require.Equal(t, key, cmd)
+}
+
+func TestPointer_Sensitivity(t *testing.T) {
+	p := NewPointer()
+	settings, err := p.SetSettings(PointerSettings{Mode: PointerRelative, Sensitivity: 0.5})
+	require.NoError(t, err)
+	require.Equal(t, PointerSettings{Mode: PointerRelative, Sensitivity: 0.5}, settings)
+
+	// the remainders add up, so slow moves are not lost
+	var totalX, totalY int
+	for range 4 {
+		cmd, ok := p.Apply(InputCommand{Type: "mouse", Action: "move_relative", X: 1, Y: -1})
+		if ok {
+			totalX += cmd.X
+			totalY += cmd.Y
+		}
+	}
+	require.Equal(t, 2, totalX)
+	require.Equal(t, -2, totalY)
+
+	_, err = p.SetSettings(PointerSettings{Mode: PointerRelative, Sensitivity: 2})
+	require.NoError(t, err)
+	cmd, ok := p.Apply(InputCommand{Type: "mouse", Action: "move_relative", X: 3, Y: -4})
+	require.True(t, ok)
+	require.Equal(t, 6, cmd.X)
+	require.Equal(t, -8, cmd.Y)
+}
+
+func TestPointer_Acceleration(t *testing.T) {
+	p := NewPointer()
+	_, err := p.SetSettings(PointerSettings{Mode: PointerRelative, Acceleration: 1})
+	require.NoError(t, err)
+
+	// slow moves are barely scaled, fast ones are scaled more
+	slow, ok := p.Apply(InputCommand{Type: "mouse", Action: "move_relative", X: 1})
+	require.True(t, ok)
+	require.Equal(t, 1, slow.X)
+
+	fast, ok := p.Apply(InputCommand{Type: "mouse", Action: "move_relative", X: 30, Y: 40})
+	require.True(t, ok)
+	require.Equal(t, 30*6, fast.X)
+	require.Equal(t, 40*6, fast.Y)
+}
+
+func TestPointerSettings_Validate(t *testing.T) {
+	require.NoError(t, PointerSettings{}.Validate())
+	require.NoError(t, DefaultPointerSettings().Validate())
+	require.NoError(t, PointerSettings{Mode: PointerRelative, Sensitivity: 10, Acceleration: 4}.Validate())
+
+	invalid := []PointerSettings{
+		{Mode: "joystick"},
+		{Mode: PointerRelative, Sensitivity: -1},
+		{Mode: PointerRelative, Sensitivity: 11},
+		{Mode: PointerRelative, Acceleration: -0.5},
+		{Mode: PointerRelative, Acceleration: 5},
+	}
+	for _, settings := range invalid {
+		require.ErrorIs(t, settings.Validate(), ErrInvalidPointerSettings, "%+v", settings)
+	}
+
+	p := NewPointer()
+	_, err := p.SetSettings(PointerSettings{Mode: "joystick"})
+	require.Error(t, err)
+	require.Equal(t, DefaultPointerSettings(), p.Settings())
+}
@@ -0,0 +1,302 @@
+package input
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policy restricts the input of a session, it is applied to the decoded
+// commands before they are injected
+type Policy struct {
+	// BlockedCombos are key combinations that are never pressed, like
+	// "Alt+F4". The last key is a KeyboardEvent.code, the others are
+	// modifiers. Control, Shift, Alt and Meta match the left and the right
+	// key.
+	BlockedCombos []string `json:"blocked_combos,omitempty"`
+	// ConfinePointer keeps absolute pointer positions in ConfineArea.
+	// Without one they already are in the captured window or monitor.
+	// Relative moves are dropped, the host cursor position they end at is
+	// unknown and could leave the program.
+	ConfinePointer bool `json:"confine_pointer,omitempty"`
+	// ConfineArea is a part of the captured area, in fractions of it
+	ConfineArea *Area `json:"confine_area,omitempty"`
+	// ReadOnly drops all input, for spectators
+	ReadOnly bool `json:"read_only,omitempty"`
+}
+
+// Area is a rectangle in fractions of the captured area
+type Area struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// DefaultPolicy returns the policy of programs without their own, it
+// blocks the keys that leave the program or close it
+func DefaultPolicy() Policy {
+	return Policy{
+		BlockedCombos: []string{
+			"Meta",
+			"Alt+Tab",
+			"Alt+Escape",
+			"Alt+F4",
+			"Control+Escape",
+			"Control+Alt+Delete",
+			"Control+Shift+Escape",
+		},
+	}
+}
+
+// Validate checks the key combinations and the confine area
+func (p Policy) Validate() error {
+	for _, combo := range p.BlockedCombos {
+		if _, err := parseKeyCombo(combo); err != nil {
+			return err
+		}
+	}
+	if a := p.ConfineArea; a != nil {
+		values := []float64{a.X, a.Y, a.Width, a.Height}
+		if slices.ContainsFunc(values, math.IsNaN) ||
+			a.X < 0 || a.Y < 0 || a.Width <= 0 || a.Height <= 0 || a.X+a.Width > 1 || a.Y+a.Height > 1 {
+			return fmt.Errorf("%w: the confine area must be within the captured area", ErrInvalidPolicy)
+		}
+	}
+	return nil
+}
+
+// genericModifiers are the modifier names of key combinations that match
+// the left and the right key
+var genericModifiers = map[string][2]string{
+	"Control": {"ControlLeft", "ControlRight"},
+	"Shift":   {"ShiftLeft", "ShiftRight"},
+	"Alt":     {"AltLeft", "AltRight"},
+	"Meta":    {"MetaLeft", "MetaRight"},
+}
+
+// modifierFlags are the modifier states of v2 frames for generic modifiers
+var modifierFlags = map[string]Modifiers{
+	"Control": ModCtrl,
+	"Shift":   ModShift,
+	"Alt":     ModAlt,
+	"Meta":    ModMeta,
+}
+
+// keyCombo is a parsed blocked key combination
+type keyCombo struct {
+	name      string
+	modifiers []string
+	key       string
+}
+
+// parseKeyCombo parses a combination like "Control+Alt+Delete"
+func parseKeyCombo(combo string) (keyCombo, error) {
+	parts := strings.Split(combo, "+")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return keyCombo{}, fmt.Errorf("%w: empty key in %q", ErrInvalidPolicy, combo)
+		}
+		if _, ok := genericModifiers[part]; !ok {
+			if _, ok := lookupKey(part); !ok {
+				return keyCombo{}, fmt.Errorf("%w: unknown key %q in %q", ErrInvalidPolicy, part, combo)
+			}
+		}
+		parts[i] = part
+	}
+
+	last := len(parts) - 1
+	for _, modifier := range parts[:last] {
+		if !isModifier(modifier) {
+			return keyCombo{}, fmt.Errorf("%w: %q in %q is not a modifier", ErrInvalidPolicy, modifier, combo)
+		}
+	}
+	return keyCombo{name: strings.Join(parts, "+"), modifiers: parts[:last], key: parts[last]}, nil
+}
+
+// isModifier reports whether a combination key is a modifier
+func isModifier(key string) bool {
+	if _, ok := genericModifiers[key]; ok {
+		return true
+	}
+	for _, keys := range genericModifiers {
+		if key == keys[0] || key == keys[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesKey reports whether a combination key is the pressed key
+func matchesKey(comboKey, key string) bool {
+	if keys, ok := genericModifiers[comboKey]; ok {
+		return key == keys[0] || key == keys[1]
+	}
+	return comboKey == key
+}
+
+// blockedLogInterval limits the log of dropped input, a spectator moving
+// the mouse would log every event
+const blockedLogInterval = 5 * time.Second
+
+// PolicyFilter applies the policy of a session to its input commands
+type PolicyFilter struct {
+	mu     sync.Mutex
+	policy Policy
+	combos []keyCombo
+	// held are the keys pressed through the filter
+	held map[string]bool
+
+	loggedAt   map[string]time.Time
+	suppressed map[string]int
+}
+
+// NewPolicyFilter creates a filter with the default policy
+func NewPolicyFilter() *PolicyFilter {
+	f := &PolicyFilter{}
+	if err := f.SetPolicy(DefaultPolicy()); err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// Policy returns the applied policy
+func (f *PolicyFilter) Policy() Policy {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.policy
+}
+
+// SetPolicy validates and applies a policy
+func (f *PolicyFilter) SetPolicy(policy Policy) error {
+	combos := make([]keyCombo, 0, len(policy.BlockedCombos))
+	for _, combo := range policy.BlockedCombos {
+		parsed, err := parseKeyCombo(combo)
+		if err != nil {
+			return err
+		}
+		combos = append(combos, parsed)
+	}
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.policy = policy
+	f.combos = combos
+	f.held = map[string]bool{}
+	f.loggedAt = map[string]time.Time{}
+	f.suppressed = map[string]int{}
+	return nil
+}
+
+// Filter returns the command to inject, ok is false when the policy
+// blocks it. Blocked attempts are logged.
+func (f *PolicyFilter) Filter(cmd InputCommand) (InputCommand, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.policy.ReadOnly {
+		f.logBlocked("read only", "dropped %s %s of a spectator", cmd.Type, cmd.Action)
+		return cmd, false
+	}
+
+	switch cmd.Type {
+	case "keyboard":
+		return f.filterKey(cmd)
+	case "mouse", "touch":
+		if f.policy.ConfinePointer {
+			return f.confine(cmd)
+		}
+	}
+	return cmd, true
+}
+
+// filterKey blocks the presses that complete a blocked combination,
+// releases always pass so no key stays pressed
+func (f *PolicyFilter) filterKey(cmd InputCommand) (InputCommand, bool) {
+	if cmd.Action == "release" {
+		delete(f.held, cmd.Key)
+		return cmd, true
+	}
+	if cmd.Action != "press" {
+		return cmd, true
+	}
+
+	for _, combo := range f.combos {
+		if matchesKey(combo.key, cmd.Key) && f.modifiersHeld(combo.modifiers, cmd.Modifiers) {
+			f.logBlocked(combo.name, "blocked %s, it matches %s", cmd.Key, combo.name)
+			return cmd, false
+		}
+	}
+	f.held[cmd.Key] = true
+	return cmd, true
+}
+
+// modifiersHeld reports whether all modifiers are pressed, the modifier
+// state of v2 frames counts as well
+func (f *PolicyFilter) modifiersHeld(modifiers []string, state Modifiers) bool {
+	for _, modifier := range modifiers {
+		if keys, ok := genericModifiers[modifier]; ok {
+			if !f.held[keys[0]] && !f.held[keys[1]] && state&modifierFlags[modifier] == 0 {
+				return false
+			}
+			continue
+		}
+		if !f.held[modifier] {
+			return false
+		}
+	}
+	return true
+}
+
+// confine blocks relative moves and clamps the position of absolute
+// pointer and touch commands to the confine area
+func (f *PolicyFilter) confine(cmd InputCommand) (InputCommand, bool) {
+	switch cmd.Action {
+	case "move_relative":
+		f.logBlocked("confine", "dropped a relative move of a confined pointer")
+		return cmd, false
+	case "move", "press", "release", "click", "start", "end", "cancel":
+	default:
+		return cmd, true
+	}
+
+	if f.policy.ConfineArea == nil {
+		return cmd, true
+	}
+	area := *f.policy.ConfineArea
+	minX, maxX := int(area.X*normalizedMax), int((area.X+area.Width)*normalizedMax)
+	minY, maxY := int(area.Y*normalizedMax), int((area.Y+area.Height)*normalizedMax)
+
+	x, y := min(max(cmd.X, minX), maxX), min(max(cmd.Y, minY), maxY)
+	if x != cmd.X || y != cmd.Y {
+		f.logBlocked("confine", "confined the pointer from %d,%d to %d,%d", cmd.X, cmd.Y, x, y)
+	}
+	cmd.X, cmd.Y = x, y
+	return cmd, true
+}
+
+// logBlocked logs a blocked attempt, repeated ones of the same reason are
+// counted and logged at most every blockedLogInterval. The caller holds
+// f.mu.
+func (f *PolicyFilter) logBlocked(reason, format string, args ...any) {
+	if time.Since(f.loggedAt[reason]) < blockedLogInterval {
+		f.suppressed[reason]++
+		return
+	}
+
+	message := fmt.Sprintf(format, args...)
+	if n := f.suppressed[reason]; n > 0 {
+		message = fmt.Sprintf("%s, %d more since the last report", message, n)
+	}
+	log.Printf("input policy: %s", message)
+	f.loggedAt[reason] = time.Now()
+	f.suppressed[reason] = 0
+}
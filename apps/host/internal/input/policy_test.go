@@ -0,0 +1,103 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func key(action, code string) InputCommand {
+	return InputCommand{Type: "keyboard", Action: action, Key: code}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	require.NoError(t, DefaultPolicy().Validate())
+	require.NoError(t, Policy{BlockedCombos: []string{"ControlLeft+ShiftRight+KeyQ", " Alt + F4 "}}.Validate())
+	require.NoError(t, Policy{ConfineArea: &Area{X: 0.5, Y: 0, Width: 0.5, Height: 1}}.Validate())
+
+	invalid := []Policy{
+		{BlockedCombos: []string{"Alt+"}},
+		{BlockedCombos: []string{"Alt+Nope"}},
+		{BlockedCombos: []string{"KeyA+KeyB"}},
+		{ConfineArea: &Area{X: 0.5, Width: 0.6, Height: 1}},
+		{ConfineArea: &Area{Width: 0, Height: 1}},
+		{ConfineArea: &Area{X: -0.1, Width: 0.5, Height: 1}},
+	}
+	for _, policy := range invalid {
+		require.ErrorIs(t, policy.Validate(), ErrInvalidPolicy, "%+v", policy)
+	}
+}
+
+func TestPolicyFilter_BlockedCombos(t *testing.T) {
+	f := NewPolicyFilter()
+
+	_, ok := f.Filter(key("press", "Tab"))
+	require.True(t, ok)
+	_, ok = f.Filter(key("release", "Tab"))
+	require.True(t, ok)
+
+	// Alt+Tab is blocked with either Alt key
+	_, ok = f.Filter(key("press", "AltRight"))
+	require.True(t, ok)
+	_, ok = f.Filter(key("press", "Tab"))
+	require.False(t, ok)
+	_, ok = f.Filter(key("release", "Tab"))
+	require.True(t, ok)
+	_, ok = f.Filter(key("release", "AltRight"))
+	require.True(t, ok)
+
+	// the modifiers of v2 frames count as held
+	cmd := key("press", "F4")
+	cmd.Modifiers = ModAlt
+	_, ok = f.Filter(cmd)
+	require.False(t, ok)
+
+	_, ok = f.Filter(key("press", "MetaLeft"))
+	require.False(t, ok)
+
+	_, ok = f.Filter(key("press", "F4"))
+	require.True(t, ok)
+}
+
+func TestPolicyFilter_ReadOnly(t *testing.T) {
+	f := NewPolicyFilter()
+	require.NoError(t, f.SetPolicy(Policy{ReadOnly: true}))
+
+	for _, cmd := range []InputCommand{
+		key("press", "KeyA"),
+		{Type: "mouse", Action: "move", X: 10, Y: 10},
+		{Type: "mouse", Action: "scroll", Y: 1},
+	} {
+		_, ok := f.Filter(cmd)
+		require.False(t, ok)
+	}
+}
+
+func TestPolicyFilter_ConfinePointer(t *testing.T) {
+	f := NewPolicyFilter()
+	require.NoError(t, f.SetPolicy(Policy{
+		ConfinePointer: true,
+		ConfineArea:    &Area{X: 0.5, Y: 0, Width: 0.5, Height: 0.5},
+	}))
+
+	cmd, ok := f.Filter(InputCommand{Type: "mouse", Action: "move", X: 0, Y: normalizedMax})
+	require.True(t, ok)
+	require.Equal(t, normalizedMax/2, cmd.X)
+	require.Equal(t, normalizedMax/2, cmd.Y)
+
+	cmd, ok = f.Filter(InputCommand{Type: "mouse", Action: "press", Button: "left", X: 50000, Y: 100})
+	require.True(t, ok)
+	require.Equal(t, 50000, cmd.X)
+	require.Equal(t, 100, cmd.Y)
+
+	cmd, ok = f.Filter(InputCommand{Type: "touch", Action: "start", PointerID: 2, X: 10, Y: 10})
+	require.True(t, ok)
+	require.Equal(t, InputCommand{Type: "touch", Action: "start", PointerID: 2, X: normalizedMax / 2, Y: 10}, cmd)
+
+	// relative moves could leave the confine area
+	_, ok = f.Filter(InputCommand{Type: "mouse", Action: "move_relative", X: -500, Y: 500})
+	require.False(t, ok)
+
+	require.ErrorIs(t, f.SetPolicy(Policy{BlockedCombos: []string{"Nope"}}), ErrInvalidPolicy)
+	require.True(t, f.Policy().ConfinePointer)
+}
@@ -0,0 +1,119 @@
+package input
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Versions of the binary input protocol
+const (
+	ProtocolV1 = 1
+	ProtocolV2 = 2
+)
+
+// supportedProtocols are the versions the host decodes, oldest first
+var supportedProtocols = []int{ProtocolV1, ProtocolV2}
+
+// ProtocolMessageType is the type of the text messages that negotiate the
+// input protocol
+const ProtocolMessageType = "input_protocol"
+
+// ProtocolMessage negotiates the input protocol on the control data channel.
+// The host sends the versions it supports when the channel opens, the
+// client answers with the version it wants to send and the host confirms
+// the version it will decode.
+//
+// The input channel is not ordered with the control channel, so the host
+// decodes every supported version whatever was negotiated. Clients that
+// never answer send v1.
+type ProtocolMessage struct {
+	Type     string `json:"type"`
+	Versions []int  `json:"versions,omitempty"`
+	Version  int    `json:"version,omitempty"`
+}
+
+// ProtocolHello returns the message the host sends when the control data
+// channel opens
+func ProtocolHello() []byte {
+	message, _ := json.Marshal(ProtocolMessage{Type: ProtocolMessageType, Versions: supportedProtocols})
+	return message
+}
+
+// negotiateVersion returns the newest supported version that is not newer
+// than the requested one
+func negotiateVersion(requested int) int {
+	version := ProtocolV1
+	for _, supported := range supportedProtocols {
+		if supported <= requested {
+			version = supported
+		}
+	}
+	return version
+}
+
+// Decoder decodes the input frames of one data channel. It keeps the
+// negotiated protocol version and drops pointer moves that arrive after a
+// newer one, the channel is unordered. Frames of v1 have no sequence and
+// are decoded as they come.
+type Decoder struct {
+	mu       sync.Mutex
+	version  int
+	lastMove uint32
+	moved    bool
+}
+
+// NewDecoder creates a decoder for a new data channel, it expects v1
+// until the client negotiates another version
+func NewDecoder() *Decoder {
+	return &Decoder{version: ProtocolV1}
+}
+
+// Version returns the negotiated protocol version
+func (d *Decoder) Version() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.version
+}
+
+// Negotiate handles a text message of the client, ok is false when it is
+// not a protocol message. The reply confirms the negotiated version.
+func (d *Decoder) Negotiate(message []byte) (reply []byte, ok bool) {
+	var request ProtocolMessage
+	if err := json.Unmarshal(message, &request); err != nil || request.Type != ProtocolMessageType {
+		return nil, false
+	}
+
+	version := negotiateVersion(request.Version)
+
+	d.mu.Lock()
+	d.version = version
+	d.mu.Unlock()
+
+	reply, _ = json.Marshal(ProtocolMessage{Type: ProtocolMessageType, Version: version})
+	return reply, true
+}
+
+// Decode decodes a frame of any supported version, ok is false when it is
+// malformed or a stale pointer move
+func (d *Decoder) Decode(b []byte) (InputCommand, bool) {
+	if !isFrameV2(b) {
+		return decodeFrameV1(b)
+	}
+
+	cmd, ok := decodeFrameV2(b)
+	if !ok {
+		return InputCommand{}, false
+	}
+	if cmd.Type == "mouse" && cmd.Action == "move" {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		// the difference is negative when the sequence is older, also
+		// after it wrapped around
+		if d.moved && int32(cmd.Sequence-d.lastMove) <= 0 {
+			return InputCommand{}, false
+		}
+		d.lastMove = cmd.Sequence
+		d.moved = true
+	}
+	return cmd, true
+}
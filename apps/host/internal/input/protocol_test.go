@@ -0,0 +1,210 @@
+package input
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeInputCommand_RoundTrip(t *testing.T) {
+	commands := []InputCommand{
+		{Type: "keyboard", Action: "press", Key: "KeyA", Sequence: 1, Timestamp: 1500 * time.Microsecond},
+		{Type: "keyboard", Action: "release", Key: "KeyZ", Modifiers: ModShift | ModCtrl, Sequence: 2},
+		{Type: "keyboard", Action: "press", Key: "Space", Modifiers: ModMeta | ModAlt | ModCapsLock},
+		{Type: "mouse", Action: "move", X: 0, Y: 65535, Sequence: 0xffffffff},
+		{Type: "mouse", Action: "press", Button: "left", X: 10, Y: 20},
+		{Type: "mouse", Action: "release", Button: "forward", X: 65535, Y: 0},
+		{Type: "mouse", Action: "press", Button: "back"},
+		{Type: "mouse", Action: "scroll", X: -32768, Y: 32767},
+		{Type: "mouse", Action: "scroll", Y: -3, Timestamp: 24 * time.Hour},
+		{Type: "mouse", Action: "move_relative", X: -32768, Y: 32767, Sequence: 3},
+		{Type: "touch", Action: "start", PointerID: 0, X: 100, Y: 200},
+		{Type: "touch", Action: "move", PointerID: 65535, X: 65535, Y: 0, Sequence: 4},
+		{Type: "touch", Action: "end", PointerID: 3},
+		{Type: "touch", Action: "cancel", PointerID: 1, X: 5, Y: 6},
+	}
+
+	for _, cmd := range commands {
+		frame, err := EncodeInputCommand(cmd)
+		require.NoError(t, err)
+		require.Equal(t, byte(0x82), frame[0])
+
+		decoded, ok := DecodeInputCommand(frame)
+		require.True(t, ok, "%+v", cmd)
+		require.Equal(t, cmd, decoded)
+	}
+}
+
+func TestEncodeInputCommand_Invalid(t *testing.T) {
+	commands := []InputCommand{
+		{Type: "keyboard", Action: "press", Key: "nope"},
+		{Type: "keyboard", Action: "press", Key: "a"},
+		{Type: "keyboard", Action: "click", Key: "KeyA"},
+		{Type: "mouse", Action: "move", X: -1},
+		{Type: "mouse", Action: "move", Y: 65536},
+		{Type: "mouse", Action: "press", Button: "none"},
+		{Type: "mouse", Action: "click", Button: "left"},
+		{Type: "mouse", Action: "scroll", Y: 40000},
+		{Type: "mouse", Action: "move_relative", X: -40000},
+		{Type: "mouse", Action: "move", Timestamp: -time.Second},
+		{Type: "touch", Action: "press"},
+		{Type: "touch", Action: "move", PointerID: 65536},
+		{Type: "touch", Action: "move", X: -1},
+		{Type: "gamepad", Action: "press"},
+	}
+
+	for _, cmd := range commands {
+		_, err := EncodeInputCommand(cmd)
+		require.ErrorIs(t, err, ErrInvalidInputCommand, "%+v", cmd)
+	}
+}
+
+func TestDecodeInputCommand_V2(t *testing.T) {
+	move, err := EncodeInputCommand(InputCommand{Type: "mouse", Action: "move", X: 1, Y: 2, Sequence: 7})
+	require.NoError(t, err)
+
+	t.Run("longer payload", func(t *testing.T) {
+		frame := append([]byte{}, move...)
+		frame[16] += 2
+		frame = append(frame, 0xaa, 0xbb)
+
+		cmd, ok := DecodeInputCommand(frame)
+		require.True(t, ok)
+		require.Equal(t, InputCommand{Type: "mouse", Action: "move", X: 1, Y: 2, Sequence: 7}, cmd)
+	})
+
+	t.Run("unknown extensions", func(t *testing.T) {
+		frame := append(append([]byte{}, move...), 0x10, 0x03, 1, 2, 3, 0x11, 0x00)
+
+		cmd, ok := DecodeInputCommand(frame)
+		require.True(t, ok)
+		require.Equal(t, 1, cmd.X)
+	})
+
+	malformed := map[string]func(frame []byte) []byte{
+		"short header":        func(frame []byte) []byte { return frame[:headerSizeV2-1] },
+		"truncated payload":   func(frame []byte) []byte { return frame[:len(frame)-1] },
+		"short payload":       func(frame []byte) []byte { frame[16] = 2; return frame[:headerSizeV2+2] },
+		"unknown type":        func(frame []byte) []byte { frame[1] = 0x7f; return frame },
+		"truncated extension": func(frame []byte) []byte { return append(frame, 0x10, 0x04, 1) },
+		"extension header":    func(frame []byte) []byte { return append(frame, 0x10) },
+		"timestamp overflow": func(frame []byte) []byte {
+			for i := 8; i < 16; i++ {
+				frame[i] = 0xff
+			}
+			return frame
+		},
+	}
+	for name, corrupt := range malformed {
+		t.Run(name, func(t *testing.T) {
+			_, ok := DecodeInputCommand(corrupt(append([]byte{}, move...)))
+			require.False(t, ok)
+		})
+	}
+
+	t.Run("unknown key", func(t *testing.T) {
+		frame, err := EncodeInputCommand(InputCommand{Type: "keyboard", Action: "press", Key: "KeyA"})
+		require.NoError(t, err)
+		frame[headerSizeV2+2] = 0xff
+
+		_, ok := DecodeInputCommand(frame)
+		require.False(t, ok)
+	})
+
+	t.Run("unknown touch action", func(t *testing.T) {
+		frame, err := EncodeInputCommand(InputCommand{Type: "touch", Action: "start"})
+		require.NoError(t, err)
+		frame[headerSizeV2] = 4
+
+		_, ok := DecodeInputCommand(frame)
+		require.False(t, ok)
+	})
+
+	t.Run("click action", func(t *testing.T) {
+		frame, err := EncodeInputCommand(InputCommand{Type: "mouse", Action: "press", Button: "left"})
+		require.NoError(t, err)
+		frame[headerSizeV2] = actClick
+
+		_, ok := DecodeInputCommand(frame)
+		require.False(t, ok)
+	})
+}
+
+func TestDecoder_Negotiate(t *testing.T) {
+	var hello ProtocolMessage
+	require.NoError(t, json.Unmarshal(ProtocolHello(), &hello))
+	require.Equal(t, ProtocolMessage{Type: ProtocolMessageType, Versions: []int{ProtocolV1, ProtocolV2}}, hello)
+
+	tests := []struct {
+		name      string
+		requested int
+		want      int
+	}{
+		{name: "v2", requested: 2, want: ProtocolV2},
+		{name: "v1", requested: 1, want: ProtocolV1},
+		{name: "newer than supported", requested: 9, want: ProtocolV2},
+		{name: "no version", requested: 0, want: ProtocolV1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder()
+			message, err := json.Marshal(ProtocolMessage{Type: ProtocolMessageType, Version: tt.requested})
+			require.NoError(t, err)
+
+			reply, ok := d.Negotiate(message)
+			require.True(t, ok)
+			require.Equal(t, tt.want, d.Version())
+
+			var confirmed ProtocolMessage
+			require.NoError(t, json.Unmarshal(reply, &confirmed))
+			require.Equal(t, ProtocolMessage{Type: ProtocolMessageType, Version: tt.want}, confirmed)
+		})
+	}
+
+	d := NewDecoder()
+	_, ok := d.Negotiate([]byte(`{"type":"stats_overlay","enabled":true}`))
+	require.False(t, ok)
+	_, ok = d.Negotiate([]byte(`not json`))
+	require.False(t, ok)
+	require.Equal(t, ProtocolV1, d.Version())
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	encode := func(cmd InputCommand) []byte {
+		frame, err := EncodeInputCommand(cmd)
+		require.NoError(t, err)
+		return frame
+	}
+	d := NewDecoder()
+
+	// v1 is decoded before and after negotiating v2
+	v1 := []byte{inpTypeMouseMove, actMove, 0, 0, 0, 0, 1, 0, 1, 0}
+	_, ok := d.Decode(v1)
+	require.True(t, ok)
+
+	_, ok = d.Decode(encode(InputCommand{Type: "mouse", Action: "move", X: 5, Sequence: 10}))
+	require.True(t, ok)
+
+	// a move that was overtaken by a newer one is dropped
+	_, ok = d.Decode(encode(InputCommand{Type: "mouse", Action: "move", X: 4, Sequence: 9}))
+	require.False(t, ok)
+	_, ok = d.Decode(encode(InputCommand{Type: "mouse", Action: "move", X: 5, Sequence: 10}))
+	require.False(t, ok)
+
+	// buttons are never dropped
+	_, ok = d.Decode(encode(InputCommand{Type: "mouse", Action: "release", Button: "left", Sequence: 8}))
+	require.True(t, ok)
+
+	_, ok = d.Decode(v1)
+	require.True(t, ok)
+
+	// the sequence wraps around
+	d = NewDecoder()
+	_, ok = d.Decode(encode(InputCommand{Type: "mouse", Action: "move", Sequence: 0xfffffffe}))
+	require.True(t, ok)
+	_, ok = d.Decode(encode(InputCommand{Type: "mouse", Action: "move", Sequence: 1}))
+	require.True(t, ok)
+}
@@ -0,0 +1,321 @@
+package input
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// Protocol v2 frames start with a header, followed by the payload of the
+// frame type and optional extensions. All values are little endian.
+//
+//	offset  size  field
+//	0       1     version, with the high bit set: 0x82
+//	1       1     type
+//	2       1     modifiers, see Modifiers
+//	3       1     reserved, ignored
+//	4       4     sequence, incremented by one for every frame
+//	8       8     timestamp in microseconds on the clock of the client
+//	16      2     payload length
+//	18      n     payload
+//	18+n          extensions until the end of the frame
+//
+// The first byte of a v1 frame is its type, which never has the high bit
+// set, so both versions can be decoded on the same channel.
+//
+// Keys are sent as USB HID usages, the physical key, so the keyboard
+// layout of the host decides the typed character. Letters, digits and most
+// keys are on the keyboard page 0x07, media keys on the consumer page 0x0C.
+//
+// Payloads can grow at the end, bytes past the known fields are ignored.
+// Every extension is an id byte, a length byte and the data, extensions
+// that are not known are skipped.
+
+const (
+	protocolV2Marker = 0x80 | ProtocolV2
+
+	headerSizeV2 = 18
+
+	frameTypeKey           = 0x01
+	frameTypePointerMove   = 0x02
+	frameTypePointerButton = 0x03
+	frameTypeWheel         = 0x04
+	frameTypePointerDelta  = 0x05
+	frameTypeTouch         = 0x06
+
+	// payload sizes of the frame types
+	keyPayloadSize           = 4 // action, HID usage page, HID usage id
+	pointerMovePayloadSize   = 4 // x, y
+	pointerButtonPayloadSize = 6 // action, button, x, y
+	wheelPayloadSize         = 4 // signed dx, dy
+	pointerDeltaPayloadSize  = 4 // signed dx, dy
+	touchPayloadSize         = 8 // action, reserved, pointer id, x, y
+
+	// actions of touch payloads
+	touchStart  = 0
+	touchMove   = 1
+	touchEnd    = 2
+	touchCancel = 3
+
+	// maxTimestampV2 keeps the timestamp within time.Duration
+	maxTimestampV2 = uint64(math.MaxInt64 / time.Microsecond)
+)
+
+// isFrameV2 reports whether the frame starts with the v2 header
+func isFrameV2(b []byte) bool {
+	return len(b) > 0 && b[0] == protocolV2Marker
+}
+
+// decodeFrameV2 decodes a frame of protocol v2, ok is false when it is
+// malformed or of an unknown type
+func decodeFrameV2(b []byte) (InputCommand, bool) {
+	if len(b) < headerSizeV2 || !isFrameV2(b) {
+		return InputCommand{}, false
+	}
+
+	timestamp := binary.LittleEndian.Uint64(b[8:16])
+	if timestamp > maxTimestampV2 {
+		return InputCommand{}, false
+	}
+
+	payloadSize := int(binary.LittleEndian.Uint16(b[16:18]))
+	if len(b) < headerSizeV2+payloadSize {
+		return InputCommand{}, false
+	}
+	payload := b[headerSizeV2 : headerSizeV2+payloadSize]
+
+	cmd := InputCommand{
+		Modifiers: Modifiers(b[2]),
+		Sequence:  binary.LittleEndian.Uint32(b[4:8]),
+		Timestamp: time.Duration(timestamp) * time.Microsecond,
+	}
+	if !decodePayloadV2(b[1], payload, &cmd) {
+		return InputCommand{}, false
+	}
+	if !decodeExtensionsV2(b[headerSizeV2+payloadSize:]) {
+		return InputCommand{}, false
+	}
+	return cmd, true
+}
+
+// decodePayloadV2 decodes the payload of a frame type into the command
+func decodePayloadV2(frameType byte, payload []byte, cmd *InputCommand) bool {
+	switch frameType {
+	case frameTypeKey:
+		if len(payload) < keyPayloadSize {
+			return false
+		}
+		action, ok := pressAction(payload[0])
+		if !ok {
+			return false
+		}
+		hid := uint32(payload[1])<<16 | uint32(binary.LittleEndian.Uint16(payload[2:4]))
+		key, ok := keyCodeFromHID(hid)
+		if !ok {
+			return false
+		}
+		cmd.Type = "keyboard"
+		cmd.Action = action
+		cmd.Key = key
+	case frameTypePointerMove:
+		if len(payload) < pointerMovePayloadSize {
+			return false
+		}
+		cmd.Type = "mouse"
+		cmd.Action = "move"
+		cmd.X = int(binary.LittleEndian.Uint16(payload[0:2]))
+		cmd.Y = int(binary.LittleEndian.Uint16(payload[2:4]))
+	case frameTypePointerButton:
+		if len(payload) < pointerButtonPayloadSize {
+			return false
+		}
+		action, ok := pressAction(payload[0])
+		if !ok {
+			return false
+		}
+		button := buttonToString(payload[1])
+		if button == "none" {
+			return false
+		}
+		cmd.Type = "mouse"
+		cmd.Action = action
+		cmd.Button = button
+		cmd.X = int(binary.LittleEndian.Uint16(payload[2:4]))
+		cmd.Y = int(binary.LittleEndian.Uint16(payload[4:6]))
+	case frameTypeWheel:
+		if len(payload) < wheelPayloadSize {
+			return false
+		}
+		cmd.Type = "mouse"
+		cmd.Action = "scroll"
+		cmd.X = int(int16(binary.LittleEndian.Uint16(payload[0:2])))
+		cmd.Y = int(int16(binary.LittleEndian.Uint16(payload[2:4])))
+	case frameTypePointerDelta:
+		if len(payload) < pointerDeltaPayloadSize {
+			return false
+		}
+		cmd.Type = "mouse"
+		cmd.Action = "move_relative"
+		cmd.X = int(int16(binary.LittleEndian.Uint16(payload[0:2])))
+		cmd.Y = int(int16(binary.LittleEndian.Uint16(payload[2:4])))
+	case frameTypeTouch:
+		if len(payload) < touchPayloadSize || int(payload[0]) >= len(touchActions) {
+			return false
+		}
+		cmd.Type = "touch"
+		cmd.Action = touchActions[payload[0]]
+		cmd.PointerID = int(binary.LittleEndian.Uint16(payload[2:4]))
+		cmd.X = int(binary.LittleEndian.Uint16(payload[4:6]))
+		cmd.Y = int(binary.LittleEndian.Uint16(payload[6:8]))
+	default:
+		return false
+	}
+	return true
+}
+
+// touchActions are the actions of touch payloads by their code
+var touchActions = [...]string{
+	touchStart:  "start",
+	touchMove:   "move",
+	touchEnd:    "end",
+	touchCancel: "cancel",
+}
+
+// decodeExtensionsV2 walks the extensions of a frame, none are defined
+// yet so all of them are skipped. It fails when one is truncated.
+func decodeExtensionsV2(b []byte) bool {
+	for len(b) > 0 {
+		if len(b) < 2 || len(b) < 2+int(b[1]) {
+			return false
+		}
+		b = b[2+int(b[1]):]
+	}
+	return true
+}
+
+// pressAction returns the action of a key or button payload, v2 has no
+// clicks, the client sends the press and the release
+func pressAction(a byte) (string, bool) {
+	switch a {
+	case actPress:
+		return "press", true
+	case actRelease:
+		return "release", true
+	default:
+		return "", false
+	}
+}
+
+// EncodeInputCommand encodes a command as a frame of protocol v2, the
+// counterpart of DecodeInputCommand
+func EncodeInputCommand(cmd InputCommand) ([]byte, error) {
+	if cmd.Timestamp < 0 {
+		return nil, fmt.Errorf("%w: negative timestamp", ErrInvalidInputCommand)
+	}
+
+	var (
+		frameType byte
+		payload   []byte
+	)
+	switch {
+	case cmd.Type == "keyboard" && (cmd.Action == "press" || cmd.Action == "release"):
+		key, ok := lookupKey(cmd.Key)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidInputCommand, cmd.Key)
+		}
+		frameType = frameTypeKey
+		payload = make([]byte, keyPayloadSize)
+		payload[0] = pressActionCode(cmd.Action)
+		payload[1] = byte(key.hid >> 16)
+		binary.LittleEndian.PutUint16(payload[2:4], uint16(key.hid))
+	case cmd.Type == "mouse" && cmd.Action == "move":
+		if !inRange(cmd.X, 0, math.MaxUint16) || !inRange(cmd.Y, 0, math.MaxUint16) {
+			return nil, fmt.Errorf("%w: position %d,%d out of range", ErrInvalidInputCommand, cmd.X, cmd.Y)
+		}
+		frameType = frameTypePointerMove
+		payload = make([]byte, pointerMovePayloadSize)
+		binary.LittleEndian.PutUint16(payload[0:2], uint16(cmd.X))
+		binary.LittleEndian.PutUint16(payload[2:4], uint16(cmd.Y))
+	case cmd.Type == "mouse" && (cmd.Action == "press" || cmd.Action == "release"):
+		button, ok := buttonCode(cmd.Button)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown button %q", ErrInvalidInputCommand, cmd.Button)
+		}
+		if !inRange(cmd.X, 0, math.MaxUint16) || !inRange(cmd.Y, 0, math.MaxUint16) {
+			return nil, fmt.Errorf("%w: position %d,%d out of range", ErrInvalidInputCommand, cmd.X, cmd.Y)
+		}
+		frameType = frameTypePointerButton
+		payload = make([]byte, pointerButtonPayloadSize)
+		payload[0] = pressActionCode(cmd.Action)
+		payload[1] = button
+		binary.LittleEndian.PutUint16(payload[2:4], uint16(cmd.X))
+		binary.LittleEndian.PutUint16(payload[4:6], uint16(cmd.Y))
+	case cmd.Type == "mouse" && cmd.Action == "scroll":
+		if !inRange(cmd.X, math.MinInt16, math.MaxInt16) || !inRange(cmd.Y, math.MinInt16, math.MaxInt16) {
+			return nil, fmt.Errorf("%w: scroll delta %d,%d out of range", ErrInvalidInputCommand, cmd.X, cmd.Y)
+		}
+		frameType = frameTypeWheel
+		payload = make([]byte, wheelPayloadSize)
+		binary.LittleEndian.PutUint16(payload[0:2], uint16(int16(cmd.X)))
+		binary.LittleEndian.PutUint16(payload[2:4], uint16(int16(cmd.Y)))
+	case cmd.Type == "mouse" && cmd.Action == "move_relative":
+		if !inRange(cmd.X, math.MinInt16, math.MaxInt16) || !inRange(cmd.Y, math.MinInt16, math.MaxInt16) {
+			return nil, fmt.Errorf("%w: move delta %d,%d out of range", ErrInvalidInputCommand, cmd.X, cmd.Y)
+		}
+		frameType = frameTypePointerDelta
+		payload = make([]byte, pointerDeltaPayloadSize)
+		binary.LittleEndian.PutUint16(payload[0:2], uint16(int16(cmd.X)))
+		binary.LittleEndian.PutUint16(payload[2:4], uint16(int16(cmd.Y)))
+	case cmd.Type == "touch":
+		action := slices.Index(touchActions[:], cmd.Action)
+		if action < 0 {
+			return nil, fmt.Errorf("%w: touch %s", ErrInvalidInputCommand, cmd.Action)
+		}
+		if !inRange(cmd.PointerID, 0, math.MaxUint16) {
+			return nil, fmt.Errorf("%w: pointer id %d out of range", ErrInvalidInputCommand, cmd.PointerID)
+		}
+		if !inRange(cmd.X, 0, math.MaxUint16) || !inRange(cmd.Y, 0, math.MaxUint16) {
+			return nil, fmt.Errorf("%w: position %d,%d out of range", ErrInvalidInputCommand, cmd.X, cmd.Y)
+		}
+		frameType = frameTypeTouch
+		payload = make([]byte, touchPayloadSize)
+		payload[0] = byte(action)
+		binary.LittleEndian.PutUint16(payload[2:4], uint16(cmd.PointerID))
+		binary.LittleEndian.PutUint16(payload[4:6], uint16(cmd.X))
+		binary.LittleEndian.PutUint16(payload[6:8], uint16(cmd.Y))
+	default:
+		return nil, fmt.Errorf("%w: %s %s", ErrInvalidInputCommand, cmd.Type, cmd.Action)
+	}
+
+	frame := make([]byte, headerSizeV2, headerSizeV2+len(payload))
+	frame[0] = protocolV2Marker
+	frame[1] = frameType
+	frame[2] = byte(cmd.Modifiers)
+	binary.LittleEndian.PutUint32(frame[4:8], cmd.Sequence)
+	binary.LittleEndian.PutUint64(frame[8:16], uint64(cmd.Timestamp/time.Microsecond))
+	binary.LittleEndian.PutUint16(frame[16:18], uint16(len(payload)))
+	return append(frame, payload...), nil
+}
+
+func pressActionCode(action string) byte {
+	if action == "press" {
+		return actPress
+	}
+	return actRelease
+}
+
+// buttonCode returns the protocol id of a button name
+func buttonCode(name string) (byte, bool) {
+	for code := byte(btnLeft); code <= btnForward; code++ {
+		if buttonToString(code) == name {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+func inRange(v, minimum, maximum int) bool {
+	return v >= minimum && v <= maximum
+}
@@ -0,0 +1,181 @@
+package input
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// RecordingVersion is the version of the recording format
+const RecordingVersion = 1
+
+// RecordingExt is the extension of recording files, they are gzip
+// compressed JSON lines: a RecordingHeader followed by RecordedCommands
+const RecordingExt = ".jsonl.gz"
+
+// RecordingHeader is the first line of a recording
+type RecordingHeader struct {
+	Version   int       `json:"version"`
+	SessionID string    `json:"session_id,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// RecordedCommand is a command of a recording with its offset from the
+// start of the recording
+type RecordedCommand struct {
+	Offset  time.Duration `json:"t"`
+	Command InputCommand  `json:"cmd"`
+}
+
+// Recorder writes the input commands of a session to a recording, it is
+// safe for concurrent use. The session records the commands it injects,
+// after the remap, the input policy and the pointer mode, so Replay passes
+// them to HandleCommand as they are.
+type Recorder struct {
+	mu      sync.Mutex
+	closer  io.Closer
+	gz      *gzip.Writer
+	buf     *bufio.Writer
+	encoder *json.Encoder
+	start   time.Time
+	// failed is set after a write error, the rest of the session is not
+	// recorded
+	failed bool
+}
+
+// NewRecorder starts a recording of a session in w, it is closed with the
+// recorder
+func NewRecorder(w io.WriteCloser, sessionID string) (*Recorder, error) {
+	gz := gzip.NewWriter(w)
+	buf := bufio.NewWriter(gz)
+	r := &Recorder{
+		closer:  w,
+		gz:      gz,
+		buf:     buf,
+		encoder: json.NewEncoder(buf),
+		start:   time.Now(),
+	}
+
+	header := RecordingHeader{Version: RecordingVersion, SessionID: sessionID, StartedAt: r.start}
+	if err := r.encoder.Encode(header); err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Record appends a command to the recording, a write error is logged once
+// and stops the recording
+func (r *Recorder) Record(cmd InputCommand) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failed {
+		return
+	}
+
+	if err := r.encoder.Encode(RecordedCommand{Offset: time.Since(r.start), Command: cmd}); err != nil {
+		log.Printf("failed to record input, stopping the recording: %v", err)
+		r.failed = true
+	}
+}
+
+// Close flushes the recording and closes the file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed = true
+
+	err := r.buf.Flush()
+	if closeErr := r.gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := r.closer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// RecordingReader reads the commands of a recording
+type RecordingReader struct {
+	header  RecordingHeader
+	decoder *json.Decoder
+}
+
+// NewRecordingReader reads the header of a recording
+func NewRecordingReader(r io.Reader) (*RecordingReader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecording, err)
+	}
+
+	reader := &RecordingReader{decoder: json.NewDecoder(gz)}
+	if err := reader.decoder.Decode(&reader.header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecording, err)
+	}
+	if reader.header.Version != RecordingVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidRecording, reader.header.Version)
+	}
+	return reader, nil
+}
+
+// Header returns the header of the recording
+func (r *RecordingReader) Header() RecordingHeader {
+	return r.header
+}
+
+// Next returns the next command, io.EOF at the end of the recording
+func (r *RecordingReader) Next() (RecordedCommand, error) {
+	var recorded RecordedCommand
+	if err := r.decoder.Decode(&recorded); err != nil {
+		if errors.Is(err, io.EOF) {
+			return RecordedCommand{}, io.EOF
+		}
+		return RecordedCommand{}, fmt.Errorf("%w: %v", ErrInvalidRecording, err)
+	}
+	return recorded, nil
+}
+
+// Replay passes the commands of a recording to inject at their recorded
+// offsets divided by speed, 2 replays twice as fast. It returns the number
+// of replayed commands.
+func Replay(ctx context.Context, reader *RecordingReader, speed float64, inject func(cmd InputCommand)) (int, error) {
+	if speed <= 0 || math.IsNaN(speed) || math.IsInf(speed, 0) {
+		return 0, fmt.Errorf("invalid replay speed %v", speed)
+	}
+
+	start := time.Now()
+	replayed := 0
+	for {
+		recorded, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return replayed, nil
+		}
+		if err != nil {
+			return replayed, err
+		}
+
+		due := start.Add(time.Duration(float64(recorded.Offset) / speed))
+		if wait := time.Until(due); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return replayed, ctx.Err()
+			case <-timer.C:
+			}
+		} else if ctx.Err() != nil {
+			return replayed, ctx.Err()
+		}
+
+		inject(recorded.Command)
+		replayed++
+	}
+}
@@ -0,0 +1,97 @@
+package input
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// nopCloser is a buffer closed by the recorder
+type nopCloser struct{ *bytes.Buffer }
+
+func (nopCloser) Close() error { return nil }
+
+func TestRecorder_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	recorder, err := NewRecorder(nopCloser{&buf}, "session-1")
+	require.NoError(t, err)
+
+	cmds := []InputCommand{
+		{Type: "keyboard", Action: "press", Key: "KeyW", Modifiers: ModShift, Sequence: 7},
+		{Type: "mouse", Action: "move", X: 100, Y: 200},
+		{Type: "mouse", Action: "move_relative", X: -3, Y: 4},
+		{Type: "keyboard", Action: "release", Key: "KeyW"},
+	}
+	for _, cmd := range cmds {
+		recorder.Record(cmd)
+	}
+	require.NoError(t, recorder.Close())
+	// commands after the close are dropped
+	recorder.Record(cmds[0])
+
+	reader, err := NewRecordingReader(&buf)
+	require.NoError(t, err)
+	require.Equal(t, RecordingVersion, reader.Header().Version)
+	require.Equal(t, "session-1", reader.Header().SessionID)
+
+	var last time.Duration
+	for _, cmd := range cmds {
+		recorded, err := reader.Next()
+		require.NoError(t, err)
+		require.Equal(t, cmd, recorded.Command)
+		require.GreaterOrEqual(t, recorded.Offset, last)
+		last = recorded.Offset
+	}
+	_, err = reader.Next()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestNewRecordingReader_Invalid(t *testing.T) {
+	_, err := NewRecordingReader(bytes.NewReader([]byte(`{"version":1}`)))
+	require.ErrorIs(t, err, ErrInvalidRecording)
+}
+
+func TestReplay(t *testing.T) {
+	var buf bytes.Buffer
+	recorder, err := NewRecorder(nopCloser{&buf}, "")
+	require.NoError(t, err)
+	recorder.Record(InputCommand{Type: "keyboard", Action: "press", Key: "KeyA"})
+	time.Sleep(100 * time.Millisecond)
+	recorder.Record(InputCommand{Type: "keyboard", Action: "release", Key: "KeyA"})
+	require.NoError(t, recorder.Close())
+	data := buf.Bytes()
+
+	replay := func(ctx context.Context, speed float64) ([]InputCommand, time.Duration, error) {
+		reader, err := NewRecordingReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		var cmds []InputCommand
+		start := time.Now()
+		n, err := Replay(ctx, reader, speed, func(cmd InputCommand) { cmds = append(cmds, cmd) })
+		require.Len(t, cmds, n)
+		return cmds, time.Since(start), err
+	}
+
+	cmds, elapsed, err := replay(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, []InputCommand{key("press", "KeyA"), key("release", "KeyA")}, cmds)
+	require.GreaterOrEqual(t, elapsed, 100*time.Millisecond)
+
+	// scaled replays keep the order
+	cmds, elapsed, err = replay(context.Background(), 100)
+	require.NoError(t, err)
+	require.Len(t, cmds, 2)
+	require.Less(t, elapsed, 100*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	cmds, _, err = replay(ctx, 1)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Len(t, cmds, 1)
+
+	_, _, err = replay(context.Background(), 0)
+	require.Error(t, err)
+}
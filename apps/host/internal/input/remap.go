@@ -0,0 +1,431 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxMacroSteps and maxMacroDuration keep a macro from holding the
+	// input of a session for long
+	maxMacroSteps    = 64
+	maxMacroDuration = 10 * time.Second
+)
+
+// RemapProfile remaps the input of a program before it is injected. The
+// zero value leaves the input as is.
+type RemapProfile struct {
+	// Keys maps key codes to the key codes they press instead
+	Keys map[string]string `json:"keys,omitempty"`
+	// Buttons maps mouse buttons to the key codes they press instead
+	Buttons map[string]string `json:"buttons,omitempty"`
+	// Macros play a sequence of keys when their key or button is pressed
+	Macros []Macro `json:"macros,omitempty"`
+}
+
+// Macro is a sequence of keys and delays played when Key or Button is
+// pressed, one of them is set
+type Macro struct {
+	Key    string      `json:"key,omitempty"`
+	Button string      `json:"button,omitempty"`
+	Steps  []MacroStep `json:"steps"`
+}
+
+// MacroStep presses or releases a key, or waits for Delay when Key is
+// empty
+type MacroStep struct {
+	Key string `json:"key,omitempty"`
+	// Action is "press" or "release", empty taps the key
+	Action string        `json:"action,omitempty"`
+	Delay  time.Duration `json:"delay,omitempty"`
+}
+
+// IsEmpty reports whether the profile leaves the input as is
+func (p RemapProfile) IsEmpty() bool {
+	return len(p.Keys) == 0 && len(p.Buttons) == 0 && len(p.Macros) == 0
+}
+
+// Validate checks the keys and buttons of the profile and the length of
+// its macros, every key or button is remapped at most once
+func (p RemapProfile) Validate() error {
+	for from, to := range p.Keys {
+		if err := validateKeys(from, to); err != nil {
+			return err
+		}
+	}
+	for button, to := range p.Buttons {
+		if err := validateButton(button); err != nil {
+			return err
+		}
+		if err := validateKeys(to); err != nil {
+			return err
+		}
+	}
+
+	for _, macro := range p.Macros {
+		if (macro.Key == "") == (macro.Button == "") {
+			return fmt.Errorf("%w: a macro is started by a key or a button", ErrInvalidRemapProfile)
+		}
+		if macro.Key != "" {
+			if err := validateKeys(macro.Key); err != nil {
+				return err
+			}
+			if _, ok := p.Keys[macro.Key]; ok {
+				return fmt.Errorf("%w: %s is remapped and starts a macro", ErrInvalidRemapProfile, macro.Key)
+			}
+		} else {
+			if err := validateButton(macro.Button); err != nil {
+				return err
+			}
+			if _, ok := p.Buttons[macro.Button]; ok {
+				return fmt.Errorf("%w: button %s is remapped and starts a macro", ErrInvalidRemapProfile, macro.Button)
+			}
+		}
+		if err := macro.validateSteps(); err != nil {
+			return err
+		}
+	}
+
+	sources := make(map[string]bool, len(p.Macros))
+	for _, macro := range p.Macros {
+		source := macro.source()
+		if sources[source] {
+			return fmt.Errorf("%w: more than one macro for %s", ErrInvalidRemapProfile, source)
+		}
+		sources[source] = true
+	}
+	return nil
+}
+
+// validateSteps checks the keys, the actions and the length of a macro
+func (m Macro) validateSteps() error {
+	if len(m.Steps) == 0 || len(m.Steps) > maxMacroSteps {
+		return fmt.Errorf("%w: a macro has 1 to %d steps", ErrInvalidRemapProfile, maxMacroSteps)
+	}
+
+	var total time.Duration
+	for _, step := range m.Steps {
+		if step.Key == "" {
+			if step.Delay <= 0 {
+				return fmt.Errorf("%w: a macro step without a key needs a delay", ErrInvalidRemapProfile)
+			}
+			total += step.Delay
+			continue
+		}
+		if err := validateKeys(step.Key); err != nil {
+			return err
+		}
+		switch step.Action {
+		case "", "press", "release":
+		default:
+			return fmt.Errorf("%w: unknown macro action %q", ErrInvalidRemapProfile, step.Action)
+		}
+	}
+	if total > maxMacroDuration {
+		return fmt.Errorf("%w: a macro waits at most %s", ErrInvalidRemapProfile, maxMacroDuration)
+	}
+	return nil
+}
+
+// source returns the key or button starting the macro, as written in
+// ParseRemapProfile
+func (m Macro) source() string {
+	if m.Button != "" {
+		return "button " + m.Button
+	}
+	return m.Key
+}
+
+func validateKeys(codes ...string) error {
+	for _, code := range codes {
+		if _, ok := lookupKey(code); !ok {
+			return fmt.Errorf("%w: unknown key %q", ErrInvalidRemapProfile, code)
+		}
+	}
+	return nil
+}
+
+func validateButton(button string) error {
+	// the decoders name buttons in lower case
+	if _, ok := evdevButtonCode(button); !ok || button != strings.ToLower(button) {
+		return fmt.Errorf("%w: unknown button %q", ErrInvalidRemapProfile, button)
+	}
+	return nil
+}
+
+// String returns the profile in the text format of ParseRemapProfile
+func (p RemapProfile) String() string {
+	var lines []string
+	for _, from := range slices.Sorted(maps.Keys(p.Keys)) {
+		lines = append(lines, fmt.Sprintf("key %s %s", from, p.Keys[from]))
+	}
+	for _, button := range slices.Sorted(maps.Keys(p.Buttons)) {
+		lines = append(lines, fmt.Sprintf("button %s %s", button, p.Buttons[button]))
+	}
+	for _, macro := range p.Macros {
+		parts := []string{"macro", macro.source()}
+		for _, step := range macro.Steps {
+			parts = append(parts, step.String())
+		}
+		lines = append(lines, strings.Join(parts, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// String returns the step as a token of ParseRemapProfile
+func (s MacroStep) String() string {
+	switch {
+	case s.Key == "":
+		return s.Delay.String()
+	case s.Action == "press":
+		return "+" + s.Key
+	case s.Action == "release":
+		return "-" + s.Key
+	}
+	return s.Key
+}
+
+// ParseRemapProfile parses a profile with one rule per line, keys are
+// KeyboardEvent.code values:
+//
+//	key CapsLock ControlLeft
+//	button back KeyE
+//	macro F1 KeyH 50ms KeyI
+//	macro button forward +ShiftLeft Digit1 -ShiftLeft
+//
+// Macro steps tap a key, press it with +, release it with - or wait for a
+// duration. Empty lines and lines starting with # are skipped.
+func ParseRemapProfile(text string) (RemapProfile, error) {
+	var profile RemapProfile
+
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch kind := strings.ToLower(fields[0]); kind {
+		case "key", "button":
+			if len(fields) != 3 {
+				return RemapProfile{}, fmt.Errorf("%w: line %d: expected %s FROM TO", ErrInvalidRemapProfile, i+1, kind)
+			}
+			rules := &profile.Keys
+			if kind == "button" {
+				rules = &profile.Buttons
+			}
+			if *rules == nil {
+				*rules = map[string]string{}
+			}
+			if _, ok := (*rules)[fields[1]]; ok {
+				return RemapProfile{}, fmt.Errorf("%w: line %d: %s is remapped twice", ErrInvalidRemapProfile, i+1, fields[1])
+			}
+			(*rules)[fields[1]] = fields[2]
+		case "macro":
+			macro, err := parseMacro(fields[1:])
+			if err != nil {
+				return RemapProfile{}, fmt.Errorf("%w: line %d: %v", ErrInvalidRemapProfile, i+1, err)
+			}
+			profile.Macros = append(profile.Macros, macro)
+		default:
+			return RemapProfile{}, fmt.Errorf("%w: line %d: unknown rule %q", ErrInvalidRemapProfile, i+1, fields[0])
+		}
+	}
+
+	return profile, profile.Validate()
+}
+
+// parseMacro parses the fields of a macro line after "macro"
+func parseMacro(fields []string) (Macro, error) {
+	var macro Macro
+	switch {
+	case len(fields) > 1 && fields[0] == "button":
+		macro.Button, fields = fields[1], fields[2:]
+	case len(fields) > 0:
+		macro.Key, fields = fields[0], fields[1:]
+	}
+	if len(fields) == 0 {
+		return Macro{}, fmt.Errorf("expected macro FROM STEPS")
+	}
+
+	for _, field := range fields {
+		step := MacroStep{Key: field}
+		if key, ok := strings.CutPrefix(field, "+"); ok {
+			step = MacroStep{Key: key, Action: "press"}
+		} else if key, ok := strings.CutPrefix(field, "-"); ok {
+			step = MacroStep{Key: key, Action: "release"}
+		} else if delay, err := time.ParseDuration(field); err == nil {
+			step = MacroStep{Delay: delay}
+		}
+		macro.Steps = append(macro.Steps, step)
+	}
+	return macro, nil
+}
+
+// Remapper applies the remap profile of a session to its input commands,
+// macros are played on their own and injected through inject
+type Remapper struct {
+	inject func(cmd InputCommand)
+
+	mu      sync.Mutex
+	profile RemapProfile
+	macros  map[string]Macro
+	// cancel stops the running macro, nil when none runs
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRemapper creates a remapper without a profile, macros are injected
+// through inject
+func NewRemapper(inject func(cmd InputCommand)) *Remapper {
+	return &Remapper{inject: inject}
+}
+
+// Profile returns the applied profile
+func (r *Remapper) Profile() RemapProfile {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.profile
+}
+
+// SetProfile validates and applies a profile, a running macro is stopped
+func (r *Remapper) SetProfile(profile RemapProfile) error {
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+	r.Stop()
+
+	macros := make(map[string]Macro, len(profile.Macros))
+	for _, macro := range profile.Macros {
+		macros[macro.source()] = macro
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.profile = profile
+	r.macros = macros
+	return nil
+}
+
+// Stop stops the running macro and waits until it released its keys
+func (r *Remapper) Stop() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Apply returns the commands to inject for a command of the client, none
+// when it starts a macro
+func (r *Remapper) Apply(cmd InputCommand) []InputCommand {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch cmd.Type {
+	case "keyboard":
+		if macro, ok := r.macros[cmd.Key]; ok {
+			r.startMacro(macro, cmd.Action)
+			return nil
+		}
+		if to, ok := r.profile.Keys[cmd.Key]; ok {
+			cmd.Key = to
+		}
+	case "mouse":
+		if cmd.Button == "" || cmd.Action == "move" {
+			break
+		}
+		if macro, ok := r.macros["button "+cmd.Button]; ok {
+			r.startMacro(macro, cmd.Action)
+			return nil
+		}
+		if to, ok := r.profile.Buttons[cmd.Button]; ok {
+			key := InputCommand{Type: "keyboard", Action: cmd.Action, Key: to, Modifiers: cmd.Modifiers}
+			if cmd.Action == "click" {
+				release := key
+				key.Action, release.Action = "press", "release"
+				return []InputCommand{key, release}
+			}
+			return []InputCommand{key}
+		}
+	}
+	return []InputCommand{cmd}
+}
+
+// startMacro plays a macro on a press of its key or button, presses while
+// a macro runs are dropped. The caller holds r.mu.
+func (r *Remapper) startMacro(macro Macro, action string) {
+	if action != "press" && action != "click" {
+		return
+	}
+	if r.cancel != nil {
+		log.Printf("input remap: dropped macro %s, another macro is running", macro.source())
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	r.cancel, r.done = cancel, done
+
+	go func() {
+		defer close(done)
+		r.play(ctx, macro)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		cancel()
+		if r.done == done {
+			r.cancel, r.done = nil, nil
+		}
+	}()
+}
+
+// play injects the steps of a macro, the keys it leaves pressed are
+// released at the end or when it is stopped
+func (r *Remapper) play(ctx context.Context, macro Macro) {
+	var pressed []string
+	defer func() {
+		for _, key := range slices.Backward(pressed) {
+			r.inject(InputCommand{Type: "keyboard", Action: "release", Key: key})
+		}
+	}()
+
+	for _, step := range macro.Steps {
+		if step.Key == "" {
+			timer := time.NewTimer(step.Delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		switch step.Action {
+		case "press":
+			r.inject(InputCommand{Type: "keyboard", Action: "press", Key: step.Key})
+			if !slices.Contains(pressed, step.Key) {
+				pressed = append(pressed, step.Key)
+			}
+		case "release":
+			r.inject(InputCommand{Type: "keyboard", Action: "release", Key: step.Key})
+			pressed = slices.DeleteFunc(pressed, func(key string) bool { return key == step.Key })
+		default:
+			r.inject(InputCommand{Type: "keyboard", Action: "press", Key: step.Key})
+			r.inject(InputCommand{Type: "keyboard", Action: "release", Key: step.Key})
+		}
+	}
+}
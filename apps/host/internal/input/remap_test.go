@@ -0,0 +1,140 @@
+package input
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRemapProfile(t *testing.T) {
+	text := `
+# laptop layout
+key CapsLock ControlLeft
+button back KeyE
+macro F1 KeyH 50ms KeyI
+macro button forward +ShiftLeft Digit1 -ShiftLeft
+`
+	profile, err := ParseRemapProfile(text)
+	require.NoError(t, err)
+	require.Equal(t, RemapProfile{
+		Keys:    map[string]string{"CapsLock": "ControlLeft"},
+		Buttons: map[string]string{"back": "KeyE"},
+		Macros: []Macro{
+			{Key: "F1", Steps: []MacroStep{{Key: "KeyH"}, {Delay: 50 * time.Millisecond}, {Key: "KeyI"}}},
+			{Button: "forward", Steps: []MacroStep{
+				{Key: "ShiftLeft", Action: "press"}, {Key: "Digit1"}, {Key: "ShiftLeft", Action: "release"},
+			}},
+		},
+	}, profile)
+
+	// the text format round trips
+	parsed, err := ParseRemapProfile(profile.String())
+	require.NoError(t, err)
+	require.Equal(t, profile, parsed)
+
+	empty, err := ParseRemapProfile("")
+	require.NoError(t, err)
+	require.True(t, empty.IsEmpty())
+
+	invalid := []string{
+		"key CapsLock",
+		"key CapsLock Nope",
+		"key CapsLock KeyA\nkey CapsLock KeyB",
+		"button Left KeyA",
+		"button wheel KeyA",
+		"macro F1",
+		"macro F1 -1s",
+		"macro F1 KeyA 11s",
+		"key F1 KeyA\nmacro F1 KeyB",
+		"macro F1 KeyA\nmacro F1 KeyB",
+		"remap F1 KeyA",
+	}
+	for _, text := range invalid {
+		_, err := ParseRemapProfile(text)
+		require.ErrorIs(t, err, ErrInvalidRemapProfile, text)
+	}
+}
+
+func TestRemapper_Apply(t *testing.T) {
+	r := NewRemapper(func(InputCommand) {})
+	require.NoError(t, r.SetProfile(RemapProfile{
+		Keys:    map[string]string{"CapsLock": "ControlLeft"},
+		Buttons: map[string]string{"back": "KeyE"},
+	}))
+
+	cmd := key("press", "CapsLock")
+	cmd.Modifiers = ModShift
+	require.Equal(t, []InputCommand{{Type: "keyboard", Action: "press", Key: "ControlLeft", Modifiers: ModShift}}, r.Apply(cmd))
+	require.Equal(t, []InputCommand{key("release", "ControlLeft")}, r.Apply(key("release", "CapsLock")))
+	require.Equal(t, []InputCommand{key("press", "KeyA")}, r.Apply(key("press", "KeyA")))
+
+	require.Equal(t, []InputCommand{key("press", "KeyE")},
+		r.Apply(InputCommand{Type: "mouse", Action: "press", Button: "back", X: 10, Y: 20}))
+	require.Equal(t, []InputCommand{key("press", "KeyE"), key("release", "KeyE")},
+		r.Apply(InputCommand{Type: "mouse", Action: "click", Button: "back"}))
+
+	left := InputCommand{Type: "mouse", Action: "press", Button: "left", X: 10, Y: 20}
+	require.Equal(t, []InputCommand{left}, r.Apply(left))
+}
+
+// recorder collects the commands injected by macros
+type recorder struct {
+	mu   sync.Mutex
+	cmds []InputCommand
+}
+
+func (r *recorder) inject(cmd InputCommand) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cmds = append(r.cmds, cmd)
+}
+
+func (r *recorder) commands() []InputCommand {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]InputCommand(nil), r.cmds...)
+}
+
+func TestRemapper_Macro(t *testing.T) {
+	rec := &recorder{}
+	r := NewRemapper(rec.inject)
+	profile, err := ParseRemapProfile("macro F1 KeyH 20ms +ShiftLeft KeyI")
+	require.NoError(t, err)
+	require.NoError(t, r.SetProfile(profile))
+
+	require.Empty(t, r.Apply(key("press", "F1")))
+	// presses while the macro runs are dropped
+	require.Empty(t, r.Apply(key("press", "F1")))
+	require.Empty(t, r.Apply(key("release", "F1")))
+
+	// the key left pressed is released at the end
+	want := []InputCommand{
+		key("press", "KeyH"), key("release", "KeyH"),
+		key("press", "ShiftLeft"),
+		key("press", "KeyI"), key("release", "KeyI"),
+		key("release", "ShiftLeft"),
+	}
+	require.Eventually(t, func() bool { return len(rec.commands()) == len(want) }, time.Second, 5*time.Millisecond)
+	require.Equal(t, want, rec.commands())
+}
+
+func TestRemapper_Stop(t *testing.T) {
+	rec := &recorder{}
+	r := NewRemapper(rec.inject)
+	profile, err := ParseRemapProfile("macro button forward +ShiftLeft 5s KeyA")
+	require.NoError(t, err)
+	require.NoError(t, r.SetProfile(profile))
+
+	require.Empty(t, r.Apply(InputCommand{Type: "mouse", Action: "press", Button: "forward"}))
+	require.Eventually(t, func() bool { return len(rec.commands()) == 1 }, time.Second, 5*time.Millisecond)
+
+	r.Stop()
+	require.Equal(t, []InputCommand{key("press", "ShiftLeft"), key("release", "ShiftLeft")}, rec.commands())
+
+	// a new macro starts after the stop
+	require.Empty(t, r.Apply(InputCommand{Type: "mouse", Action: "press", Button: "forward"}))
+	require.Eventually(t, func() bool { return len(rec.commands()) == 3 }, time.Second, 5*time.Millisecond)
+	r.Stop()
+}
@@ -0,0 +1,102 @@
+package input
+
+import (
+	"maps"
+	"slices"
+	"sync"
+)
+
+// InputState is the input held down on the host, for debugging stuck keys
+type InputState struct {
+	Keys    []string `json:"keys"`
+	Buttons []string `json:"buttons"`
+	// Touches are the pointer ids of the fingers on the screen
+	Touches []int `json:"touches"`
+	// Paused is set while the client paused its input
+	Paused bool `json:"paused"`
+}
+
+// InputTracker tracks the keys, buttons and fingers pressed by the injected
+// commands, so they can be released when the client cannot do it anymore
+type InputTracker struct {
+	mu      sync.Mutex
+	keys    map[string]bool
+	buttons map[string]bool
+	// touches holds the last position of every finger
+	touches map[int][2]int
+}
+
+// NewInputTracker creates a tracker with nothing pressed
+func NewInputTracker() *InputTracker {
+	return &InputTracker{
+		keys:    map[string]bool{},
+		buttons: map[string]bool{},
+		touches: map[int][2]int{},
+	}
+}
+
+// Track updates the pressed input with an injected command
+func (t *InputTracker) Track(cmd InputCommand) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch cmd.Type {
+	case "keyboard":
+		switch cmd.Action {
+		case "press":
+			t.keys[cmd.Key] = true
+		case "release":
+			delete(t.keys, cmd.Key)
+		}
+	case "mouse":
+		switch cmd.Action {
+		case "press":
+			t.buttons[cmd.Button] = true
+		case "release":
+			delete(t.buttons, cmd.Button)
+		}
+	case "touch":
+		switch cmd.Action {
+		case "start", "move":
+			t.touches[cmd.PointerID] = [2]int{cmd.X, cmd.Y}
+		case "end", "cancel":
+			delete(t.touches, cmd.PointerID)
+		}
+	}
+}
+
+// State returns the pressed input in a stable order
+func (t *InputTracker) State() InputState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return InputState{
+		Keys:    slices.Sorted(maps.Keys(t.keys)),
+		Buttons: slices.Sorted(maps.Keys(t.buttons)),
+		Touches: slices.Sorted(maps.Keys(t.touches)),
+	}
+}
+
+// Release forgets the pressed input and returns the commands releasing it,
+// fingers are cancelled where they were last
+func (t *InputTracker) Release() []InputCommand {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var cmds []InputCommand
+	for _, key := range slices.Sorted(maps.Keys(t.keys)) {
+		cmds = append(cmds, InputCommand{Type: "keyboard", Action: "release", Key: key})
+	}
+	for _, button := range slices.Sorted(maps.Keys(t.buttons)) {
+		cmds = append(cmds, InputCommand{Type: "mouse", Action: "release", Button: button})
+	}
+	for _, id := range slices.Sorted(maps.Keys(t.touches)) {
+		position := t.touches[id]
+		cmds = append(cmds, InputCommand{Type: "touch", Action: "cancel", PointerID: id, X: position[0], Y: position[1]})
+	}
+
+	clear(t.keys)
+	clear(t.buttons)
+	clear(t.touches)
+	return cmds
+}
@@ -0,0 +1,43 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInputTracker(t *testing.T) {
+	tracker := NewInputTracker()
+
+	for _, cmd := range []InputCommand{
+		key("press", "ShiftLeft"),
+		key("press", "KeyW"),
+		key("press", "KeyA"),
+		key("release", "KeyA"),
+		{Type: "mouse", Action: "press", Button: "right"},
+		{Type: "mouse", Action: "click", Button: "left"},
+		{Type: "touch", Action: "start", PointerID: 4, X: 10, Y: 20},
+		{Type: "touch", Action: "move", PointerID: 4, X: 30, Y: 40},
+		{Type: "touch", Action: "start", PointerID: 5, X: 1, Y: 1},
+		{Type: "touch", Action: "end", PointerID: 5, X: 1, Y: 1},
+	} {
+		tracker.Track(cmd)
+	}
+
+	require.Equal(t, InputState{
+		Keys:    []string{"KeyW", "ShiftLeft"},
+		Buttons: []string{"right"},
+		Touches: []int{4},
+	}, tracker.State())
+
+	require.Equal(t, []InputCommand{
+		key("release", "KeyW"),
+		key("release", "ShiftLeft"),
+		{Type: "mouse", Action: "release", Button: "right"},
+		{Type: "touch", Action: "cancel", PointerID: 4, X: 30, Y: 40},
+	}, tracker.Release())
+
+	// everything is released once
+	require.Empty(t, tracker.Release())
+	require.Equal(t, InputState{}, tracker.State())
+}
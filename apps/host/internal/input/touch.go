@@ -0,0 +1,96 @@
+package input
+
+import "sync"
+
+// maxTouchContacts is the number of fingers injected at once, more are
+// dropped
+const maxTouchContacts = 10
+
+// touchContacts assigns the pointer ids of the client to the contact slots
+// of a touch device
+type touchContacts struct {
+	ids    [maxTouchContacts]int
+	active [maxTouchContacts]bool
+}
+
+// start returns the slot of a new contact, a pointer that is already down
+// keeps its slot. ok is false when all slots are taken.
+func (c *touchContacts) start(id int) (slot int, ok bool) {
+	if slot, ok := c.find(id); ok {
+		return slot, true
+	}
+	for slot := range c.active {
+		if !c.active[slot] {
+			c.ids[slot], c.active[slot] = id, true
+			return slot, true
+		}
+	}
+	return 0, false
+}
+
+// find returns the slot of an active contact
+func (c *touchContacts) find(id int) (int, bool) {
+	for slot := range c.active {
+		if c.active[slot] && c.ids[slot] == id {
+			return slot, true
+		}
+	}
+	return 0, false
+}
+
+// end frees the slot of a contact
+func (c *touchContacts) end(slot int) {
+	c.active[slot] = false
+}
+
+// count returns the number of active contacts
+func (c *touchContacts) count() int {
+	n := 0
+	for _, active := range c.active {
+		if active {
+			n++
+		}
+	}
+	return n
+}
+
+// touchEmulator emulates the mouse with the first finger on the screen,
+// for backends without touch injection. The other fingers are ignored.
+type touchEmulator struct {
+	mu      sync.Mutex
+	primary int
+	down    bool
+}
+
+// mouseCommands returns the mouse commands emulating a touch command
+func (e *touchEmulator) mouseCommands(cmd InputCommand) []InputCommand {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	move := InputCommand{Type: "mouse", Action: "move", X: cmd.X, Y: cmd.Y}
+	switch cmd.Action {
+	case "start":
+		if e.down {
+			return nil
+		}
+		e.primary, e.down = cmd.PointerID, true
+		return []InputCommand{move, {Type: "mouse", Action: "press", Button: "left", X: cmd.X, Y: cmd.Y}}
+	case "move":
+		if e.down && cmd.PointerID == e.primary {
+			return []InputCommand{move}
+		}
+	case "end", "cancel":
+		if e.down && cmd.PointerID == e.primary {
+			e.down = false
+			return []InputCommand{{Type: "mouse", Action: "release", Button: "left", X: cmd.X, Y: cmd.Y}}
+		}
+	}
+	return nil
+}
+
+// reset forgets the finger that is down, after the devices were closed
+func (e *touchEmulator) reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.down = false
+}
@@ -0,0 +1,65 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTouchContacts(t *testing.T) {
+	var contacts touchContacts
+
+	first, ok := contacts.start(7)
+	require.True(t, ok)
+	second, ok := contacts.start(42)
+	require.True(t, ok)
+	require.NotEqual(t, first, second)
+	require.Equal(t, 2, contacts.count())
+
+	// a finger that is already down keeps its slot
+	slot, ok := contacts.start(7)
+	require.True(t, ok)
+	require.Equal(t, first, slot)
+
+	contacts.end(first)
+	_, ok = contacts.find(7)
+	require.False(t, ok)
+	slot, ok = contacts.find(42)
+	require.True(t, ok)
+	require.Equal(t, second, slot)
+
+	// freed slots are reused, extra fingers are dropped
+	for id := 100; contacts.count() < maxTouchContacts; id++ {
+		_, ok := contacts.start(id)
+		require.True(t, ok)
+	}
+	_, ok = contacts.start(7)
+	require.False(t, ok)
+}
+
+func TestTouchEmulator(t *testing.T) {
+	var e touchEmulator
+	touch := func(action string, id, x, y int) InputCommand {
+		return InputCommand{Type: "touch", Action: action, PointerID: id, X: x, Y: y}
+	}
+
+	require.Equal(t, []InputCommand{
+		{Type: "mouse", Action: "move", X: 10, Y: 20},
+		{Type: "mouse", Action: "press", Button: "left", X: 10, Y: 20},
+	}, e.mouseCommands(touch("start", 1, 10, 20)))
+
+	// only the first finger moves the pointer
+	require.Empty(t, e.mouseCommands(touch("start", 2, 50, 50)))
+	require.Empty(t, e.mouseCommands(touch("move", 2, 60, 60)))
+	require.Equal(t, []InputCommand{{Type: "mouse", Action: "move", X: 30, Y: 40}},
+		e.mouseCommands(touch("move", 1, 30, 40)))
+
+	require.Empty(t, e.mouseCommands(touch("end", 2, 60, 60)))
+	require.Equal(t, []InputCommand{{Type: "mouse", Action: "release", Button: "left", X: 30, Y: 40}},
+		e.mouseCommands(touch("cancel", 1, 30, 40)))
+
+	// the next finger on the screen becomes the primary one
+	require.Len(t, e.mouseCommands(touch("start", 2, 0, 0)), 2)
+	e.reset()
+	require.Len(t, e.mouseCommands(touch("start", 3, 0, 0)), 2)
+}
@@ -0,0 +1,198 @@
+package input
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// touch injection constants, see winuser.h
+const (
+	ptTouch = 0x02
+
+	pointerFlagInRange   = 0x00000002
+	pointerFlagInContact = 0x00000004
+	pointerFlagCanceled  = 0x00008000
+	pointerFlagDown      = 0x00010000
+	pointerFlagUpdate    = 0x00020000
+	pointerFlagUp        = 0x00040000
+
+	touchFeedbackDefault = 0x1
+)
+
+var (
+	touchUser32                  = syscall.NewLazyDLL("user32.dll")
+	procInitializeTouchInjection = touchUser32.NewProc("InitializeTouchInjection")
+	procInjectTouchInput         = touchUser32.NewProc("InjectTouchInput")
+)
+
+// pointerInfo is POINTER_INFO
+type pointerInfo struct {
+	PointerType           uint32
+	PointerID             uint32
+	FrameID               uint32
+	PointerFlags          uint32
+	SourceDevice          uintptr
+	HwndTarget            uintptr
+	PtPixelLocation       point
+	PtHimetricLocation    point
+	PtPixelLocationRaw    point
+	PtHimetricLocationRaw point
+	DwTime                uint32
+	HistoryCount          uint32
+	InputData             int32
+	DwKeyStates           uint32
+	PerformanceCount      uint64
+	ButtonChangeType      int32
+}
+
+// pointerTouchInfo is POINTER_TOUCH_INFO
+type pointerTouchInfo struct {
+	PointerInfo  pointerInfo
+	TouchFlags   uint32
+	TouchMask    uint32
+	RcContact    rect
+	RcContactRaw rect
+	Orientation  uint32
+	Pressure     uint32
+}
+
+type point struct{ X, Y int32 }
+
+type rect struct{ Left, Top, Right, Bottom int32 }
+
+// windowsTouch injects touch with InjectTouchInput. Every injection lists
+// all fingers that are down, Windows cancels the ones left out.
+type windowsTouch struct {
+	mu          sync.Mutex
+	initialized bool
+	unsupported bool
+	contacts    touchContacts
+	positions   [maxTouchContacts]point
+	// emulator is used when touch injection is not available
+	emulator touchEmulator
+}
+
+var touchInjector windowsTouch
+
+// handleTouchCommand injects a touch command, or emulates the mouse with
+// the first finger when touch injection is not available
+func handleTouchCommand(cmd InputCommand) {
+	err := touchInjector.inject(cmd)
+	if errors.Is(err, errNoTouchInjection) {
+		for _, mouse := range touchInjector.emulator.mouseCommands(cmd) {
+			handleMouseCommand(mouse)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to inject touch: %v", err)
+	}
+}
+
+// errNoTouchInjection is returned when InitializeTouchInjection failed
+var errNoTouchInjection = errors.New("touch injection is not available")
+
+// inject sends a touch command with the state of all fingers
+func (t *windowsTouch) inject(cmd InputCommand) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.init() {
+		return errNoTouchInjection
+	}
+
+	x, y := normalizeCoordsToScreen(cmd.X, cmd.Y)
+	slot, down := t.contacts.find(cmd.PointerID)
+	var flags uint32
+	switch cmd.Action {
+	case "start":
+		if !down {
+			var ok bool
+			if slot, ok = t.contacts.start(cmd.PointerID); !ok {
+				return fmt.Errorf("more than %d touch contacts", maxTouchContacts)
+			}
+			flags = pointerFlagDown | pointerFlagInRange | pointerFlagInContact
+		} else {
+			flags = pointerFlagUpdate | pointerFlagInRange | pointerFlagInContact
+		}
+	case "move":
+		if !down {
+			return nil
+		}
+		flags = pointerFlagUpdate | pointerFlagInRange | pointerFlagInContact
+	case "end":
+		if !down {
+			return nil
+		}
+		flags = pointerFlagUp
+	case "cancel":
+		if !down {
+			return nil
+		}
+		flags = pointerFlagUp | pointerFlagCanceled
+	default:
+		return fmt.Errorf("unknown touch action: %s", cmd.Action)
+	}
+	if cmd.Action != "end" && cmd.Action != "cancel" {
+		t.positions[slot] = point{X: int32(x), Y: int32(y)}
+	}
+
+	contacts := make([]pointerTouchInfo, 0, maxTouchContacts)
+	for other := range t.contacts.active {
+		if !t.contacts.active[other] {
+			continue
+		}
+		contactFlags := uint32(pointerFlagUpdate | pointerFlagInRange | pointerFlagInContact)
+		if other == slot {
+			contactFlags = flags
+		}
+		contacts = append(contacts, touchInfo(other, contactFlags, t.positions[other]))
+	}
+	if flags&pointerFlagUp != 0 {
+		t.contacts.end(slot)
+	}
+
+	ret, _, err := procInjectTouchInput.Call(uintptr(len(contacts)), uintptr(unsafe.Pointer(&contacts[0])))
+	if ret == 0 {
+		return fmt.Errorf("InjectTouchInput failed: %w", err)
+	}
+	return nil
+}
+
+// init initializes touch injection once, it reports whether it is
+// available. The caller holds t.mu.
+func (t *windowsTouch) init() bool {
+	if t.initialized || t.unsupported {
+		return t.initialized
+	}
+	if err := procInitializeTouchInjection.Find(); err != nil {
+		log.Printf("Touch injection is not available, emulating the mouse: %v", err)
+		t.unsupported = true
+		return false
+	}
+	ret, _, err := procInitializeTouchInjection.Call(maxTouchContacts, touchFeedbackDefault)
+	if ret == 0 {
+		log.Printf("InitializeTouchInjection failed, emulating the mouse: %v", err)
+		t.unsupported = true
+		return false
+	}
+	t.initialized = true
+	return true
+}
+
+// touchInfo returns the POINTER_TOUCH_INFO of a finger, the pointer id is
+// the slot so ids stay below the maximum count of InitializeTouchInjection
+func touchInfo(slot int, flags uint32, position point) pointerTouchInfo {
+	return pointerTouchInfo{
+		PointerInfo: pointerInfo{
+			PointerType:     ptTouch,
+			PointerID:       uint32(slot),
+			PointerFlags:    flags,
+			PtPixelLocation: position,
+		},
+	}
+}
@@ -0,0 +1,415 @@
+//go:build linux
+// +build linux
+
+package input
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	uinputPath = "/dev/uinput"
+
+	// uinput ioctl requests, see linux/uinput.h
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiDevSetup   = 0x405c5503 // _IOW('U', 3, struct uinput_setup)
+	uiAbsSetup   = 0x401c5504 // _IOW('U', 4, struct uinput_abs_setup)
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+	uiSetRelBit  = 0x40045566
+	uiSetAbsBit  = 0x40045567
+	uiSetPropBit = 0x4004556e
+
+	busVirtual = 0x06
+
+	// uinputAbsMax is the maximum of the absolute axes, the range covers
+	// the whole desktop
+	uinputAbsMax = 65535
+
+	// uinputSettleDelay gives udev and the compositor time to pick up new
+	// devices, events sent before are lost
+	uinputSettleDelay = 200 * time.Millisecond
+)
+
+// uinputSetup is struct uinput_setup
+type uinputSetup struct {
+	BusType      uint16
+	Vendor       uint16
+	Product      uint16
+	Version      uint16
+	Name         [80]byte
+	FFEffectsMax uint32
+}
+
+// uinputAbsSetup is struct uinput_abs_setup
+type uinputAbsSetup struct {
+	Code       uint16
+	_          uint16
+	Value      int32
+	Minimum    int32
+	Maximum    int32
+	Fuzz       int32
+	Flat       int32
+	Resolution int32
+}
+
+// inputEvent is struct input_event
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// uinputDevice is a virtual input device created through /dev/uinput
+type uinputDevice struct {
+	file *os.File
+}
+
+// newUinputDevice creates a device with the given name, configure enables
+// the event types and codes before the device is created
+func newUinputDevice(name string, product uint16, configure func(d *uinputDevice) error) (*uinputDevice, error) {
+	file, err := os.OpenFile(uinputPath, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) || errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %v", ErrUinputNotWritable, err)
+		}
+		return nil, err
+	}
+
+	d := &uinputDevice{file: file}
+	if err := configure(d); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to configure %s: %w", name, err)
+	}
+
+	setup := uinputSetup{
+		BusType: busVirtual,
+		Vendor:  0x1209,
+		Product: product,
+		Version: 1,
+	}
+	copy(setup.Name[:len(setup.Name)-1], name)
+	if err := d.ioctl(uiDevSetup, uintptr(unsafe.Pointer(&setup))); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to set up %s: %w", name, err)
+	}
+	if err := d.ioctl(uiDevCreate, 0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to create %s: %w", name, err)
+	}
+
+	return d, nil
+}
+
+// enable enables the event type and the given codes of it
+func (d *uinputDevice) enable(eventType uint16, codes ...uint16) error {
+	if err := d.ioctl(uiSetEvBit, uintptr(eventType)); err != nil {
+		return err
+	}
+
+	var request uintptr
+	switch eventType {
+	case evKey:
+		request = uiSetKeyBit
+	case evRel:
+		request = uiSetRelBit
+	case evAbs:
+		request = uiSetAbsBit
+	}
+	for _, code := range codes {
+		if err := d.ioctl(request, uintptr(code)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setProp sets an input property of the device
+func (d *uinputDevice) setProp(prop uint16) error {
+	return d.ioctl(uiSetPropBit, uintptr(prop))
+}
+
+// setupAbs sets the range of an absolute axis
+func (d *uinputDevice) setupAbs(code uint16, maximum int32) error {
+	setup := uinputAbsSetup{Code: code, Maximum: maximum}
+	return d.ioctl(uiAbsSetup, uintptr(unsafe.Pointer(&setup)))
+}
+
+func (d *uinputDevice) ioctl(request, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, d.file.Fd(), request, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// emit writes the events followed by a SYN_REPORT, so they are applied
+// together
+func (d *uinputDevice) emit(events ...inputEvent) error {
+	var buf bytes.Buffer
+	for _, event := range append(events, inputEvent{Type: evSyn, Code: synReport}) {
+		if err := binary.Write(&buf, binary.NativeEndian, event); err != nil {
+			return err
+		}
+	}
+	_, err := d.file.Write(buf.Bytes())
+	return err
+}
+
+// Close destroys the device
+func (d *uinputDevice) Close() error {
+	_ = d.ioctl(uiDevDestroy, 0)
+	return d.file.Close()
+}
+
+// uinputBackend injects input through a virtual keyboard, a relative mouse,
+// an absolute pointer and a touchscreen. Buttons and the wheel are sent by
+// the mouse, the compositor applies them at the pointer position.
+type uinputBackend struct {
+	keyboard *uinputDevice
+	mouse    *uinputDevice
+	pointer  *uinputDevice
+	screen   *screenGeometry
+
+	// touch is the multitouch device, nil when it could not be created
+	touch   *uinputDevice
+	touchMu sync.Mutex
+	// contacts are the fingers on the touchscreen by slot, primary is the
+	// slot of the first finger, which also drives the single touch axes
+	contacts   touchContacts
+	primary    int
+	trackingID int32
+}
+
+// newUinputBackend creates the virtual devices
+func newUinputBackend() (*uinputBackend, error) {
+	keyboard, err := newUinputDevice("Imperium Keyboard", 0x0001, func(d *uinputDevice) error {
+		codes := make([]uint16, 0, evdevMaxKeyboard)
+		for code := uint16(1); code <= evdevMaxKeyboard; code++ {
+			codes = append(codes, code)
+		}
+		return d.enable(evKey, codes...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buttons := []uint16{evdevBtnLeft, evdevBtnRight, evdevBtnMiddle, evdevBtnSide, evdevBtnExtra}
+	mouse, err := newUinputDevice("Imperium Mouse", 0x0002, func(d *uinputDevice) error {
+		if err := d.enable(evKey, buttons...); err != nil {
+			return err
+		}
+		return d.enable(evRel, relX, relY, relWheel, relHWheel, relWheelHiRes, relHWheelHiRes)
+	})
+	if err != nil {
+		keyboard.Close()
+		return nil, err
+	}
+
+	// the buttons make the compositor treat it as a pointer, not a joystick
+	pointer, err := newUinputDevice("Imperium Pointer", 0x0003, func(d *uinputDevice) error {
+		if err := d.enable(evKey, buttons...); err != nil {
+			return err
+		}
+		if err := d.enable(evAbs, absX, absY); err != nil {
+			return err
+		}
+		if err := d.setupAbs(absX, uinputAbsMax); err != nil {
+			return err
+		}
+		return d.setupAbs(absY, uinputAbsMax)
+	})
+	if err != nil {
+		keyboard.Close()
+		mouse.Close()
+		return nil, err
+	}
+
+	// without a touchscreen touch is emulated with the pointer
+	touch, err := newUinputTouchscreen()
+	if err != nil {
+		log.Printf("failed to create the uinput touchscreen, emulating touch with the mouse: %v", err)
+		touch = nil
+	}
+
+	time.Sleep(uinputSettleDelay)
+	return &uinputBackend{
+		keyboard: keyboard,
+		mouse:    mouse,
+		pointer:  pointer,
+		screen:   &screenGeometry{},
+		touch:    touch,
+		primary:  -1,
+	}, nil
+}
+
+// newUinputTouchscreen creates a multitouch device using the slots of
+// protocol B, see Documentation/input/multi-touch-protocol.rst
+func newUinputTouchscreen() (*uinputDevice, error) {
+	return newUinputDevice("Imperium Touchscreen", 0x0004, func(d *uinputDevice) error {
+		if err := d.setProp(inputPropDirect); err != nil {
+			return err
+		}
+		if err := d.enable(evKey, evdevBtnTouch); err != nil {
+			return err
+		}
+		if err := d.enable(evAbs, absX, absY, absMTSlot, absMTTrackingID, absMTPositionX, absMTPositionY); err != nil {
+			return err
+		}
+		for _, code := range []uint16{absX, absY, absMTPositionX, absMTPositionY, absMTTrackingID} {
+			if err := d.setupAbs(code, uinputAbsMax); err != nil {
+				return err
+			}
+		}
+		return d.setupAbs(absMTSlot, maxTouchContacts-1)
+	})
+}
+
+func (b *uinputBackend) Name() string {
+	return "uinput"
+}
+
+func (b *uinputBackend) Key(name string, pressed bool) error {
+	code, ok := evdevKeyCode(name)
+	if !ok {
+		return fmt.Errorf("unknown key: %s", name)
+	}
+	return b.keyboard.emit(inputEvent{Type: evKey, Code: code, Value: boolValue(pressed)})
+}
+
+func (b *uinputBackend) Button(name string, pressed bool) error {
+	code, ok := evdevButtonCode(name)
+	if !ok {
+		return fmt.Errorf("unknown mouse button: %s", name)
+	}
+	return b.mouse.emit(inputEvent{Type: evKey, Code: code, Value: boolValue(pressed)})
+}
+
+func (b *uinputBackend) MoveAbsolute(nx, ny int) error {
+	x, y := b.screen.desktopPosition(nx, ny, uinputAbsMax)
+	return b.pointer.emit(
+		inputEvent{Type: evAbs, Code: absX, Value: int32(x)},
+		inputEvent{Type: evAbs, Code: absY, Value: int32(y)},
+	)
+}
+
+func (b *uinputBackend) MoveRelative(dx, dy int) error {
+	return b.mouse.emit(
+		inputEvent{Type: evRel, Code: relX, Value: int32(dx)},
+		inputEvent{Type: evRel, Code: relY, Value: int32(dy)},
+	)
+}
+
+func (b *uinputBackend) Scroll(dx, dy int) error {
+	var events []inputEvent
+	if steps, hiRes := wheelSteps(dy); steps != 0 {
+		events = append(events,
+			inputEvent{Type: evRel, Code: relWheel, Value: int32(steps)},
+			inputEvent{Type: evRel, Code: relWheelHiRes, Value: int32(hiRes)},
+		)
+	}
+	// horizontal wheel steps are positive to the right, like the deltas
+	if steps, hiRes := wheelSteps(dx); steps != 0 {
+		events = append(events,
+			inputEvent{Type: evRel, Code: relHWheel, Value: int32(-steps)},
+			inputEvent{Type: evRel, Code: relHWheelHiRes, Value: int32(-hiRes)},
+		)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	return b.mouse.emit(events...)
+}
+
+// Touch injects a touch command on the touchscreen, errNoTouchDevice
+// when there is none
+func (b *uinputBackend) Touch(action string, id, nx, ny int) error {
+	if b.touch == nil {
+		return errNoTouchDevice
+	}
+
+	b.touchMu.Lock()
+	defer b.touchMu.Unlock()
+
+	x, y := b.screen.desktopPosition(nx, ny, uinputAbsMax)
+	position := func(slot int) []inputEvent {
+		events := []inputEvent{
+			{Type: evAbs, Code: absMTSlot, Value: int32(slot)},
+			{Type: evAbs, Code: absMTPositionX, Value: int32(x)},
+			{Type: evAbs, Code: absMTPositionY, Value: int32(y)},
+		}
+		if slot == b.primary {
+			events = append(events,
+				inputEvent{Type: evAbs, Code: absX, Value: int32(x)},
+				inputEvent{Type: evAbs, Code: absY, Value: int32(y)},
+			)
+		}
+		return events
+	}
+
+	slot, down := b.contacts.find(id)
+	switch action {
+	case "start":
+		if down {
+			return b.touch.emit(position(slot)...)
+		}
+		slot, ok := b.contacts.start(id)
+		if !ok {
+			return fmt.Errorf("more than %d touch contacts", maxTouchContacts)
+		}
+		b.trackingID = (b.trackingID + 1) % uinputAbsMax
+		events := []inputEvent{{Type: evAbs, Code: absMTSlot, Value: int32(slot)}, {Type: evAbs, Code: absMTTrackingID, Value: b.trackingID}}
+		if b.contacts.count() == 1 {
+			b.primary = slot
+			events = append(events, inputEvent{Type: evKey, Code: evdevBtnTouch, Value: 1})
+		}
+		return b.touch.emit(append(events, position(slot)...)...)
+	case "move":
+		if !down {
+			return nil
+		}
+		return b.touch.emit(position(slot)...)
+	case "end", "cancel":
+		if !down {
+			return nil
+		}
+		b.contacts.end(slot)
+		events := []inputEvent{{Type: evAbs, Code: absMTSlot, Value: int32(slot)}, {Type: evAbs, Code: absMTTrackingID, Value: -1}}
+		if slot == b.primary {
+			b.primary = -1
+		}
+		if b.contacts.count() == 0 {
+			events = append(events, inputEvent{Type: evKey, Code: evdevBtnTouch, Value: 0})
+		}
+		return b.touch.emit(events...)
+	}
+	return fmt.Errorf("unknown touch action: %s", action)
+}
+
+// Close destroys the devices, the compositor releases everything they
+// still hold pressed
+func (b *uinputBackend) Close() error {
+	err := errors.Join(b.keyboard.Close(), b.mouse.Close(), b.pointer.Close())
+	if b.touch != nil {
+		err = errors.Join(err, b.touch.Close())
+	}
+	return err
+}
+
+func boolValue(pressed bool) int32 {
+	if pressed {
+		return 1
+	}
+	return 0
+}
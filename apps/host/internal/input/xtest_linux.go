@@ -0,0 +1,215 @@
+//go:build linux
+// +build linux
+
+package input
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/m1thrandir225/imperium/apps/host/internal/x11"
+)
+
+const (
+	xtestFakeInput = 2
+
+	xtestKeyPress      = 2
+	xtestKeyRelease    = 3
+	xtestButtonPress   = 4
+	xtestButtonRelease = 5
+	xtestMotionNotify  = 6
+
+	// xtestKeycodeOffset is the difference between evdev and X keycodes,
+	// the X server maps keycode n to evdev code n-8
+	xtestKeycodeOffset = 8
+)
+
+// X pointer buttons, 4 to 7 are the wheel
+const (
+	xButtonLeft       = 1
+	xButtonMiddle     = 2
+	xButtonRight      = 3
+	xButtonWheelUp    = 4
+	xButtonWheelDown  = 5
+	xButtonWheelLeft  = 6
+	xButtonWheelRight = 7
+	xButtonBack       = 8
+	xButtonForward    = 9
+)
+
+// xtestBackend injects input through the XTEST extension of the X server,
+// it only reaches X11 sessions and XWayland windows
+type xtestBackend struct {
+	xtest  *xtest
+	screen *screenGeometry
+}
+
+func newXTestBackend() (*xtestBackend, error) {
+	xtest, err := newXTest()
+	if err != nil {
+		return nil, err
+	}
+	return &xtestBackend{xtest: xtest, screen: &screenGeometry{}}, nil
+}
+
+func (b *xtestBackend) Name() string {
+	return "XTest"
+}
+
+func (b *xtestBackend) Key(name string, pressed bool) error {
+	code, ok := evdevKeyCode(name)
+	if !ok {
+		return fmt.Errorf("unknown key: %s", name)
+	}
+	return b.xtest.FakeKey(code, pressed)
+}
+
+func (b *xtestBackend) Button(name string, pressed bool) error {
+	code, ok := evdevButtonCode(name)
+	if !ok {
+		return fmt.Errorf("unknown mouse button: %s", name)
+	}
+
+	button := map[uint16]byte{
+		evdevBtnLeft:   xButtonLeft,
+		evdevBtnMiddle: xButtonMiddle,
+		evdevBtnRight:  xButtonRight,
+		evdevBtnSide:   xButtonBack,
+		evdevBtnExtra:  xButtonForward,
+	}[code]
+	return b.xtest.FakeButton(button, pressed)
+}
+
+func (b *xtestBackend) MoveAbsolute(nx, ny int) error {
+	x, y := b.screen.screenPosition(nx, ny)
+	return b.xtest.FakeMotion(x, y, false)
+}
+
+func (b *xtestBackend) MoveRelative(dx, dy int) error {
+	return b.xtest.FakeMotion(dx, dy, true)
+}
+
+// Scroll clicks the wheel buttons once per step, X has no high resolution
+// scrolling
+func (b *xtestBackend) Scroll(dx, dy int) error {
+	steps, _ := wheelSteps(dy)
+	button := byte(xButtonWheelUp)
+	if steps < 0 {
+		button, steps = xButtonWheelDown, -steps
+	}
+	if err := b.clickButton(button, steps); err != nil {
+		return err
+	}
+
+	steps, _ = wheelSteps(dx)
+	button = xButtonWheelLeft
+	if steps < 0 {
+		button, steps = xButtonWheelRight, -steps
+	}
+	return b.clickButton(button, steps)
+}
+
+func (b *xtestBackend) clickButton(button byte, times int) error {
+	for range times {
+		if err := b.xtest.FakeButton(button, true); err != nil {
+			return err
+		}
+		if err := b.xtest.FakeButton(button, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Touch returns errNoTouchDevice, XTest has no touch, it is emulated with
+// the pointer
+func (b *xtestBackend) Touch(action string, id, nx, ny int) error {
+	return errNoTouchDevice
+}
+
+func (b *xtestBackend) Close() error {
+	return b.xtest.Close()
+}
+
+// xtest is a connection to the X server that fakes input events through the
+// XTEST extension
+type xtest struct {
+	mu     sync.Mutex
+	conn   *x11.Conn
+	opcode byte
+}
+
+// newXTest connects to the X server of $DISPLAY and checks that it supports
+// the XTEST extension
+func newXTest() (*xtest, error) {
+	conn, err := x11.DialDisplay()
+	if err != nil {
+		return nil, err
+	}
+
+	opcode, err := conn.QueryExtension("XTEST")
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// the requests of the connection have no replies from here on, the
+	// errors the server sends for them are only logged
+	conn.DiscardReplies(func(code byte) {
+		log.Printf("XTest request failed with error code %d", code)
+	})
+
+	return &xtest{conn: conn, opcode: opcode}, nil
+}
+
+// FakeKey presses or releases the key of the given evdev code
+func (x *xtest) FakeKey(evdevCode uint16, pressed bool) error {
+	eventType := byte(xtestKeyRelease)
+	if pressed {
+		eventType = xtestKeyPress
+	}
+	return x.fakeInput(eventType, byte(evdevCode+xtestKeycodeOffset), 0, 0)
+}
+
+// FakeButton presses or releases a pointer button, buttons 4 to 7 are the
+// wheel
+func (x *xtest) FakeButton(button byte, pressed bool) error {
+	eventType := byte(xtestButtonRelease)
+	if pressed {
+		eventType = xtestButtonPress
+	}
+	return x.fakeInput(eventType, button, 0, 0)
+}
+
+// FakeMotion moves the pointer to the given root window position, or by
+// the given amount when relative is set
+func (x *xtest) FakeMotion(posX, posY int, relative bool) error {
+	var detail byte
+	if relative {
+		detail = 1
+	}
+	return x.fakeInput(xtestMotionNotify, detail, int16(posX), int16(posY))
+}
+
+// Close closes the connection to the X server
+func (x *xtest) Close() error {
+	return x.conn.Close()
+}
+
+// fakeInput sends an XTestFakeInput request for the current time on the
+// screen of the pointer
+func (x *xtest) fakeInput(eventType, detail byte, rootX, rootY int16) error {
+	req := make([]byte, 36)
+	req[0] = x.opcode
+	req[1] = xtestFakeInput
+	req[4] = eventType
+	req[5] = detail
+	binary.LittleEndian.PutUint16(req[24:], uint16(rootX))
+	binary.LittleEndian.PutUint16(req[26:], uint16(rootY))
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.conn.Send(req)
+}
@@ -2,6 +2,7 @@ package programs
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -11,7 +12,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/m1thrandir225/imperium/apps/host/internal/input"
 	"github.com/m1thrandir225/imperium/apps/host/internal/util"
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
 	_ "github.com/mattn/go-sqlite3" // sqlite3 driver
 )
 
@@ -20,9 +23,25 @@ type Database interface {
 	GetPrograms() ([]*Program, error)
 	GetProgramByID(id string) (*Program, error)
 	GetProgramByPath(path string) (*Program, error)
+	SetProgramMonitor(id string, monitor string) error
+	SetProgramCaptureRegion(id string, region video.CaptureRegion) error
+	SetProgramInputPolicy(id string, policy *input.Policy) error
+	SetProgramRemapProfile(id string, profile input.RemapProfile) error
 	CleanupNonExistentPrograms() error
 }
 
+// programColumns are the columns read into a Program, see scanProgram
+const programColumns = "id, name, path, description, default_monitor, capture_region, input_policy, remap_profile"
+
+// addedColumns are the columns introduced after the first release with
+// their definitions
+var addedColumns = []struct{ name, definition string }{
+	{"default_monitor", "TEXT NOT NULL DEFAULT ''"},
+	{"capture_region", "TEXT NOT NULL DEFAULT ''"},
+	{"input_policy", "TEXT NOT NULL DEFAULT ''"},
+	{"remap_profile", "TEXT NOT NULL DEFAULT ''"},
+}
+
 type sqliteDB struct {
 	db *sql.DB
 }
@@ -79,6 +98,10 @@ func (pdb *sqliteDB) initTables() error {
 		name TEXT NOT NULL,
 		path TEXT UNIQUE NOT NULL,
 		description TEXT,
+		default_monitor TEXT NOT NULL DEFAULT '',
+		capture_region TEXT NOT NULL DEFAULT '',
+		input_policy TEXT NOT NULL DEFAULT '',
+		remap_profile TEXT NOT NULL DEFAULT '',
 		last_modified DATETIME,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
@@ -88,8 +111,54 @@ func (pdb *sqliteDB) initTables() error {
 	CREATE INDEX IF NOT EXISTS idx_programs_name ON programs(name);
 	`
 
-	_, err := pdb.db.Exec(query)
-	return err
+	if _, err := pdb.db.Exec(query); err != nil {
+		return err
+	}
+
+	return pdb.migrateTables()
+}
+
+// migrateTables adds the columns introduced after the first release to
+// existing databases
+func (pdb *sqliteDB) migrateTables() error {
+	for _, column := range addedColumns {
+		exists, err := pdb.columnExists("programs", column.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		query := fmt.Sprintf("ALTER TABLE programs ADD COLUMN %s %s", column.name, column.definition)
+		if _, err := pdb.db.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pdb *sqliteDB) columnExists(table, column string) (bool, error) {
+	rows, err := pdb.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid          int
+			name, ctype  string
+			notNull, pk  int
+			defaultValue sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
 }
 
 func (pdb *sqliteDB) Close() error {
@@ -105,9 +174,17 @@ func (pdb *sqliteDB) SaveProgram(program *Program) error {
 		return ErrInvalidProgram
 	}
 
+	// an upsert instead of INSERT OR REPLACE keeps the id and the settings
+	// of the program when it is discovered again
 	query := `
-	INSERT OR REPLACE INTO programs (name, path, description, last_modified, updated_at)
+	INSERT INTO programs (name, path, description, last_modified, updated_at)
 	VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+	ON CONFLICT(path) DO UPDATE SET
+		name = excluded.name,
+		description = excluded.description,
+		last_modified = excluded.last_modified,
+		updated_at = CURRENT_TIMESTAMP
+	RETURNING id, default_monitor, capture_region, input_policy, remap_profile
 	`
 
 	fileInfo, err := os.Stat(program.Path)
@@ -116,22 +193,156 @@ func (pdb *sqliteDB) SaveProgram(program *Program) error {
 		lastModified = fileInfo.ModTime()
 	}
 
-	result, err := pdb.db.Exec(query, program.Name, program.Path, program.Description, lastModified)
+	var (
+		id                    int64
+		region, policy, remap string
+	)
+	err = pdb.db.QueryRow(query, program.Name, program.Path, program.Description, lastModified).
+		Scan(&id, &program.DefaultMonitor, &region, &policy, &remap)
 	if err != nil {
 		return err
 	}
 
-	id, err := result.LastInsertId()
+	program.ID = strconv.FormatInt(id, 10)
+	program.CaptureRegion = decodeCaptureRegion(region)
+	program.InputPolicy = decodeInputPolicy(policy)
+	program.RemapProfile = decodeRemapProfile(remap)
+	return nil
+}
+
+// SetProgramMonitor sets the monitor captured by default for the program,
+// an empty monitor means the primary monitor
+func (pdb *sqliteDB) SetProgramMonitor(id string, monitor string) error {
+	return pdb.updateProgramColumn(id, "default_monitor", monitor)
+}
+
+// updateProgramColumn sets a column of the program, sql.ErrNoRows when
+// there is no program with the id. The column is one of the constant names
+// of the setters, it is not a query parameter.
+func (pdb *sqliteDB) updateProgramColumn(id, column string, value any) error {
+	query := `UPDATE programs SET ` + column + ` = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+
+	result, err := pdb.db.Exec(query, value, id)
 	if err != nil {
 		return err
 	}
 
-	program.ID = strconv.FormatInt(id, 10)
-	return err
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetProgramCaptureRegion sets the region captured by default for the
+// program, the zero region captures the whole monitor
+func (pdb *sqliteDB) SetProgramCaptureRegion(id string, region video.CaptureRegion) error {
+	value := ""
+	if !region.IsEmpty() {
+		data, err := json.Marshal(region)
+		if err != nil {
+			return err
+		}
+		value = string(data)
+	}
+
+	return pdb.updateProgramColumn(id, "capture_region", value)
+}
+
+// scanProgram reads a row of programColumns
+func scanProgram(row interface{ Scan(dest ...any) error }) (*Program, error) {
+	var (
+		program               = &Program{}
+		region, policy, remap string
+	)
+	err := row.Scan(&program.ID, &program.Name, &program.Path, &program.Description, &program.DefaultMonitor,
+		&region, &policy, &remap)
+	if err != nil {
+		return nil, err
+	}
+	program.CaptureRegion = decodeCaptureRegion(region)
+	program.InputPolicy = decodeInputPolicy(policy)
+	program.RemapProfile = decodeRemapProfile(remap)
+	return program, nil
+}
+
+// SetProgramInputPolicy sets the input policy of sessions of the program,
+// nil restores the default policy
+func (pdb *sqliteDB) SetProgramInputPolicy(id string, policy *input.Policy) error {
+	value := ""
+	if policy != nil {
+		data, err := json.Marshal(policy)
+		if err != nil {
+			return err
+		}
+		value = string(data)
+	}
+
+	return pdb.updateProgramColumn(id, "input_policy", value)
+}
+
+// SetProgramRemapProfile sets the key and button remapping of sessions of
+// the program, the empty profile leaves the input as is
+func (pdb *sqliteDB) SetProgramRemapProfile(id string, profile input.RemapProfile) error {
+	value := ""
+	if !profile.IsEmpty() {
+		data, err := json.Marshal(profile)
+		if err != nil {
+			return err
+		}
+		value = string(data)
+	}
+
+	return pdb.updateProgramColumn(id, "remap_profile", value)
+}
+
+// decodeCaptureRegion decodes a stored capture region, a region that
+// cannot be decoded is logged and dropped
+func decodeCaptureRegion(value string) video.CaptureRegion {
+	var region video.CaptureRegion
+	if value == "" {
+		return region
+	}
+	if err := json.Unmarshal([]byte(value), &region); err != nil {
+		log.Printf("Error decoding capture region %q: %v", value, err)
+		return video.CaptureRegion{}
+	}
+	return region
+}
+
+// decodeInputPolicy decodes a stored input policy, a policy that cannot be
+// decoded is logged and dropped so the default policy applies
+func decodeInputPolicy(value string) *input.Policy {
+	if value == "" {
+		return nil
+	}
+	policy := &input.Policy{}
+	if err := json.Unmarshal([]byte(value), policy); err != nil {
+		log.Printf("Error decoding input policy %q: %v", value, err)
+		return nil
+	}
+	return policy
+}
+
+// decodeRemapProfile decodes a stored remap profile, a profile that cannot
+// be decoded is logged and dropped
+func decodeRemapProfile(value string) input.RemapProfile {
+	var profile input.RemapProfile
+	if value == "" {
+		return profile
+	}
+	if err := json.Unmarshal([]byte(value), &profile); err != nil {
+		log.Printf("Error decoding remap profile %q: %v", value, err)
+		return input.RemapProfile{}
+	}
+	return profile
 }
 
 func (pdb *sqliteDB) GetPrograms() ([]*Program, error) {
-	query := `SELECT id, name, path, description FROM programs ORDER BY name`
+	query := `SELECT ` + programColumns + ` FROM programs ORDER BY name`
 
 	rows, err := pdb.db.Query(query)
 	if err != nil {
@@ -143,8 +354,7 @@ func (pdb *sqliteDB) GetPrograms() ([]*Program, error) {
 
 	var programs []*Program
 	for rows.Next() {
-		program := &Program{}
-		err := rows.Scan(&program.ID, &program.Name, &program.Path, &program.Description)
+		program, err := scanProgram(rows)
 		if err != nil {
 			log.Printf("Error scanning program: %v", err)
 			continue
@@ -155,25 +365,13 @@ func (pdb *sqliteDB) GetPrograms() ([]*Program, error) {
 }
 
 func (pdb *sqliteDB) GetProgramByID(id string) (*Program, error) {
-	query := `SELECT id, name, path, description FROM programs WHERE id = ?`
-	program := &Program{}
-	err := pdb.db.QueryRow(query, id).Scan(&program.ID, &program.Name, &program.Path, &program.Description)
-	if err != nil {
-		return nil, err
-	}
-	return program, nil
+	query := `SELECT ` + programColumns + ` FROM programs WHERE id = ?`
+	return scanProgram(pdb.db.QueryRow(query, id))
 }
 
 func (pdb *sqliteDB) GetProgramByPath(path string) (*Program, error) {
-	query := `SELECT id, name, path, description FROM programs WHERE path = ?`
-
-	program := &Program{}
-	err := pdb.db.QueryRow(query, path).Scan(&program.ID, &program.Name, &program.Path, &program.Description)
-	if err != nil {
-		return nil, err
-	}
-
-	return program, nil
+	query := `SELECT ` + programColumns + ` FROM programs WHERE path = ?`
+	return scanProgram(pdb.db.QueryRow(query, path))
 }
 
 func (pdb *sqliteDB) CleanupNonExistentPrograms() error {
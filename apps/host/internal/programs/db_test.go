@@ -1,10 +1,13 @@
 package programs
 
 import (
+	"database/sql"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/m1thrandir225/imperium/apps/host/internal/input"
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
 	"github.com/stretchr/testify/require"
 )
 
@@ -136,17 +139,214 @@ func TestSqliteDB_SaveProgram(t *testing.T) {
 }
 
 func TestSqliteDB_SaveProgram_Update(t *testing.T) {
-	testCases := []struct {
-		name string
-	}{
-		{},
+	db, err := NewDatabase(InMemoryDb)
+	require.NoError(t, err)
+
+	defer func() {
+		if sqliteDB, ok := db.(*sqliteDB); ok {
+			_ = sqliteDB.Close()
+		}
+	}()
+
+	program := &Program{
+		Name:        "Test Program",
+		Path:        "/test/path",
+		Description: "Test Description",
 	}
+	require.NoError(t, db.SaveProgram(program))
+	require.NoError(t, db.SetProgramMonitor(program.ID, "HDMI-1"))
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
+	// discovering the program again keeps its id and default monitor
+	updated := &Program{
+		Name:        "Renamed Program",
+		Path:        "/test/path",
+		Description: "Updated Description",
+	}
+	require.NoError(t, db.SaveProgram(updated))
+	require.Equal(t, program.ID, updated.ID)
+	require.Equal(t, "HDMI-1", updated.DefaultMonitor)
 
-		})
+	dbProgram, err := db.GetProgramByID(program.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Renamed Program", dbProgram.Name)
+	require.Equal(t, "Updated Description", dbProgram.Description)
+	require.Equal(t, "HDMI-1", dbProgram.DefaultMonitor)
+}
+
+func TestSqliteDB_SetProgramMonitor(t *testing.T) {
+	db, err := NewDatabase(InMemoryDb)
+	require.NoError(t, err)
+
+	defer func() {
+		if sqliteDB, ok := db.(*sqliteDB); ok {
+			_ = sqliteDB.Close()
+		}
+	}()
+
+	program := &Program{Name: "Test Program", Path: "/test/path"}
+	require.NoError(t, db.SaveProgram(program))
+	require.Empty(t, program.DefaultMonitor)
+
+	require.NoError(t, db.SetProgramMonitor(program.ID, "1"))
+	dbProgram, err := db.GetProgramByPath(program.Path)
+	require.NoError(t, err)
+	require.Equal(t, "1", dbProgram.DefaultMonitor)
+
+	require.NoError(t, db.SetProgramMonitor(program.ID, ""))
+	dbProgram, err = db.GetProgramByPath(program.Path)
+	require.NoError(t, err)
+	require.Empty(t, dbProgram.DefaultMonitor)
+
+	require.ErrorIs(t, db.SetProgramMonitor("42", "1"), sql.ErrNoRows)
+}
+
+func TestSqliteDB_MigrateTables(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "programs.db")
+
+	legacy, err := sql.Open("sqlite3", "file:"+dbPath)
+	require.NoError(t, err)
+	_, err = legacy.Exec(`
+	CREATE TABLE programs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		path TEXT UNIQUE NOT NULL,
+		description TEXT,
+		last_modified DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO programs (name, path, description) VALUES ('Legacy', '/legacy/path', '');
+	`)
+	require.NoError(t, err)
+	require.NoError(t, legacy.Close())
+
+	db, err := NewDatabase(dbPath)
+	require.NoError(t, err)
+
+	defer func() {
+		if sqliteDB, ok := db.(*sqliteDB); ok {
+			_ = sqliteDB.Close()
+		}
+	}()
+
+	dbProgram, err := db.GetProgramByPath("/legacy/path")
+	require.NoError(t, err)
+	require.Empty(t, dbProgram.DefaultMonitor)
+	require.NoError(t, db.SetProgramMonitor(dbProgram.ID, "DP-1"))
+	require.True(t, dbProgram.CaptureRegion.IsEmpty())
+	require.NoError(t, db.SetProgramCaptureRegion(dbProgram.ID, video.CaptureRegion{Masks: []video.Mask{{Window: "Chat"}}}))
+}
+
+func TestSqliteDB_SetProgramCaptureRegion(t *testing.T) {
+	db, err := NewDatabase(InMemoryDb)
+	require.NoError(t, err)
+
+	defer func() {
+		if sqliteDB, ok := db.(*sqliteDB); ok {
+			_ = sqliteDB.Close()
+		}
+	}()
+
+	program := &Program{Name: "Test Program", Path: "/test/path"}
+	require.NoError(t, db.SaveProgram(program))
+	require.True(t, program.CaptureRegion.IsEmpty())
+
+	region := video.CaptureRegion{
+		Crop:  &video.Rect{X: 0, Y: 40, Width: 1920, Height: 1040},
+		Masks: []video.Mask{{Rect: video.Rect{X: 1600, Y: 0, Width: 320, Height: 40}}, {Window: "Discord"}},
+	}
+	require.NoError(t, db.SetProgramCaptureRegion(program.ID, region))
+
+	dbProgram, err := db.GetProgramByID(program.ID)
+	require.NoError(t, err)
+	require.Equal(t, region, dbProgram.CaptureRegion)
+
+	// discovering the program again keeps its region
+	require.NoError(t, db.SaveProgram(&Program{Name: "Test Program", Path: "/test/path"}))
+	programs, err := db.GetPrograms()
+	require.NoError(t, err)
+	require.Len(t, programs, 1)
+	require.Equal(t, region, programs[0].CaptureRegion)
+
+	require.NoError(t, db.SetProgramCaptureRegion(program.ID, video.CaptureRegion{}))
+	dbProgram, err = db.GetProgramByPath(program.Path)
+	require.NoError(t, err)
+	require.True(t, dbProgram.CaptureRegion.IsEmpty())
+
+	require.ErrorIs(t, db.SetProgramCaptureRegion("42", region), sql.ErrNoRows)
+}
+
+func TestSqliteDB_SetProgramInputPolicy(t *testing.T) {
+	db, err := NewDatabase(InMemoryDb)
+	require.NoError(t, err)
+
+	defer func() {
+		if sqliteDB, ok := db.(*sqliteDB); ok {
+			_ = sqliteDB.Close()
+		}
+	}()
+
+	program := &Program{Name: "Test Program", Path: "/test/path"}
+	require.NoError(t, db.SaveProgram(program))
+	require.Nil(t, program.InputPolicy)
+
+	policy := &input.Policy{
+		BlockedCombos:  []string{"Alt+F4", "MetaLeft"},
+		ConfinePointer: true,
+		ConfineArea:    &input.Area{X: 0.25, Y: 0.25, Width: 0.5, Height: 0.5},
 	}
+	require.NoError(t, db.SetProgramInputPolicy(program.ID, policy))
+
+	dbProgram, err := db.GetProgramByID(program.ID)
+	require.NoError(t, err)
+	require.Equal(t, policy, dbProgram.InputPolicy)
+
+	// discovering the program again keeps its policy
+	rediscovered := &Program{Name: "Test Program", Path: "/test/path"}
+	require.NoError(t, db.SaveProgram(rediscovered))
+	require.Equal(t, policy, rediscovered.InputPolicy)
+
+	require.NoError(t, db.SetProgramInputPolicy(program.ID, nil))
+	dbProgram, err = db.GetProgramByPath(program.Path)
+	require.NoError(t, err)
+	require.Nil(t, dbProgram.InputPolicy)
+
+	require.ErrorIs(t, db.SetProgramInputPolicy("42", policy), sql.ErrNoRows)
+}
+
+func TestSqliteDB_SetProgramRemapProfile(t *testing.T) {
+	db, err := NewDatabase(InMemoryDb)
+	require.NoError(t, err)
+
+	defer func() {
+		if sqliteDB, ok := db.(*sqliteDB); ok {
+			_ = sqliteDB.Close()
+		}
+	}()
+
+	program := &Program{Name: "Test Program", Path: "/test/path"}
+	require.NoError(t, db.SaveProgram(program))
+	require.True(t, program.RemapProfile.IsEmpty())
+
+	profile, err := input.ParseRemapProfile("key CapsLock ControlLeft\nbutton back KeyE\nmacro F1 KeyH 50ms KeyI")
+	require.NoError(t, err)
+	require.NoError(t, db.SetProgramRemapProfile(program.ID, profile))
+
+	dbProgram, err := db.GetProgramByID(program.ID)
+	require.NoError(t, err)
+	require.Equal(t, profile, dbProgram.RemapProfile)
+
+	// discovering the program again keeps its profile
+	rediscovered := &Program{Name: "Test Program", Path: "/test/path"}
+	require.NoError(t, db.SaveProgram(rediscovered))
+	require.Equal(t, profile, rediscovered.RemapProfile)
+
+	require.NoError(t, db.SetProgramRemapProfile(program.ID, input.RemapProfile{}))
+	dbProgram, err = db.GetProgramByPath(program.Path)
+	require.NoError(t, err)
+	require.True(t, dbProgram.RemapProfile.IsEmpty())
+
+	require.ErrorIs(t, db.SetProgramRemapProfile("42", profile), sql.ErrNoRows)
 }
 
 func TestSqliteDB_GetPrograms(t *testing.T) {
@@ -12,7 +12,9 @@ package mockprograms
 import (
 	reflect "reflect"
 
+	input "github.com/m1thrandir225/imperium/apps/host/internal/input"
 	programs "github.com/m1thrandir225/imperium/apps/host/internal/programs"
+	video "github.com/m1thrandir225/imperium/apps/host/internal/video"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -112,3 +114,59 @@ func (mr *MockDatabaseMockRecorder) SaveProgram(program any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveProgram", reflect.TypeOf((*MockDatabase)(nil).SaveProgram), program)
 }
+
+// SetProgramCaptureRegion mocks base method.
+func (m *MockDatabase) SetProgramCaptureRegion(id string, region video.CaptureRegion) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetProgramCaptureRegion", id, region)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetProgramCaptureRegion indicates an expected call of SetProgramCaptureRegion.
+func (mr *MockDatabaseMockRecorder) SetProgramCaptureRegion(id, region any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProgramCaptureRegion", reflect.TypeOf((*MockDatabase)(nil).SetProgramCaptureRegion), id, region)
+}
+
+// SetProgramInputPolicy mocks base method.
+func (m *MockDatabase) SetProgramInputPolicy(id string, policy *input.Policy) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetProgramInputPolicy", id, policy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetProgramInputPolicy indicates an expected call of SetProgramInputPolicy.
+func (mr *MockDatabaseMockRecorder) SetProgramInputPolicy(id, policy any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProgramInputPolicy", reflect.TypeOf((*MockDatabase)(nil).SetProgramInputPolicy), id, policy)
+}
+
+// SetProgramMonitor mocks base method.
+func (m *MockDatabase) SetProgramMonitor(id, monitor string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetProgramMonitor", id, monitor)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetProgramMonitor indicates an expected call of SetProgramMonitor.
+func (mr *MockDatabaseMockRecorder) SetProgramMonitor(id, monitor any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProgramMonitor", reflect.TypeOf((*MockDatabase)(nil).SetProgramMonitor), id, monitor)
+}
+
+// SetProgramRemapProfile mocks base method.
+func (m *MockDatabase) SetProgramRemapProfile(id string, profile input.RemapProfile) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetProgramRemapProfile", id, profile)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetProgramRemapProfile indicates an expected call of SetProgramRemapProfile.
+func (mr *MockDatabaseMockRecorder) SetProgramRemapProfile(id, profile any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProgramRemapProfile", reflect.TypeOf((*MockDatabase)(nil).SetProgramRemapProfile), id, profile)
+}
@@ -13,7 +13,9 @@ import (
 	exec "os/exec"
 	reflect "reflect"
 
+	input "github.com/m1thrandir225/imperium/apps/host/internal/input"
 	programs "github.com/m1thrandir225/imperium/apps/host/internal/programs"
+	video "github.com/m1thrandir225/imperium/apps/host/internal/video"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -188,3 +190,59 @@ func (mr *MockServiceMockRecorder) SaveProgram(req any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveProgram", reflect.TypeOf((*MockService)(nil).SaveProgram), req)
 }
+
+// SetProgramCaptureRegion mocks base method.
+func (m *MockService) SetProgramCaptureRegion(id string, region video.CaptureRegion) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetProgramCaptureRegion", id, region)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetProgramCaptureRegion indicates an expected call of SetProgramCaptureRegion.
+func (mr *MockServiceMockRecorder) SetProgramCaptureRegion(id, region any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProgramCaptureRegion", reflect.TypeOf((*MockService)(nil).SetProgramCaptureRegion), id, region)
+}
+
+// SetProgramInputPolicy mocks base method.
+func (m *MockService) SetProgramInputPolicy(id string, policy *input.Policy) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetProgramInputPolicy", id, policy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetProgramInputPolicy indicates an expected call of SetProgramInputPolicy.
+func (mr *MockServiceMockRecorder) SetProgramInputPolicy(id, policy any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProgramInputPolicy", reflect.TypeOf((*MockService)(nil).SetProgramInputPolicy), id, policy)
+}
+
+// SetProgramMonitor mocks base method.
+func (m *MockService) SetProgramMonitor(id, monitor string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetProgramMonitor", id, monitor)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetProgramMonitor indicates an expected call of SetProgramMonitor.
+func (mr *MockServiceMockRecorder) SetProgramMonitor(id, monitor any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProgramMonitor", reflect.TypeOf((*MockService)(nil).SetProgramMonitor), id, monitor)
+}
+
+// SetProgramRemapProfile mocks base method.
+func (m *MockService) SetProgramRemapProfile(id string, profile input.RemapProfile) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetProgramRemapProfile", id, profile)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetProgramRemapProfile indicates an expected call of SetProgramRemapProfile.
+func (mr *MockServiceMockRecorder) SetProgramRemapProfile(id, profile any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProgramRemapProfile", reflect.TypeOf((*MockService)(nil).SetProgramRemapProfile), id, profile)
+}
@@ -1,9 +1,26 @@
 package programs
 
+import (
+	"github.com/m1thrandir225/imperium/apps/host/internal/input"
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
+)
+
 type Program struct {
 	ID          string `json:"id"`
 	Name        string `json:"name"`
 	Path        string `json:"path"`
 	Description string `json:"description"`
 	HostID      string `json:"hostId"`
+	// DefaultMonitor is the monitor captured when the session does not
+	// select one, empty for the primary monitor
+	DefaultMonitor string `json:"default_monitor"`
+	// CaptureRegion is the crop and the masks applied to the monitor when
+	// the session does not set its own
+	CaptureRegion video.CaptureRegion `json:"capture_region"`
+	// InputPolicy restricts the input of sessions of the program, nil for
+	// the default policy
+	InputPolicy *input.Policy `json:"input_policy,omitempty"`
+	// RemapProfile remaps keys and buttons and plays macros in sessions of
+	// the program
+	RemapProfile input.RemapProfile `json:"remap_profile"`
 }
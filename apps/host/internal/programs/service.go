@@ -9,7 +9,9 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/m1thrandir225/imperium/apps/host/internal/input"
 	"github.com/m1thrandir225/imperium/apps/host/internal/util"
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
 	"github.com/m1thrandir225/imperium/apps/host/pkg/rawg"
 )
 
@@ -24,6 +26,10 @@ type Service interface {
 	DiscoverPrograms() ([]Program, error)
 	DiscoverProgramsIn(paths []string) ([]Program, error) //??? should return pointer no?
 	SaveProgram(req CreateProgramRequest) (*Program, error)
+	SetProgramMonitor(id string, monitor string) error
+	SetProgramCaptureRegion(id string, region video.CaptureRegion) error
+	SetProgramInputPolicy(id string, policy *input.Policy) error
+	SetProgramRemapProfile(id string, profile input.RemapProfile) error
 	LaunchProgram(path string) (*exec.Cmd, error)
 	GetWindowTitleByProcessID(pid uint32) (string, error)
 	RawgSearch(program Program) Program
@@ -244,6 +250,53 @@ func (s *programService) SaveProgram(req CreateProgramRequest) (*Program, error)
 	return progr, nil
 }
 
+// SetProgramMonitor sets the monitor captured by default when a session
+// starts the program
+func (s *programService) SetProgramMonitor(id string, monitor string) error {
+	if s.db == nil {
+		return fmt.Errorf("program database not initialized")
+	}
+	return s.db.SetProgramMonitor(id, strings.TrimSpace(monitor))
+}
+
+// SetProgramCaptureRegion sets the crop and the masks applied when a
+// session starts the program
+func (s *programService) SetProgramCaptureRegion(id string, region video.CaptureRegion) error {
+	if s.db == nil {
+		return fmt.Errorf("program database not initialized")
+	}
+	if err := region.Validate(); err != nil {
+		return err
+	}
+	return s.db.SetProgramCaptureRegion(id, region)
+}
+
+// SetProgramInputPolicy sets the blocked keys, the pointer confinement and
+// the read only mode of sessions of the program, nil restores the default
+func (s *programService) SetProgramInputPolicy(id string, policy *input.Policy) error {
+	if s.db == nil {
+		return fmt.Errorf("program database not initialized")
+	}
+	if policy != nil {
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+	}
+	return s.db.SetProgramInputPolicy(id, policy)
+}
+
+// SetProgramRemapProfile sets the remapped keys and buttons and the macros
+// of sessions of the program
+func (s *programService) SetProgramRemapProfile(id string, profile input.RemapProfile) error {
+	if s.db == nil {
+		return fmt.Errorf("program database not initialized")
+	}
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+	return s.db.SetProgramRemapProfile(id, profile)
+}
+
 func (s *programService) LaunchProgram(path string) (*exec.Cmd, error) {
 	cmd := exec.Command(path)
 	err := cmd.Start()
@@ -3,6 +3,12 @@
 
 package programs
 
+import "github.com/m1thrandir225/imperium/apps/host/internal/video"
+
 func (s *programService) GetWindowTitleByProcessID(pid uint32) (string, error) {
-	return "", ErrOSNotSupported
+	window, err := video.FindProcessWindow(pid, 0)
+	if err != nil {
+		return "", err
+	}
+	return window.Title, nil
 }
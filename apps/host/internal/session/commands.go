@@ -1,6 +1,11 @@
 package session
 
-import "time"
+import (
+	"time"
+
+	"github.com/m1thrandir225/imperium/apps/host/internal/input"
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
+)
 
 // StartSessionCommand is the struct to start a session via the service
 type StartSessionCommand struct {
@@ -13,6 +18,68 @@ type StartSessionCommand struct {
 	SessionID    string
 	HostID       string
 	HostName     string
-	StartedAt    time.Time
-	CreatedAt    time.Time
+	// Monitor selects the monitor to capture by index or name, the default
+	// monitor of the program is used when empty
+	Monitor string
+	// Stream holds the resolution, frame rate and bitrate requested by the
+	// client, zero values leave the choice to the host
+	Stream video.StreamParams
+	// Region crops and masks the captured monitor, the capture region of
+	// the program is used when nil
+	Region *video.CaptureRegion
+	// Spectator drops the input of the client, whatever the input policy
+	// of the program
+	Spectator bool
+	StartedAt time.Time
+	CreatedAt time.Time
+}
+
+// ControlType is the type of a ControlCommand
+type ControlType string
+
+const (
+	// ControlStatsOverlay shows or hides the encoder stats in the video
+	ControlStatsOverlay ControlType = "stats_overlay"
+	// ControlScreenshot stores a full quality PNG of the captured source in
+	// the screenshots directory
+	ControlScreenshot ControlType = "screenshot"
+	// ControlEncoderChanged is sent by the host when a fallback encoder
+	// took over the stream, it is not a command
+	ControlEncoderChanged ControlType = "encoder_changed"
+	// ControlPointer switches the pointer between absolute and relative
+	// moves and sets the scaling of relative moves. Relative moves are
+	// refused while the input policy confines the pointer.
+	ControlPointer ControlType = "pointer"
+	// ControlPause pauses the input of the client, e.g. while its window
+	// is not focused. The held input is released and new input is dropped
+	// until it is resumed.
+	ControlPause ControlType = "pause"
+)
+
+// ControlCommand changes the running session, it is sent by the client as
+// a JSON text message on the control data channel or to
+// /api/session/control
+type ControlCommand struct {
+	Type ControlType `json:"type"`
+	// Enabled switches a toggle like ControlStatsOverlay or ControlPause
+	Enabled bool `json:"enabled,omitempty"`
+	// Pointer holds the settings of ControlPointer
+	Pointer *input.PointerSettings `json:"pointer,omitempty"`
+}
+
+// ControlResponse is the reply to a ControlCommand
+type ControlResponse struct {
+	Type    ControlType `json:"type"`
+	Success bool        `json:"success"`
+	Error   string      `json:"error,omitempty"`
+	// StatsOverlay is the state of the overlay after ControlStatsOverlay
+	StatsOverlay *bool `json:"stats_overlay,omitempty"`
+	// Screenshot is the screenshot stored by ControlScreenshot
+	Screenshot *Screenshot `json:"screenshot,omitempty"`
+	// Encoder is the encoder in use after ControlEncoderChanged
+	Encoder string `json:"encoder,omitempty"`
+	// Pointer holds the applied settings after ControlPointer
+	Pointer *input.PointerSettings `json:"pointer,omitempty"`
+	// Paused is the state of the input after ControlPause
+	Paused *bool `json:"paused,omitempty"`
 }
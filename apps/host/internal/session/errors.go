@@ -4,14 +4,31 @@ import "errors"
 
 var (
 	ErrInvalidProgramService        = errors.New("invalid ProgramService")
-	ErrInvalidVideoRecorder         = errors.New("invalid VideoRecorder")
+	ErrInvalidVideoEncoder          = errors.New("invalid VideoEncoder")
+	ErrInvalidCaptureSource         = errors.New("invalid CaptureSource")
 	ErrInvalidWebrtcStreamer        = errors.New("invalid WebrtcStreamer")
 	ErrInvalidAuthService           = errors.New("invalid AuthService")
 	ErrInvalidAuthBaseURL           = errors.New("invalid AuthBaseURL")
 	ErrFailedToGetProgram           = errors.New("failed to get program")
 	ErrFailedToLaunchProgram        = errors.New("failed to launch program")
+	ErrSessionStarting              = errors.New("another session is starting")
 	ErrFailedToCreateWebrtcStreamer = errors.New("failed to create WebRTC streamer")
 	ErrFailedToStartRecording       = errors.New("failed to start video recording")
+	ErrMonitorNotFound              = errors.New("selected monitor is not connected")
+	ErrNoActiveSession              = errors.New("no active session")
+	ErrInvalidCaptureRegion         = errors.New("invalid capture region")
+	ErrCaptureRegionNotSupported    = errors.New("the session does not capture a monitor")
+	ErrFailedToReconfigureStream    = errors.New("failed to reconfigure the video stream")
+	ErrUnknownControlCommand        = errors.New("unknown control command")
+	ErrInvalidControlCommand        = errors.New("invalid control command")
+	ErrFailedToSetStatsOverlay      = errors.New("failed to change the stats overlay")
+	ErrInvalidPointerSettings       = errors.New("invalid pointer settings")
+	ErrPointerConfined              = errors.New("the input policy confines the pointer to absolute moves")
+	ErrPreviewDisabled              = errors.New("the host preview is disabled")
+	ErrFailedToTakePreview          = errors.New("failed to take the host preview")
+	ErrFailedToTakeScreenshot       = errors.New("failed to take a screenshot")
+	ErrScreenshotsNotConfigured     = errors.New("no screenshots directory is set")
+	ErrScreenshotNotFound           = errors.New("screenshot not found")
 	ErrNotInitializedProgramService = errors.New("program service is not initialized")
 	ErrNotInitializedWebRTCStreamer = errors.New("webrtc streamer is not initialized")
 	ErrFailedWebRTCOfferGeneration  = errors.New("failed to generate WebRTC offer answer")
@@ -88,6 +88,21 @@ func (mr *MockServiceMockRecorder) GetCurrentSession() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCurrentSession", reflect.TypeOf((*MockService)(nil).GetCurrentSession))
 }
 
+// GetMonitors mocks base method.
+func (m *MockService) GetMonitors() ([]*video.MonitorInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMonitors")
+	ret0, _ := ret[0].([]*video.MonitorInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMonitors indicates an expected call of GetMonitors.
+func (mr *MockServiceMockRecorder) GetMonitors() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMonitors", reflect.TypeOf((*MockService)(nil).GetMonitors))
+}
+
 // GetPrograms mocks base method.
 func (m *MockService) GetPrograms() ([]*programs.Program, error) {
 	m.ctrl.T.Helper()
@@ -103,6 +118,65 @@ func (mr *MockServiceMockRecorder) GetPrograms() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPrograms", reflect.TypeOf((*MockService)(nil).GetPrograms))
 }
 
+// HandleControlCommand mocks base method.
+func (m *MockService) HandleControlCommand(cmd session.ControlCommand) (session.ControlResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HandleControlCommand", cmd)
+	ret0, _ := ret[0].(session.ControlResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HandleControlCommand indicates an expected call of HandleControlCommand.
+func (mr *MockServiceMockRecorder) HandleControlCommand(cmd any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleControlCommand", reflect.TypeOf((*MockService)(nil).HandleControlCommand), cmd)
+}
+
+// InputState mocks base method.
+func (m *MockService) InputState() input.InputState {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InputState")
+	ret0, _ := ret[0].(input.InputState)
+	return ret0
+}
+
+// InputState indicates an expected call of InputState.
+func (mr *MockServiceMockRecorder) InputState() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InputState", reflect.TypeOf((*MockService)(nil).InputState))
+}
+
+// ListScreenshots mocks base method.
+func (m *MockService) ListScreenshots() ([]session.Screenshot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListScreenshots")
+	ret0, _ := ret[0].([]session.Screenshot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListScreenshots indicates an expected call of ListScreenshots.
+func (mr *MockServiceMockRecorder) ListScreenshots() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListScreenshots", reflect.TypeOf((*MockService)(nil).ListScreenshots))
+}
+
+// Preview mocks base method.
+func (m *MockService) Preview(ctx context.Context) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Preview", ctx)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Preview indicates an expected call of Preview.
+func (mr *MockServiceMockRecorder) Preview(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Preview", reflect.TypeOf((*MockService)(nil).Preview), ctx)
+}
+
 // ProcessInputCommand mocks base method.
 func (m *MockService) ProcessInputCommand(cmd input.InputCommand) {
 	m.ctrl.T.Helper()
@@ -115,6 +189,87 @@ func (mr *MockServiceMockRecorder) ProcessInputCommand(cmd any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessInputCommand", reflect.TypeOf((*MockService)(nil).ProcessInputCommand), cmd)
 }
 
+// ReconfigureStream mocks base method.
+func (m *MockService) ReconfigureStream(params video.StreamParams) (video.StreamParams, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReconfigureStream", params)
+	ret0, _ := ret[0].(video.StreamParams)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReconfigureStream indicates an expected call of ReconfigureStream.
+func (mr *MockServiceMockRecorder) ReconfigureStream(params any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReconfigureStream", reflect.TypeOf((*MockService)(nil).ReconfigureStream), params)
+}
+
+// ScreenshotPath mocks base method.
+func (m *MockService) ScreenshotPath(name string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScreenshotPath", name)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ScreenshotPath indicates an expected call of ScreenshotPath.
+func (mr *MockServiceMockRecorder) ScreenshotPath(name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScreenshotPath", reflect.TypeOf((*MockService)(nil).ScreenshotPath), name)
+}
+
+// SetCaptureRegion mocks base method.
+func (m *MockService) SetCaptureRegion(region video.CaptureRegion) (video.StreamParams, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetCaptureRegion", region)
+	ret0, _ := ret[0].(video.StreamParams)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetCaptureRegion indicates an expected call of SetCaptureRegion.
+func (mr *MockServiceMockRecorder) SetCaptureRegion(region any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCaptureRegion", reflect.TypeOf((*MockService)(nil).SetCaptureRegion), region)
+}
+
+// SetInputRecordingDir mocks base method.
+func (m *MockService) SetInputRecordingDir(dir string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetInputRecordingDir", dir)
+}
+
+// SetInputRecordingDir indicates an expected call of SetInputRecordingDir.
+func (mr *MockServiceMockRecorder) SetInputRecordingDir(dir any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetInputRecordingDir", reflect.TypeOf((*MockService)(nil).SetInputRecordingDir), dir)
+}
+
+// SetPreviewEnabled mocks base method.
+func (m *MockService) SetPreviewEnabled(enabled bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetPreviewEnabled", enabled)
+}
+
+// SetPreviewEnabled indicates an expected call of SetPreviewEnabled.
+func (mr *MockServiceMockRecorder) SetPreviewEnabled(enabled any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPreviewEnabled", reflect.TypeOf((*MockService)(nil).SetPreviewEnabled), enabled)
+}
+
+// SetScreenshotDir mocks base method.
+func (m *MockService) SetScreenshotDir(dir string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetScreenshotDir", dir)
+}
+
+// SetScreenshotDir indicates an expected call of SetScreenshotDir.
+func (mr *MockServiceMockRecorder) SetScreenshotDir(dir any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetScreenshotDir", reflect.TypeOf((*MockService)(nil).SetScreenshotDir), dir)
+}
+
 // StartSession mocks base method.
 func (m *MockService) StartSession(ctx context.Context, cmd session.StartSessionCommand) (*session.Session, error) {
 	m.ctrl.T.Helper()
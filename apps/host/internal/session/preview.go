@@ -0,0 +1,227 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
+)
+
+const (
+	// previewInterval is how long a preview is served from the cache, at
+	// most one capture is started in this interval
+	previewInterval = 5 * time.Second
+	// previewWidth and previewHeight are the size the preview fits into
+	previewWidth  = 480
+	previewHeight = 270
+	// previewTimeout and screenshotTimeout bound the ffmpeg run of a capture
+	previewTimeout    = 10 * time.Second
+	screenshotTimeout = 15 * time.Second
+	// screenshotExt is the extension of the stored screenshots
+	screenshotExt = ".png"
+)
+
+// Screenshot is a screenshot stored in the screenshots directory
+type Screenshot struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// previewCache holds the last preview, callers arriving while a preview is
+// taken wait for it instead of starting another capture
+type previewCache struct {
+	mu      sync.Mutex
+	takenAt time.Time
+	image   []byte
+	err     error
+}
+
+// get returns the cached preview, or takes a new one with capture when the
+// cached one is older than previewInterval. Failures are cached as well, so
+// a broken capture is not retried on every request.
+func (c *previewCache) get(capture func() ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.takenAt.IsZero() && time.Since(c.takenAt) < previewInterval {
+		return c.image, c.err
+	}
+
+	c.image, c.err = capture()
+	c.takenAt = time.Now()
+	return c.image, c.err
+}
+
+// reset drops the cached preview
+func (c *previewCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.takenAt = time.Time{}
+	c.image, c.err = nil, nil
+}
+
+// Preview returns a low resolution JPEG of the host screen. During a
+// session the captured source of the session is used, so its crop and
+// masks apply to the preview as well.
+func (s *sessionService) Preview(ctx context.Context) ([]byte, error) {
+	if s.previewDisabled.Load() {
+		return nil, ErrPreviewDisabled
+	}
+
+	return s.preview.get(func() ([]byte, error) {
+		s.mu.Lock()
+		encoder, source := s.videoEncoder, s.captureSource
+		if s.currentSession != nil {
+			source = s.currentSession.Source
+		}
+		s.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(ctx, previewTimeout)
+		defer cancel()
+
+		image, err := encoder.Snapshot(ctx, source, video.SnapshotOptions{
+			Format: video.ImageJPEG,
+			Width:  previewWidth,
+			Height: previewHeight,
+		})
+		if err != nil {
+			log.Printf("failed to take the host preview: %v", err)
+			return nil, ErrFailedToTakePreview
+		}
+		return image, nil
+	})
+}
+
+// SetPreviewEnabled enables or disables the host preview
+func (s *sessionService) SetPreviewEnabled(enabled bool) {
+	s.previewDisabled.Store(!enabled)
+	if !enabled {
+		s.preview.reset()
+	}
+}
+
+// SetScreenshotDir sets the directory the screenshots are stored in
+func (s *sessionService) SetScreenshotDir(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.screenshotDir = dir
+}
+
+// takeScreenshot stores a PNG of the captured source of the session at its
+// native resolution. s.mu is only held to read the session, ffmpeg runs
+// without it.
+func (s *sessionService) takeScreenshot() (*Screenshot, error) {
+	s.mu.Lock()
+	session, encoder, dir := s.currentSession, s.videoEncoder, s.screenshotDir
+	s.mu.Unlock()
+
+	if session == nil {
+		return nil, ErrNoActiveSession
+	}
+	if dir == "" {
+		return nil, ErrScreenshotsNotConfigured
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), screenshotTimeout)
+	defer cancel()
+
+	image, err := encoder.Snapshot(ctx, session.Source, video.SnapshotOptions{Format: video.ImagePNG})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	createdAt := time.Now()
+	name := fmt.Sprintf("%s-%s%s", screenshotPrefix(session), createdAt.Format("20060102-150405.000"), screenshotExt)
+	if err := os.WriteFile(filepath.Join(dir, name), image, 0o644); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Saved screenshot %s", name)
+	return &Screenshot{Name: name, Size: int64(len(image)), CreatedAt: createdAt}, nil
+}
+
+// ListScreenshots returns the stored screenshots, the newest first
+func (s *sessionService) ListScreenshots() ([]Screenshot, error) {
+	s.mu.Lock()
+	dir := s.screenshotDir
+	s.mu.Unlock()
+
+	if dir == "" {
+		return nil, ErrScreenshotsNotConfigured
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []Screenshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	screenshots := []Screenshot{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != screenshotExt {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		screenshots = append(screenshots, Screenshot{
+			Name:      entry.Name(),
+			Size:      info.Size(),
+			CreatedAt: info.ModTime(),
+		})
+	}
+
+	slices.SortFunc(screenshots, func(a, b Screenshot) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return screenshots, nil
+}
+
+// ScreenshotPath returns the path of a stored screenshot, names that point
+// outside of the screenshots directory are not found
+func (s *sessionService) ScreenshotPath(name string) (string, error) {
+	s.mu.Lock()
+	dir := s.screenshotDir
+	s.mu.Unlock()
+
+	if dir == "" {
+		return "", ErrScreenshotsNotConfigured
+	}
+	if name == "" || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) || filepath.Ext(name) != screenshotExt {
+		return "", ErrScreenshotNotFound
+	}
+
+	path := filepath.Join(dir, name)
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return "", ErrScreenshotNotFound
+	}
+	return path, nil
+}
+
+// screenshotPrefix returns the name prefix of the screenshots of a session
+func screenshotPrefix(session *Session) string {
+	if session.ID == "" {
+		return "session"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, session.ID)
+}
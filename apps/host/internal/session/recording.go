@@ -0,0 +1,63 @@
+package session
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/m1thrandir225/imperium/apps/host/internal/input"
+)
+
+// SetInputRecordingDir sets the directory the input of sessions is recorded
+// in, an empty directory disables the recording. It applies from the next
+// session on.
+func (s *sessionService) SetInputRecordingDir(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inputRecordingDir = dir
+}
+
+// startInputRecording starts recording the input of a session when a
+// recording directory is set and returns the path of the recording. A
+// recording that cannot be created is logged, the session starts anyway.
+// The caller holds s.mu.
+func (s *sessionService) startInputRecording(sessionID string) string {
+	s.stopInputRecording()
+	if s.inputRecordingDir == "" {
+		return ""
+	}
+	if err := os.MkdirAll(s.inputRecordingDir, 0o755); err != nil {
+		log.Printf("failed to create the input recording directory: %v", err)
+		return ""
+	}
+
+	name := fmt.Sprintf("%s-%s%s", time.Now().Format("20060102-150405"), sessionID, input.RecordingExt)
+	path := filepath.Join(s.inputRecordingDir, filepath.Base(name))
+	file, err := os.Create(path)
+	if err != nil {
+		log.Printf("failed to create the input recording: %v", err)
+		return ""
+	}
+
+	recorder, err := input.NewRecorder(file, sessionID)
+	if err != nil {
+		log.Printf("failed to start the input recording: %v", err)
+		return ""
+	}
+	s.recorder.Store(recorder)
+	log.Printf("Recording the input of session %s to %s", sessionID, path)
+	return path
+}
+
+// stopInputRecording closes the recording of the current session, if any
+func (s *sessionService) stopInputRecording() {
+	recorder := s.recorder.Swap(nil)
+	if recorder == nil {
+		return
+	}
+	if err := recorder.Close(); err != nil {
+		log.Printf("failed to close the input recording: %v", err)
+	}
+}
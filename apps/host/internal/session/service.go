@@ -2,8 +2,13 @@ package session
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/m1thrandir225/imperium/apps/host/internal/input"
 	"github.com/m1thrandir225/imperium/apps/host/internal/programs"
@@ -13,15 +18,36 @@ import (
 	"github.com/m1thrandir225/imperium/apps/host/pkg/httpclient"
 )
 
+const (
+	// processWindowTimeout is how long to wait for the window of a
+	// launched program, launchers can take a while to start the actual
+	// program
+	processWindowTimeout = 15 * time.Second
+	// inspectedFrames is the number of frames at the start of a stream
+	// that are checked for problems browsers cannot decode
+	inspectedFrames = 300
+)
+
 type Service interface {
 	StartSession(ctx context.Context, cmd StartSessionCommand) (*Session, error)
 	EndSession() error
 	GetCurrentSession() *Session
 	ProcessInputCommand(cmd input.InputCommand)
 	GetPrograms() ([]*programs.Program, error)
+	GetMonitors() ([]*video.MonitorInfo, error)
 	GenerateWebRTCAnswer(offer string) (string, error)
 	WebRTCStreamer() webrtc.Streamer
+	ReconfigureStream(params video.StreamParams) (video.StreamParams, error)
+	SetCaptureRegion(region video.CaptureRegion) (video.StreamParams, error)
+	HandleControlCommand(cmd ControlCommand) (ControlResponse, error)
 	UpdateVideoConfig(cfg *video.Config)
+	Preview(ctx context.Context) ([]byte, error)
+	SetPreviewEnabled(enabled bool)
+	SetScreenshotDir(dir string)
+	SetInputRecordingDir(dir string)
+	InputState() input.InputState
+	ListScreenshots() ([]Screenshot, error)
+	ScreenshotPath(name string) (string, error)
 }
 
 type sessionService struct {
@@ -29,10 +55,36 @@ type sessionService struct {
 	httpClient        *httpclient.Client
 	token             string
 	programService    programs.Service
-	videoRecorder     *video.Recorder
+	videoEncoder      video.Encoder
+	captureSource     video.CaptureSource
 	webrtcStreamer    webrtc.Streamer
 	currentSession    *Session
+	screenshotDir     string
+	inputRecordingDir string
 	mu                sync.Mutex
+	// starting is set while StartSession waits for the window of the
+	// launched program without holding mu
+	starting bool
+
+	preview         previewCache
+	previewDisabled atomic.Bool
+
+	// pointer holds the pointer mode of the current session, it has its
+	// own lock as every input command passes it
+	pointer *input.Pointer
+	// policy filters the input of the current session before the pointer
+	policy *input.PolicyFilter
+	// remap remaps the input of the current session before the policy, so
+	// remapped keys cannot bypass it
+	remap *input.Remapper
+	// recorder records the input injected for the current session, nil
+	// when it is not recorded
+	recorder atomic.Pointer[input.Recorder]
+	// held tracks the input the session holds down, it is released when
+	// the client disconnects, pauses or the session ends
+	held *input.InputTracker
+	// inputPaused drops the input of the client until it resumes
+	inputPaused atomic.Bool
 }
 
 // NewService returns a new instance of the session service
@@ -41,7 +93,8 @@ func NewService(
 	token string,
 	authService interface{ GetAuthenticatedClient() *httpclient.Client },
 	programService programs.Service,
-	videoRecorder *video.Recorder,
+	videoEncoder video.Encoder,
+	captureSource video.CaptureSource,
 	webrtcStreamer webrtc.Streamer,
 ) (Service, error) {
 	return newSessionService(
@@ -49,7 +102,8 @@ func NewService(
 		token,
 		authService,
 		programService,
-		videoRecorder,
+		videoEncoder,
+		captureSource,
 		webrtcStreamer,
 	)
 }
@@ -59,7 +113,8 @@ func newSessionService(
 	token string,
 	authService interface{ GetAuthenticatedClient() *httpclient.Client },
 	programService programs.Service,
-	videoRecorder *video.Recorder,
+	videoEncoder video.Encoder,
+	captureSource video.CaptureSource,
 	webrtcStreamer webrtc.Streamer,
 ) (*sessionService, error) {
 	if !util.ValidURL(authServerBaseURL) {
@@ -70,8 +125,12 @@ func newSessionService(
 		return nil, ErrInvalidProgramService
 	}
 
-	if videoRecorder == nil {
-		return nil, ErrInvalidVideoRecorder
+	if videoEncoder == nil {
+		return nil, ErrInvalidVideoEncoder
+	}
+
+	if captureSource == nil {
+		return nil, ErrInvalidCaptureSource
 	}
 
 	// if webrtcStreamer == nil {
@@ -82,14 +141,35 @@ func newSessionService(
 		return nil, ErrInvalidAuthService
 	}
 
-	return &sessionService{
+	s := &sessionService{
 		authServerBaseURL: authServerBaseURL,
 		programService:    programService,
-		videoRecorder:     videoRecorder,
+		videoEncoder:      videoEncoder,
+		captureSource:     captureSource,
 		webrtcStreamer:    webrtcStreamer,
 		token:             token,
 		httpClient:        authService.GetAuthenticatedClient(),
-	}, nil
+		pointer:           input.NewPointer(),
+		policy:            input.NewPolicyFilter(),
+		held:              input.NewInputTracker(),
+	}
+	s.remap = input.NewRemapper(s.injectInput)
+
+	// the handler is passed on to the encoders of later video configs, so
+	// the session keeps following the encoder in use
+	onEvent := videoEncoder.EventHandler()
+	videoEncoder.SetEventHandler(func(event video.ProcessEvent) {
+		if event.Type == video.ProcessEncoderFallback {
+			// the event is emitted by the encoder, which may be waited on
+			// while s.mu is held
+			go s.encoderChanged()
+		}
+		if onEvent != nil {
+			onEvent(event)
+		}
+	})
+
+	return s, nil
 }
 
 // Deprecated: WebRTCStreamer
@@ -102,16 +182,71 @@ func (s *sessionService) StartSession(ctx context.Context, cmd StartSessionComma
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.starting {
+		return nil, ErrSessionStarting
+	}
+
 	program, err := s.programService.GetLocalProgramByID(cmd.ProgramID)
 	if err != nil {
 		return nil, ErrFailedToLaunchProgram
 	}
 
+	// the monitor is checked before launching, so a disconnected monitor
+	// does not leave the program running
+	monitor := cmd.Monitor
+	if monitor == "" {
+		monitor = program.DefaultMonitor
+	}
+	source := video.WithMonitor(s.captureSource, monitor)
+	if source != s.captureSource {
+		if _, err := video.ResolveMonitor(monitor); err != nil {
+			log.Printf("failed to select monitor %q: %v", monitor, err)
+			return nil, ErrMonitorNotFound
+		}
+	}
+
+	region := program.CaptureRegion
+	if cmd.Region != nil {
+		region = *cmd.Region
+	}
+	if err := region.Validate(); err != nil {
+		log.Printf("invalid capture region for %s: %v", program.Name, err)
+		return nil, ErrInvalidCaptureRegion
+	}
+	source = video.WithRegion(source, region)
+
 	programCmd, err := s.programService.LaunchProgram(program.Path)
 	if err != nil {
 		return nil, ErrFailedToLaunchProgram
 	}
 
+	// capture only the window of the program, the selected monitor is
+	// captured while there is none. A region is relative to the monitor,
+	// so the monitor is captured when one is set.
+	windowTitle := program.Name
+	var window *video.WindowInfo
+	if video.IsScreenSource(source) && region.IsEmpty() {
+		// the lookup can take until the timeout, the session stays
+		// responsive meanwhile and other starts are refused
+		s.starting = true
+		s.mu.Unlock()
+		window, err = video.FindProcessWindow(uint32(programCmd.Process.Pid), processWindowTimeout)
+		s.mu.Lock()
+		s.starting = false
+
+		if ctx.Err() != nil {
+			programCmd.Process.Kill()
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			log.Printf("no window found for %s, capturing the screen: %v", program.Name, err)
+		} else {
+			log.Printf("capturing window %q of %s", window.Title, program.Name)
+			source = video.WithWindow(source, window)
+			windowTitle = window.Title
+		}
+	}
+
 	// Create WebRTC streamer
 	streamer, err := webrtc.NewStreamer()
 	if err != nil {
@@ -120,30 +255,59 @@ func (s *sessionService) StartSession(ctx context.Context, cmd StartSessionComma
 	}
 
 	// Start video recording
-	videoStream, err := s.videoRecorder.RecordScreen(nil)
+	stream := s.videoEncoder.Configure(source, cmd.Stream)
+	frames, err := s.videoEncoder.Start(source)
 	if err != nil {
+		log.Printf("failed to start encoding %s: %v", source.Name(), err)
 		programCmd.Process.Kill()
 		streamer.Close()
+		if errors.Is(err, video.ErrMonitorNotFound) {
+			return nil, ErrMonitorNotFound
+		}
 		return nil, ErrFailedToStartRecording
 	}
 
-	configFPS := s.videoRecorder.GetFPS()
-	log.Printf("Starting video stream at %d FPS", configFPS)
-	streamer.StartStream(videoStream, configFPS)
+	log.Printf("Starting %s video stream at %s", s.videoEncoder.Codec(), stream)
+	streamer.StartStream(video.InspectFrames(frames, inspectedFrames, logStreamInfo))
+	// the client sees the captured monitor or window, its input is mapped
+	// to that area of the desktop
+	input.SetCaptureSource(source)
+	// every session starts with an absolute pointer
+	pointer, _ := s.pointer.SetSettings(input.DefaultPointerSettings())
+	policy := s.inputPolicy(program, cmd.Spectator)
+	if err := s.remap.SetProfile(program.RemapProfile); err != nil {
+		log.Printf("invalid remap profile for %s: %v", program.Name, err)
+		_ = s.remap.SetProfile(input.RemapProfile{})
+	}
+	streamer.SetControlHandler(s.handleControlMessage)
+	streamer.SetInputHandler(s.ProcessInputCommand)
+	streamer.SetDisconnectHandler(s.releaseInput)
+	s.inputPaused.Store(false)
 
 	session := &Session{
-		ID:           cmd.SessionID,
-		ProgramID:    cmd.ProgramID,
-		HostID:       cmd.HostID,
-		HostName:     cmd.HostName,
-		ClientID:     cmd.ClientID,
-		ClientName:   cmd.ClientName,
-		Status:       cmd.Status,
-		Process:      programCmd,
-		WindowTitle:  program.Name,
-		SessionToken: cmd.SessionToken,
-		CreatedAt:    cmd.CreatedAt,
-		StartedAt:    cmd.StartedAt,
+		ID:              cmd.SessionID,
+		ProgramID:       cmd.ProgramID,
+		HostID:          cmd.HostID,
+		HostName:        cmd.HostName,
+		ClientID:        cmd.ClientID,
+		ClientName:      cmd.ClientName,
+		Status:          cmd.Status,
+		Process:         programCmd,
+		WindowTitle:     windowTitle,
+		Window:          window,
+		Monitor:         monitor,
+		Region:          region,
+		Stream:          stream,
+		Encoder:         s.videoEncoder.ActiveEncoder(),
+		Pointer:         pointer,
+		InputPolicy:     policy,
+		Remap:           s.remap.Profile(),
+		InputRecording:  s.startInputRecording(cmd.SessionID),
+		SessionToken:    cmd.SessionToken,
+		Source:          source,
+		RequestedStream: cmd.Stream,
+		CreatedAt:       cmd.CreatedAt,
+		StartedAt:       cmd.StartedAt,
 	}
 
 	s.currentSession = session
@@ -160,14 +324,20 @@ func (s *sessionService) EndSession() error {
 		return nil
 	}
 
+	// Release the held input while the program still receives it, this
+	// stops a running macro as well
+	s.releaseInput("the session ended")
+
 	// Kill the program process
 	if s.currentSession.Process != nil {
 		s.currentSession.Process.Process.Kill()
 	}
 
-	// Stop video recording
-	if s.videoRecorder != nil {
-		s.videoRecorder.StopRecording()
+	// Stop video recording, the overlay is only shown for the session it
+	// was enabled in
+	if s.videoEncoder != nil {
+		s.videoEncoder.Stop()
+		_ = s.videoEncoder.SetStatsOverlay(false)
 	}
 
 	// Close WebRTC connection
@@ -175,6 +345,14 @@ func (s *sessionService) EndSession() error {
 		s.webrtcStreamer.Close()
 	}
 
+	s.stopInputRecording()
+
+	// Remove the virtual input devices, releasing anything still held
+	if err := input.Close(); err != nil {
+		log.Printf("failed to close the input devices: %v", err)
+	}
+	input.SetCaptureSource(nil)
+
 	s.currentSession = nil
 	return nil
 }
@@ -185,10 +363,75 @@ func (s *sessionService) GetCurrentSession() *Session {
 	return s.currentSession
 }
 
+// inputPolicy applies the input policy of the program, or the default one
+// when it has none or it is invalid. Spectators are read only.
+func (s *sessionService) inputPolicy(program *programs.Program, spectator bool) input.Policy {
+	policy := input.DefaultPolicy()
+	if program.InputPolicy != nil {
+		policy = *program.InputPolicy
+	}
+	policy.ReadOnly = policy.ReadOnly || spectator
+
+	if err := s.policy.SetPolicy(policy); err != nil {
+		log.Printf("invalid input policy for %s, using the default: %v", program.Name, err)
+		policy = input.DefaultPolicy()
+		policy.ReadOnly = spectator
+		_ = s.policy.SetPolicy(policy)
+	}
+	return policy
+}
+
+// ProcessInputCommand remaps an input command of the client and injects the
+// result
 func (s *sessionService) ProcessInputCommand(cmd input.InputCommand) {
+	if s.inputPaused.Load() {
+		return
+	}
+	for _, cmd := range s.remap.Apply(cmd) {
+		s.injectInput(cmd)
+	}
+}
+
+// injectInput injects a remapped command or a macro step allowed by the
+// input policy in the pointer mode of the session. The injected command is
+// recorded, so a replay injects the same input without the session.
+func (s *sessionService) injectInput(cmd input.InputCommand) {
+	cmd, ok := s.policy.Filter(cmd)
+	if !ok {
+		return
+	}
+	cmd, ok = s.pointer.Apply(cmd)
+	if !ok {
+		return
+	}
+	s.held.Track(cmd)
+	if recorder := s.recorder.Load(); recorder != nil {
+		recorder.Record(cmd)
+	}
 	input.HandleCommand(cmd)
 }
 
+// releaseInput stops a running macro and releases the keys, buttons and
+// fingers the session holds down, the client cannot release them anymore
+func (s *sessionService) releaseInput(reason string) {
+	s.remap.Stop()
+	cmds := s.held.Release()
+	if len(cmds) == 0 {
+		return
+	}
+	log.Printf("Releasing %d held keys, buttons and fingers, %s", len(cmds), reason)
+	for _, cmd := range cmds {
+		s.injectInput(cmd)
+	}
+}
+
+// InputState returns the input held down by the session
+func (s *sessionService) InputState() input.InputState {
+	state := s.held.State()
+	state.Paused = s.inputPaused.Load()
+	return state
+}
+
 func (s *sessionService) GetPrograms() ([]*programs.Program, error) {
 	if s.programService == nil {
 		return nil, ErrNotInitializedProgramService
@@ -197,6 +440,11 @@ func (s *sessionService) GetPrograms() ([]*programs.Program, error) {
 	return s.programService.GetLocalPrograms()
 }
 
+// GetMonitors returns the monitors that can be selected for a session
+func (s *sessionService) GetMonitors() ([]*video.MonitorInfo, error) {
+	return video.ListMonitors()
+}
+
 func (s *sessionService) GenerateWebRTCAnswer(offer string) (string, error) {
 	if s.webrtcStreamer == nil {
 		return "", ErrNotInitializedWebRTCStreamer
@@ -210,15 +458,274 @@ func (s *sessionService) GenerateWebRTCAnswer(offer string) (string, error) {
 	return answer, nil
 }
 
-// Deprecated: UpdateVideoConfig
+// ReconfigureStream applies new stream parameters to the running session
+// without reconnecting, the effective parameters are returned
+func (s *sessionService) ReconfigureStream(params video.StreamParams) (video.StreamParams, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.currentSession == nil {
+		return video.StreamParams{}, ErrNoActiveSession
+	}
+
+	stream, err := s.videoEncoder.Reconfigure(params)
+	if err != nil {
+		log.Printf("failed to reconfigure the stream to %s: %v", params, err)
+		return video.StreamParams{}, ErrFailedToReconfigureStream
+	}
+
+	log.Printf("Reconfigured video stream to %s", stream)
+	s.currentSession.RequestedStream = params
+	s.currentSession.Stream = stream
+	return stream, nil
+}
+
+// SetCaptureRegion changes the crop and the masks of the running session,
+// the capture restarts behind the same track. The effective stream
+// parameters are returned, a crop changes the resolution.
+func (s *sessionService) SetCaptureRegion(region video.CaptureRegion) (video.StreamParams, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.currentSession == nil {
+		return video.StreamParams{}, ErrNoActiveSession
+	}
+
+	if err := video.SetRegion(s.currentSession.Source, region); err != nil {
+		log.Printf("failed to set the capture region: %v", err)
+		if errors.Is(err, video.ErrRegionNotSupported) {
+			return video.StreamParams{}, ErrCaptureRegionNotSupported
+		}
+		return video.StreamParams{}, ErrInvalidCaptureRegion
+	}
+	s.currentSession.Region = region
+	// a crop moves the area the input is mapped to
+	input.SetCaptureSource(s.currentSession.Source)
+
+	stream, err := s.videoEncoder.Reconfigure(s.currentSession.RequestedStream)
+	if err != nil {
+		log.Printf("failed to restart the capture with the region: %v", err)
+		return video.StreamParams{}, ErrFailedToReconfigureStream
+	}
+
+	log.Printf("Changed the capture region, video stream at %s", stream)
+	s.currentSession.Stream = stream
+	return stream, nil
+}
+
+// HandleControlCommand applies a control command to the running session
+func (s *sessionService) HandleControlCommand(cmd ControlCommand) (ControlResponse, error) {
+	// the capture takes a while, it does not block the session
+	if cmd.Type == ControlScreenshot {
+		return s.screenshot()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response := ControlResponse{Type: cmd.Type}
+	if s.currentSession == nil {
+		return response, ErrNoActiveSession
+	}
+
+	switch cmd.Type {
+	case ControlStatsOverlay:
+		if err := s.videoEncoder.SetStatsOverlay(cmd.Enabled); err != nil {
+			log.Printf("failed to change the stats overlay: %v", err)
+			return response, ErrFailedToSetStatsOverlay
+		}
+		log.Printf("Stats overlay enabled: %v", cmd.Enabled)
+		s.currentSession.StatsOverlay = cmd.Enabled
+		enabled := cmd.Enabled
+		response.StatsOverlay = &enabled
+	case ControlPointer:
+		if cmd.Pointer == nil {
+			return response, ErrInvalidControlCommand
+		}
+		// the policy drops relative moves of a confined pointer
+		if cmd.Pointer.Mode == input.PointerRelative && s.policy.Policy().ConfinePointer {
+			return response, ErrPointerConfined
+		}
+		pointer, err := s.pointer.SetSettings(*cmd.Pointer)
+		if err != nil {
+			log.Printf("failed to change the pointer mode: %v", err)
+			return response, ErrInvalidPointerSettings
+		}
+		log.Printf("Pointer mode %s, sensitivity %.2f, acceleration %.2f",
+			pointer.Mode, pointer.Sensitivity, pointer.Acceleration)
+		s.currentSession.Pointer = pointer
+		response.Pointer = &pointer
+	case ControlPause:
+		s.inputPaused.Store(cmd.Enabled)
+		if cmd.Enabled {
+			s.releaseInput("the client paused its input")
+		}
+		log.Printf("Input paused: %v", cmd.Enabled)
+		s.currentSession.InputPaused = cmd.Enabled
+		paused := cmd.Enabled
+		response.Paused = &paused
+	default:
+		return response, ErrUnknownControlCommand
+	}
+
+	response.Success = true
+	return response, nil
+}
+
+// screenshot takes a screenshot for ControlScreenshot
+func (s *sessionService) screenshot() (ControlResponse, error) {
+	response := ControlResponse{Type: ControlScreenshot}
+	screenshot, err := s.takeScreenshot()
+	if errors.Is(err, ErrNoActiveSession) {
+		return response, err
+	}
+	if err != nil {
+		log.Printf("failed to take a screenshot: %v", err)
+		return response, ErrFailedToTakeScreenshot
+	}
+
+	response.Screenshot = screenshot
+	response.Success = true
+	return response, nil
+}
+
+// handleControlMessage handles a control command received on the data
+// channel and returns the JSON reply. A screenshot is replied to with
+// SendControl once it is stored, the data channel is not blocked meanwhile.
+func (s *sessionService) handleControlMessage(message []byte) []byte {
+	var cmd ControlCommand
+	if err := json.Unmarshal(message, &cmd); err != nil {
+		return controlReply(ControlResponse{}, ErrInvalidControlCommand)
+	}
+
+	if cmd.Type == ControlScreenshot {
+		go s.sendScreenshot()
+		return nil
+	}
+	return controlReply(s.HandleControlCommand(cmd))
+}
+
+// sendScreenshot takes a screenshot and sends the reply to the client
+func (s *sessionService) sendScreenshot() {
+	reply := controlReply(s.screenshot())
+
+	s.mu.Lock()
+	streamer := s.webrtcStreamer
+	s.mu.Unlock()
+
+	if streamer == nil {
+		return
+	}
+	if err := streamer.SendControl(reply); err != nil {
+		log.Printf("failed to send the screenshot to the client: %v", err)
+	}
+}
+
+// controlReply returns the JSON reply to a control command
+func controlReply(response ControlResponse, err error) []byte {
+	if err != nil {
+		response.Error = err.Error()
+	}
+
+	reply, _ := json.Marshal(response)
+	return reply
+}
+
+// UpdateVideoConfig replaces the encoder with one for the config. A running
+// session switches to the new encoder on the same WebRTC track, when that is
+// not possible the config applies to the next session.
 func (s *sessionService) UpdateVideoConfig(cfg *video.Config) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	recorder, err := video.NewRecorder(cfg)
+	encoder, source, err := video.NewEncoderForConfig(cfg)
 	if err != nil {
 		//TODO: fix error handling
-		log.Printf("invalid recorder might be a nil reference: %v", err.Error())
+		log.Printf("invalid video config, keeping the current encoder: %v", err.Error())
+		return
+	}
+	if s.videoEncoder != nil {
+		encoder.SetEventHandler(s.videoEncoder.EventHandler())
+	}
+
+	if s.currentSession != nil {
+		if err := s.switchEncoder(encoder, source); err != nil {
+			log.Printf("keeping the current encoder for the running session: %v", err)
+			return
+		}
+	}
+
+	s.videoEncoder = encoder
+	s.captureSource = source
+}
+
+// switchEncoder starts the new encoder on the source of the current session
+// and hands its frames to the streamer, the current encoder is stopped once
+// the stream switched over
+func (s *sessionService) switchEncoder(encoder video.Encoder, source video.CaptureSource) error {
+	session := s.currentSession
+
+	if encoder.Codec() != s.videoEncoder.Codec() {
+		return fmt.Errorf("the track was negotiated for %s, not %s", s.videoEncoder.Codec(), encoder.Codec())
+	}
+	if source.Name() != s.captureSource.Name() {
+		return fmt.Errorf("the capture source changed from %s to %s", s.captureSource.Name(), source.Name())
+	}
+
+	if err := encoder.SetStatsOverlay(session.StatsOverlay); err != nil {
+		return err
+	}
+	stream := encoder.Configure(session.Source, session.RequestedStream)
+	frames, err := encoder.Start(session.Source)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Switching to %s video stream at %s", encoder.Codec(), stream)
+	s.webrtcStreamer.SwitchStream(video.InspectFrames(frames, inspectedFrames, logStreamInfo))
+	if err := s.videoEncoder.Stop(); err != nil {
+		log.Printf("failed to stop the previous encoder: %v", err)
+	}
+
+	session.Stream = stream
+	session.Encoder = encoder.ActiveEncoder()
+	return nil
+}
+
+// encoderChanged records the encoder that took over the stream of the
+// running session and tells the client about it
+func (s *sessionService) encoderChanged() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.currentSession == nil {
+		return
+	}
+
+	encoder := s.videoEncoder.ActiveEncoder()
+	if encoder == s.currentSession.Encoder {
+		return
+	}
+	log.Printf("Session %s switched from encoder %s to %s", s.currentSession.ID, s.currentSession.Encoder, encoder)
+	s.currentSession.Encoder = encoder
+
+	if s.webrtcStreamer == nil {
+		return
+	}
+	message, _ := json.Marshal(ControlResponse{
+		Type:    ControlEncoderChanged,
+		Success: true,
+		Encoder: encoder,
+	})
+	if err := s.webrtcStreamer.SendControl(message); err != nil {
+		log.Printf("failed to report the encoder change to the client: %v", err)
+	}
+}
+
+// logStreamInfo logs the inspected stream and its problems
+func logStreamInfo(info video.H264StreamInfo) {
+	log.Printf("H.264 stream: %s", info)
+	for _, warning := range info.Warnings {
+		log.Printf("Warning: H.264 stream: %s", warning)
 	}
-	s.videoRecorder = recorder
 }
@@ -4,6 +4,9 @@ package session
 import (
 	"os/exec"
 	"time"
+
+	"github.com/m1thrandir225/imperium/apps/host/internal/input"
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
 )
 
 type Session struct {
@@ -19,4 +22,33 @@ type Session struct {
 	CreatedAt    time.Time `json:"created_at"`
 	Process      *exec.Cmd `json:"-"`
 	WindowTitle  string    `json:"window_title"`
+	// Window is the captured window of the program, nil when the screen is
+	// captured
+	Window  *video.WindowInfo `json:"window,omitempty"`
+	Monitor string            `json:"monitor"`
+	// Region is the crop and the masks applied to the monitor
+	Region video.CaptureRegion `json:"region"`
+	// StatsOverlay is set while the encoder stats are shown in the video
+	StatsOverlay bool `json:"stats_overlay"`
+	// Encoder is the ffmpeg encoder of the stream, it differs from the
+	// configured one after a fallback
+	Encoder string `json:"encoder"`
+	// Pointer is the pointer mode and the scaling of relative moves
+	Pointer input.PointerSettings `json:"pointer"`
+	// InputPolicy holds the blocked keys and the pointer confinement, read
+	// only for spectators
+	InputPolicy input.Policy `json:"input_policy"`
+	// Remap holds the remapped keys and buttons and the macros
+	Remap input.RemapProfile `json:"remap"`
+	// InputRecording is the file the input of the session is recorded to,
+	// empty when it is not recorded
+	InputRecording string `json:"input_recording,omitempty"`
+	// InputPaused is set while the client paused its input
+	InputPaused bool `json:"input_paused"`
+	// Stream holds the effective stream parameters of the session
+	Stream video.StreamParams `json:"stream"`
+	// RequestedStream holds the parameters requested by the client, they
+	// are applied again when the encoder changes
+	RequestedStream video.StreamParams  `json:"-"`
+	Source          video.CaptureSource `json:"-"`
 }
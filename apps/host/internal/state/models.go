@@ -1,6 +1,10 @@
 package state
 
-import "time"
+import (
+	"time"
+
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
+)
 
 // AppState represents the overall persissted state of the application
 type AppState struct {
@@ -42,4 +46,44 @@ type Settings struct {
 	ServerAddress      string   `mapstructure:"server_address" json:"server_address" yaml:"server_address"`
 	CustomProgramPaths []string `mapstructure:"custom_program_paths" json:"custom_program_paths" yaml:"custom_program_paths"`
 	RawgAPIKey         string   `mapstructure:"rawg_api_key" json:"rawg_api_key" yaml:"rawg_api_key"`
+	CaptureSource      string   `mapstructure:"capture_source" json:"capture_source" yaml:"capture_source"`
+	// EncoderProfiles are the named encoder profiles, EncoderProfile is the
+	// name of the one in use
+	EncoderProfiles []video.Profile `mapstructure:"encoder_profiles" json:"encoder_profiles" yaml:"encoder_profiles"`
+	EncoderProfile  string          `mapstructure:"encoder_profile" json:"encoder_profile" yaml:"encoder_profile"`
+	// DisablePreview stops the host from serving a preview of its screen
+	DisablePreview bool `mapstructure:"disable_preview" json:"disable_preview" yaml:"disable_preview"`
+	// RecordInput records the input of every session for debugging, see
+	// the replay subcommand
+	RecordInput bool `mapstructure:"record_input" json:"record_input" yaml:"record_input"`
+}
+
+// ActiveEncoderProfile returns the selected encoder profile. Without a
+// matching named profile the default profile is used, with the legacy
+// Bitrate setting applied when it is set.
+func (s Settings) ActiveEncoderProfile() video.Profile {
+	for _, profile := range s.EncoderProfiles {
+		if profile.Name == s.EncoderProfile {
+			return profile
+		}
+	}
+
+	profile := video.NewDefaultProfile()
+	if bitrate, err := video.ParseBitrate(s.Bitrate); err == nil {
+		profile.Bitrate = bitrate
+		profile.MaxBitrate = bitrate
+		profile.BufferSize = bitrate / 4
+	}
+	return profile
+}
+
+// SetEncoderProfile adds or replaces the named encoder profile
+func (s *Settings) SetEncoderProfile(profile video.Profile) {
+	for i := range s.EncoderProfiles {
+		if s.EncoderProfiles[i].Name == profile.Name {
+			s.EncoderProfiles[i] = profile
+			return
+		}
+	}
+	s.EncoderProfiles = append(s.EncoderProfiles, profile)
 }
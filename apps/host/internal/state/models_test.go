@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
 	"github.com/stretchr/testify/require"
 )
 
@@ -71,3 +72,25 @@ func TestSettings_DefaultValues(t *testing.T) {
 	require.Equal(t, "https://api.example.com", settings.ServerAddress)
 	require.Equal(t, "/usr/bin/ffmpeg", settings.FFmpegPath)
 }
+
+func TestSettings_ActiveEncoderProfile(t *testing.T) {
+	settings := Settings{Bitrate: "6M"}
+
+	profile := settings.ActiveEncoderProfile()
+	require.Equal(t, video.NewDefaultProfile().Name, profile.Name)
+	require.Equal(t, 6000, profile.Bitrate)
+	require.Equal(t, 6000, profile.MaxBitrate)
+
+	settings.SetEncoderProfile(video.Profile{Name: "quality", RateControl: video.RateControlCQ, Quality: 18})
+	settings.SetEncoderProfile(video.Profile{Name: "lan", Bitrate: 20000})
+	settings.SetEncoderProfile(video.Profile{Name: "quality", RateControl: video.RateControlCQ, Quality: 20})
+	require.Len(t, settings.EncoderProfiles, 2)
+
+	settings.EncoderProfile = "quality"
+	profile = settings.ActiveEncoderProfile()
+	require.Equal(t, "quality", profile.Name)
+	require.Equal(t, 20, profile.Quality)
+
+	settings.EncoderProfile = "missing"
+	require.Equal(t, video.NewDefaultProfile().Name, settings.ActiveEncoderProfile().Name)
+}
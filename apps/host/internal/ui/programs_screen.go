@@ -1,9 +1,12 @@
 package ui
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -11,17 +14,23 @@ import (
 	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/widget"
 	uapp "github.com/m1thrandir225/imperium/apps/host/internal/app"
+	"github.com/m1thrandir225/imperium/apps/host/internal/input"
 	"github.com/m1thrandir225/imperium/apps/host/internal/state"
 	"github.com/m1thrandir225/imperium/apps/host/internal/util"
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
 )
 
 type ProgramsScreen struct {
 	manager      *uiManager
 	programsList *widget.List
 	programs     []uapp.ProgramItem
+	monitors     []*video.MonitorInfo
 	subscribed   bool
 }
 
+// primaryMonitorOption is the monitor option for an empty default monitor
+const primaryMonitorOption = "Primary monitor"
+
 func NewProgramsScreen(manager *uiManager) *ProgramsScreen {
 	return &ProgramsScreen{
 		manager: manager,
@@ -44,6 +53,7 @@ func (s *ProgramsScreen) Render(w fyne.Window) fyne.CanvasObject {
 					continue
 				}
 				s.programs = payload.Programs
+				s.monitors = payload.Monitors
 				if s.programsList != nil {
 					fyne.Do(func() { s.programsList.Refresh() })
 				}
@@ -58,6 +68,10 @@ func (s *ProgramsScreen) Render(w fyne.Window) fyne.CanvasObject {
 			return container.NewHBox(
 				widget.NewLabel("Program Name"),
 				widget.NewLabel("Path"),
+				widget.NewSelect(nil, nil),
+				widget.NewButton("Region", nil),
+				widget.NewButton("Input", nil),
+				widget.NewButton("Remap", nil),
 			)
 		},
 		func(id widget.ListItemID, obj fyne.CanvasObject) {
@@ -66,9 +80,44 @@ func (s *ProgramsScreen) Render(w fyne.Window) fyne.CanvasObject {
 
 			nameLabel := box.Objects[0].(*widget.Label)
 			pathLabel := box.Objects[1].(*widget.Label)
+			monitorSelect := box.Objects[2].(*widget.Select)
+			regionBtn := box.Objects[3].(*widget.Button)
+			inputBtn := box.Objects[4].(*widget.Button)
+			remapBtn := box.Objects[5].(*widget.Button)
 
 			nameLabel.SetText(program.Name)
 			pathLabel.SetText(util.ShortPath(program.Path))
+
+			// the row widgets are reused, reset the callback before selecting
+			monitorSelect.OnChanged = nil
+			monitorSelect.Options = s.monitorOptions(program.DefaultMonitor)
+			monitorSelect.SetSelected(s.monitorOption(program.DefaultMonitor))
+			monitorSelect.OnChanged = func(option string) {
+				monitor := s.monitorSelector(option)
+				if monitor == s.programs[id].DefaultMonitor {
+					return
+				}
+				s.programs[id].DefaultMonitor = monitor
+				s.manager.publish(uapp.EventProgramMonitorRequested, uapp.ProgramMonitorRequestedPayload{
+					ProgramID: s.programs[id].ID,
+					Monitor:   monitor,
+				})
+			}
+
+			regionBtn.SetText(regionButtonText(program.CaptureRegion))
+			regionBtn.OnTapped = func() {
+				s.showRegionDialog(id)
+			}
+
+			inputBtn.SetText(inputButtonText(program.InputPolicy))
+			inputBtn.OnTapped = func() {
+				s.showInputPolicyDialog(id)
+			}
+
+			remapBtn.SetText(remapButtonText(program.RemapProfile))
+			remapBtn.OnTapped = func() {
+				s.showRemapDialog(id)
+			}
 		},
 	)
 
@@ -159,3 +208,211 @@ func (s *ProgramsScreen) Render(w fyne.Window) fyne.CanvasObject {
 
 	return content
 }
+
+// showRegionDialog edits the capture region of the program, one rule per
+// line in the format of video.ParseCaptureRegion
+func (s *ProgramsScreen) showRegionDialog(id widget.ListItemID) {
+	program := s.programs[id]
+
+	entry := widget.NewMultiLineEntry()
+	entry.SetText(program.CaptureRegion.String())
+	entry.SetPlaceHolder("crop 1920x1080+0+0\nmask 400x300+1500+20\nmask window Discord")
+	entry.SetMinRowsVisible(6)
+
+	hint := widget.NewLabel("Coordinates are relative to the captured monitor. " +
+		"Masks black out a rectangle, or every window whose title contains the text.")
+	hint.Wrapping = fyne.TextWrapWord
+
+	content := container.NewBorder(hint, nil, nil, nil, entry)
+	d := dialog.NewCustomConfirm(fmt.Sprintf("Capture region of %s", program.Name), "Save", "Cancel", content,
+		func(save bool) {
+			if !save {
+				return
+			}
+			region, err := video.ParseCaptureRegion(entry.Text)
+			if err != nil {
+				dialog.ShowError(err, s.manager.window)
+				return
+			}
+			s.programs[id].CaptureRegion = region
+			s.manager.publish(uapp.EventProgramRegionRequested, uapp.ProgramRegionRequestedPayload{
+				ProgramID: program.ID,
+				Region:    region,
+			})
+			s.programsList.RefreshItem(id)
+		}, s.manager.window)
+	d.Resize(fyne.NewSize(480, 320))
+	d.Show()
+}
+
+// showInputPolicyDialog edits the input policy of the program, the
+// blocked key combinations are entered one per line
+func (s *ProgramsScreen) showInputPolicyDialog(id widget.ListItemID) {
+	program := s.programs[id]
+	policy := input.DefaultPolicy()
+	if program.InputPolicy != nil {
+		policy = *program.InputPolicy
+	}
+
+	combos := widget.NewMultiLineEntry()
+	combos.SetText(strings.Join(policy.BlockedCombos, "\n"))
+	combos.SetPlaceHolder("Alt+F4\nControl+Escape\nMeta")
+	combos.SetMinRowsVisible(6)
+
+	confine := widget.NewCheck("Confine the pointer to the captured window or monitor", nil)
+	confine.SetChecked(policy.ConfinePointer)
+	readOnly := widget.NewCheck("Read only, drop all input", nil)
+	readOnly.SetChecked(policy.ReadOnly)
+
+	hint := widget.NewLabel("Blocked key combinations use key codes like KeyA, F4 or Escape. " +
+		"Control, Shift, Alt and Meta match the left and the right key.")
+	hint.Wrapping = fyne.TextWrapWord
+
+	content := container.NewBorder(hint, container.NewVBox(confine, readOnly), nil, nil, combos)
+	d := dialog.NewCustomConfirm(fmt.Sprintf("Input of %s", program.Name), "Save", "Cancel", content,
+		func(save bool) {
+			if !save {
+				return
+			}
+			policy.BlockedCombos = nil
+			for _, line := range strings.Split(combos.Text, "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					policy.BlockedCombos = append(policy.BlockedCombos, line)
+				}
+			}
+			policy.ConfinePointer = confine.Checked
+			policy.ReadOnly = readOnly.Checked
+			if err := policy.Validate(); err != nil {
+				dialog.ShowError(err, s.manager.window)
+				return
+			}
+
+			s.programs[id].InputPolicy = &policy
+			s.manager.publish(uapp.EventProgramInputRequested, uapp.ProgramInputPolicyRequestedPayload{
+				ProgramID: program.ID,
+				Policy:    &policy,
+			})
+			s.programsList.RefreshItem(id)
+		}, s.manager.window)
+	d.Resize(fyne.NewSize(480, 380))
+	d.Show()
+}
+
+// showRemapDialog edits the remap profile of the program, one rule per
+// line in the format of input.ParseRemapProfile
+func (s *ProgramsScreen) showRemapDialog(id widget.ListItemID) {
+	program := s.programs[id]
+
+	entry := widget.NewMultiLineEntry()
+	entry.SetText(program.RemapProfile.String())
+	entry.SetPlaceHolder("key CapsLock ControlLeft\nbutton back KeyE\nmacro F1 KeyH 50ms KeyI")
+	entry.SetMinRowsVisible(6)
+
+	hint := widget.NewLabel("Keys use key codes like KeyA, F4 or Escape, buttons are left, right, middle, " +
+		"back and forward. Macro steps tap a key, press it with +, release it with - or wait like 50ms.")
+	hint.Wrapping = fyne.TextWrapWord
+
+	content := container.NewBorder(hint, nil, nil, nil, entry)
+	d := dialog.NewCustomConfirm(fmt.Sprintf("Remapping of %s", program.Name), "Save", "Cancel", content,
+		func(save bool) {
+			if !save {
+				return
+			}
+			profile, err := input.ParseRemapProfile(entry.Text)
+			if err != nil {
+				dialog.ShowError(err, s.manager.window)
+				return
+			}
+			s.programs[id].RemapProfile = profile
+			s.manager.publish(uapp.EventProgramRemapRequested, uapp.ProgramRemapRequestedPayload{
+				ProgramID: program.ID,
+				Profile:   profile,
+			})
+			s.programsList.RefreshItem(id)
+		}, s.manager.window)
+	d.Resize(fyne.NewSize(480, 340))
+	d.Show()
+}
+
+// remapButtonText returns the label of the remap button of a program
+func remapButtonText(profile input.RemapProfile) string {
+	if profile.IsEmpty() {
+		return "No remapping"
+	}
+	return fmt.Sprintf("%d remapped, %d macros", len(profile.Keys)+len(profile.Buttons), len(profile.Macros))
+}
+
+// inputButtonText returns the label of the input button of a program
+func inputButtonText(policy *input.Policy) string {
+	switch {
+	case policy == nil:
+		return "Default input"
+	case policy.ReadOnly:
+		return "Read only"
+	case policy.ConfinePointer:
+		return fmt.Sprintf("%d blocked, confined", len(policy.BlockedCombos))
+	}
+	return fmt.Sprintf("%d blocked", len(policy.BlockedCombos))
+}
+
+// regionButtonText returns the label of the region button of a program
+func regionButtonText(region video.CaptureRegion) string {
+	if region.IsEmpty() {
+		return "Full monitor"
+	}
+	if region.Crop == nil {
+		return fmt.Sprintf("%d masks", len(region.Masks))
+	}
+	return fmt.Sprintf("Crop %dx%d, %d masks", region.Crop.Width, region.Crop.Height, len(region.Masks))
+}
+
+// monitorOptions returns the options of the default monitor select, a
+// default monitor that is not connected right now is kept as an option
+func (s *ProgramsScreen) monitorOptions(current string) []string {
+	options := []string{primaryMonitorOption}
+	for _, monitor := range s.monitors {
+		options = append(options, monitorLabel(monitor))
+	}
+	if option := s.monitorOption(current); !slices.Contains(options, option) {
+		options = append(options, option)
+	}
+	return options
+}
+
+// monitorOption returns the select option for a default monitor
+func (s *ProgramsScreen) monitorOption(selector string) string {
+	if selector == "" {
+		return primaryMonitorOption
+	}
+	if monitor, err := video.FindMonitor(s.monitors, selector); err == nil {
+		return monitorLabel(monitor)
+	}
+	return selector
+}
+
+// monitorSelector returns the default monitor stored for a select option,
+// monitors are stored by name when they have one so a changed order keeps
+// the selection
+func (s *ProgramsScreen) monitorSelector(option string) string {
+	if option == primaryMonitorOption {
+		return ""
+	}
+	for _, monitor := range s.monitors {
+		if monitorLabel(monitor) != option {
+			continue
+		}
+		if monitor.Name != "" {
+			return monitor.Name
+		}
+		return strconv.Itoa(monitor.Index)
+	}
+	return option
+}
+
+func monitorLabel(monitor *video.MonitorInfo) string {
+	name := monitor.Name
+	if name == "" {
+		name = fmt.Sprintf("Monitor %d", monitor.Index+1)
+	}
+	return fmt.Sprintf("%s (%dx%d)", name, monitor.Width, monitor.Height)
+}
@@ -17,7 +17,12 @@ import (
 )
 
 type SettingsScreen struct {
-	manager *uiManager
+	manager          *uiManager
+	window           fyne.Window
+	encoderSelect    *widget.Select
+	fallbackEncoders []string
+	subscribed       bool
+	notifyProbe      bool
 }
 
 func NewSettingsScreen(manager *uiManager) *SettingsScreen {
@@ -31,6 +36,21 @@ func (s *SettingsScreen) Name() string {
 }
 
 func (s *SettingsScreen) Render(w fyne.Window) fyne.CanvasObject {
+	if !s.subscribed {
+		ch := s.manager.bus.Subscribe(uapp.EventEncodersProbed)
+		s.subscribed = true
+
+		go func() {
+			for evt := range ch {
+				payload, ok := evt.(uapp.EncodersProbedPayload)
+				if !ok {
+					continue
+				}
+				fyne.Do(func() { s.applyEncoderProbe(payload) })
+			}
+		}()
+	}
+
 	current := s.manager.getState().Settings
 
 	// Server Address Section
@@ -74,65 +94,96 @@ func (s *SettingsScreen) Render(w fyne.Window) fyne.CanvasObject {
 		encoderSelect.SetSelected("libx264") // Default
 	}
 
-	// Function to load available encoders
-	loadAvailableEncoders := func() {
-		h264Encoders, h265Encoders, err := video.GetAvailableEncodersForCodecs()
-		if err != nil {
-			// UI updates must be on main thread
-			fyne.Do(func() {
-				dialog.ShowError(fmt.Errorf("failed to detect encoders: %v", err), w)
-			})
-			return
-		}
+	s.encoderSelect = encoderSelect
+	s.fallbackEncoders = fallbackEncoders
+	s.window = w
 
-		availableEncoders := make([]string, 0)
-		availableEncoders = append(availableEncoders, h264Encoders...)
-		availableEncoders = append(availableEncoders, h265Encoders...)
-
-		fyne.Do(func() {
-			if len(availableEncoders) > 0 {
-				currentSelection := encoderSelect.Selected
+	// Auto-load encoders on startup if FFmpeg path is available
+	if current.FFmpegPath != "" {
+		s.requestEncoderProbe(current.FFmpegPath, false, false)
+	}
 
-				encoderSelect.Options = availableEncoders
+	// Detect encoders button, runs real test encodes with every encoder
+	detectEncodersBtn := widget.NewButton("Detect Available Encoders", func() {
+		if ffmpegPathEntry.Text == "" {
+			dialog.ShowError(fmt.Errorf("please set FFmpeg path first"), w)
+			return
+		}
+		s.requestEncoderProbe(ffmpegPathEntry.Text, true, true)
+	})
 
-				found := false
-				if slices.Contains(availableEncoders, currentSelection) {
-					encoderSelect.SetSelected(currentSelection)
-					found = true
-				}
+	// Encoder profile selection, profiles are stored in the settings file.
+	// Edited profiles are saved with the settings.
+	defaultProfileName := video.NewDefaultProfile().Name
+	profiles := slices.Clone(current.EncoderProfiles)
+	var editedProfiles []video.Profile
+	profileOptions := []string{defaultProfileName}
+	for _, profile := range profiles {
+		if profile.Name != defaultProfileName {
+			profileOptions = append(profileOptions, profile.Name)
+		}
+	}
+	profileSelect := widget.NewSelect(profileOptions, nil)
+	if slices.Contains(profileOptions, current.EncoderProfile) {
+		profileSelect.SetSelected(current.EncoderProfile)
+	} else {
+		profileSelect.SetSelected(defaultProfileName)
+	}
 
-				if !found && len(availableEncoders) > 0 {
-					encoderSelect.SetSelected(availableEncoders[0])
-				}
+	saveProfile := func(profile video.Profile) {
+		editedProfiles = append(editedProfiles, profile)
+		if i := slices.IndexFunc(profiles, func(p video.Profile) bool { return p.Name == profile.Name }); i >= 0 {
+			profiles[i] = profile
+		} else {
+			profiles = append(profiles, profile)
+		}
+		if !slices.Contains(profileSelect.Options, profile.Name) {
+			profileSelect.Options = append(profileSelect.Options, profile.Name)
+		}
+		profileSelect.SetSelected(profile.Name)
+		profileSelect.Refresh()
+	}
 
-				encoderSelect.Refresh()
-				dialog.ShowInformation("Success",
-					fmt.Sprintf("Detected %d H.264 encoders and %d H.265 encoders",
-						len(h264Encoders), len(h265Encoders)), w)
-			} else {
-				encoderSelect.Options = fallbackEncoders
-				encoderSelect.SetSelected("libx264")
-				encoderSelect.Refresh()
-				dialog.ShowInformation("No Hardware Encoders",
-					"No hardware encoders detected. Using software fallbacks.", w)
+	editProfileBtn := widget.NewButton("Edit", func() {
+		profile := video.NewDefaultProfile()
+		if i := slices.IndexFunc(profiles, func(p video.Profile) bool { return p.Name == profileSelect.Selected }); i >= 0 {
+			profile = profiles[i]
+		}
+		s.showProfileDialog(profile, false, saveProfile)
+	})
+	newProfileBtn := widget.NewButton("New", func() {
+		profile := video.NewDefaultProfile()
+		profile.Name = ""
+		s.showProfileDialog(profile, true, func(profile video.Profile) {
+			if slices.Contains(profileSelect.Options, profile.Name) {
+				dialog.ShowError(fmt.Errorf("a profile named %q exists already", profile.Name), w)
+				return
 			}
+			saveProfile(profile)
 		})
-	}
+	})
 
-	// Auto-load encoders on startup if FFmpeg path is available
-	if current.FFmpegPath != "" {
-		go loadAvailableEncoders() // Run in background to avoid blocking UI
+	// Capture source, the test pattern works without a display or GPU
+	sourceSelect := widget.NewSelect([]string{
+		string(video.SourceModeScreen),
+		string(video.SourceModeTestPattern),
+	}, nil)
+	if current.CaptureSource != "" {
+		sourceSelect.SetSelected(current.CaptureSource)
+	} else {
+		sourceSelect.SetSelected(string(video.SourceModeScreen))
 	}
 
-	// Detect encoders button
-	detectEncodersBtn := widget.NewButton("Detect Available Encoders", func() {
-		loadAvailableEncoders()
-	})
-
 	fpsOptions := []string{"30", "60", "90", "120"}
 	fpsSelect := widget.NewSelect(fpsOptions, nil)
 	fpsSelect.SetSelected(fmt.Sprintf("%d", current.Framerate))
 
+	previewCheck := widget.NewCheck("Serve a preview of the screen to clients", nil)
+	previewCheck.SetChecked(!current.DisablePreview)
+
+	recordCheck := widget.NewCheck("Record the input of sessions for debugging", nil)
+	recordCheck.SetChecked(current.RecordInput)
+
 	// Validation functions
 	validateServerAddress := func(address string) error {
 		if address == "" {
@@ -176,13 +227,20 @@ func (s *SettingsScreen) Render(w fyne.Window) fyne.CanvasObject {
 			return
 		}
 
+		disablePreview := !previewCheck.Checked
+		recordInput := recordCheck.Checked
 		s.manager.publish(uapp.EventSettingsSaved, uapp.SettingsSavedPayload{
 			Settings: state.Settings{
-				FFmpegPath:    ffmpegPathEntry.Text,
-				ServerAddress: serverAddressEntry.Text,
-				Encoder:       encoderSelect.Selected,
-				Framerate:     fps,
+				FFmpegPath:      ffmpegPathEntry.Text,
+				ServerAddress:   serverAddressEntry.Text,
+				Encoder:         encoderSelect.Selected,
+				Framerate:       fps,
+				EncoderProfile:  profileSelect.Selected,
+				EncoderProfiles: editedProfiles,
+				CaptureSource:   sourceSelect.Selected,
 			},
+			DisablePreview: &disablePreview,
+			RecordInput:    &recordInput,
 		})
 
 		dialog.ShowInformation("Success", "Settings saved successfully!", w)
@@ -197,6 +255,10 @@ func (s *SettingsScreen) Render(w fyne.Window) fyne.CanvasObject {
 				encoderSelect.Options = fallbackEncoders
 				encoderSelect.SetSelected("libx264")
 				fpsSelect.SetSelected("30")
+				profileSelect.SetSelected(defaultProfileName)
+				sourceSelect.SetSelected(string(video.SourceModeScreen))
+				previewCheck.SetChecked(true)
+				recordCheck.SetChecked(false)
 				encoderSelect.Refresh()
 			}
 		}, w)
@@ -256,10 +318,26 @@ func (s *SettingsScreen) Render(w fyne.Window) fyne.CanvasObject {
 		widget.NewLabel("Video Encoder:"),
 		encoderSelect,
 
+		widget.NewLabel("Encoder Profile:"),
+		container.NewBorder(nil, nil, nil, container.NewHBox(editProfileBtn, newProfileBtn), profileSelect),
+
+		widget.NewLabel("Capture Source:"),
+		sourceSelect,
+
 		widget.NewLabel("FPS (Frames Per Second):"),
 		fpsSelect,
 		widget.NewSeparator(),
 
+		// Privacy Section
+		widget.NewLabel("Privacy"),
+		previewCheck,
+		widget.NewSeparator(),
+
+		// Debugging Section
+		widget.NewLabel("Debugging"),
+		recordCheck,
+		widget.NewSeparator(),
+
 		// Action buttons
 		container.NewHBox(saveBtn, resetBtn),
 		backBtn,
@@ -267,3 +345,189 @@ func (s *SettingsScreen) Render(w fyne.Window) fyne.CanvasObject {
 
 	return container.NewScroll(container.NewPadded(form))
 }
+
+// showProfileDialog edits an encoder profile, empty numbers use the
+// defaults of video.Profile.WithDefaults. The name of an edited profile is
+// kept.
+func (s *SettingsScreen) showProfileDialog(profile video.Profile, create bool, onSave func(video.Profile)) {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(profile.Name)
+	nameEntry.SetPlaceHolder("e.g. low-bandwidth")
+	if !create {
+		nameEntry.Disable()
+	}
+
+	rateControlSelect := widget.NewSelect([]string{
+		string(video.RateControlCBR), string(video.RateControlVBR), string(video.RateControlCQ),
+	}, nil)
+	rateControlSelect.SetSelected(string(profile.RateControl))
+	presetSelect := widget.NewSelect([]string{
+		string(video.PresetFastest), string(video.PresetFast), string(video.PresetBalanced), string(video.PresetQuality),
+	}, nil)
+	presetSelect.SetSelected(string(profile.Preset))
+	colorRangeSelect := widget.NewSelect([]string{
+		string(video.ColorRangeLimited), string(video.ColorRangeFull),
+	}, nil)
+	colorRangeSelect.SetSelected(string(profile.ColorRange))
+
+	numberEntry := func(value int) *widget.Entry {
+		entry := widget.NewEntry()
+		entry.SetPlaceHolder("default")
+		if value != 0 {
+			entry.SetText(strconv.Itoa(value))
+		}
+		return entry
+	}
+	bitrateEntry := numberEntry(profile.Bitrate)
+	maxBitrateEntry := numberEntry(profile.MaxBitrate)
+	bufferSizeEntry := numberEntry(profile.BufferSize)
+	qualityEntry := numberEntry(profile.Quality)
+	keyframeEntry := numberEntry(profile.KeyframeInterval)
+	widthEntry := numberEntry(profile.Width)
+	heightEntry := numberEntry(profile.Height)
+
+	form := widget.NewForm(
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Rate control", rateControlSelect),
+		widget.NewFormItem("Bitrate (kbit/s)", bitrateEntry),
+		widget.NewFormItem("Max bitrate (kbit/s)", maxBitrateEntry),
+		widget.NewFormItem("Buffer size (kbit)", bufferSizeEntry),
+		widget.NewFormItem("Quality (cq)", qualityEntry),
+		widget.NewFormItem("Keyframe interval", keyframeEntry),
+		widget.NewFormItem("Preset", presetSelect),
+		widget.NewFormItem("Width", widthEntry),
+		widget.NewFormItem("Height", heightEntry),
+		widget.NewFormItem("Color range", colorRangeSelect),
+	)
+
+	title := "Edit Encoder Profile"
+	if create {
+		title = "New Encoder Profile"
+	}
+	d := dialog.NewCustomConfirm(title, "Save", "Cancel", form, func(save bool) {
+		if !save {
+			return
+		}
+
+		edited := video.Profile{
+			Name:        strings.TrimSpace(nameEntry.Text),
+			RateControl: video.RateControl(rateControlSelect.Selected),
+			Preset:      video.Preset(presetSelect.Selected),
+			ColorRange:  video.ColorRange(colorRangeSelect.Selected),
+		}
+		if edited.Name == "" {
+			dialog.ShowError(fmt.Errorf("the profile needs a name"), s.window)
+			return
+		}
+
+		numbers := []struct {
+			label string
+			entry *widget.Entry
+			value *int
+		}{
+			{"bitrate", bitrateEntry, &edited.Bitrate},
+			{"max bitrate", maxBitrateEntry, &edited.MaxBitrate},
+			{"buffer size", bufferSizeEntry, &edited.BufferSize},
+			{"quality", qualityEntry, &edited.Quality},
+			{"keyframe interval", keyframeEntry, &edited.KeyframeInterval},
+			{"width", widthEntry, &edited.Width},
+			{"height", heightEntry, &edited.Height},
+		}
+		for _, number := range numbers {
+			text := strings.TrimSpace(number.entry.Text)
+			if text == "" {
+				continue
+			}
+			value, err := strconv.Atoi(text)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("invalid %s %q", number.label, text), s.window)
+				return
+			}
+			*number.value = value
+		}
+
+		fps := s.manager.getState().Settings.Framerate
+		if err := edited.WithDefaults(fps).Validate(); err != nil {
+			dialog.ShowError(err, s.window)
+			return
+		}
+		onSave(edited)
+	}, s.window)
+	d.Resize(fyne.NewSize(480, 560))
+	d.Show()
+}
+
+// requestEncoderProbe asks the app to probe the encoders of the given ffmpeg
+// build, the result arrives via EventEncodersProbed
+func (s *SettingsScreen) requestEncoderProbe(ffmpegPath string, force, notify bool) {
+	s.notifyProbe = notify
+	if notify {
+		dialog.ShowInformation("Detecting Encoders",
+			"Running a short test encode with every encoder, this can take a few seconds.", s.window)
+	}
+
+	s.manager.publish(uapp.EventEncodersProbeRequested, uapp.EncodersProbeRequestedPayload{
+		FFmpegPath: ffmpegPath,
+		Force:      force,
+	})
+}
+
+// applyEncoderProbe limits the encoder selection to the encoders that
+// passed their test encode
+func (s *SettingsScreen) applyEncoderProbe(payload uapp.EncodersProbedPayload) {
+	notify := s.notifyProbe
+	s.notifyProbe = false
+
+	if s.encoderSelect == nil {
+		return
+	}
+
+	if payload.Err != nil || payload.Probe == nil {
+		if notify {
+			dialog.ShowError(fmt.Errorf("failed to detect encoders: %v", payload.Err), s.window)
+		}
+		return
+	}
+
+	h264Encoders, h265Encoders := payload.Probe.WorkingEncoders()
+	availableEncoders := make([]string, 0, len(h264Encoders)+len(h265Encoders))
+	availableEncoders = append(availableEncoders, h264Encoders...)
+	availableEncoders = append(availableEncoders, h265Encoders...)
+
+	if len(availableEncoders) == 0 {
+		s.encoderSelect.Options = s.fallbackEncoders
+		s.encoderSelect.SetSelected("libx264")
+		s.encoderSelect.Refresh()
+		if notify {
+			dialog.ShowInformation("No Working Encoders",
+				"No encoder passed the test encode. Using software fallbacks.", s.window)
+		}
+		return
+	}
+
+	currentSelection := s.encoderSelect.Selected
+	s.encoderSelect.Options = availableEncoders
+	if slices.Contains(availableEncoders, currentSelection) {
+		s.encoderSelect.SetSelected(currentSelection)
+	} else {
+		s.encoderSelect.SetSelected(availableEncoders[0])
+	}
+	s.encoderSelect.Refresh()
+
+	if !notify {
+		return
+	}
+
+	var lines []string
+	for _, result := range payload.Probe.Results {
+		if result.Success {
+			lines = append(lines, fmt.Sprintf("%s: %.1fx realtime", result.Encoder, result.Speed))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s: unavailable", result.Encoder))
+		}
+	}
+
+	dialog.ShowInformation("Success",
+		fmt.Sprintf("Detected %d working H.264 encoders and %d H.265 encoders (FFmpeg %s)\n\n%s",
+			len(h264Encoders), len(h265Encoders), payload.Probe.FFmpegVersion, strings.Join(lines, "\n")), s.window)
+}
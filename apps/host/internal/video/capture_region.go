@@ -0,0 +1,318 @@
+package video
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Rect is a rectangle in pixels, relative to the top left corner of the
+// captured monitor
+type Rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// String returns the rectangle as an X11 geometry, WxH+X+Y
+func (r Rect) String() string {
+	return fmt.Sprintf("%dx%d%+d%+d", r.Width, r.Height, r.X, r.Y)
+}
+
+// Empty reports whether the rectangle has no area
+func (r Rect) Empty() bool {
+	return r.Width <= 0 || r.Height <= 0
+}
+
+// intersect returns the part of the rectangle inside bounds
+func (r Rect) intersect(bounds Rect) Rect {
+	x0, y0 := max(r.X, bounds.X), max(r.Y, bounds.Y)
+	x1 := min(r.X+r.Width, bounds.X+bounds.Width)
+	y1 := min(r.Y+r.Height, bounds.Y+bounds.Height)
+	if x1 <= x0 || y1 <= y0 {
+		return Rect{}
+	}
+	return Rect{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}
+}
+
+// Mask is a rectangle that is blacked out. A mask with a window title
+// covers every window whose title contains it instead, and follows the
+// windows when they are moved.
+type Mask struct {
+	Rect
+	Window string `json:"window,omitempty"`
+}
+
+// String returns the mask as a line of ParseCaptureRegion
+func (m Mask) String() string {
+	if m.Window != "" {
+		return "mask window " + m.Window
+	}
+	return "mask " + m.Rect.String()
+}
+
+// CaptureRegion limits what is visible of a captured monitor: the capture
+// is cropped to Crop and the masks are blacked out. The zero value
+// captures the whole monitor.
+type CaptureRegion struct {
+	Crop  *Rect  `json:"crop,omitempty"`
+	Masks []Mask `json:"masks,omitempty"`
+}
+
+// IsEmpty reports whether the region leaves the capture as is
+func (r CaptureRegion) IsEmpty() bool {
+	return r.Crop == nil && len(r.Masks) == 0
+}
+
+// Validate checks the rectangles of the region
+func (r CaptureRegion) Validate() error {
+	if r.Crop != nil && (r.Crop.Empty() || r.Crop.X < 0 || r.Crop.Y < 0) {
+		return fmt.Errorf("%w: crop %s", ErrInvalidRegion, r.Crop)
+	}
+	for _, mask := range r.Masks {
+		if mask.Window == "" && mask.Empty() {
+			return fmt.Errorf("%w: %s", ErrInvalidRegion, mask)
+		}
+	}
+	return nil
+}
+
+// String returns the region in the text format of ParseCaptureRegion
+func (r CaptureRegion) String() string {
+	var lines []string
+	if r.Crop != nil {
+		lines = append(lines, "crop "+r.Crop.String())
+	}
+	for _, mask := range r.Masks {
+		lines = append(lines, mask.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ParseCaptureRegion parses a region with one rule per line, rectangles
+// are X11 geometries:
+//
+//	crop 1920x1080+0+0
+//	mask 400x300+1500+20
+//	mask window Discord
+//
+// Empty lines and lines starting with # are skipped.
+func ParseCaptureRegion(text string) (CaptureRegion, error) {
+	var region CaptureRegion
+
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kind, value, _ := strings.Cut(line, " ")
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(kind) {
+		case "crop":
+			if region.Crop != nil {
+				return CaptureRegion{}, fmt.Errorf("%w: line %d: more than one crop", ErrInvalidRegion, i+1)
+			}
+			rect, err := parseGeometry(value)
+			if err != nil {
+				return CaptureRegion{}, fmt.Errorf("%w: line %d: %v", ErrInvalidRegion, i+1, err)
+			}
+			region.Crop = &rect
+		case "mask":
+			if title, ok := strings.CutPrefix(value, "window "); ok {
+				if title = strings.TrimSpace(title); title != "" {
+					region.Masks = append(region.Masks, Mask{Window: title})
+					continue
+				}
+			}
+			rect, err := parseGeometry(value)
+			if err != nil {
+				return CaptureRegion{}, fmt.Errorf("%w: line %d: %v", ErrInvalidRegion, i+1, err)
+			}
+			region.Masks = append(region.Masks, Mask{Rect: rect})
+		default:
+			return CaptureRegion{}, fmt.Errorf("%w: line %d: unknown rule %q", ErrInvalidRegion, i+1, kind)
+		}
+	}
+
+	return region, region.Validate()
+}
+
+// parseGeometry parses a WxH+X+Y geometry, the offsets are optional
+func parseGeometry(value string) (Rect, error) {
+	var rect Rect
+	size, offset, _ := strings.Cut(value, "+")
+
+	if n, err := fmt.Sscanf(size, "%dx%d", &rect.Width, &rect.Height); err != nil || n != 2 {
+		return Rect{}, fmt.Errorf("invalid geometry %q, expected WxH+X+Y", value)
+	}
+	if offset != "" {
+		if n, err := fmt.Sscanf(offset, "%d+%d", &rect.X, &rect.Y); err != nil || n != 2 {
+			return Rect{}, fmt.Errorf("invalid geometry %q, expected WxH+X+Y", value)
+		}
+	}
+	if rect.Empty() {
+		return Rect{}, fmt.Errorf("geometry %q has no area", value)
+	}
+	return rect, nil
+}
+
+// regionSetting holds the region of a screen source, it is shared by the
+// copies of the source so it can be changed during a recording
+type regionSetting struct {
+	mu     sync.Mutex
+	region CaptureRegion
+}
+
+func (s *regionSetting) get() CaptureRegion {
+	if s == nil {
+		return CaptureRegion{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.region
+}
+
+func (s *regionSetting) set(region CaptureRegion) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.region = region
+}
+
+// WithRegion returns the screen source limited to the region, any other
+// source is returned as is
+func WithRegion(source CaptureSource, region CaptureRegion) CaptureSource {
+	screen, ok := source.(screenSource)
+	if !ok {
+		return source
+	}
+	screen.region = &regionSetting{region: region}
+	return screen
+}
+
+// SetRegion changes the region of a source returned by WithRegion, a
+// running recording picks it up on its next restart, see
+// Encoder.Reconfigure
+func SetRegion(source CaptureSource, region CaptureRegion) error {
+	if err := region.Validate(); err != nil {
+		return err
+	}
+
+	screen, ok := source.(screenSource)
+	if !ok || screen.region == nil {
+		return fmt.Errorf("%w: %s", ErrRegionNotSupported, source.Name())
+	}
+	screen.region.set(region)
+	return nil
+}
+
+// RegionOf returns the region of the source, the zero value for sources
+// without one
+func RegionOf(source CaptureSource) CaptureRegion {
+	if screen, ok := source.(screenSource); ok {
+		return screen.region.get()
+	}
+	return CaptureRegion{}
+}
+
+// monitorBounds returns the monitor as a rectangle in its own
+// coordinates, an unknown monitor has no bounds
+func monitorBounds(monitor *MonitorInfo) (Rect, bool) {
+	if monitor == nil || monitor.Width <= 0 || monitor.Height <= 0 {
+		return Rect{}, false
+	}
+	return Rect{Width: monitor.Width, Height: monitor.Height}, true
+}
+
+// resolveMasks returns the rectangles blacked out on the monitor, window
+// masks are looked up by their title and moved into the coordinates of the
+// monitor. Masks outside the monitor are dropped.
+func resolveMasks(region CaptureRegion, monitor *MonitorInfo, windows []*WindowInfo) []Rect {
+	bounds, bounded := monitorBounds(monitor)
+
+	var rects []Rect
+	add := func(rect Rect) {
+		if bounded {
+			rect = rect.intersect(bounds)
+		}
+		if !rect.Empty() && !slices.Contains(rects, rect) {
+			rects = append(rects, rect)
+		}
+	}
+
+	for _, mask := range region.Masks {
+		if mask.Window == "" {
+			add(mask.Rect)
+			continue
+		}
+		for _, window := range matchWindows(windows, mask.Window) {
+			rect := Rect{X: window.OffsetX, Y: window.OffsetY, Width: window.Width, Height: window.Height}
+			if monitor != nil {
+				rect.X -= monitor.OffsetX
+				rect.Y -= monitor.OffsetY
+			}
+			add(rect)
+		}
+	}
+	return rects
+}
+
+// resolveCrop returns the crop inside the monitor with an even size, 4:2:0
+// needs even dimensions. Nil means the whole monitor is captured.
+func resolveCrop(region CaptureRegion, monitor *MonitorInfo) (*Rect, error) {
+	if region.Crop == nil {
+		return nil, nil
+	}
+
+	crop := *region.Crop
+	if bounds, ok := monitorBounds(monitor); ok {
+		crop = crop.intersect(bounds)
+	}
+	crop.Width, crop.Height = evenDown(crop.Width), evenDown(crop.Height)
+	if crop.Empty() {
+		return nil, fmt.Errorf("%w: crop %s is outside the monitor", ErrInvalidRegion, region.Crop)
+	}
+	return &crop, nil
+}
+
+// hasWindowMasks reports whether masks of the region follow windows
+func (r CaptureRegion) hasWindowMasks() bool {
+	return slices.ContainsFunc(r.Masks, func(mask Mask) bool { return mask.Window != "" })
+}
+
+// regionWindows returns the windows the masks of the region may follow,
+// nil when it has no window masks or the windows cannot be listed
+func regionWindows(region CaptureRegion) []*WindowInfo {
+	if !region.hasWindowMasks() {
+		return nil
+	}
+	windows, err := ListWindows()
+	if err != nil {
+		log.Printf("Failed to list the windows for the capture masks: %v", err)
+	}
+	return windows
+}
+
+// buildRegionFilters returns the ffmpeg filters that black out the masks
+// and crop the monitor, in the coordinates of the monitor. The masks are
+// drawn first, they are relative to the monitor and not to the crop.
+func buildRegionFilters(region CaptureRegion, monitor *MonitorInfo, windows []*WindowInfo) ([]string, error) {
+	crop, err := resolveCrop(region, monitor)
+	if err != nil {
+		return nil, err
+	}
+
+	var filters []string
+	for _, mask := range resolveMasks(region, monitor, windows) {
+		filters = append(filters, fmt.Sprintf("drawbox=x=%d:y=%d:w=%d:h=%d:color=black:t=fill",
+			mask.X, mask.Y, mask.Width, mask.Height))
+	}
+	if crop != nil {
+		filters = append(filters, fmt.Sprintf("crop=%d:%d:%d:%d", crop.Width, crop.Height, crop.X, crop.Y))
+	}
+	return filters, nil
+}
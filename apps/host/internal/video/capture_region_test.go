@@ -0,0 +1,122 @@
+package video
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCaptureRegion(t *testing.T) {
+	text := `
+# keep the game, hide the chat
+crop 1920x1080+0+0
+mask 400x300+1500+20
+mask window Discord
+`
+	region, err := ParseCaptureRegion(text)
+	require.NoError(t, err)
+	require.Equal(t, CaptureRegion{
+		Crop: &Rect{Width: 1920, Height: 1080},
+		Masks: []Mask{
+			{Rect: Rect{X: 1500, Y: 20, Width: 400, Height: 300}},
+			{Window: "Discord"},
+		},
+	}, region)
+	require.Equal(t, "crop 1920x1080+0+0\nmask 400x300+1500+20\nmask window Discord", region.String())
+
+	again, err := ParseCaptureRegion(region.String())
+	require.NoError(t, err)
+	require.Equal(t, region, again)
+
+	region, err = ParseCaptureRegion("mask 100x50")
+	require.NoError(t, err)
+	require.Equal(t, []Mask{{Rect: Rect{Width: 100, Height: 50}}}, region.Masks)
+
+	empty, err := ParseCaptureRegion("\n  \n")
+	require.NoError(t, err)
+	require.True(t, empty.IsEmpty())
+
+	for _, text := range []string{
+		"crop 1920x1080\ncrop 1280x720",
+		"crop 0x1080+0+0",
+		"mask 100+20+20",
+		"mask window ",
+		"blur 100x100+0+0",
+	} {
+		_, err := ParseCaptureRegion(text)
+		require.ErrorIs(t, err, ErrInvalidRegion, text)
+	}
+}
+
+func TestBuildRegionFilters(t *testing.T) {
+	monitor := &MonitorInfo{Width: 2560, Height: 1440, OffsetX: 1920, OffsetY: 0}
+	windows := []*WindowInfo{
+		{ID: 1, Title: "Discord - #general", OffsetX: 2020, OffsetY: 100, Width: 800, Height: 600},
+		// on the other monitor
+		{ID: 2, Title: "discord", OffsetX: 0, OffsetY: 0, Width: 800, Height: 600},
+		{ID: 3, Title: "Game", OffsetX: 1920, OffsetY: 0, Width: 2560, Height: 1440},
+	}
+
+	region := CaptureRegion{
+		Crop: &Rect{X: 100, Y: 100, Width: 2561, Height: 1401},
+		Masks: []Mask{
+			{Rect: Rect{X: 2400, Y: 1300, Width: 400, Height: 400}},
+			{Window: "DISCORD"},
+			{Rect: Rect{X: 3000, Y: 0, Width: 10, Height: 10}},
+		},
+	}
+
+	filters, err := buildRegionFilters(region, monitor, windows)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"drawbox=x=2400:y=1300:w=160:h=140:color=black:t=fill",
+		"drawbox=x=100:y=100:w=800:h=600:color=black:t=fill",
+		"crop=2460:1340:100:100",
+	}, filters)
+
+	_, err = buildRegionFilters(CaptureRegion{Crop: &Rect{X: 3000, Y: 0, Width: 100, Height: 100}}, monitor, nil)
+	require.ErrorIs(t, err, ErrInvalidRegion)
+
+	filters, err = buildRegionFilters(CaptureRegion{}, monitor, windows)
+	require.NoError(t, err)
+	require.Empty(t, filters)
+}
+
+func TestSetRegion(t *testing.T) {
+	source := WithRegion(NewMonitorSource("1"), CaptureRegion{})
+	require.True(t, IsScreenSource(source))
+	require.True(t, RegionOf(source).IsEmpty())
+
+	region := CaptureRegion{Masks: []Mask{{Window: "Slack"}}}
+	require.NoError(t, SetRegion(source, region))
+	require.Equal(t, region, RegionOf(source))
+	// the copies of the source share the region
+	require.Equal(t, region, RegionOf(WithMonitor(source, "2")))
+
+	require.ErrorIs(t, SetRegion(source, CaptureRegion{Crop: &Rect{}}), ErrInvalidRegion)
+	require.ErrorIs(t, SetRegion(NewMonitorSource("1"), region), ErrRegionNotSupported)
+	require.ErrorIs(t, SetRegion(NewTestPatternSource(), region), ErrRegionNotSupported)
+
+	pattern := NewTestPatternSource()
+	require.Equal(t, pattern, WithRegion(pattern, region))
+}
+
+func TestScreenSource_InputArgsWithRegion(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("x11grab arguments are only built on Linux")
+	}
+	// without a display the whole desktop is captured
+	t.Setenv("DISPLAY", "")
+
+	config := NewDefaultConfig()
+	config.Width, config.Height = 1280, 720
+
+	source := WithRegion(NewScreenSource(), CaptureRegion{
+		Crop:  &Rect{X: 10, Y: 20, Width: 1920, Height: 1080},
+		Masks: []Mask{{Rect: Rect{X: 0, Y: 0, Width: 300, Height: 100}}},
+	})
+	args, err := source.InputArgs(config)
+	require.NoError(t, err)
+	require.Contains(t, args, "drawbox=x=0:y=0:w=300:h=100:color=black:t=fill,crop=1920:1080:10:20,"+buildScaleFilter(config))
+}
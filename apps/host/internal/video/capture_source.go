@@ -0,0 +1,610 @@
+package video
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SourceMode selects what is captured for a session
+type SourceMode string
+
+const (
+	// SourceModeScreen captures the screen or a window of the host
+	SourceModeScreen SourceMode = "screen"
+	// SourceModeTestPattern generates a synthetic test pattern with
+	// ffmpeg's lavfi sources, no display or GPU is needed
+	SourceModeTestPattern SourceMode = "testpattern"
+	// SourceModeFile replays an H.264 or MP4 file without re-encoding it
+	SourceModeFile SourceMode = "file"
+)
+
+const (
+	// CaptureSourceEnv is the environment variable that overrides the
+	// capture source from the settings
+	CaptureSourceEnv = "IMPERIUM_CAPTURE_SOURCE"
+	// ReplayFileEnv is the environment variable that sets the file replayed
+	// by SourceModeFile
+	ReplayFileEnv = "IMPERIUM_REPLAY_FILE"
+)
+
+var sourceModes = []SourceMode{SourceModeScreen, SourceModeTestPattern, SourceModeFile}
+
+// SourceModeFromEnv returns the source mode set in CaptureSourceEnv,
+// or source when the variable is not set
+func SourceModeFromEnv(source SourceMode) SourceMode {
+	if value := strings.TrimSpace(os.Getenv(CaptureSourceEnv)); value != "" {
+		return SourceMode(strings.ToLower(value))
+	}
+	return source
+}
+
+// ReplayFileFromEnv returns the file set in ReplayFileEnv, or path when the
+// variable is not set
+func ReplayFileFromEnv(path string) string {
+	if value := strings.TrimSpace(os.Getenv(ReplayFileEnv)); value != "" {
+		return value
+	}
+	return path
+}
+
+// validateSourceMode checks the source mode, an empty mode means
+// SourceModeScreen
+func validateSourceMode(source SourceMode) error {
+	if source == "" || slices.Contains(sourceModes, source) {
+		return nil
+	}
+	return fmt.Errorf("%w: unknown source %q", ErrInvalidCaptureSource, source)
+}
+
+// NewEncoderForConfig returns the encoder and the capture source for the
+// source mode of the config. The file source is replayed as is, everything
+// else is encoded by a Recorder.
+func NewEncoderForConfig(config *Config) (Encoder, CaptureSource, error) {
+	if config == nil {
+		config = NewDefaultConfig()
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	if config.Source == SourceModeFile {
+		encoder, err := NewReplayEncoder(config)
+		if err != nil {
+			return nil, nil, err
+		}
+		return encoder, NewFileSource(config.SourcePath, true), nil
+	}
+
+	recorder, err := NewRecorder(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if config.Source == SourceModeTestPattern {
+		return recorder, NewTestPatternSource(), nil
+	}
+	return recorder, NewScreenSource(), nil
+}
+
+// screenSource captures a single monitor
+type screenSource struct {
+	monitor string
+	// region is set by WithRegion, nil captures the whole monitor
+	region *regionSetting
+}
+
+// NewScreenSource returns a source that captures the primary monitor, the
+// capture method depends on the encoder
+func NewScreenSource() CaptureSource {
+	return screenSource{}
+}
+
+// NewMonitorSource returns a source that captures the monitor matching the
+// selector, see FindMonitor. An empty selector is the primary monitor.
+func NewMonitorSource(monitor string) CaptureSource {
+	return screenSource{monitor: monitor}
+}
+
+// IsScreenSource reports whether the source captures a monitor
+func IsScreenSource(source CaptureSource) bool {
+	_, ok := source.(screenSource)
+	return ok
+}
+
+// CaptureBounds returns the captured area on the desktop, the monitor or
+// the crop of its region, or the window. It follows windows and monitors
+// that moved since the capture started.
+func CaptureBounds(source CaptureSource) (Rect, error) {
+	switch s := source.(type) {
+	case screenSource:
+		return s.bounds()
+	case windowSource:
+		return s.bounds()
+	}
+	return Rect{}, fmt.Errorf("%w: %s", ErrBoundsUnknown, source.Name())
+}
+
+// WithMonitor returns a screen source for the monitor when source captures
+// the screen, any other source is returned as is
+func WithMonitor(source CaptureSource, monitor string) CaptureSource {
+	if screen, ok := source.(screenSource); ok {
+		screen.monitor = monitor
+		return screen
+	}
+	return source
+}
+
+func (s screenSource) Name() string {
+	if s.monitor == "" {
+		return "screen"
+	}
+	return fmt.Sprintf("monitor %q", s.monitor)
+}
+
+// resolveMonitor returns the selected monitor. When no monitor was selected
+// and the monitors cannot be listed, nil is returned so the whole desktop is
+// captured.
+func (s screenSource) resolveMonitor() (*MonitorInfo, error) {
+	monitor, err := ResolveMonitor(s.monitor)
+	if err != nil {
+		if s.monitor != "" {
+			return nil, err
+		}
+		fmt.Printf("Warning: Could not detect primary monitor, using full desktop: %v\n", err)
+		return nil, nil
+	}
+	return monitor, nil
+}
+
+// Size returns the resolution of the monitor, or of the crop of its region
+func (s screenSource) Size(*Config) (int, int, error) {
+	monitor, err := ResolveMonitor(s.monitor)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	crop, err := resolveCrop(s.region.get(), monitor)
+	if err != nil {
+		return 0, 0, err
+	}
+	if crop != nil {
+		return crop.Width, crop.Height, nil
+	}
+	return monitor.Width, monitor.Height, nil
+}
+
+// bounds returns the monitor, or the crop of its region, on the desktop
+func (s screenSource) bounds() (Rect, error) {
+	monitor, err := ResolveMonitor(s.monitor)
+	if err != nil {
+		return Rect{}, err
+	}
+	return monitorCaptureBounds(monitor, s.region.get())
+}
+
+// monitorCaptureBounds returns the captured part of the monitor on the
+// desktop, crops are relative to the monitor
+func monitorCaptureBounds(monitor *MonitorInfo, region CaptureRegion) (Rect, error) {
+	crop, err := resolveCrop(region, monitor)
+	if err != nil {
+		return Rect{}, err
+	}
+	if crop == nil {
+		return Rect{X: monitor.OffsetX, Y: monitor.OffsetY, Width: monitor.Width, Height: monitor.Height}, nil
+	}
+	crop.X += monitor.OffsetX
+	crop.Y += monitor.OffsetY
+	return *crop, nil
+}
+
+func (s screenSource) InputArgs(config *Config) ([]string, error) {
+	args := buildBaseArgs(config)
+
+	monitor, err := s.resolveMonitor()
+	if err != nil {
+		return nil, err
+	}
+
+	region := s.region.get()
+	regionFilters, err := buildRegionFilters(region, monitor, regionWindows(region))
+	if err != nil {
+		return nil, err
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		// Auto-select capture method based on encoder
+		if isHardwareEncoder(config.Encoder) {
+
+			w, h, index := 1920, 1080, 0
+			if monitor != nil {
+				index = monitor.Index
+				if monitor.Width > 0 && monitor.Height > 0 {
+					w, h = monitor.Width, monitor.Height
+				}
+			}
+			if config.Width > 0 && config.Height > 0 && len(regionFilters) == 0 {
+				// gfxcapture scales to the output resolution itself, with a
+				// region the monitor is captured as is so the region keeps
+				// its coordinates and is scaled afterwards
+				w, h = FitResolution(w, h, config.Width, config.Height)
+			}
+			fmt.Printf("Using gfxcapture with monitor %d: %dx%d\n", index, w, h)
+
+			filters := []string{fmt.Sprintf(
+				"gfxcapture=monitor_idx=%d:width=%d:height=%d:resize_mode=scale_aspect:output_fmt=8bit,hwdownload,format=bgra",
+				index, w, h,
+			)}
+			if len(regionFilters) > 0 {
+				filters = append(filters, regionFilters...)
+				if scale := buildScaleFilter(config); scale != "" {
+					filters = append(filters, scale)
+				}
+			}
+			filters = append(filters, "format=nv12")
+			args = append(args, "-filter_complex", strings.Join(filters, ","))
+			args = append(args, "-filter_threads", "2")
+			args = append(args, "-filter_complex_threads", "2")
+
+		} else {
+
+			args = append(args, "-f", "gdigrab")
+			args = append(args, "-i", "desktop")
+
+			var filters []string
+			if monitor != nil {
+				filters = append(filters, fmt.Sprintf("crop=%d:%d:%d:%d",
+					monitor.Width,
+					monitor.Height,
+					monitor.OffsetX,
+					monitor.OffsetY))
+				fmt.Printf("Using gdigrab with monitor %d: %dx%d at offset (%d,%d)\n",
+					monitor.Index, monitor.Width, monitor.Height, monitor.OffsetX, monitor.OffsetY)
+			}
+			filters = append(filters, regionFilters...)
+			if scale := buildScaleFilter(config); scale != "" {
+				filters = append(filters, scale)
+			}
+			if len(filters) > 0 {
+				args = append(args, "-vf", strings.Join(filters, ","))
+			}
+		}
+
+		args = append(args, "-fflags", "fastseek+flush_packets")
+		args = append(args, "-flags", "global_header")
+
+	case "linux":
+		display := os.Getenv("DISPLAY")
+		if display == "" {
+			display = ":0"
+		}
+
+		args = append(args, "-f", "x11grab")
+		if monitor != nil {
+			args = append(args, "-video_size", fmt.Sprintf("%dx%d", monitor.Width, monitor.Height))
+			display = fmt.Sprintf("%s+%d,%d", display, monitor.OffsetX, monitor.OffsetY)
+			fmt.Printf("Using x11grab with monitor %d: %dx%d at offset (%d,%d)\n",
+				monitor.Index, monitor.Width, monitor.Height, monitor.OffsetX, monitor.OffsetY)
+		}
+		args = append(args, "-i", display)
+
+		filters := regionFilters
+		if scale := buildScaleFilter(config); scale != "" {
+			filters = append(filters, scale)
+		}
+		if len(filters) > 0 {
+			args = append(args, "-vf", strings.Join(filters, ","))
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrOSNotSupported, runtime.GOOS)
+	}
+
+	return args, nil
+}
+
+// Follow polls the windows covered by the masks of the region until stop
+// is closed and calls changed when one of them moved, the masks are drawn
+// at fixed positions so the capture has to be restarted
+func (s screenSource) Follow(stop <-chan struct{}, changed func()) {
+	if s.region == nil {
+		return
+	}
+
+	monitor, _ := s.resolveMonitor()
+	masks := func(region CaptureRegion) []Rect {
+		if !region.hasWindowMasks() {
+			return nil
+		}
+		return resolveMasks(region, monitor, regionWindows(region))
+	}
+
+	ticker := time.NewTicker(windowFollowInterval)
+	defer ticker.Stop()
+
+	region := s.region.get()
+	last := masks(region)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		// a changed region is applied by whoever changed it
+		current := s.region.get()
+		masked := masks(current)
+		if current.String() != region.String() || slices.Equal(masked, last) {
+			region, last = current, masked
+			continue
+		}
+		log.Printf("Masked windows on %s moved, updating the masks", s.Name())
+		last = masked
+		changed()
+	}
+}
+
+// windowSource captures a single window, either by its title or, for the
+// window of a launched program, by its id
+type windowSource struct {
+	title string
+	id    uint64
+	// fallback is captured while the window is gone or minimized
+	fallback CaptureSource
+}
+
+// NewWindowSource returns a source that captures the window with the given
+// title, only supported on Windows
+func NewWindowSource(title string) CaptureSource {
+	return windowSource{title: title}
+}
+
+// NewProcessWindowSource returns a source that captures the window, see
+// FindProcessWindow. The capture follows the window when it is moved or
+// resized, fallback is captured while the window is gone.
+func NewProcessWindowSource(window *WindowInfo, fallback CaptureSource) CaptureSource {
+	return windowSource{
+		title:    window.Title,
+		id:       window.ID,
+		fallback: fallback,
+	}
+}
+
+// WithWindow returns a source for the window when source captures the
+// screen, the screen stays the fallback. Any other source is returned as is.
+func WithWindow(source CaptureSource, window *WindowInfo) CaptureSource {
+	if IsScreenSource(source) && window != nil {
+		return NewProcessWindowSource(window, source)
+	}
+	return source
+}
+
+func (s windowSource) Name() string {
+	return fmt.Sprintf("window %q", s.title)
+}
+
+// window returns the current state of the window, nil without an error means
+// the window is only known by its title
+func (s windowSource) window() (*WindowInfo, error) {
+	if s.id == 0 {
+		return nil, nil
+	}
+	return GetWindowInfo(s.id)
+}
+
+// Size returns the current size of the window
+func (s windowSource) Size(config *Config) (int, int, error) {
+	window, err := s.window()
+	if err != nil {
+		if sized, ok := s.fallback.(sizedSource); ok {
+			return sized.Size(config)
+		}
+		return 0, 0, err
+	}
+	if window == nil {
+		return 0, 0, fmt.Errorf("%w: size of %s is unknown", ErrInvalidCaptureSource, s.Name())
+	}
+	return window.Width, window.Height, nil
+}
+
+// bounds returns the rectangle of the window, or of the fallback while the
+// window is gone
+func (s windowSource) bounds() (Rect, error) {
+	window, err := s.window()
+	if err == nil && window == nil {
+		window, err = s.windowByTitle()
+	}
+	if err != nil {
+		if s.fallback != nil {
+			return CaptureBounds(s.fallback)
+		}
+		return Rect{}, err
+	}
+	return Rect{X: window.OffsetX, Y: window.OffsetY, Width: window.Width, Height: window.Height}, nil
+}
+
+// windowByTitle returns the window with the title of the source, gdigrab
+// captures it by its exact title
+func (s windowSource) windowByTitle() (*WindowInfo, error) {
+	windows, err := ListWindows()
+	if err != nil {
+		return nil, err
+	}
+	matches := matchWindows(windows, s.title)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrWindowNotFound, s.title)
+	}
+	for _, window := range matches {
+		if window.Title == s.title {
+			return window, nil
+		}
+	}
+	return matches[0], nil
+}
+
+func (s windowSource) InputArgs(config *Config) ([]string, error) {
+	window, err := s.window()
+	if err != nil {
+		if s.fallback == nil {
+			return nil, err
+		}
+		log.Printf("Capturing %s instead of %s: %v", s.fallback.Name(), s.Name(), err)
+		return s.fallback.InputArgs(config)
+	}
+
+	title := s.title
+	if window != nil && window.Title != "" {
+		title = window.Title
+	}
+
+	args := buildBaseArgs(config)
+
+	switch runtime.GOOS {
+	case "windows":
+		// Use GDI capture with optimizations for games
+		args = append(args, "-f", "gdigrab")
+		args = append(args, "-i", fmt.Sprintf("title=%s", title))
+		args = append(args, "-draw_mouse", "0")
+		args = append(args, "-show_region", "1")
+
+		// Add some optimizations for better game capture
+		args = append(args, "-fflags", "nobuffer+fastseek")
+		args = append(args, "-flags", "low_delay")
+
+	case "linux":
+		if window == nil {
+			return nil, fmt.Errorf("%w: capturing a window by title", ErrOSNotSupported)
+		}
+
+		display := os.Getenv("DISPLAY")
+		if display == "" {
+			display = ":0"
+		}
+
+		// x11grab follows the window when it is moved
+		args = append(args, "-f", "x11grab")
+		args = append(args, "-window_id", fmt.Sprintf("0x%x", window.ID))
+		args = append(args, "-i", display)
+		fmt.Printf("Using x11grab with window 0x%x: %dx%d\n", window.ID, window.Width, window.Height)
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrOSNotSupported, runtime.GOOS)
+	}
+
+	// windows can have odd sizes, 4:2:0 needs even dimensions
+	return append(args, convertRGBToBT709(config, "crop=trunc(iw/2)*2:trunc(ih/2)*2")...), nil
+}
+
+// Follow polls the window until stop is closed and calls changed when it
+// was resized, closed or minimized, or when it came back. The capture has to
+// be restarted then, moving the window does not need a restart.
+func (s windowSource) Follow(stop <-chan struct{}, changed func()) {
+	if s.id == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(windowFollowInterval)
+	defer ticker.Stop()
+
+	last, _ := s.window()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		current, err := s.window()
+		switch {
+		case err != nil && last == nil:
+			continue
+		case err != nil:
+			log.Printf("Lost %s: %v", s.Name(), err)
+		case last == nil:
+			log.Printf("Found %s again", s.Name())
+		case current.Width == last.Width && current.Height == last.Height:
+			last = current
+			continue
+		default:
+			log.Printf("%s was resized to %dx%d", s.Name(), current.Width, current.Height)
+		}
+
+		last = current
+		changed()
+	}
+}
+
+// testPatternSource generates a synthetic test pattern
+type testPatternSource struct{}
+
+// NewTestPatternSource returns a source that generates a testsrc2 pattern
+// with a burnt-in wall clock, stream timestamp and frame counter
+func NewTestPatternSource() CaptureSource {
+	return testPatternSource{}
+}
+
+func (testPatternSource) Name() string {
+	return "test pattern"
+}
+
+// Size returns the resolution the pattern is generated at
+func (testPatternSource) Size(*Config) (int, int, error) {
+	return testPatternWidth, testPatternHeight, nil
+}
+
+func (testPatternSource) InputArgs(config *Config) ([]string, error) {
+	return []string{
+		"-re",
+		"-thread_queue_size", "4096",
+		"-f", "lavfi",
+		"-i", buildTestPatternSource(config),
+		"-map", "0:v",
+	}, nil
+}
+
+// FileSource is a video file that is replayed in real time
+type FileSource struct {
+	Path string
+	// Loop restarts the file once it ended
+	Loop bool
+}
+
+// NewFileSource returns a source that replays the given file
+func NewFileSource(path string, loop bool) *FileSource {
+	return &FileSource{
+		Path: path,
+		Loop: loop,
+	}
+}
+
+func (s *FileSource) Name() string {
+	return fmt.Sprintf("file %q", s.Path)
+}
+
+func (s *FileSource) InputArgs(config *Config) ([]string, error) {
+	if _, err := os.Stat(s.Path); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPath, err)
+	}
+
+	args := []string{"-re"}
+	if s.Loop {
+		args = append(args, "-stream_loop", "-1")
+	}
+	if isRawH264File(s.Path) {
+		// raw streams carry no timing, read them at the configured rate
+		args = append(args, "-f", "h264", "-framerate", fmt.Sprintf("%d", config.FPS))
+	}
+	return append(args, "-i", s.Path, "-map", "0:v:0"), nil
+}
+
+// isRawH264File reports whether the path looks like an Annex-B file
+func isRawH264File(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".h264") || strings.HasSuffix(lower, ".264")
+}
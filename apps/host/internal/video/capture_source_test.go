@@ -0,0 +1,159 @@
+package video
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEncoderForConfig(t *testing.T) {
+	config := NewDefaultConfig()
+	config.SetSource(SourceModeTestPattern)
+
+	encoder, source, err := NewEncoderForConfig(config)
+	require.NoError(t, err)
+	require.IsType(t, &Recorder{}, encoder)
+	require.Equal(t, NewTestPatternSource(), source)
+	require.Equal(t, CodecH264, encoder.Codec())
+	require.Equal(t, 30, encoder.FPS())
+
+	config = NewDefaultConfig()
+	config.SetSource(SourceModeFile)
+	_, _, err = NewEncoderForConfig(config)
+	require.ErrorIs(t, err, ErrInvalidCaptureSource)
+
+	config.SetSourcePath("/tmp/session.mp4")
+	encoder, source, err = NewEncoderForConfig(config)
+	require.NoError(t, err)
+	require.IsType(t, &ReplayEncoder{}, encoder)
+	require.Equal(t, NewFileSource("/tmp/session.mp4", true), source)
+}
+
+func TestReplayFileFromEnv(t *testing.T) {
+	t.Setenv(ReplayFileEnv, "")
+	require.Equal(t, "a.mp4", ReplayFileFromEnv("a.mp4"))
+
+	t.Setenv(ReplayFileEnv, " /tmp/b.h264 ")
+	require.Equal(t, "/tmp/b.h264", ReplayFileFromEnv("a.mp4"))
+}
+
+func TestFileSource_InputArgs(t *testing.T) {
+	dir := t.TempDir()
+	mp4 := filepath.Join(dir, "capture.mp4")
+	raw := filepath.Join(dir, "capture.h264")
+	require.NoError(t, os.WriteFile(mp4, nil, 0o644))
+	require.NoError(t, os.WriteFile(raw, nil, 0o644))
+
+	config := NewDefaultConfig()
+
+	args, err := NewFileSource(mp4, true).InputArgs(config)
+	require.NoError(t, err)
+	require.Equal(t, []string{"-re", "-stream_loop", "-1", "-i", mp4, "-map", "0:v:0"}, args)
+
+	args, err = NewFileSource(raw, false).InputArgs(config)
+	require.NoError(t, err)
+	require.Equal(t, []string{"-re", "-f", "h264", "-framerate", "30", "-i", raw, "-map", "0:v:0"}, args)
+
+	_, err = NewFileSource(filepath.Join(dir, "missing.mp4"), false).InputArgs(config)
+	require.ErrorIs(t, err, ErrInvalidPath)
+}
+
+func TestBuildSourceArgs_H265(t *testing.T) {
+	config := NewDefaultConfig()
+	config.SetEncoder("libx265")
+	require.NoError(t, config.Validate())
+
+	args, err := buildSourceArgs(NewTestPatternSource(), config)
+	require.NoError(t, err)
+	require.Equal(t, []string{"-an", "-f", "hevc", "-"}, args[len(args)-4:])
+	require.Contains(t, strings.Join(args, " "), "-c:v libx265")
+
+	recorder := &Recorder{config: config}
+	require.Equal(t, []string{"libx265"}, recorder.getFallbackEncoders())
+}
+
+func TestBuildSourceArgs_VAAPI(t *testing.T) {
+	config := NewDefaultConfig()
+	config.SetEncoder("h264_vaapi")
+	require.NoError(t, config.Validate())
+
+	args, err := buildSourceArgs(NewTestPatternSource(), config, "drawbox")
+	require.NoError(t, err)
+	require.Equal(t, []string{"-vaapi_device", vaapiDevice}, args[:2])
+
+	// the frames are uploaded after the software filters, like the overlay
+	vf := slices.Index(args, "-vf")
+	require.NotEqual(t, -1, vf)
+	require.Equal(t, "drawbox,format=nv12,hwupload", args[vf+1])
+	// the last pixel format wins, the frames stay in GPU memory
+	require.Equal(t, []string{"-pix_fmt", "vaapi"}, args[len(args)-6:len(args)-4])
+}
+
+func TestRecorder_FallbackChainArgs(t *testing.T) {
+	probe := &EncoderProbe{
+		Results: []EncoderProbeResult{
+			{Encoder: "h264_vaapi", Codec: "h264", Success: true},
+			{Encoder: "h264_nvenc", Codec: "h264", Success: true},
+		},
+	}
+
+	config := NewDefaultConfig()
+	config.SetEncoder("h264_nvenc")
+	require.NoError(t, config.Validate())
+	recorder := &Recorder{config: config}
+	recorder.SetFallbackEncoders(probe.FallbackChain("h264_nvenc"))
+
+	// every step of the chain gets the args of its encoder, like the
+	// supervisor builds them
+	uploads := map[string]bool{}
+	for _, encoder := range append([]string{config.Encoder}, recorder.getFallbackEncoders()...) {
+		args, err := buildSourceArgs(NewTestPatternSource(), recorder.configForEncoder(encoder, StreamParams{}))
+		require.NoError(t, err)
+		require.Contains(t, args, encoder)
+		uploads[encoder] = strings.Contains(strings.Join(args, " "), "format=nv12,hwupload")
+	}
+	require.Equal(t, map[string]bool{"h264_nvenc": false, "h264_vaapi": true, "libx264": false}, uploads)
+}
+
+func TestReplayEncoder_BuildReplayArgs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.mp4")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+
+	encoder := &ReplayEncoder{fps: 60}
+	args, err := encoder.buildReplayArgs(NewFileSource(path, true))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"-re", "-stream_loop", "-1", "-i", path, "-map", "0:v:0",
+		"-c:v", "copy", "-bsf:v", "h264_mp4toannexb", "-an", "-f", "h264", "-",
+	}, args)
+
+	_, err = encoder.Start(NewTestPatternSource())
+	require.ErrorIs(t, err, ErrUnsupportedSource)
+}
+
+func TestMonitorCaptureBounds(t *testing.T) {
+	monitor := &MonitorInfo{Width: 2560, Height: 1440, OffsetX: 1920, OffsetY: -200}
+
+	bounds, err := monitorCaptureBounds(monitor, CaptureRegion{})
+	require.NoError(t, err)
+	require.Equal(t, Rect{X: 1920, Y: -200, Width: 2560, Height: 1440}, bounds)
+
+	// crops are relative to the monitor and clipped to it
+	bounds, err = monitorCaptureBounds(monitor, CaptureRegion{Crop: &Rect{X: 2000, Y: 100, Width: 1000, Height: 501}})
+	require.NoError(t, err)
+	require.Equal(t, Rect{X: 3920, Y: -100, Width: 560, Height: 500}, bounds)
+
+	_, err = monitorCaptureBounds(monitor, CaptureRegion{Crop: &Rect{X: 3000, Y: 0, Width: 100, Height: 100}})
+	require.ErrorIs(t, err, ErrInvalidRegion)
+}
+
+func TestCaptureBounds_Unknown(t *testing.T) {
+	_, err := CaptureBounds(NewTestPatternSource())
+	require.ErrorIs(t, err, ErrBoundsUnknown)
+	_, err = CaptureBounds(NewFileSource("replay.h264", false))
+	require.ErrorIs(t, err, ErrBoundsUnknown)
+}
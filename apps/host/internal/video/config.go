@@ -1,10 +1,20 @@
 // Package video provides a wrapper for the ffmpeg command line tool, and takes care of the encoding process.
 package video
 
+import "fmt"
+
+// Config is the encoder configuration of a Recorder, the embedded Profile
+// holds the rate-control, keyframe, preset and output settings
 type Config struct {
-	Encoder    string `json:"encoder" mapstructure:"encoder"`
-	FPS        int    `json:"fps" mapstructure:"fps"`
-	FFMPEGPath string `json:"ffmpeg_path" mapstructure:"ffmpeg_path"`
+	Encoder    string     `json:"encoder" mapstructure:"encoder"`
+	FPS        int        `json:"fps" mapstructure:"fps"`
+	FFMPEGPath string     `json:"ffmpeg_path" mapstructure:"ffmpeg_path"`
+	Source     SourceMode `json:"source" mapstructure:"source"`
+	SourcePath string     `json:"source_path" mapstructure:"source_path"`
+	// FallbackEncoders are tried in order when the encoder fails to start,
+	// see EncoderProbe.FallbackChain
+	FallbackEncoders []string `json:"fallback_encoders" mapstructure:"fallback_encoders"`
+	Profile          `mapstructure:",squash"`
 }
 
 func (c *Config) SetEncoder(encoder string) {
@@ -19,6 +29,43 @@ func (c *Config) SetFFMPEGPath(ffmpegPath string) {
 	c.FFMPEGPath = ffmpegPath
 }
 
+func (c *Config) SetSource(source SourceMode) {
+	c.Source = source
+}
+
+func (c *Config) SetSourcePath(path string) {
+	c.SourcePath = path
+}
+
+func (c *Config) SetProfile(profile Profile) {
+	c.Profile = profile
+}
+
+// Validate fills in the profile defaults and checks the config
+func (c *Config) Validate() error {
+	if c.Encoder == "" {
+		return fmt.Errorf("%w: no encoder set", ErrInvalidProfile)
+	}
+	if c.FPS <= 0 || c.FPS > maxProfileFPS {
+		return fmt.Errorf("%w: fps must be between 1 and %d", ErrInvalidProfile, maxProfileFPS)
+	}
+	if err := validateSourceMode(c.Source); err != nil {
+		return err
+	}
+	if c.Source == SourceModeFile && c.SourcePath == "" {
+		return fmt.Errorf("%w: no file set to replay", ErrInvalidCaptureSource)
+	}
+	// the track is negotiated for one codec, a fallback must keep it
+	for _, encoder := range c.FallbackEncoders {
+		if codecForEncoder(encoder) != codecForEncoder(c.Encoder) {
+			return fmt.Errorf("%w: fallback encoder %s does not encode %s", ErrInvalidProfile, encoder, codecForEncoder(c.Encoder))
+		}
+	}
+
+	c.Profile = c.Profile.WithDefaults(c.FPS)
+	return c.Profile.Validate()
+}
+
 func LoadConfig(encoder string, fps int, ffmpegPath string) *Config {
 	return &Config{
 		Encoder:    encoder,
@@ -33,5 +80,7 @@ func NewDefaultConfig() *Config {
 		Encoder:    "libx264",
 		FPS:        30,
 		FFMPEGPath: "",
+		Source:     SourceModeScreen,
+		Profile:    NewDefaultProfile(),
 	}
 }
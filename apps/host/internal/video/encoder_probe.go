@@ -0,0 +1,447 @@
+package video
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	probeFrames  = 60
+	probeTimeout = 15 * time.Second
+	// vaapiDevice is the render node used for VAAPI encoders
+	vaapiDevice = "/dev/dri/renderD128"
+)
+
+// EncoderProbeResult is the outcome of a short test encode with one encoder
+type EncoderProbeResult struct {
+	Encoder      string   `json:"encoder"`
+	Codec        string   `json:"codec"`
+	Success      bool     `json:"success"`
+	Speed        float64  `json:"speed"` // multiple of realtime
+	FPS          float64  `json:"fps"`
+	PixelFormats []string `json:"pixel_formats"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// EncoderProbe holds the probe results of a single ffmpeg build
+type EncoderProbe struct {
+	FFmpegVersion string               `json:"ffmpeg_version"`
+	ProbedAt      time.Time            `json:"probed_at"`
+	Results       []EncoderProbeResult `json:"results"`
+}
+
+// WorkingEncoders returns the encoders whose test encode succeeded
+func (p *EncoderProbe) WorkingEncoders() (h264Encoders, h265Encoders []string) {
+	for _, result := range p.Results {
+		if !result.Success {
+			continue
+		}
+		if result.Codec == "hevc" {
+			h265Encoders = append(h265Encoders, result.Encoder)
+		} else {
+			h264Encoders = append(h264Encoders, result.Encoder)
+		}
+	}
+	return h264Encoders, h265Encoders
+}
+
+// fallbackOrder is the order encoders are tried in when the configured one
+// fails, hardware encoders first and the software encoder last
+var fallbackOrder = map[Codec][]string{
+	CodecH264: {"h264_nvenc", "h264_qsv", "h264_amf", "h264_vaapi", "h264_videotoolbox", "libx264"},
+	CodecH265: {"hevc_nvenc", "hevc_qsv", "hevc_amf", "hevc_vaapi", "hevc_videotoolbox", "libx265"},
+}
+
+// FallbackChain returns the encoders to try in order when the given encoder
+// fails. Only encoders of the same codec whose test encode succeeded are
+// included, the software encoder is always the last one. A nil probe
+// returns only the software encoder.
+func (p *EncoderProbe) FallbackChain(encoder string) []string {
+	order := fallbackOrder[codecForEncoder(encoder)]
+	software := order[len(order)-1]
+
+	var chain []string
+	for _, candidate := range order[:len(order)-1] {
+		if candidate == encoder || p == nil {
+			continue
+		}
+		if result, ok := p.Result(candidate); ok && result.Success {
+			chain = append(chain, candidate)
+		}
+	}
+	if encoder != software {
+		chain = append(chain, software)
+	}
+	return chain
+}
+
+// Result returns the probe result of the given encoder
+func (p *EncoderProbe) Result(encoder string) (EncoderProbeResult, bool) {
+	for _, result := range p.Results {
+		if result.Encoder == encoder {
+			return result, true
+		}
+	}
+	return EncoderProbeResult{}, false
+}
+
+// EncoderProber runs test encodes with every H.264/H.265 encoder of an
+// ffmpeg build. Results are cached per ffmpeg build in memory and, when a
+// cache path is set, on disk. A build is the resolved ffmpeg path and its
+// version banner, two builds of the same version can have other encoders.
+type EncoderProber struct {
+	ffmpeg    *FFMPEGWrapper
+	cachePath string
+
+	// buildMu guards the memoized build, `ffmpeg -version` runs once per
+	// prober
+	buildMu sync.Mutex
+	build   string
+	version string
+}
+
+// probeCall is a probe of one ffmpeg build in progress, concurrent probes
+// of the build wait for its result
+type probeCall struct {
+	done  chan struct{}
+	probe *EncoderProbe
+	err   error
+}
+
+var (
+	probeCache   = make(map[string]*EncoderProbe)
+	probeRunning = make(map[string]*probeCall)
+	// probeCacheMu guards the cache and the running probes, it is not held
+	// during the test encodes
+	probeCacheMu sync.Mutex
+)
+
+// NewEncoderProber returns a new EncoderProber for the given ffmpeg path.
+// cachePath may be empty to disable the on-disk cache.
+func NewEncoderProber(ffmpegPath, cachePath string) (*EncoderProber, error) {
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+
+	ffmpegWrapper, err := NewFFMPEGWrapper(ffmpegPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EncoderProber{
+		ffmpeg:    ffmpegWrapper,
+		cachePath: cachePath,
+	}, nil
+}
+
+// Probe returns the probe results for the current ffmpeg build, running the
+// test encodes if there is no cached result or force is set. A forced probe
+// looks up the build again, the binary may have been replaced. A probe that
+// is already running is waited for instead of starting another one.
+func (p *EncoderProber) Probe(force bool) (*EncoderProbe, error) {
+	build, version, err := p.ffmpegBuild(force)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.probeBuild(build, force, func() (*EncoderProbe, error) {
+		return p.runProbe(version)
+	})
+}
+
+// ffmpegBuild returns the cache key of the ffmpeg build and its version,
+// they are looked up once per prober or when refresh is set
+func (p *EncoderProber) ffmpegBuild(refresh bool) (build, version string, err error) {
+	p.buildMu.Lock()
+	defer p.buildMu.Unlock()
+
+	if p.build == "" || refresh {
+		versionOutput, err := p.ffmpeg.Version()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to get ffmpeg version: %w", err)
+		}
+		p.build = ffmpegBuildKey(resolveFFmpegPath(p.ffmpeg.path), string(versionOutput))
+		p.version = parseFFmpegVersion(string(versionOutput))
+	}
+	return p.build, p.version, nil
+}
+
+// probeBuild returns the cached probe of the build or runs it, only one
+// probe of a build runs at a time
+func (p *EncoderProber) probeBuild(build string, force bool, run func() (*EncoderProbe, error)) (*EncoderProbe, error) {
+	probeCacheMu.Lock()
+	if call, ok := probeRunning[build]; ok {
+		probeCacheMu.Unlock()
+		<-call.done
+		return call.probe, call.err
+	}
+	if !force {
+		if probe, ok := p.cachedLocked(build); ok {
+			probeCacheMu.Unlock()
+			return probe, nil
+		}
+	}
+	call := &probeCall{done: make(chan struct{})}
+	probeRunning[build] = call
+	probeCacheMu.Unlock()
+
+	call.probe, call.err = run()
+
+	probeCacheMu.Lock()
+	delete(probeRunning, build)
+	if call.err == nil {
+		probeCache[build] = call.probe
+		if err := p.saveCached(build, call.probe); err != nil {
+			log.Printf("failed to save encoder probe cache: %v", err)
+		}
+	}
+	probeCacheMu.Unlock()
+	close(call.done)
+
+	return call.probe, call.err
+}
+
+// runProbe runs a test encode with every encoder of the ffmpeg build
+func (p *EncoderProber) runProbe(version string) (*EncoderProbe, error) {
+	h264Encoders, h265Encoders, err := GetAvailableEncodersForCodecs(p.ffmpeg.path)
+	if err != nil {
+		return nil, err
+	}
+
+	probe := &EncoderProbe{
+		FFmpegVersion: version,
+		ProbedAt:      time.Now(),
+	}
+	for _, encoder := range h264Encoders {
+		probe.Results = append(probe.Results, p.probeEncoder(encoder, "h264"))
+	}
+	for _, encoder := range h265Encoders {
+		probe.Results = append(probe.Results, p.probeEncoder(encoder, "hevc"))
+	}
+	return probe, nil
+}
+
+// Cached returns the cached probe results of the current ffmpeg build
+// without running any test encodes, a running probe is not waited for
+func (p *EncoderProber) Cached() (*EncoderProbe, bool) {
+	build, _, err := p.ffmpegBuild(false)
+	if err != nil {
+		return nil, false
+	}
+
+	probeCacheMu.Lock()
+	defer probeCacheMu.Unlock()
+	return p.cachedLocked(build)
+}
+
+// cachedLocked returns the probe of the build from memory or from the
+// on-disk cache, the caller holds probeCacheMu
+func (p *EncoderProber) cachedLocked(build string) (*EncoderProbe, bool) {
+	if probe, ok := probeCache[build]; ok {
+		return probe, true
+	}
+	probe, ok := p.loadCached(build)
+	if ok {
+		probeCache[build] = probe
+	}
+	return probe, ok
+}
+
+// probeEncoder looks up the supported pixel formats of an encoder and runs
+// a short test encode from a lavfi source with it
+func (p *EncoderProber) probeEncoder(encoder, codec string) EncoderProbeResult {
+	result := EncoderProbeResult{
+		Encoder: encoder,
+		Codec:   codec,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	help, _, err := p.ffmpeg.Run(ctx, "-hide_banner", "-h", "encoder="+encoder)
+	if err == nil {
+		result.PixelFormats = parsePixelFormats(string(help))
+	}
+
+	stdout, stderr, err := p.ffmpeg.Run(ctx, buildProbeArgs(encoder, result.PixelFormats)...)
+	if err != nil {
+		result.Error = probeErrorMessage(stderr, err)
+		log.Printf("encoder probe: %s failed: %s", encoder, result.Error)
+		return result
+	}
+
+	var last Progress
+	_ = parseProgress(bytes.NewReader(stdout), func(progress Progress) {
+		last = progress
+	})
+
+	if last.Frame == 0 {
+		result.Error = "no frames were encoded"
+		return result
+	}
+
+	result.Success = true
+	result.Speed = last.Speed
+	result.FPS = last.FPS
+	log.Printf("encoder probe: %s ok (speed %.2fx, %.1f fps)", encoder, result.Speed, result.FPS)
+	return result
+}
+
+// buildProbeArgs returns the ffmpeg arguments for a test encode with the
+// given encoder
+func buildProbeArgs(encoder string, pixelFormats []string) []string {
+	args := []string{"-hide_banner", "-nostdin", "-loglevel", "error"}
+
+	if strings.Contains(encoder, "vaapi") {
+		args = append(args, "-vaapi_device", vaapiDevice)
+	}
+
+	args = append(args,
+		"-f", "lavfi",
+		"-i", "testsrc2=size=1280x720:rate=30",
+		"-frames:v", fmt.Sprintf("%d", probeFrames),
+	)
+
+	if strings.Contains(encoder, "vaapi") {
+		args = append(args, "-vf", "format=nv12,hwupload")
+	} else if pixFmt := probePixelFormat(pixelFormats); pixFmt != "" {
+		args = append(args, "-pix_fmt", pixFmt)
+	}
+
+	return append(args,
+		"-c:v", encoder,
+		"-progress", "pipe:1",
+		"-nostats",
+		"-f", "null",
+		"-",
+	)
+}
+
+// probePixelFormat picks the pixel format used for the test encode
+func probePixelFormat(pixelFormats []string) string {
+	for _, preferred := range []string{"yuv420p", "nv12"} {
+		if slices.Contains(pixelFormats, preferred) {
+			return preferred
+		}
+	}
+	return ""
+}
+
+// parseFFmpegVersion extracts the version from `ffmpeg -version` output,
+// e.g. "6.1.1-3ubuntu5" from "ffmpeg version 6.1.1-3ubuntu5 Copyright ..."
+func parseFFmpegVersion(output string) string {
+	firstLine, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	fields := strings.Fields(firstLine)
+	if len(fields) >= 3 && fields[0] == "ffmpeg" && fields[1] == "version" {
+		return fields[2]
+	}
+	return strings.TrimSpace(firstLine)
+}
+
+// resolveFFmpegPath returns the absolute path of the ffmpeg binary, a name
+// is looked up in $PATH. It returns the path as is when it cannot be
+// resolved.
+func resolveFFmpegPath(path string) string {
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return path
+	}
+	if abs, err := filepath.Abs(resolved); err == nil {
+		resolved = abs
+	}
+	if target, err := filepath.EvalSymlinks(resolved); err == nil {
+		resolved = target
+	}
+	return resolved
+}
+
+// ffmpegBuildKey returns the cache key of an ffmpeg build from its path and
+// the first line of its `ffmpeg -version` output, which carries the version
+// and the vendor suffix
+func ffmpegBuildKey(path, versionOutput string) string {
+	banner, _, _ := strings.Cut(strings.TrimSpace(versionOutput), "\n")
+	return path + ": " + strings.TrimSpace(banner)
+}
+
+// parsePixelFormats extracts the supported pixel formats from
+// `ffmpeg -h encoder=<name>` output
+func parsePixelFormats(output string) []string {
+	for _, line := range strings.Split(output, "\n") {
+		_, formats, found := strings.Cut(strings.TrimSpace(line), "Supported pixel formats:")
+		if found {
+			return strings.Fields(formats)
+		}
+	}
+	return nil
+}
+
+// probeErrorMessage returns the last line ffmpeg printed to stderr, which is
+// usually the reason the encoder could not be opened
+func probeErrorMessage(stderr []byte, err error) string {
+	lines := strings.Split(strings.TrimSpace(string(stderr)), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return last
+	}
+	return err.Error()
+}
+
+// loadCached reads the probe of the given build from the on-disk cache
+func (p *EncoderProber) loadCached(build string) (*EncoderProbe, bool) {
+	cache, err := readProbeCache(p.cachePath)
+	if err != nil {
+		return nil, false
+	}
+	probe, ok := cache[build]
+	return probe, ok
+}
+
+// saveCached adds the probe of the build to the on-disk cache
+func (p *EncoderProber) saveCached(build string, probe *EncoderProbe) error {
+	if p.cachePath == "" {
+		return nil
+	}
+
+	cache, err := readProbeCache(p.cachePath)
+	if err != nil {
+		cache = make(map[string]*EncoderProbe)
+	}
+	cache[build] = probe
+
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p.cachePath), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p.cachePath, data, 0o644)
+}
+
+// readProbeCache reads the probe cache file keyed by ffmpeg build
+func readProbeCache(path string) (map[string]*EncoderProbe, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no cache path set")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cache map[string]*EncoderProbe
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
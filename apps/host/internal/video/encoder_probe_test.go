@@ -0,0 +1,241 @@
+package video
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFFmpegVersion(t *testing.T) {
+	cases := []struct {
+		input  string
+		result string
+	}{
+		{
+			input:  "ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers\nbuilt with gcc 13",
+			result: "6.1.1-3ubuntu5",
+		},
+		{
+			input:  "ffmpeg version n7.0 Copyright (c) 2000-2024 the FFmpeg developers",
+			result: "n7.0",
+		},
+		{
+			input:  "something unexpected",
+			result: "something unexpected",
+		},
+	}
+
+	for _, tc := range cases {
+		require.Equal(t, tc.result, parseFFmpegVersion(tc.input))
+	}
+}
+
+func TestParsePixelFormats(t *testing.T) {
+	output := `Encoder h264_nvenc [NVIDIA NVENC H.264 encoder]:
+    General capabilities: dr1 delay hardware
+    Threading capabilities: none
+    Supported hardware devices: cuda cuda d3d11va d3d11va
+    Supported pixel formats: yuv420p nv12 p010le yuv444p p016le yuv444p16le bgr0 bgra rgb0 rgba x2rgb10le x2bgr10le gbrp gbrp16le cuda d3d11
+h264_nvenc AVOptions:
+`
+	require.Equal(t,
+		[]string{"yuv420p", "nv12", "p010le", "yuv444p", "p016le", "yuv444p16le", "bgr0", "bgra", "rgb0", "rgba", "x2rgb10le", "x2bgr10le", "gbrp", "gbrp16le", "cuda", "d3d11"},
+		parsePixelFormats(output),
+	)
+	require.Nil(t, parsePixelFormats("Unknown encoder 'foo'."))
+}
+
+func TestBuildProbeArgs(t *testing.T) {
+	testCases := []struct {
+		name         string
+		encoder      string
+		pixelFormats []string
+		contains     [][]string
+		excludes     []string
+	}{
+		{
+			name:         "software",
+			encoder:      "libx264",
+			pixelFormats: []string{"yuv420p", "yuvj420p", "yuv422p"},
+			contains:     [][]string{{"-pix_fmt", "yuv420p"}, {"-c:v", "libx264"}, {"-f", "lavfi"}, {"-progress", "pipe:1"}},
+			excludes:     []string{"-vaapi_device"},
+		},
+		{
+			name:         "qsv prefers nv12",
+			encoder:      "h264_qsv",
+			pixelFormats: []string{"nv12", "p010le", "qsv"},
+			contains:     [][]string{{"-pix_fmt", "nv12"}, {"-c:v", "h264_qsv"}},
+		},
+		{
+			name:         "vaapi uploads frames",
+			encoder:      "h264_vaapi",
+			pixelFormats: []string{"vaapi"},
+			contains:     [][]string{{"-vaapi_device", vaapiDevice}, {"-vf", "format=nv12,hwupload"}},
+			excludes:     []string{"-pix_fmt"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			args := buildProbeArgs(tc.encoder, tc.pixelFormats)
+			for _, pair := range tc.contains {
+				idx := indexOf(args, pair[0])
+				require.GreaterOrEqual(t, idx, 0, "missing %s", pair[0])
+				require.Equal(t, pair[1], args[idx+1])
+			}
+			for _, arg := range tc.excludes {
+				require.NotContains(t, args, arg)
+			}
+			require.Equal(t, "-", args[len(args)-1])
+		})
+	}
+}
+
+func TestEncoderProbe_WorkingEncoders(t *testing.T) {
+	probe := &EncoderProbe{
+		Results: []EncoderProbeResult{
+			{Encoder: "libx264", Codec: "h264", Success: true},
+			{Encoder: "h264_nvenc", Codec: "h264", Success: false, Error: "No capable devices found"},
+			{Encoder: "libx265", Codec: "hevc", Success: true},
+			{Encoder: "hevc_qsv", Codec: "hevc", Success: false},
+		},
+	}
+
+	h264, h265 := probe.WorkingEncoders()
+	require.Equal(t, []string{"libx264"}, h264)
+	require.Equal(t, []string{"libx265"}, h265)
+
+	result, ok := probe.Result("h264_nvenc")
+	require.True(t, ok)
+	require.Equal(t, "No capable devices found", result.Error)
+
+	_, ok = probe.Result("h264_amf")
+	require.False(t, ok)
+}
+
+func TestEncoderProbe_FallbackChain(t *testing.T) {
+	probe := &EncoderProbe{
+		Results: []EncoderProbeResult{
+			{Encoder: "libx264", Codec: "h264", Success: true},
+			{Encoder: "h264_vaapi", Codec: "h264", Success: true},
+			{Encoder: "h264_nvenc", Codec: "h264", Success: true},
+			{Encoder: "h264_qsv", Codec: "h264", Success: false},
+			{Encoder: "hevc_nvenc", Codec: "hevc", Success: true},
+		},
+	}
+
+	require.Equal(t, []string{"h264_vaapi", "libx264"}, probe.FallbackChain("h264_nvenc"))
+	require.Equal(t, []string{"h264_nvenc", "h264_vaapi"}, probe.FallbackChain("libx264"))
+	// the software encoder is tried even when the probe found no encoder
+	require.Equal(t, []string{"libx265"}, probe.FallbackChain("hevc_nvenc"))
+
+	var missing *EncoderProbe
+	require.Equal(t, []string{"libx264"}, missing.FallbackChain("h264_qsv"))
+}
+
+func TestEncoderProber_Cache(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "encoders.json")
+	prober := &EncoderProber{cachePath: cachePath}
+	distro := "/usr/bin/ffmpeg: ffmpeg version 6.1"
+	custom := "/opt/ffmpeg/bin/ffmpeg: ffmpeg version 6.1"
+
+	_, ok := prober.loadCached(distro)
+	require.False(t, ok)
+
+	probe := &EncoderProbe{
+		FFmpegVersion: "6.1",
+		ProbedAt:      time.Now().UTC().Truncate(time.Second),
+		Results: []EncoderProbeResult{
+			{Encoder: "libx264", Codec: "h264", Success: true, Speed: 4.2, FPS: 126, PixelFormats: []string{"yuv420p"}},
+		},
+	}
+	require.NoError(t, prober.saveCached(distro, probe))
+	require.NoError(t, prober.saveCached("/usr/bin/ffmpeg: ffmpeg version 7.0", &EncoderProbe{FFmpegVersion: "7.0"}))
+
+	loaded, ok := prober.loadCached(distro)
+	require.True(t, ok)
+	require.Equal(t, probe, loaded)
+
+	_, ok = prober.loadCached("/usr/bin/ffmpeg: ffmpeg version 7.0")
+	require.True(t, ok)
+
+	// another build of the same version is probed on its own
+	_, ok = prober.loadCached(custom)
+	require.False(t, ok)
+}
+
+func TestFFmpegBuildKey(t *testing.T) {
+	output := "ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers\nbuilt with gcc 13\n"
+	require.Equal(t,
+		"/usr/bin/ffmpeg: ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers",
+		ffmpegBuildKey("/usr/bin/ffmpeg", output))
+	require.NotEqual(t, ffmpegBuildKey("/usr/bin/ffmpeg", output), ffmpegBuildKey("/opt/ffmpeg/ffmpeg", output))
+}
+
+func TestEncoderProber_ProbeBuild(t *testing.T) {
+	prober := &EncoderProber{}
+	build := "test-" + t.Name()
+	t.Cleanup(func() {
+		probeCacheMu.Lock()
+		defer probeCacheMu.Unlock()
+		delete(probeCache, build)
+	})
+
+	started, finish := make(chan struct{}), make(chan struct{})
+	runs := 0
+	run := func() (*EncoderProbe, error) {
+		runs++
+		close(started)
+		<-finish
+		return &EncoderProbe{FFmpegVersion: build}, nil
+	}
+
+	first := make(chan *EncoderProbe)
+	go func() {
+		probe, _ := prober.probeBuild(build, false, run)
+		first <- probe
+	}()
+	<-started
+
+	// the cache does not wait for the running probe
+	probeCacheMu.Lock()
+	_, ok := prober.cachedLocked(build)
+	probeCacheMu.Unlock()
+	require.False(t, ok)
+
+	// a second probe waits for the running one
+	second := make(chan *EncoderProbe)
+	go func() {
+		probe, _ := prober.probeBuild(build, false, run)
+		second <- probe
+	}()
+
+	close(finish)
+	probe := <-first
+	require.Same(t, probe, <-second)
+	require.Equal(t, 1, runs)
+
+	probeCacheMu.Lock()
+	cached, ok := prober.cachedLocked(build)
+	probeCacheMu.Unlock()
+	require.True(t, ok)
+	require.Same(t, probe, cached)
+}
+
+func TestProbeErrorMessage(t *testing.T) {
+	stderr := []byte("[h264_nvenc @ 0x5581] Cannot load libcuda.so.1\n[vost#0:0/h264_nvenc @ 0x5582] Error while opening encoder\n")
+	require.Equal(t, "[vost#0:0/h264_nvenc @ 0x5582] Error while opening encoder", probeErrorMessage(stderr, nil))
+	require.Equal(t, "exit status 1", probeErrorMessage(nil, errors.New("exit status 1")))
+}
+
+func indexOf(list []string, item string) int {
+	for i, value := range list {
+		if value == item {
+			return i
+		}
+	}
+	return -1
+}
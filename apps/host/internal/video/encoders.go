@@ -14,6 +14,7 @@ var h264Preferred = []string{
 	"h264_nvenc",
 	"h264_qsv",
 	"h264_amf",
+	"h264_vaapi",
 	"h264_videotoolbox",
 }
 
@@ -21,13 +22,13 @@ var h265Preferred = []string{
 	"hevc_nvenc",
 	"hevc_qsv",
 	"hevc_amf",
+	"hevc_vaapi",
 	"hevc_videotoolbox",
 	"libx265",
 }
 
 func itemInList(el string, list []string) bool {
 	for _, value := range list {
-		fmt.Println(value, el)
 		if strings.Contains(value, el) {
 			return true
 		}
@@ -35,8 +36,15 @@ func itemInList(el string, list []string) bool {
 	return false
 }
 
-func GetAvailableEncodersForCodecs() (h264Encoders, h265Encoders []string, err error) {
-	cmd := exec.Command("ffmpeg", "-encoders")
+// GetAvailableEncodersForCodecs lists the H.264 and H.265 encoders the given
+// ffmpeg build was compiled with. Listed encoders are not guaranteed to work
+// on this machine, use EncoderProber for that.
+func GetAvailableEncodersForCodecs(ffmpegPath string) (h264Encoders, h265Encoders []string, err error) {
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+
+	cmd := exec.Command(ffmpegPath, "-hide_banner", "-encoders")
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
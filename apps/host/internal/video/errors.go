@@ -5,4 +5,33 @@ import "errors"
 var (
 	ErrOSNotSupported = errors.New("OS currently not supported")
 	ErrInvalidPath    = errors.New("the current path is invalid")
+	ErrInvalidProfile = errors.New("invalid encoder profile")
+	// ErrInvalidCaptureSource is returned for an unknown capture source or
+	// one that is missing what it captures
+	ErrInvalidCaptureSource = errors.New("invalid capture source")
+	// ErrUnsupportedSource is returned by an Encoder that cannot encode the
+	// given capture source
+	ErrUnsupportedSource = errors.New("capture source not supported by the encoder")
+	// ErrMonitorNotFound is returned when the selected monitor is not
+	// connected (anymore)
+	ErrMonitorNotFound = errors.New("monitor not found")
+	// ErrNotRecording is returned when a running recording is required
+	ErrNotRecording = errors.New("no recording in progress")
+	// ErrWindowNotFound is returned when a window does not exist (anymore)
+	ErrWindowNotFound = errors.New("window not found")
+	// ErrInvalidRegion is returned for a capture region that cannot be
+	// applied to the monitor
+	ErrInvalidRegion = errors.New("invalid capture region")
+	// ErrRegionNotSupported is returned when the region of a source that
+	// does not capture a monitor is changed
+	ErrRegionNotSupported = errors.New("capture region not supported by the source")
+	// ErrBoundsUnknown is returned for a capture source that is not an area
+	// of the desktop, like a test pattern or a file
+	ErrBoundsUnknown = errors.New("capture source has no area on the desktop")
+	// ErrOverlayNotSupported is returned by an Encoder that does not
+	// encode the frames itself and cannot draw an overlay
+	ErrOverlayNotSupported = errors.New("stats overlay not supported by the encoder")
+	// ErrEncoderStalled is the exit error of a process that was stopped as
+	// it encoded no frame after starting
+	ErrEncoderStalled = errors.New("the encoder produced no frames")
 )
@@ -1,17 +1,26 @@
 package video
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"sync"
+	"time"
 
 	"github.com/m1thrandir225/imperium/apps/host/internal/util"
 )
 
+// processStopTimeout is how long a process gets to quit after receiving 'q'
+// before it is killed
+const processStopTimeout = 5 * time.Second
+
 type FFMPEGWrapper struct {
 	path    string
+	mu      sync.Mutex
 	cmd     *exec.Cmd
 	running bool
 	stdin   io.WriteCloser
@@ -29,6 +38,8 @@ func NewFFMPEGWrapper(path string) (*FFMPEGWrapper, error) {
 }
 
 func (w *FFMPEGWrapper) Execute(args ...string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
 	w.cmd = exec.Command(w.path, args...)
 
@@ -39,7 +50,7 @@ func (w *FFMPEGWrapper) Execute(args ...string) error {
 	}
 
 	w.cmd.Stdout = os.Stdout
-	w.cmd.Stderr = os.Stderr
+	w.cmd.Stderr = newFFmpegLogWriter()
 
 	w.running = true
 	log.Printf("Executing command: %s", w.cmd.String())
@@ -47,35 +58,60 @@ func (w *FFMPEGWrapper) Execute(args ...string) error {
 }
 
 func (w *FFMPEGWrapper) ExecuteWithoutOutput(args ...string) ([]byte, error) {
-	w.cmd = exec.Command(w.path, args...)
-	w.running = true
-	return w.cmd.CombinedOutput()
+	cmd := exec.Command(w.path, args...)
+	return cmd.CombinedOutput()
 }
 
 func (w *FFMPEGWrapper) Version() ([]byte, error) {
 	return w.ExecuteWithoutOutput("-version")
 }
 
+// IsRunning reports whether the tracked ffmpeg process is running
+func (w *FFMPEGWrapper) IsRunning() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.running
+}
+
+// Run executes a short-lived ffmpeg command bound to ctx and returns its
+// stdout and stderr separately. It does not touch the tracked process.
+func (w *FFMPEGWrapper) Run(ctx context.Context, args ...string) ([]byte, []byte, error) {
+	cmd := exec.CommandContext(ctx, w.path, args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
 func (w *FFMPEGWrapper) Stop() error {
-	if w.cmd != nil && w.cmd.Process != nil && w.running {
-		w.running = false
-
-		// Send 'q' command to FFmpeg through stdin
-		if w.stdin != nil {
-			_, err := w.stdin.Write([]byte("q"))
-			if err != nil {
-				log.Printf("Warning: Failed to send quit command: %v", err)
-			}
-			w.stdin.Close()
-		}
+	w.mu.Lock()
+	cmd, stdin := w.cmd, w.stdin
+	if cmd == nil || cmd.Process == nil || !w.running {
+		w.mu.Unlock()
+		return fmt.Errorf("no running FFmpeg process to stop")
+	}
+	w.running = false
+	w.mu.Unlock()
 
-		return w.cmd.Wait()
+	// Send 'q' command to FFmpeg through stdin
+	if stdin != nil {
+		_, err := stdin.Write([]byte("q"))
+		if err != nil {
+			log.Printf("Warning: Failed to send quit command: %v", err)
+		}
+		stdin.Close()
 	}
-	return fmt.Errorf("no running FFmpeg process to stop")
 
+	return cmd.Wait()
 }
 
 func (w *FFMPEGWrapper) ExecuteWithStdout(args ...string) (io.ReadCloser, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	w.cmd = exec.Command(w.path, args...)
 
 	var err error
@@ -89,14 +125,14 @@ func (w *FFMPEGWrapper) ExecuteWithStdout(args ...string) (io.ReadCloser, error)
 		return nil, fmt.Errorf("failed to create stdout pipe: %v", err)
 	}
 
-	w.cmd.Stderr = os.Stderr
+	w.cmd.Stderr = newFFmpegLogWriter()
 
-	w.running = true
 	log.Printf("Executing command with stdout: %s", w.cmd.String())
 
 	if err := w.cmd.Start(); err != nil {
 		return nil, fmt.Errorf("failed to start command: %v", err)
 	}
+	w.running = true
 
 	return &ffmpegStream{
 		rc:   stdout,
@@ -104,6 +140,103 @@ func (w *FFMPEGWrapper) ExecuteWithStdout(args ...string) (io.ReadCloser, error)
 	}, nil
 }
 
+// Start starts an ffmpeg process with its stdout and stderr connected to
+// pipes. The process is not tracked by the wrapper, use Process.Stop.
+func (w *FFMPEGWrapper) Start(args ...string) (*Process, error) {
+	cmd := exec.Command(w.path, args...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin pipe: %v", err)
+	}
+
+	// os.Pipe instead of StdoutPipe so Wait does not close the read ends
+	// while the output is still being consumed
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %v", err)
+	}
+	stderrReader, stderrWriter, err := os.Pipe()
+	if err != nil {
+		stdoutReader.Close()
+		stdoutWriter.Close()
+		return nil, fmt.Errorf("failed to create stderr pipe: %v", err)
+	}
+
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+
+	log.Printf("Starting supervised command: %s", cmd.String())
+	err = cmd.Start()
+
+	// the child holds its own copies of the write ends
+	stdoutWriter.Close()
+	stderrWriter.Close()
+
+	if err != nil {
+		stdoutReader.Close()
+		stderrReader.Close()
+		return nil, fmt.Errorf("failed to start command: %v", err)
+	}
+
+	p := &Process{
+		cmd:    cmd,
+		stdin:  stdin,
+		Stdout: stdoutReader,
+		Stderr: stderrReader,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		p.err = cmd.Wait()
+		close(p.done)
+	}()
+
+	return p, nil
+}
+
+// Process is an ffmpeg process started with FFMPEGWrapper.Start
+type Process struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	Stdout io.ReadCloser
+	Stderr io.ReadCloser
+
+	done     chan struct{}
+	err      error
+	stopOnce sync.Once
+}
+
+// Done is closed once the process has exited
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait blocks until the process has exited and returns its exit error
+func (p *Process) Wait() error {
+	<-p.done
+	return p.err
+}
+
+// Stop asks ffmpeg to quit and kills it when it does not exit in time
+func (p *Process) Stop() error {
+	p.stopOnce.Do(func() {
+		if _, err := p.stdin.Write([]byte("q")); err != nil {
+			log.Printf("Warning: Failed to send quit command: %v", err)
+		}
+		p.stdin.Close()
+
+		select {
+		case <-p.done:
+		case <-time.After(processStopTimeout):
+			log.Printf("Warning: FFmpeg did not quit in time, killing it")
+			_ = p.cmd.Process.Kill()
+		}
+	})
+
+	return p.Wait()
+}
+
 // ffmpegStream Implements io.ReadCloser
 type ffmpegStream struct {
 	rc   io.ReadCloser
@@ -120,3 +253,28 @@ func (s *ffmpegStream) Close() error {
 	}
 	return s.rc.Close()
 }
+
+// ffmpegLogWriter logs every line ffmpeg writes to stderr instead of
+// passing it through to the host's stderr
+type ffmpegLogWriter struct {
+	buf []byte
+}
+
+func newFFmpegLogWriter() *ffmpegLogWriter {
+	return &ffmpegLogWriter{}
+}
+
+func (w *ffmpegLogWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		if line := bytes.TrimSpace(w.buf[:i]); len(line) > 0 {
+			log.Printf("ffmpeg: %s", line)
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
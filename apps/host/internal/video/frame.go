@@ -0,0 +1,295 @@
+package video
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Codec is the codec of encoded frames
+type Codec string
+
+const (
+	CodecH264 Codec = "h264"
+	CodecH265 Codec = "h265"
+)
+
+// codecForEncoder returns the codec produced by the given ffmpeg encoder
+func codecForEncoder(encoder string) Codec {
+	switch {
+	case strings.Contains(encoder, "265"), strings.Contains(encoder, "hevc"):
+		return CodecH265
+	default:
+		return CodecH264
+	}
+}
+
+// Frame is a single encoded access unit in Annex-B format
+type Frame struct {
+	Data     []byte
+	PTS      time.Duration
+	Keyframe bool
+	Codec    Codec
+}
+
+// FrameReader yields encoded frames, ReadFrame returns io.EOF once the
+// stream ended
+type FrameReader interface {
+	ReadFrame() (Frame, error)
+	Close() error
+}
+
+// CaptureSource is something that can be captured and encoded, e.g. the
+// screen, a window, a test pattern or a file
+type CaptureSource interface {
+	// Name describes the source in logs
+	Name() string
+	// InputArgs returns the ffmpeg arguments that open and filter the
+	// source. config.Encoder is the encoder the frames are prepared for.
+	InputArgs(config *Config) ([]string, error)
+}
+
+// followedSource is implemented by capture sources that change while they
+// are captured. Follow calls changed until stop is closed whenever the
+// capture has to be restarted with new arguments.
+type followedSource interface {
+	Follow(stop <-chan struct{}, changed func())
+}
+
+// Encoder encodes a CaptureSource into frames
+type Encoder interface {
+	// Start starts encoding the source, only one source can be encoded at
+	// a time
+	Start(source CaptureSource) (FrameReader, error)
+	// Configure sets the stream parameters requested by the client for
+	// the next Start. They are clamped to the limits of the host, the
+	// effective parameters for the source are returned.
+	Configure(source CaptureSource, requested StreamParams) StreamParams
+	// Stop stops encoding, the frame reader returns io.EOF afterwards
+	Stop() error
+	Codec() Codec
+	FPS() int
+	// ActiveEncoder returns the ffmpeg encoder in use, which changes when
+	// the configured one fails and a fallback encoder takes over
+	ActiveEncoder() string
+	// Reconfigure applies new stream parameters to the running encode. The
+	// capture is restarted behind the same FrameReader, which continues
+	// with a keyframe. The effective parameters are returned.
+	Reconfigure(requested StreamParams) (StreamParams, error)
+	// SetStatsOverlay shows or hides the encoder stats, frame number and
+	// wall clock in the video. A running encode is restarted like with
+	// Reconfigure.
+	SetStatsOverlay(enabled bool) error
+	// Snapshot grabs a single image of the source, it can be taken while
+	// the source is encoded
+	Snapshot(ctx context.Context, source CaptureSource, opts SnapshotOptions) ([]byte, error)
+	// SetEventHandler sets the handler for encoder lifecycle events
+	SetEventHandler(fn func(ProcessEvent))
+	EventHandler() func(ProcessEvent)
+}
+
+// annexBFrameReader splits an Annex-B byte stream into access units. The
+// stream is expected to have a constant frame rate, which the presentation
+// timestamps are derived from.
+type annexBFrameReader struct {
+	stream   io.ReadCloser
+	reader   *bufio.Reader
+	codec    Codec
+	duration atomic.Int64 // frame duration, changes with the frame rate
+
+	pts     time.Duration
+	pending []byte // NAL unit that starts the next access unit
+	inNAL   bool   // a start code was consumed
+	eof     bool
+}
+
+// NewAnnexBFrameReader returns a FrameReader over a raw Annex-B stream with
+// the given constant frame rate
+func NewAnnexBFrameReader(stream io.ReadCloser, codec Codec, fps int) FrameReader {
+	return newAnnexBFrameReader(stream, codec, fps)
+}
+
+func newAnnexBFrameReader(stream io.ReadCloser, codec Codec, fps int) *annexBFrameReader {
+	r := &annexBFrameReader{
+		stream: stream,
+		reader: bufio.NewReaderSize(stream, 1<<20),
+		codec:  codec,
+	}
+	r.setFPS(fps)
+	return r
+}
+
+// setFPS changes the frame rate of the following frames, the timestamps
+// stay continuous
+func (r *annexBFrameReader) setFPS(fps int) {
+	if fps <= 0 {
+		fps = 30
+	}
+	r.duration.Store(int64(time.Second / time.Duration(fps)))
+}
+
+func (r *annexBFrameReader) ReadFrame() (Frame, error) {
+	var (
+		data     []byte
+		keyframe bool
+		hasVCL   bool
+	)
+
+	appendNAL := func(nal []byte) {
+		data = append(data, 0, 0, 0, 1)
+		data = append(data, nal...)
+		if isKeyframeNAL(r.codec, nal) {
+			keyframe = true
+		}
+		if isVCLNAL(r.codec, nal) {
+			hasVCL = true
+		}
+	}
+
+	if r.pending != nil {
+		appendNAL(r.pending)
+		r.pending = nil
+	}
+
+	for {
+		nal, err := r.nextNAL()
+		if err != nil {
+			if err == io.EOF && len(data) > 0 {
+				return r.frame(data, keyframe), nil
+			}
+			return Frame{}, err
+		}
+
+		if hasVCL && startsAccessUnit(r.codec, nal) {
+			r.pending = nal
+			return r.frame(data, keyframe), nil
+		}
+
+		appendNAL(nal)
+	}
+}
+
+func (r *annexBFrameReader) Close() error {
+	return r.stream.Close()
+}
+
+func (r *annexBFrameReader) frame(data []byte, keyframe bool) Frame {
+	frame := Frame{
+		Data:     data,
+		PTS:      r.pts,
+		Keyframe: keyframe,
+		Codec:    r.codec,
+	}
+	r.pts += time.Duration(r.duration.Load())
+	return frame
+}
+
+// nextNAL returns the next NAL unit without its start code
+func (r *annexBFrameReader) nextNAL() ([]byte, error) {
+	if r.eof {
+		return nil, io.EOF
+	}
+
+	var nal []byte
+	zeros := 0
+
+	for {
+		b, err := r.reader.ReadByte()
+		if err != nil {
+			if err != io.EOF {
+				return nil, err
+			}
+			r.eof = true
+			if !r.inNAL || len(nal) == 0 {
+				return nil, io.EOF
+			}
+			return nal, nil
+		}
+
+		if b == 0 {
+			zeros++
+			continue
+		}
+
+		if b == 1 && zeros >= 2 {
+			// the start code of the next NAL unit, zeros in front of it
+			// are not part of the current one
+			if r.inNAL && len(nal) > 0 {
+				return nal, nil
+			}
+			r.inNAL = true
+			zeros = 0
+			continue
+		}
+
+		if r.inNAL {
+			for ; zeros > 0; zeros-- {
+				nal = append(nal, 0)
+			}
+			nal = append(nal, b)
+		}
+		zeros = 0
+	}
+}
+
+// startsAccessUnit reports whether the NAL unit begins a new access unit
+// once the current one already holds a picture
+func startsAccessUnit(codec Codec, nal []byte) bool {
+	if len(nal) == 0 {
+		return false
+	}
+
+	if codec == CodecH265 {
+		if len(nal) < 3 {
+			return false
+		}
+		nalType := (nal[0] >> 1) & 0x3f
+		switch {
+		case nalType <= 31:
+			// first_slice_segment_in_pic_flag
+			return nal[2]&0x80 != 0
+		case nalType >= 32 && nalType <= 35, nalType == 39:
+			// VPS, SPS, PPS, AUD, prefix SEI
+			return true
+		}
+		return false
+	}
+
+	nalType := nal[0] & 0x1f
+	switch nalType {
+	case 1, 5:
+		// first_mb_in_slice == 0 is encoded as a single 1 bit
+		return len(nal) > 1 && nal[1]&0x80 != 0
+	case 6, 7, 8, 9:
+		// SEI, SPS, PPS, AUD
+		return true
+	}
+	return false
+}
+
+// isVCLNAL reports whether the NAL unit carries picture data
+func isVCLNAL(codec Codec, nal []byte) bool {
+	if len(nal) == 0 {
+		return false
+	}
+	if codec == CodecH265 {
+		return (nal[0]>>1)&0x3f <= 31
+	}
+	nalType := nal[0] & 0x1f
+	return nalType >= 1 && nalType <= 5
+}
+
+// isKeyframeNAL reports whether the NAL unit is an IDR/IRAP picture
+func isKeyframeNAL(codec Codec, nal []byte) bool {
+	if len(nal) == 0 {
+		return false
+	}
+	if codec == CodecH265 {
+		nalType := (nal[0] >> 1) & 0x3f
+		return nalType >= 16 && nalType <= 21
+	}
+	return nal[0]&0x1f == 5
+}
@@ -0,0 +1,125 @@
+package video
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func annexB(nals ...[]byte) []byte {
+	var buf bytes.Buffer
+	for i, nal := range nals {
+		if i%2 == 0 {
+			buf.Write([]byte{0, 0, 0, 1})
+		} else {
+			buf.Write([]byte{0, 0, 1})
+		}
+		buf.Write(nal)
+	}
+	return buf.Bytes()
+}
+
+func TestAnnexBFrameReader_H264(t *testing.T) {
+	var (
+		sps      = []byte{0x67, 0x42, 0x00, 0x1f}
+		pps      = []byte{0x68, 0xce, 0x3c, 0x80}
+		idr      = []byte{0x65, 0x88, 0x84, 0x80}
+		idrSlice = []byte{0x65, 0x40, 0x12} // first_mb_in_slice != 0
+		p1       = []byte{0x41, 0x9a, 0x00, 0x00, 0x03, 0x01}
+		p2       = []byte{0x41, 0x9b, 0x22}
+	)
+
+	stream := io.NopCloser(bytes.NewReader(annexB(sps, pps, idr, idrSlice, p1, p2)))
+	reader := NewAnnexBFrameReader(stream, CodecH264, 25)
+
+	frame, err := reader.ReadFrame()
+	require.NoError(t, err)
+	require.True(t, frame.Keyframe)
+	require.Equal(t, CodecH264, frame.Codec)
+	require.Equal(t, time.Duration(0), frame.PTS)
+	require.Equal(t, annexBWithLongStartCodes(sps, pps, idr, idrSlice), frame.Data)
+
+	frame, err = reader.ReadFrame()
+	require.NoError(t, err)
+	require.False(t, frame.Keyframe)
+	require.Equal(t, 40*time.Millisecond, frame.PTS)
+	require.Equal(t, annexBWithLongStartCodes(p1), frame.Data)
+
+	frame, err = reader.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, 80*time.Millisecond, frame.PTS)
+	require.Equal(t, annexBWithLongStartCodes(p2), frame.Data)
+
+	_, err = reader.ReadFrame()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestAnnexBFrameReader_H265(t *testing.T) {
+	var (
+		vps  = []byte{0x40, 0x01, 0x0c}
+		sps  = []byte{0x42, 0x01, 0x01}
+		pps  = []byte{0x44, 0x01, 0xc1}
+		idr  = []byte{0x26, 0x01, 0xaf} // IDR_W_RADL, first slice
+		next = []byte{0x02, 0x01, 0xd0} // TRAIL_R, first slice
+	)
+
+	stream := io.NopCloser(bytes.NewReader(annexB(vps, sps, pps, idr, next)))
+	reader := NewAnnexBFrameReader(stream, CodecH265, 30)
+
+	frame, err := reader.ReadFrame()
+	require.NoError(t, err)
+	require.True(t, frame.Keyframe)
+	require.Equal(t, annexBWithLongStartCodes(vps, sps, pps, idr), frame.Data)
+
+	frame, err = reader.ReadFrame()
+	require.NoError(t, err)
+	require.False(t, frame.Keyframe)
+	require.Equal(t, CodecH265, frame.Codec)
+
+	_, err = reader.ReadFrame()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestAnnexBFrameReader_SetFPS(t *testing.T) {
+	var (
+		idr = []byte{0x65, 0x88, 0x84, 0x80}
+		p1  = []byte{0x41, 0x9a, 0x01}
+		p2  = []byte{0x41, 0x9b, 0x22}
+		p3  = []byte{0x41, 0x9b, 0x23}
+	)
+
+	stream := io.NopCloser(bytes.NewReader(annexB(idr, p1, p2, p3)))
+	reader := newAnnexBFrameReader(stream, CodecH264, 25)
+
+	var pts []time.Duration
+	for i := 0; i < 4; i++ {
+		frame, err := reader.ReadFrame()
+		require.NoError(t, err)
+		pts = append(pts, frame.PTS)
+		if i == 1 {
+			reader.setFPS(50)
+		}
+	}
+
+	// the timestamps continue from the last frame at the new rate
+	require.Equal(t, []time.Duration{0, 40 * time.Millisecond, 80 * time.Millisecond, 100 * time.Millisecond}, pts)
+}
+
+func annexBWithLongStartCodes(nals ...[]byte) []byte {
+	var buf bytes.Buffer
+	for _, nal := range nals {
+		buf.Write([]byte{0, 0, 0, 1})
+		buf.Write(nal)
+	}
+	return buf.Bytes()
+}
+
+func TestCodecForEncoder(t *testing.T) {
+	require.Equal(t, CodecH264, codecForEncoder("libx264"))
+	require.Equal(t, CodecH264, codecForEncoder("h264_nvenc"))
+	require.Equal(t, CodecH265, codecForEncoder("libx265"))
+	require.Equal(t, CodecH265, codecForEncoder("hevc_qsv"))
+}
@@ -0,0 +1,715 @@
+package video
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// This file implements a small H.264 bitstream parser that decodes the
+// parts of SPS, PPS and slice headers needed to check that a stream can be
+// decoded by browsers.
+
+const (
+	h264NALSlice = 1
+	h264NALIDR   = 5
+	h264NALSPS   = 7
+	h264NALPPS   = 8
+
+	h264ProfileBaseline = 66
+	h264ProfileMain     = 77
+	h264ProfileExtended = 88
+	h264ProfileHigh     = 100
+	h264ProfileHigh10   = 110
+	h264ProfileHigh422  = 122
+	h264ProfileHigh444  = 244
+	h264ProfileCAVLC444 = 44
+)
+
+var errH264Truncated = errors.New("truncated H.264 syntax element")
+
+// H264FrameType is the picture type of an access unit
+type H264FrameType string
+
+const (
+	H264FrameIDR H264FrameType = "IDR"
+	H264FrameI   H264FrameType = "I"
+	H264FrameP   H264FrameType = "P"
+	H264FrameB   H264FrameType = "B"
+)
+
+// H264StreamInfo summarizes the inspected part of an H.264 stream
+type H264StreamInfo struct {
+	Profile    string `json:"profile"`
+	ProfileIDC int    `json:"profile_idc"`
+	Level      string `json:"level"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	// CABAC is set when the PPS selects CABAC entropy coding
+	CABAC  bool                  `json:"cabac"`
+	Frames int                   `json:"frames"`
+	Types  map[H264FrameType]int `json:"types"`
+	// GOPs holds the length in frames of each complete group of pictures,
+	// i.e. the distance between two IDR frames
+	GOPs     []int    `json:"gops"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+func (i H264StreamInfo) String() string {
+	entropy := "CAVLC"
+	if i.CABAC {
+		entropy = "CABAC"
+	}
+
+	gop := "unknown"
+	if len(i.GOPs) > 0 {
+		gop = fmt.Sprintf("%d", slices.Max(i.GOPs))
+	}
+
+	return fmt.Sprintf("%s level %s %dx%d %s, %d frames (%d IDR, %d I, %d P, %d B), GOP %s",
+		i.Profile, i.Level, i.Width, i.Height, entropy, i.Frames,
+		i.Types[H264FrameIDR], i.Types[H264FrameI], i.Types[H264FrameP], i.Types[H264FrameB], gop)
+}
+
+// h264SPS holds the fields of a sequence parameter set the inspector uses
+type h264SPS struct {
+	ProfileIDC      int
+	ConstraintFlags byte
+	LevelIDC        int
+	ID              uint32
+	ChromaFormatIDC uint32
+	Width           int
+	Height          int
+}
+
+// h264PPS holds the fields of a picture parameter set the inspector uses
+type h264PPS struct {
+	ID    uint32
+	SPSID uint32
+	CABAC bool
+}
+
+// h264SliceHeader holds the first fields of a slice header
+type h264SliceHeader struct {
+	FirstMB   uint32
+	SliceType uint32
+	PPSID     uint32
+}
+
+// H264Inspector collects stream information from H.264 access units
+type H264Inspector struct {
+	sps map[uint32]*h264SPS
+	pps map[uint32]*h264PPS
+
+	info     H264StreamInfo
+	gopStart int // frame index of the last IDR frame, -1 before the first
+}
+
+// NewH264Inspector returns an inspector without any parameter sets
+func NewH264Inspector() *H264Inspector {
+	return &H264Inspector{
+		sps:      make(map[uint32]*h264SPS),
+		pps:      make(map[uint32]*h264PPS),
+		info:     H264StreamInfo{Types: make(map[H264FrameType]int)},
+		gopStart: -1,
+	}
+}
+
+// InspectFrame inspects one access unit in Annex-B format
+func (i *H264Inspector) InspectFrame(data []byte) {
+	frameType := H264FrameType("")
+	// browsers need the parameter sets in front of every IDR frame to
+	// recover from packet loss or start decoding mid-stream
+	hasSPS, hasPPS := false, false
+
+	for _, nal := range splitAnnexB(data) {
+		if len(nal) == 0 {
+			continue
+		}
+
+		switch nal[0] & 0x1f {
+		case h264NALSPS:
+			sps, err := parseH264SPS(nal)
+			if err != nil {
+				i.warn("invalid SPS: %v", err)
+				continue
+			}
+			i.sps[sps.ID] = sps
+			i.checkSPS(sps)
+			hasSPS = true
+
+		case h264NALPPS:
+			pps, err := parseH264PPS(nal)
+			if err != nil {
+				i.warn("invalid PPS: %v", err)
+				continue
+			}
+			i.pps[pps.ID] = pps
+			hasPPS = true
+
+		case h264NALSlice, h264NALIDR:
+			header, err := parseH264SliceHeader(nal)
+			if err != nil {
+				i.warn("invalid slice header: %v", err)
+				continue
+			}
+			idr := nal[0]&0x1f == h264NALIDR
+			if idr && (!hasSPS || !hasPPS) {
+				i.warn("IDR frame without SPS/PPS in front of it, the encoder has to repeat the headers")
+			}
+			i.checkSlice(header)
+			if frameType == "" {
+				frameType = sliceFrameType(header.SliceType, idr)
+			}
+		}
+	}
+
+	if frameType == "" {
+		return
+	}
+
+	if frameType == H264FrameIDR {
+		if i.gopStart >= 0 {
+			i.info.GOPs = append(i.info.GOPs, i.info.Frames-i.gopStart)
+		}
+		i.gopStart = i.info.Frames
+	} else if i.info.Frames == 0 {
+		i.warn("stream does not start with an IDR frame")
+	}
+
+	i.info.Types[frameType]++
+	i.info.Frames++
+}
+
+// Info returns the information collected so far
+func (i *H264Inspector) Info() H264StreamInfo {
+	info := i.info
+	info.Types = make(map[H264FrameType]int, len(i.info.Types))
+	for frameType, count := range i.info.Types {
+		info.Types[frameType] = count
+	}
+	info.GOPs = slices.Clone(i.info.GOPs)
+	info.Warnings = slices.Clone(i.info.Warnings)
+	return info
+}
+
+// checkSPS records the stream properties and warns about profiles that
+// browsers cannot decode
+func (i *H264Inspector) checkSPS(sps *h264SPS) {
+	i.info.Profile = h264ProfileName(sps.ProfileIDC, sps.ConstraintFlags)
+	i.info.ProfileIDC = sps.ProfileIDC
+	i.info.Level = h264LevelName(sps.LevelIDC, sps.ProfileIDC, sps.ConstraintFlags)
+	i.info.Width, i.info.Height = sps.Width, sps.Height
+
+	switch sps.ProfileIDC {
+	case h264ProfileHigh10, h264ProfileHigh422, h264ProfileHigh444, h264ProfileCAVLC444:
+		i.warn("profile %s is not supported by browsers", i.info.Profile)
+	}
+	if sps.ChromaFormatIDC != 1 {
+		i.warn("chroma format %d is not 4:2:0, browsers only decode 4:2:0", sps.ChromaFormatIDC)
+	}
+}
+
+// checkSlice warns about slices that cannot be decoded
+func (i *H264Inspector) checkSlice(header *h264SliceHeader) {
+	pps, ok := i.pps[header.PPSID]
+	if !ok {
+		i.warn("slice refers to the missing PPS %d", header.PPSID)
+		return
+	}
+	sps, ok := i.sps[pps.SPSID]
+	if !ok {
+		i.warn("PPS %d refers to the missing SPS %d", pps.ID, pps.SPSID)
+		return
+	}
+
+	i.info.CABAC = pps.CABAC
+	if pps.CABAC && (sps.ProfileIDC == h264ProfileBaseline || sps.ProfileIDC == h264ProfileExtended) {
+		i.warn("CABAC entropy coding is not allowed in the %s profile", i.info.Profile)
+	}
+	if header.SliceType%5 == 1 {
+		i.warn("stream contains B-frames, they add latency and are not decoded by every browser")
+	}
+}
+
+// warn adds a warning once
+func (i *H264Inspector) warn(format string, args ...any) {
+	warning := fmt.Sprintf(format, args...)
+	if !slices.Contains(i.info.Warnings, warning) {
+		i.info.Warnings = append(i.info.Warnings, warning)
+	}
+}
+
+// InspectH264 inspects up to maxFrames access units of an Annex-B stream,
+// all of them when maxFrames is 0
+func InspectH264(stream io.ReadCloser, maxFrames int) (H264StreamInfo, error) {
+	reader := NewAnnexBFrameReader(stream, CodecH264, 30)
+	defer reader.Close()
+
+	inspector := NewH264Inspector()
+	for maxFrames <= 0 || inspector.info.Frames < maxFrames {
+		frame, err := reader.ReadFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return inspector.Info(), err
+		}
+		inspector.InspectFrame(frame.Data)
+	}
+	return inspector.Info(), nil
+}
+
+// inspectingFrameReader passes frames through and inspects the first ones
+type inspectingFrameReader struct {
+	FrameReader
+	inspector *H264Inspector
+	remaining int
+	report    func(H264StreamInfo)
+}
+
+// InspectFrames returns a FrameReader that inspects the first count H.264
+// frames read from frames and calls report with the result. Other codecs
+// are passed through without inspection.
+func InspectFrames(frames FrameReader, count int, report func(H264StreamInfo)) FrameReader {
+	return &inspectingFrameReader{
+		FrameReader: frames,
+		inspector:   NewH264Inspector(),
+		remaining:   count,
+		report:      report,
+	}
+}
+
+func (r *inspectingFrameReader) ReadFrame() (Frame, error) {
+	frame, err := r.FrameReader.ReadFrame()
+	if err != nil {
+		r.done()
+		return frame, err
+	}
+
+	if r.remaining > 0 && frame.Codec == CodecH264 {
+		r.inspector.InspectFrame(frame.Data)
+		r.remaining--
+		if r.remaining == 0 {
+			r.done()
+		}
+	}
+	return frame, nil
+}
+
+// done reports the inspected frames once
+func (r *inspectingFrameReader) done() {
+	if r.report == nil || r.inspector.info.Frames == 0 {
+		return
+	}
+	r.remaining = 0
+	r.report(r.inspector.Info())
+	r.report = nil
+}
+
+// splitAnnexB returns the NAL units of an Annex-B buffer without their start
+// codes
+func splitAnnexB(data []byte) [][]byte {
+	var nals [][]byte
+	start := -1
+	for i := 0; i+2 < len(data); i++ {
+		if data[i] != 0 || data[i+1] != 0 || data[i+2] != 1 {
+			continue
+		}
+		if start >= 0 {
+			nals = append(nals, trimTrailingZeros(data[start:i]))
+		}
+		start = i + 3
+		i += 2
+	}
+	if start >= 0 && start <= len(data) {
+		nals = append(nals, data[start:])
+	}
+	return nals
+}
+
+// trimTrailingZeros removes trailing zero bytes from a NAL unit, they belong
+// to the following four byte start code or are padding
+func trimTrailingZeros(nal []byte) []byte {
+	for len(nal) > 0 && nal[len(nal)-1] == 0 {
+		nal = nal[:len(nal)-1]
+	}
+	return nal
+}
+
+// h264RBSP removes the emulation prevention bytes and the NAL header
+func h264RBSP(nal []byte) []byte {
+	rbsp := make([]byte, 0, len(nal))
+	zeros := 0
+	for _, b := range nal[1:] {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		rbsp = append(rbsp, b)
+	}
+	return rbsp
+}
+
+// bitReader reads the bits and Exp-Golomb codes of an RBSP
+type bitReader struct {
+	data []byte
+	pos  int // in bits
+}
+
+func (r *bitReader) readBit() (uint32, error) {
+	if r.pos >= len(r.data)*8 {
+		return 0, errH264Truncated
+	}
+	bit := (r.data[r.pos/8] >> (7 - r.pos%8)) & 1
+	r.pos++
+	return uint32(bit), nil
+}
+
+func (r *bitReader) readBits(n int) (uint32, error) {
+	var value uint32
+	for ; n > 0; n-- {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		value = value<<1 | bit
+	}
+	return value, nil
+}
+
+func (r *bitReader) readFlag() (bool, error) {
+	bit, err := r.readBit()
+	return bit == 1, err
+}
+
+// readUE reads an unsigned Exp-Golomb code
+func (r *bitReader) readUE() (uint32, error) {
+	leadingZeros := 0
+	for {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit == 1 {
+			break
+		}
+		leadingZeros++
+		if leadingZeros > 31 {
+			return 0, fmt.Errorf("invalid Exp-Golomb code")
+		}
+	}
+
+	suffix, err := r.readBits(leadingZeros)
+	if err != nil {
+		return 0, err
+	}
+	return (1<<leadingZeros - 1) + suffix, nil
+}
+
+// readSE reads a signed Exp-Golomb code
+func (r *bitReader) readSE() (int32, error) {
+	value, err := r.readUE()
+	if err != nil {
+		return 0, err
+	}
+	if value%2 == 1 {
+		return int32(value/2 + 1), nil
+	}
+	return -int32(value / 2), nil
+}
+
+// parseH264SPS parses a sequence parameter set NAL unit up to the frame
+// cropping, the VUI is not needed
+func parseH264SPS(nal []byte) (*h264SPS, error) {
+	r := &bitReader{data: h264RBSP(nal)}
+	sps := &h264SPS{ChromaFormatIDC: 1}
+
+	profile, err := r.readBits(8)
+	if err != nil {
+		return nil, err
+	}
+	constraints, err := r.readBits(8)
+	if err != nil {
+		return nil, err
+	}
+	level, err := r.readBits(8)
+	if err != nil {
+		return nil, err
+	}
+	sps.ProfileIDC, sps.ConstraintFlags, sps.LevelIDC = int(profile), byte(constraints), int(level)
+
+	if sps.ID, err = r.readUE(); err != nil {
+		return nil, err
+	}
+
+	if hasChromaFormat(sps.ProfileIDC) {
+		if sps.ChromaFormatIDC, err = r.readUE(); err != nil {
+			return nil, err
+		}
+		if sps.ChromaFormatIDC == 3 {
+			if _, err := r.readFlag(); err != nil { // separate_colour_plane_flag
+				return nil, err
+			}
+		}
+		// bit_depth_luma_minus8, bit_depth_chroma_minus8
+		for range 2 {
+			if _, err := r.readUE(); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := r.readFlag(); err != nil { // qpprime_y_zero_transform_bypass_flag
+			return nil, err
+		}
+		scalingMatrix, err := r.readFlag()
+		if err != nil {
+			return nil, err
+		}
+		if scalingMatrix {
+			lists := 8
+			if sps.ChromaFormatIDC == 3 {
+				lists = 12
+			}
+			for i := range lists {
+				present, err := r.readFlag()
+				if err != nil {
+					return nil, err
+				}
+				if !present {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				if err := skipScalingList(r, size); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if _, err := r.readUE(); err != nil { // log2_max_frame_num_minus4
+		return nil, err
+	}
+	pocType, err := r.readUE()
+	if err != nil {
+		return nil, err
+	}
+	switch pocType {
+	case 0:
+		if _, err := r.readUE(); err != nil { // log2_max_pic_order_cnt_lsb_minus4
+			return nil, err
+		}
+	case 1:
+		if _, err := r.readFlag(); err != nil { // delta_pic_order_always_zero_flag
+			return nil, err
+		}
+		// offset_for_non_ref_pic, offset_for_top_to_bottom_field
+		for range 2 {
+			if _, err := r.readSE(); err != nil {
+				return nil, err
+			}
+		}
+		cycle, err := r.readUE()
+		if err != nil {
+			return nil, err
+		}
+		for range cycle {
+			if _, err := r.readSE(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if _, err := r.readUE(); err != nil { // max_num_ref_frames
+		return nil, err
+	}
+	if _, err := r.readFlag(); err != nil { // gaps_in_frame_num_value_allowed_flag
+		return nil, err
+	}
+
+	widthInMBs, err := r.readUE()
+	if err != nil {
+		return nil, err
+	}
+	heightInMapUnits, err := r.readUE()
+	if err != nil {
+		return nil, err
+	}
+	frameMBsOnly, err := r.readFlag()
+	if err != nil {
+		return nil, err
+	}
+	if !frameMBsOnly {
+		if _, err := r.readFlag(); err != nil { // mb_adaptive_frame_field_flag
+			return nil, err
+		}
+	}
+	if _, err := r.readFlag(); err != nil { // direct_8x8_inference_flag
+		return nil, err
+	}
+
+	fieldFactor := 2
+	if frameMBsOnly {
+		fieldFactor = 1
+	}
+	sps.Width = int(widthInMBs+1) * 16
+	sps.Height = fieldFactor * int(heightInMapUnits+1) * 16
+
+	cropping, err := r.readFlag()
+	if err != nil {
+		return nil, err
+	}
+	if cropping {
+		var crop [4]uint32 // left, right, top, bottom
+		for i := range crop {
+			if crop[i], err = r.readUE(); err != nil {
+				return nil, err
+			}
+		}
+		unitX, unitY := cropUnits(sps.ChromaFormatIDC)
+		sps.Width -= unitX * int(crop[0]+crop[1])
+		sps.Height -= unitY * fieldFactor * int(crop[2]+crop[3])
+	}
+
+	return sps, nil
+}
+
+// hasChromaFormat reports whether the SPS of the profile carries the chroma
+// format and bit depths
+func hasChromaFormat(profile int) bool {
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		return true
+	}
+	return false
+}
+
+// cropUnits returns the horizontal and vertical crop unit of the chroma
+// format for frame pictures
+func cropUnits(chromaFormat uint32) (int, int) {
+	switch chromaFormat {
+	case 0, 3:
+		return 1, 1
+	case 2:
+		return 2, 1
+	default:
+		return 2, 2
+	}
+}
+
+// skipScalingList skips a scaling list of the given size
+func skipScalingList(r *bitReader, size int) error {
+	last, next := int32(8), int32(8)
+	for range size {
+		if next != 0 {
+			delta, err := r.readSE()
+			if err != nil {
+				return err
+			}
+			next = (last + delta + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+	return nil
+}
+
+// parseH264PPS parses the ids and the entropy coding of a picture
+// parameter set NAL unit
+func parseH264PPS(nal []byte) (*h264PPS, error) {
+	r := &bitReader{data: h264RBSP(nal)}
+	pps := &h264PPS{}
+
+	var err error
+	if pps.ID, err = r.readUE(); err != nil {
+		return nil, err
+	}
+	if pps.SPSID, err = r.readUE(); err != nil {
+		return nil, err
+	}
+	if pps.CABAC, err = r.readFlag(); err != nil {
+		return nil, err
+	}
+	return pps, nil
+}
+
+// parseH264SliceHeader parses the first fields of a slice header
+func parseH264SliceHeader(nal []byte) (*h264SliceHeader, error) {
+	r := &bitReader{data: h264RBSP(nal)}
+	header := &h264SliceHeader{}
+
+	var err error
+	if header.FirstMB, err = r.readUE(); err != nil {
+		return nil, err
+	}
+	if header.SliceType, err = r.readUE(); err != nil {
+		return nil, err
+	}
+	if header.SliceType > 9 {
+		return nil, fmt.Errorf("invalid slice type %d", header.SliceType)
+	}
+	if header.PPSID, err = r.readUE(); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// sliceFrameType maps a slice type to the frame type, SP and SI slices count
+// as P and I
+func sliceFrameType(sliceType uint32, idr bool) H264FrameType {
+	if idr {
+		return H264FrameIDR
+	}
+	switch sliceType % 5 {
+	case 1:
+		return H264FrameB
+	case 2, 4:
+		return H264FrameI
+	default:
+		return H264FrameP
+	}
+}
+
+// h264ProfileName returns the name of the profile
+func h264ProfileName(profile int, constraints byte) string {
+	switch profile {
+	case h264ProfileBaseline:
+		if constraints&0x40 != 0 {
+			return "Constrained Baseline"
+		}
+		return "Baseline"
+	case h264ProfileMain:
+		return "Main"
+	case h264ProfileExtended:
+		return "Extended"
+	case h264ProfileHigh:
+		return "High"
+	case h264ProfileHigh10:
+		return "High 10"
+	case h264ProfileHigh422:
+		return "High 4:2:2"
+	case h264ProfileHigh444:
+		return "High 4:4:4 Predictive"
+	case h264ProfileCAVLC444:
+		return "CAVLC 4:4:4 Intra"
+	}
+	return fmt.Sprintf("profile %d", profile)
+}
+
+// h264LevelName returns the level as written in the specification, e.g. 3.1
+func h264LevelName(level, profile int, constraints byte) string {
+	if level == 9 || (level == 11 && constraints&0x10 != 0 &&
+		(profile == h264ProfileBaseline || profile == h264ProfileMain || profile == h264ProfileExtended)) {
+		return "1b"
+	}
+	return fmt.Sprintf("%d.%d", level/10, level%10)
+}
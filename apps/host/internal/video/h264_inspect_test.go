@@ -0,0 +1,293 @@
+package video
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// bitWriter writes the bits and Exp-Golomb codes of an RBSP
+type bitWriter struct {
+	data []byte
+	bits int
+}
+
+func (w *bitWriter) writeBits(value uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.bits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if value>>i&1 == 1 {
+			w.data[len(w.data)-1] |= 1 << (7 - w.bits%8)
+		}
+		w.bits++
+	}
+}
+
+func (w *bitWriter) writeFlag(flag bool) {
+	if flag {
+		w.writeBits(1, 1)
+	} else {
+		w.writeBits(0, 1)
+	}
+}
+
+func (w *bitWriter) writeUE(value uint32) {
+	value++
+	n := 0
+	for v := value; v > 1; v >>= 1 {
+		n++
+	}
+	w.writeBits(0, n)
+	w.writeBits(value, n+1)
+}
+
+func (w *bitWriter) writeSE(value int32) {
+	if value > 0 {
+		w.writeUE(uint32(2*value - 1))
+	} else {
+		w.writeUE(uint32(-2 * value))
+	}
+}
+
+// nal returns the NAL unit with the header byte, the stop bit and
+// emulation prevention bytes
+func (w *bitWriter) nal(header byte) []byte {
+	w.writeBits(1, 1)
+	for w.bits%8 != 0 {
+		w.writeBits(0, 1)
+	}
+
+	nal := []byte{header}
+	zeros := 0
+	for _, b := range w.data {
+		if zeros >= 2 && b <= 3 {
+			nal = append(nal, 3)
+			zeros = 0
+		}
+		nal = append(nal, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return nal
+}
+
+type testSPS struct {
+	profile, constraints, level int
+	widthMBs, heightMBs         uint32
+	cropBottom                  uint32
+}
+
+func buildTestSPS(sps testSPS) []byte {
+	w := &bitWriter{}
+	w.writeBits(uint32(sps.profile), 8)
+	w.writeBits(uint32(sps.constraints), 8)
+	w.writeBits(uint32(sps.level), 8)
+	w.writeUE(0) // seq_parameter_set_id
+	if hasChromaFormat(sps.profile) {
+		w.writeUE(1) // chroma_format_idc
+		w.writeUE(0) // bit_depth_luma_minus8
+		w.writeUE(0) // bit_depth_chroma_minus8
+		w.writeFlag(false)
+		w.writeFlag(true) // seq_scaling_matrix_present_flag
+		for i := 0; i < 8; i++ {
+			// only the first list is present
+			w.writeFlag(i == 0)
+			for j := 0; i == 0 && j < 16; j++ {
+				w.writeSE(1) // delta_scale
+			}
+		}
+	}
+	w.writeUE(0)       // log2_max_frame_num_minus4
+	w.writeUE(0)       // pic_order_cnt_type
+	w.writeUE(2)       // log2_max_pic_order_cnt_lsb_minus4
+	w.writeUE(1)       // max_num_ref_frames
+	w.writeFlag(false) // gaps_in_frame_num_value_allowed_flag
+	w.writeUE(sps.widthMBs - 1)
+	w.writeUE(sps.heightMBs - 1)
+	w.writeFlag(true) // frame_mbs_only_flag
+	w.writeFlag(true) // direct_8x8_inference_flag
+	w.writeFlag(sps.cropBottom > 0)
+	if sps.cropBottom > 0 {
+		w.writeUE(0)
+		w.writeUE(0)
+		w.writeUE(0)
+		w.writeUE(sps.cropBottom)
+	}
+	w.writeFlag(false) // vui_parameters_present_flag
+	return w.nal(0x67)
+}
+
+func buildTestPPS(cabac bool) []byte {
+	w := &bitWriter{}
+	w.writeUE(0) // pic_parameter_set_id
+	w.writeUE(0) // seq_parameter_set_id
+	w.writeFlag(cabac)
+	w.writeFlag(false) // bottom_field_pic_order_in_frame_present_flag
+	return w.nal(0x68)
+}
+
+func buildTestSlice(header byte, sliceType uint32) []byte {
+	w := &bitWriter{}
+	w.writeUE(0) // first_mb_in_slice
+	w.writeUE(sliceType)
+	w.writeUE(0) // pic_parameter_set_id
+	w.writeBits(0xa5, 8)
+	return w.nal(header)
+}
+
+func TestBitReader(t *testing.T) {
+	w := &bitWriter{}
+	unsigned := []uint32{0, 1, 2, 7, 255, 65535}
+	signed := []int32{0, 1, -1, 5, -300}
+	for _, v := range unsigned {
+		w.writeUE(v)
+	}
+	for _, v := range signed {
+		w.writeSE(v)
+	}
+
+	r := &bitReader{data: w.data}
+	for _, want := range unsigned {
+		got, err := r.readUE()
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+	for _, want := range signed {
+		got, err := r.readSE()
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+
+	_, err := (&bitReader{data: []byte{0x00}}).readUE()
+	require.ErrorIs(t, err, errH264Truncated)
+}
+
+func TestH264RBSP(t *testing.T) {
+	require.Equal(t, []byte{0x00, 0x00, 0x01, 0x00, 0x00, 0x03}, h264RBSP([]byte{0x67, 0x00, 0x00, 0x03, 0x01, 0x00, 0x00, 0x03, 0x03}))
+}
+
+func TestParseH264SPS(t *testing.T) {
+	sps, err := parseH264SPS(buildTestSPS(testSPS{profile: 100, level: 41, widthMBs: 120, heightMBs: 68, cropBottom: 4}))
+	require.NoError(t, err)
+	require.Equal(t, 100, sps.ProfileIDC)
+	require.Equal(t, 41, sps.LevelIDC)
+	require.Equal(t, 1920, sps.Width)
+	require.Equal(t, 1080, sps.Height)
+
+	sps, err = parseH264SPS(buildTestSPS(testSPS{profile: 66, constraints: 0xc0, level: 31, widthMBs: 80, heightMBs: 45}))
+	require.NoError(t, err)
+	require.Equal(t, 1280, sps.Width)
+	require.Equal(t, 720, sps.Height)
+	require.Equal(t, "Constrained Baseline", h264ProfileName(sps.ProfileIDC, sps.ConstraintFlags))
+	require.Equal(t, "3.1", h264LevelName(sps.LevelIDC, sps.ProfileIDC, sps.ConstraintFlags))
+
+	_, err = parseH264SPS([]byte{0x67, 0x64, 0x00})
+	require.ErrorIs(t, err, errH264Truncated)
+}
+
+func TestH264Inspector(t *testing.T) {
+	var (
+		sps = buildTestSPS(testSPS{profile: 100, level: 41, widthMBs: 120, heightMBs: 68, cropBottom: 4})
+		pps = buildTestPPS(true)
+		idr = buildTestSlice(0x65, 7)
+		p   = buildTestSlice(0x41, 5)
+	)
+
+	var stream []byte
+	for gop := 0; gop < 3; gop++ {
+		stream = append(stream, annexB(sps, pps, idr)...)
+		for i := 0; i < 3; i++ {
+			stream = append(stream, annexBWithLongStartCodes(p)...)
+		}
+	}
+
+	info, err := InspectH264(io.NopCloser(bytes.NewReader(stream)), 0)
+	require.NoError(t, err)
+	require.Equal(t, "High", info.Profile)
+	require.Equal(t, "4.1", info.Level)
+	require.Equal(t, 1920, info.Width)
+	require.Equal(t, 1080, info.Height)
+	require.True(t, info.CABAC)
+	require.Equal(t, 12, info.Frames)
+	require.Equal(t, 3, info.Types[H264FrameIDR])
+	require.Equal(t, 9, info.Types[H264FrameP])
+	require.Equal(t, []int{4, 4}, info.GOPs)
+	require.Empty(t, info.Warnings)
+	require.Equal(t, "High level 4.1 1920x1080 CABAC, 12 frames (3 IDR, 0 I, 9 P, 0 B), GOP 4", info.String())
+
+	info, err = InspectH264(io.NopCloser(bytes.NewReader(stream)), 5)
+	require.NoError(t, err)
+	require.Equal(t, 5, info.Frames)
+}
+
+func TestH264Inspector_Warnings(t *testing.T) {
+	var (
+		sps = buildTestSPS(testSPS{profile: 66, level: 31, widthMBs: 80, heightMBs: 45})
+		pps = buildTestPPS(true)
+		idr = buildTestSlice(0x65, 7)
+		b   = buildTestSlice(0x01, 6)
+	)
+
+	inspector := NewH264Inspector()
+	inspector.InspectFrame(annexB(sps, pps, idr))
+	inspector.InspectFrame(annexB(b))
+	// the second IDR frame comes without repeated headers
+	inspector.InspectFrame(annexB(idr))
+
+	info := inspector.Info()
+	require.Equal(t, 1, info.Types[H264FrameB])
+	require.Equal(t, []string{
+		"CABAC entropy coding is not allowed in the Baseline profile",
+		"stream contains B-frames, they add latency and are not decoded by every browser",
+		"IDR frame without SPS/PPS in front of it, the encoder has to repeat the headers",
+	}, info.Warnings)
+
+	inspector = NewH264Inspector()
+	inspector.InspectFrame(annexB(buildTestSlice(0x41, 0)))
+	require.Equal(t, []string{
+		"slice refers to the missing PPS 0",
+		"stream does not start with an IDR frame",
+	}, inspector.Info().Warnings)
+}
+
+func TestInspectFrames(t *testing.T) {
+	var (
+		sps = buildTestSPS(testSPS{profile: 100, level: 40, widthMBs: 80, heightMBs: 45})
+		pps = buildTestPPS(false)
+		idr = buildTestSlice(0x65, 7)
+		p   = buildTestSlice(0x41, 5)
+	)
+
+	stream := annexB(sps, pps, idr)
+	for i := 0; i < 4; i++ {
+		stream = append(stream, annexBWithLongStartCodes(p)...)
+	}
+
+	var reports []H264StreamInfo
+	frames := InspectFrames(NewAnnexBFrameReader(io.NopCloser(bytes.NewReader(stream)), CodecH264, 30), 2,
+		func(info H264StreamInfo) {
+			reports = append(reports, info)
+		})
+
+	count := 0
+	for {
+		_, err := frames.ReadFrame()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		count++
+	}
+
+	require.Equal(t, 5, count)
+	require.Len(t, reports, 1)
+	require.Equal(t, 2, reports[0].Frames)
+	require.Equal(t, "4.0", reports[0].Level)
+	require.False(t, reports[0].CABAC)
+}
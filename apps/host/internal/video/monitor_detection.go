@@ -1,10 +1,78 @@
 package video
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // MonitorInfo represents information about a monitor
 type MonitorInfo struct {
-	Width     int
-	Height    int
-	OffsetX   int
-	OffsetY   int
-	IsPrimary bool
+	// Index is the position of the monitor in the list returned by
+	// ListMonitors
+	Index       int     `json:"index"`
+	Name        string  `json:"name"`
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	OffsetX     int     `json:"offset_x"`
+	OffsetY     int     `json:"offset_y"`
+	RefreshRate float64 `json:"refresh_rate"`
+	IsPrimary   bool    `json:"is_primary"`
+}
+
+// ListMonitors returns all connected monitors with their index set
+func ListMonitors() ([]*MonitorInfo, error) {
+	monitors, err := GetAllMonitorsInfo()
+	if err != nil {
+		return nil, err
+	}
+	for i, monitor := range monitors {
+		monitor.Index = i
+	}
+	return monitors, nil
+}
+
+// ResolveMonitor returns the connected monitor that matches the selector,
+// see FindMonitor
+func ResolveMonitor(selector string) (*MonitorInfo, error) {
+	monitors, err := ListMonitors()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMonitorNotFound, err)
+	}
+	return FindMonitor(monitors, selector)
+}
+
+// FindMonitor returns the monitor that matches the selector. An empty
+// selector is the primary monitor, a number is the index of the monitor
+// and anything else is matched against the monitor name.
+func FindMonitor(monitors []*MonitorInfo, selector string) (*MonitorInfo, error) {
+	selector = strings.TrimSpace(selector)
+
+	if selector == "" {
+		for _, monitor := range monitors {
+			if monitor.IsPrimary {
+				return monitor, nil
+			}
+		}
+		if len(monitors) > 0 {
+			return monitors[0], nil
+		}
+		return nil, fmt.Errorf("%w: no monitors connected", ErrMonitorNotFound)
+	}
+
+	if index, err := strconv.Atoi(selector); err == nil {
+		for i, monitor := range monitors {
+			if i == index {
+				return monitor, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: no monitor with index %d", ErrMonitorNotFound, index)
+	}
+
+	for _, monitor := range monitors {
+		if strings.EqualFold(monitor.Name, selector) {
+			return monitor, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no monitor named %q", ErrMonitorNotFound, selector)
 }
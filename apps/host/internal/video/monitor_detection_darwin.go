@@ -145,6 +145,7 @@ func parseDisplayInfo(display Display) (*MonitorInfo, error) {
 	isPrimary := display.Main == "spdisplays_yes"
 
 	return &MonitorInfo{
+		Name:      display.Name,
 		Width:     width,
 		Height:    height,
 		OffsetX:   0, // system_profiler doesn't provide offset info easily
@@ -3,23 +3,283 @@
 
 package video
 
-import "time"
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
 
 var (
 	cachedPrimaryMonitor *MonitorInfo
 	lastPrimaryFetch     time.Time
 )
 
+// drmSysPath is the sysfs directory holding the DRM connectors
+var drmSysPath = "/sys/class/drm"
+
+// GetPrimaryMonitorInfo returns information about the primary monitor on Linux
 func GetPrimaryMonitorInfo() (*MonitorInfo, error) {
-	return nil, ErrOSNotSupported
+	if cachedPrimaryMonitor != nil && time.Since(lastPrimaryFetch) < 10*time.Minute {
+		return cachedPrimaryMonitor, nil
+	}
+
+	monitors, err := GetAllMonitorsInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, monitor := range monitors {
+		if monitor.IsPrimary {
+			cachedPrimaryMonitor = monitor
+			lastPrimaryFetch = time.Now()
+			return monitor, nil
+		}
+	}
+
+	return nil, fmt.Errorf("primary monitor not found")
 }
 
 // GetMonitorCount returns the number of connected monitors
 func GetMonitorCount() (int, error) {
-	return 0, ErrOSNotSupported
+	monitors, err := GetAllMonitorsInfo()
+	if err != nil {
+		return 0, err
+	}
+	return len(monitors), nil
 }
 
-// GetAllMonitorsInfo returns information about all connected monitors
+// GetAllMonitorsInfo returns information about all connected monitors. The
+// X server is queried over the RandR extension first, then the xrandr
+// output is parsed and finally the DRM connectors in sysfs are used.
 func GetAllMonitorsInfo() ([]*MonitorInfo, error) {
-	return nil, ErrOSNotSupported
+	monitors, err := getMonitorsFromRandR()
+	if err == nil && len(monitors) > 0 {
+		return monitors, nil
+	}
+	if err != nil {
+		log.Printf("RandR monitor query failed, falling back to xrandr: %v", err)
+	}
+
+	monitors, err = getMonitorsFromXrandr()
+	if err == nil && len(monitors) > 0 {
+		return monitors, nil
+	}
+
+	monitors, err = parseDRMConnectors(drmSysPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return monitors, nil
+}
+
+// getMonitorsFromXrandr runs the xrandr tool and parses its output
+func getMonitorsFromXrandr() ([]*MonitorInfo, error) {
+	cmd := exec.Command("xrandr", "--query")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute xrandr: %v", err)
+	}
+
+	return parseXrandrOutput(string(output))
+}
+
+var (
+	xrandrOutputLine = regexp.MustCompile(`^(\S+) connected (primary )?(\d+)x(\d+)\+(-?\d+)\+(-?\d+)`)
+	xrandrRateField  = regexp.MustCompile(`^(\d+(?:\.\d+)?)\*`)
+)
+
+// parseXrandrOutput parses the output of `xrandr --query`. Only connected
+// outputs that are part of the screen (have a geometry) are returned.
+func parseXrandrOutput(output string) ([]*MonitorInfo, error) {
+	var monitors []*MonitorInfo
+	var current *MonitorInfo
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			current = nil
+
+			match := xrandrOutputLine.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+
+			width, _ := strconv.Atoi(match[3])
+			height, _ := strconv.Atoi(match[4])
+			offsetX, _ := strconv.Atoi(match[5])
+			offsetY, _ := strconv.Atoi(match[6])
+
+			current = &MonitorInfo{
+				Name:      match[1],
+				Width:     width,
+				Height:    height,
+				OffsetX:   offsetX,
+				OffsetY:   offsetY,
+				IsPrimary: match[2] != "",
+			}
+			monitors = append(monitors, current)
+			continue
+		}
+
+		// Mode lines of the current output, the active mode is marked with '*'
+		if current == nil || current.RefreshRate != 0 {
+			continue
+		}
+		fields := strings.Fields(line)
+		for _, field := range fields[min(1, len(fields)):] {
+			if rate := xrandrRateField.FindStringSubmatch(field); rate != nil {
+				current.RefreshRate, _ = strconv.ParseFloat(rate[1], 64)
+				break
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading xrandr output: %w", err)
+	}
+
+	if len(monitors) == 0 {
+		return nil, fmt.Errorf("no monitors found in xrandr output")
+	}
+
+	ensurePrimary(monitors)
+	return monitors, nil
+}
+
+// parseDRMConnectors reads the connector entries (e.g. card0-HDMI-A-1) from
+// the given sysfs root. Sysfs carries no layout information, so the monitors
+// are placed side by side in connector order.
+func parseDRMConnectors(root string) ([]*MonitorInfo, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", root, err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		// connectors are named <card>-<connector>, plain cards have no dash
+		if strings.HasPrefix(entry.Name(), "card") && strings.Contains(entry.Name(), "-") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var monitors []*MonitorInfo
+	offsetX := 0
+	for _, name := range names {
+		dir := filepath.Join(root, name)
+
+		status, err := os.ReadFile(filepath.Join(dir, "status"))
+		if err != nil || strings.TrimSpace(string(status)) != "connected" {
+			continue
+		}
+
+		if enabled, err := os.ReadFile(filepath.Join(dir, "enabled")); err == nil &&
+			strings.TrimSpace(string(enabled)) == "disabled" {
+			continue
+		}
+
+		modes, err := os.ReadFile(filepath.Join(dir, "modes"))
+		if err != nil {
+			continue
+		}
+		width, height, ok := parseDRMMode(string(modes))
+		if !ok {
+			continue
+		}
+
+		monitor := &MonitorInfo{
+			Name:    strings.SplitN(name, "-", 2)[1],
+			Width:   width,
+			Height:  height,
+			OffsetX: offsetX,
+		}
+
+		if edid, err := os.ReadFile(filepath.Join(dir, "edid")); err == nil {
+			monitor.RefreshRate = edidPreferredRefreshRate(edid)
+		}
+
+		offsetX += width
+		monitors = append(monitors, monitor)
+	}
+
+	if len(monitors) == 0 {
+		return nil, fmt.Errorf("no connected DRM connectors found")
+	}
+
+	ensurePrimary(monitors)
+	return monitors, nil
+}
+
+// parseDRMMode parses the first (preferred) entry of a connector modes file
+func parseDRMMode(modes string) (int, int, bool) {
+	line, _, _ := strings.Cut(strings.TrimSpace(modes), "\n")
+	// interlaced modes carry an "i" suffix, e.g. 1920x1080i
+	line = strings.TrimSuffix(strings.TrimSpace(line), "i")
+
+	w, h, found := strings.Cut(line, "x")
+	if !found {
+		return 0, 0, false
+	}
+	width, err1 := strconv.Atoi(w)
+	height, err2 := strconv.Atoi(h)
+	if err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+		return 0, 0, false
+	}
+	return width, height, true
+}
+
+// edidPreferredRefreshRate computes the refresh rate of the first detailed
+// timing descriptor of an EDID block, which holds the preferred mode
+func edidPreferredRefreshRate(edid []byte) float64 {
+	const dtdOffset = 54
+	if len(edid) < dtdOffset+18 {
+		return 0
+	}
+	d := edid[dtdOffset : dtdOffset+18]
+
+	pixelClock := float64(int(d[0])|int(d[1])<<8) * 10000
+	if pixelClock == 0 {
+		return 0
+	}
+
+	hActive := int(d[2]) | int(d[4]&0xF0)<<4
+	hBlank := int(d[3]) | int(d[4]&0x0F)<<8
+	vActive := int(d[5]) | int(d[7]&0xF0)<<4
+	vBlank := int(d[6]) | int(d[7]&0x0F)<<8
+
+	total := float64((hActive + hBlank) * (vActive + vBlank))
+	if total == 0 {
+		return 0
+	}
+
+	return roundRefreshRate(pixelClock / total)
+}
+
+// roundRefreshRate rounds a refresh rate to two decimals, like xrandr does
+func roundRefreshRate(rate float64) float64 {
+	return float64(int(rate*100+0.5)) / 100
+}
+
+// ensurePrimary marks the first monitor as primary if none is
+func ensurePrimary(monitors []*MonitorInfo) {
+	for _, monitor := range monitors {
+		if monitor.IsPrimary {
+			return
+		}
+	}
+	if len(monitors) > 0 {
+		monitors[0].IsPrimary = true
+	}
 }
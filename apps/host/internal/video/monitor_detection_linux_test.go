@@ -0,0 +1,229 @@
+//go:build linux
+// +build linux
+
+package video
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const xrandrDualMonitorOutput = `Screen 0: minimum 320 x 200, current 4480 x 1440, maximum 16384 x 16384
+DP-0 connected primary 2560x1440+0+0 (normal left inverted right x axis y axis) 597mm x 336mm
+   2560x1440    143.91*+ 119.88    59.95
+   1920x1080     60.00    59.94
+HDMI-0 connected 1920x1080+2560+180 (normal left inverted right x axis y axis) 527mm x 296mm
+   1920x1080     60.00 +  74.97    59.94*   50.00
+   1280x720      60.00
+DP-1 disconnected (normal left inverted right x axis y axis)
+`
+
+const xrandrNoPrimaryOutput = `Screen 0: minimum 8 x 8, current 1920x1200, maximum 32767 x 32767
+eDP-1 connected 1920x1200+0+0 (normal left inverted right x axis y axis) 301mm x 188mm
+   1920x1200     60.03*+  48.02
+HDMI-1 connected (normal left inverted right x axis y axis)
+   1920x1080     60.00 +
+`
+
+func TestParseXrandrOutput(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		want        []MonitorInfo
+		expectError bool
+	}{
+		{
+			name:  "dual monitor with primary",
+			input: xrandrDualMonitorOutput,
+			want: []MonitorInfo{
+				{Name: "DP-0", Width: 2560, Height: 1440, OffsetX: 0, OffsetY: 0, RefreshRate: 143.91, IsPrimary: true},
+				{Name: "HDMI-0", Width: 1920, Height: 1080, OffsetX: 2560, OffsetY: 180, RefreshRate: 59.94, IsPrimary: false},
+			},
+		},
+		{
+			name:  "no primary and inactive output",
+			input: xrandrNoPrimaryOutput,
+			want: []MonitorInfo{
+				{Name: "eDP-1", Width: 1920, Height: 1200, OffsetX: 0, OffsetY: 0, RefreshRate: 60.03, IsPrimary: true},
+			},
+		},
+		{
+			name:  "negative offsets",
+			input: "DVI-D-0 connected 1280x1024+-1280+0 (normal) 376mm x 301mm\n   1280x1024     75.02*\n",
+			want: []MonitorInfo{
+				{Name: "DVI-D-0", Width: 1280, Height: 1024, OffsetX: -1280, OffsetY: 0, RefreshRate: 75.02, IsPrimary: true},
+			},
+		},
+		{
+			name:        "nothing connected",
+			input:       "Screen 0: minimum 320 x 200, current 1024 x 768, maximum 8192 x 8192\nVGA-1 disconnected\n",
+			expectError: true,
+		},
+		{
+			name:        "empty output",
+			input:       "",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			monitors, err := parseXrandrOutput(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseXrandrOutput() failed: %v", err)
+			}
+
+			assertMonitors(t, monitors, tt.want)
+		})
+	}
+}
+
+func TestParseDRMConnectors(t *testing.T) {
+	type connector struct {
+		name    string
+		status  string
+		enabled string
+		modes   string
+		edid    []byte
+	}
+
+	tests := []struct {
+		name        string
+		connectors  []connector
+		want        []MonitorInfo
+		expectError bool
+	}{
+		{
+			name: "two connected outputs",
+			connectors: []connector{
+				{name: "card0-HDMI-A-1", status: "connected", enabled: "enabled", modes: "1920x1080\n1280x720\n", edid: testEDID(148500000, 1920, 280, 1080, 45)},
+				{name: "card0-DP-1", status: "connected", enabled: "enabled", modes: "2560x1440\n", edid: testEDID(241500000, 2560, 160, 1440, 41)},
+				{name: "card0-DP-2", status: "disconnected", enabled: "disabled", modes: ""},
+			},
+			want: []MonitorInfo{
+				{Name: "DP-1", Width: 2560, Height: 1440, OffsetX: 0, RefreshRate: 59.95, IsPrimary: true},
+				{Name: "HDMI-A-1", Width: 1920, Height: 1080, OffsetX: 2560, RefreshRate: 60, IsPrimary: false},
+			},
+		},
+		{
+			name: "connected but disabled",
+			connectors: []connector{
+				{name: "card0-eDP-1", status: "connected", enabled: "disabled", modes: "1920x1200\n"},
+				{name: "card1-HDMI-A-2", status: "connected", enabled: "enabled", modes: "1920x1080i\n"},
+			},
+			want: []MonitorInfo{
+				{Name: "HDMI-A-2", Width: 1920, Height: 1080, OffsetX: 0, IsPrimary: true},
+			},
+		},
+		{
+			name: "nothing connected",
+			connectors: []connector{
+				{name: "card0-VGA-1", status: "disconnected", enabled: "disabled", modes: ""},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			// the plain card directory must be ignored
+			if err := os.MkdirAll(filepath.Join(root, "card0"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, c := range tt.connectors {
+				dir := filepath.Join(root, c.name)
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				writeFile(t, filepath.Join(dir, "status"), []byte(c.status+"\n"))
+				writeFile(t, filepath.Join(dir, "enabled"), []byte(c.enabled+"\n"))
+				writeFile(t, filepath.Join(dir, "modes"), []byte(c.modes))
+				if c.edid != nil {
+					writeFile(t, filepath.Join(dir, "edid"), c.edid)
+				}
+			}
+
+			monitors, err := parseDRMConnectors(root)
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseDRMConnectors() failed: %v", err)
+			}
+
+			assertMonitors(t, monitors, tt.want)
+		})
+	}
+}
+
+func TestRandRModeRefreshRate(t *testing.T) {
+	tests := []struct {
+		name string
+		mode randrMode
+		want float64
+	}{
+		{name: "1080p60", mode: randrMode{DotClock: 148500000, HTotal: 2200, VTotal: 1125}, want: 60},
+		{name: "1440p144", mode: randrMode{DotClock: 586586000, HTotal: 2720, VTotal: 1498}, want: 143.96},
+		{name: "interlaced", mode: randrMode{DotClock: 74250000, HTotal: 2200, VTotal: 1125, Flags: randrModeFlagInterlace}, want: 60},
+		{name: "doublescan", mode: randrMode{DotClock: 25175000, HTotal: 800, VTotal: 525, Flags: randrModeFlagDoubleScan}, want: 29.97},
+		{name: "zero totals", mode: randrMode{DotClock: 148500000}, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := tt.mode.refreshRate(); got != tt.want {
+			t.Errorf("%s: refreshRate() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// testEDID builds an EDID block whose first detailed timing descriptor
+// describes the given mode
+func testEDID(pixelClock, hActive, hBlank, vActive, vBlank int) []byte {
+	edid := make([]byte, 128)
+	d := edid[54:]
+	clock := pixelClock / 10000
+	d[0] = byte(clock)
+	d[1] = byte(clock >> 8)
+	d[2] = byte(hActive)
+	d[3] = byte(hBlank)
+	d[4] = byte(hActive>>8)<<4 | byte(hBlank>>8)
+	d[5] = byte(vActive)
+	d[6] = byte(vBlank)
+	d[7] = byte(vActive>>8)<<4 | byte(vBlank>>8)
+	return edid
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertMonitors(t *testing.T, got []*MonitorInfo, want []MonitorInfo) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d monitors, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] == nil {
+			t.Errorf("Monitor %d is nil", i)
+			continue
+		}
+		if *got[i] != want[i] {
+			t.Errorf("Monitor %d = %+v, want %+v", i, *got[i], want[i])
+		}
+	}
+}
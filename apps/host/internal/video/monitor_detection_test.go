@@ -1,6 +1,7 @@
 package video
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -144,6 +145,59 @@ func TestMonitorInfoConsistency(t *testing.T) {
 	}
 }
 
+func TestFindMonitor(t *testing.T) {
+	monitors := []*MonitorInfo{
+		{Index: 0, Name: "DP-1", Width: 2560, Height: 1440},
+		{Index: 1, Name: "HDMI-1", Width: 1920, Height: 1080, OffsetX: 2560, IsPrimary: true},
+	}
+
+	tests := []struct {
+		name     string
+		monitors []*MonitorInfo
+		selector string
+		want     string
+		wantErr  bool
+	}{
+		{name: "primary", monitors: monitors, selector: "", want: "HDMI-1"},
+		{name: "index", monitors: monitors, selector: "0", want: "DP-1"},
+		{name: "name", monitors: monitors, selector: " hdmi-1 ", want: "HDMI-1"},
+		{name: "first without primary", monitors: monitors[:1], selector: "", want: "DP-1"},
+		{name: "index out of range", monitors: monitors, selector: "2", wantErr: true},
+		{name: "unknown name", monitors: monitors, selector: "eDP-1", wantErr: true},
+		{name: "no monitors", selector: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			monitor, err := FindMonitor(tt.monitors, tt.selector)
+			if tt.wantErr {
+				if !errors.Is(err, ErrMonitorNotFound) {
+					t.Fatalf("FindMonitor(%q) error = %v, want ErrMonitorNotFound", tt.selector, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindMonitor(%q) failed: %v", tt.selector, err)
+			}
+			if monitor.Name != tt.want {
+				t.Errorf("FindMonitor(%q) = %s, want %s", tt.selector, monitor.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithMonitor(t *testing.T) {
+	source := WithMonitor(NewScreenSource(), "1")
+	if source != NewMonitorSource("1") {
+		t.Errorf("WithMonitor() on the screen = %v, want monitor source", source)
+	}
+
+	pattern := NewTestPatternSource()
+	if WithMonitor(pattern, "1") != pattern {
+		t.Error("WithMonitor() changed the test pattern source")
+	}
+}
+
 func BenchmarkGetPrimaryMonitorInfo(b *testing.B) {
 	for b.Loop() {
 		_, err := GetPrimaryMonitorInfo()
@@ -0,0 +1,114 @@
+package video
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// statsOverlayPattern is the name pattern of the file the encoder stats are
+// written to, drawtext reloads it for every frame
+const statsOverlayPattern = "imperium-overlay-*.txt"
+
+// statsOverlay holds the encoder stats drawn by the overlay filters
+type statsOverlay struct {
+	path string
+}
+
+// newStatsOverlay creates the stats file of an overlay
+func newStatsOverlay() (*statsOverlay, error) {
+	file, err := os.CreateTemp("", statsOverlayPattern)
+	if err != nil {
+		return nil, err
+	}
+	overlay := &statsOverlay{path: file.Name()}
+	_ = file.Close()
+
+	if err := overlay.update(ProcessEvent{}); err != nil {
+		overlay.close()
+		return nil, err
+	}
+	return overlay, nil
+}
+
+// update writes the stats of a progress report. The file is replaced, so
+// drawtext never reads a partially written one.
+func (o *statsOverlay) update(event ProcessEvent) error {
+	tmp := o.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(formatOverlayStats(event)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, o.path)
+}
+
+// close removes the stats file
+func (o *statsOverlay) close() {
+	_ = os.Remove(o.path)
+	_ = os.Remove(o.path + ".tmp")
+}
+
+// formatOverlayStats returns the text of the stats line. drawtext expands
+// the text of the file, so it must not contain a '%'.
+func formatOverlayStats(event ProcessEvent) string {
+	if event.Progress.Frame == 0 {
+		return fmt.Sprintf("encoder %s starting", event.Encoder)
+	}
+	return fmt.Sprintf("encode %.1f fps  %.0f kbit/s  %s",
+		event.Progress.FPS, event.Progress.Bitrate, event.Encoder)
+}
+
+// buildStatsOverlayFilter returns the drawtext filters of the overlay: the
+// host wall clock with milliseconds, the frame number and the stats file.
+// The frames are stamped with the wall clock first, so the clock shows when
+// the frame was captured, which is what glass-to-glass latency is measured
+// against.
+func buildStatsOverlayFilter(config *Config, statsFile string) string {
+	fontSize := 24
+	if config.Height > 0 {
+		fontSize = max(config.Height/36, 16)
+	}
+	textStyle := fmt.Sprintf("fontsize=%d:fontcolor=white:box=1:boxcolor=black@0.6:boxborderw=6", fontSize)
+	line := func(n int) string {
+		return fmt.Sprintf("x=%d:y=%d", fontSize/2, fontSize/2+n*fontSize*3/2)
+	}
+
+	return strings.Join([]string{
+		"settb=1/1000000",
+		"setpts=RTCTIME",
+		fmt.Sprintf("drawtext=text='%%{pts\\:localtime\\:0\\:%%X}.%%{eif\\:mod(t*1000\\,1000)\\:d\\:3}':%s:%s", line(0), textStyle),
+		fmt.Sprintf("drawtext=text='frame %%{frame_num}':%s:%s", line(1), textStyle),
+		fmt.Sprintf("drawtext=textfile='%s':reload=1:%s:%s", escapeFilterPath(statsFile), line(2), textStyle),
+	}, ",")
+}
+
+// escapeFilterPath escapes a path for a quoted filter option, ffmpeg takes
+// forward slashes on Windows as well
+func escapeFilterPath(path string) string {
+	if runtime.GOOS == "windows" {
+		path = filepath.ToSlash(path)
+	}
+	return strings.NewReplacer(`'`, `'\''`, `:`, `\:`).Replace(path)
+}
+
+// appendFilter appends the filter to the last filter chain of the
+// arguments, or adds a -vf option when the source has none
+func appendFilter(args []string, filter string) []string {
+	args = slices.Clone(args)
+	for i := len(args) - 2; i >= 0; i-- {
+		if args[i] == "-vf" || args[i] == "-filter_complex" {
+			args[i+1] += "," + filter
+			return args
+		}
+	}
+
+	// the filter options are output options, they follow the last input
+	for i := len(args) - 2; i >= 0; i-- {
+		if args[i] == "-i" {
+			return slices.Insert(args, i+2, "-vf", filter)
+		}
+	}
+	return append(args, "-vf", filter)
+}
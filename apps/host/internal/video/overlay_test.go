@@ -0,0 +1,86 @@
+package video
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendFilter(t *testing.T) {
+	args := []string{"-f", "x11grab", "-i", ":0", "-vf", "scale=1280:720"}
+	require.Equal(t, []string{"-f", "x11grab", "-i", ":0", "-vf", "scale=1280:720,drawbox"}, appendFilter(args, "drawbox"))
+	// the arguments of the source are not changed
+	require.Equal(t, "scale=1280:720", args[5])
+
+	args = []string{"-filter_complex", "gfxcapture=monitor_idx=0,hwdownload,format=bgra,format=nv12"}
+	require.Equal(t, []string{"-filter_complex", "gfxcapture=monitor_idx=0,hwdownload,format=bgra,format=nv12,drawbox"}, appendFilter(args, "drawbox"))
+
+	args = []string{"-re", "-f", "lavfi", "-i", "testsrc2", "-map", "0:v"}
+	require.Equal(t, []string{"-re", "-f", "lavfi", "-i", "testsrc2", "-vf", "drawbox", "-map", "0:v"}, appendFilter(args, "drawbox"))
+}
+
+func TestBuildStatsOverlayFilter(t *testing.T) {
+	config := NewDefaultConfig()
+	config.Height = 720
+
+	filter := buildStatsOverlayFilter(config, "/tmp/imperium-overlay-1.txt")
+	require.True(t, strings.HasPrefix(filter, "settb=1/1000000,setpts=RTCTIME,"))
+	require.Contains(t, filter, `drawtext=text='%{pts\:localtime\:0\:%X}.%{eif\:mod(t*1000\,1000)\:d\:3}':x=10:y=10:fontsize=20`)
+	require.Contains(t, filter, "drawtext=text='frame %{frame_num}':x=10:y=40:")
+	require.Contains(t, filter, "drawtext=textfile='/tmp/imperium-overlay-1.txt':reload=1:x=10:y=70:")
+
+	require.Equal(t, `C\:/Users/it'\''s/overlay.txt`, escapeFilterPath(`C:/Users/it's/overlay.txt`))
+}
+
+func TestFormatOverlayStats(t *testing.T) {
+	require.Equal(t, "encoder libx264 starting", formatOverlayStats(ProcessEvent{Encoder: "libx264"}))
+	require.Equal(t, "encode 59.9 fps  4012 kbit/s  h264_nvenc", formatOverlayStats(ProcessEvent{
+		Encoder:  "h264_nvenc",
+		Progress: Progress{Frame: 120, FPS: 59.94, Bitrate: 4012.3},
+	}))
+}
+
+func TestRecorder_SetStatsOverlay(t *testing.T) {
+	ffmpeg := newFakeFFmpeg(t)
+	argsLog := filepath.Join(t.TempDir(), "args.log")
+	t.Setenv("ARGS_LOG", argsLog)
+
+	script := "#!/bin/sh\necho \"$@\" >> \"$ARGS_LOG\"\nread q\n"
+	require.NoError(t, os.WriteFile(ffmpeg.path, []byte(script), 0o755))
+
+	config := NewDefaultConfig()
+	require.NoError(t, config.Validate())
+	recorder := &Recorder{config: config, ffmpeg: ffmpeg}
+
+	source := NewTestPatternSource()
+	recorder.Configure(source, StreamParams{})
+	_, err := recorder.Start(source)
+	require.NoError(t, err)
+
+	require.NoError(t, recorder.SetStatsOverlay(true))
+	require.True(t, recorder.StatsOverlay())
+	statsFile := recorder.overlay.Load().path
+	require.FileExists(t, statsFile)
+
+	var runs []string
+	require.Eventually(t, func() bool {
+		data, _ := os.ReadFile(argsLog)
+		runs = strings.Split(strings.TrimSpace(string(data)), "\n")
+		return len(runs) == 2
+	}, 2*processStopTimeout, 10*time.Millisecond)
+
+	require.NotContains(t, runs[0], "drawtext=textfile")
+	require.Contains(t, runs[1], "drawtext=textfile")
+
+	require.NoError(t, recorder.Stop())
+	require.NoFileExists(t, statsFile)
+
+	// the overlay stays enabled for the next recording
+	require.True(t, recorder.StatsOverlay())
+	require.NoError(t, recorder.SetStatsOverlay(false))
+	require.False(t, recorder.StatsOverlay())
+}
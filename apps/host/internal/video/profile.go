@@ -0,0 +1,397 @@
+package video
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RateControl is the rate-control mode of an encoder profile
+type RateControl string
+
+const (
+	RateControlCBR RateControl = "cbr" // constant bitrate
+	RateControlVBR RateControl = "vbr" // variable bitrate capped at MaxBitrate
+	RateControlCQ  RateControl = "cq"  // constant quality (CRF/CQ/ICQ/QP)
+)
+
+// Preset is an encoder independent speed/quality trade-off, every encoder
+// family maps it to its own preset names
+type Preset string
+
+const (
+	PresetFastest  Preset = "fastest"
+	PresetFast     Preset = "fast"
+	PresetBalanced Preset = "balanced"
+	PresetQuality  Preset = "quality"
+)
+
+// ColorRange is the YUV range of the encoded output
+type ColorRange string
+
+const (
+	ColorRangeLimited ColorRange = "tv"
+	ColorRangeFull    ColorRange = "pc"
+)
+
+const (
+	defaultBitrate    = 8000 // kbit/s
+	maxQuality        = 51
+	maxProfileFPS     = 240
+	maxProfileBitrate = 500000
+)
+
+var (
+	rateControls = []RateControl{RateControlCBR, RateControlVBR, RateControlCQ}
+	presets      = []Preset{PresetFastest, PresetFast, PresetBalanced, PresetQuality}
+	colorRanges  = []ColorRange{ColorRangeLimited, ColorRangeFull}
+)
+
+// Profile describes how the captured video is encoded. Zero values are
+// replaced by defaults, see WithDefaults.
+type Profile struct {
+	Name             string      `mapstructure:"name" json:"name" yaml:"name"`
+	RateControl      RateControl `mapstructure:"rate_control" json:"rate_control" yaml:"rate_control"`
+	Bitrate          int         `mapstructure:"bitrate" json:"bitrate" yaml:"bitrate"`             // kbit/s
+	MaxBitrate       int         `mapstructure:"max_bitrate" json:"max_bitrate" yaml:"max_bitrate"` // kbit/s
+	BufferSize       int         `mapstructure:"buffer_size" json:"buffer_size" yaml:"buffer_size"` // kbit
+	Quality          int         `mapstructure:"quality" json:"quality" yaml:"quality"`             // CRF/CQ value, used by RateControlCQ
+	KeyframeInterval int         `mapstructure:"keyframe_interval" json:"keyframe_interval" yaml:"keyframe_interval"`
+	Preset           Preset      `mapstructure:"preset" json:"preset" yaml:"preset"`
+	Width            int         `mapstructure:"width" json:"width" yaml:"width"`    // 0 keeps the capture resolution
+	Height           int         `mapstructure:"height" json:"height" yaml:"height"` // 0 keeps the capture resolution
+	ColorRange       ColorRange  `mapstructure:"color_range" json:"color_range" yaml:"color_range"`
+}
+
+// NewDefaultProfile returns the low-latency profile used when no profile
+// was configured
+func NewDefaultProfile() Profile {
+	return Profile{
+		Name:        "default",
+		RateControl: RateControlCBR,
+		Bitrate:     defaultBitrate,
+		MaxBitrate:  defaultBitrate,
+		BufferSize:  defaultBitrate / 4,
+		Preset:      PresetFast,
+		ColorRange:  ColorRangeLimited,
+	}
+}
+
+// WithDefaults returns a copy of the profile with all unset fields filled
+// in, fps is used for the keyframe interval
+func (p Profile) WithDefaults(fps int) Profile {
+	if p.RateControl == "" {
+		p.RateControl = RateControlCBR
+	}
+	if p.Bitrate == 0 && p.RateControl != RateControlCQ {
+		p.Bitrate = defaultBitrate
+	}
+	if p.MaxBitrate == 0 && p.RateControl != RateControlCQ {
+		p.MaxBitrate = p.Bitrate
+	}
+	if p.BufferSize == 0 && p.MaxBitrate > 0 {
+		// a quarter of a second worth of data keeps latency low
+		p.BufferSize = p.MaxBitrate / 4
+	}
+	if p.Quality == 0 && p.RateControl == RateControlCQ {
+		p.Quality = 23
+	}
+	if p.KeyframeInterval == 0 {
+		p.KeyframeInterval = fps
+	}
+	if p.Preset == "" {
+		p.Preset = PresetFast
+	}
+	if p.ColorRange == "" {
+		p.ColorRange = ColorRangeLimited
+	}
+	return p
+}
+
+// WithMaxBitrate returns a copy of the profile that does not exceed the
+// given bitrate in kbit/s
+func (p Profile) WithMaxBitrate(kbps int) Profile {
+	if kbps <= 0 {
+		return p
+	}
+	previousMax := p.MaxBitrate
+
+	switch p.RateControl {
+	case RateControlCBR:
+		p.Bitrate = min(p.Bitrate, kbps)
+		p.MaxBitrate = p.Bitrate
+	case RateControlVBR:
+		p.Bitrate = min(p.Bitrate, kbps)
+		p.MaxBitrate = min(p.MaxBitrate, kbps)
+	case RateControlCQ:
+		if p.MaxBitrate == 0 || p.MaxBitrate > kbps {
+			p.MaxBitrate = kbps
+		}
+	}
+
+	// keep the buffer at the same duration
+	if previousMax > 0 && p.BufferSize > 0 {
+		p.BufferSize = max(p.BufferSize*p.MaxBitrate/previousMax, 1)
+	} else if p.BufferSize == 0 {
+		p.BufferSize = p.MaxBitrate / 4
+	}
+	return p
+}
+
+// Validate checks that the profile values are usable by every encoder family
+func (p Profile) Validate() error {
+	if !slices.Contains(rateControls, p.RateControl) {
+		return fmt.Errorf("%w: unknown rate control %q", ErrInvalidProfile, p.RateControl)
+	}
+
+	switch p.RateControl {
+	case RateControlCBR, RateControlVBR:
+		if p.Bitrate <= 0 || p.Bitrate > maxProfileBitrate {
+			return fmt.Errorf("%w: bitrate must be between 1 and %d kbit/s", ErrInvalidProfile, maxProfileBitrate)
+		}
+		if p.MaxBitrate != 0 && p.MaxBitrate < p.Bitrate {
+			return fmt.Errorf("%w: max bitrate is lower than the bitrate", ErrInvalidProfile)
+		}
+		if p.RateControl == RateControlCBR && p.MaxBitrate != 0 && p.MaxBitrate != p.Bitrate {
+			return fmt.Errorf("%w: max bitrate must equal the bitrate for cbr", ErrInvalidProfile)
+		}
+	case RateControlCQ:
+		if p.Quality < 0 || p.Quality > maxQuality {
+			return fmt.Errorf("%w: quality must be between 0 and %d", ErrInvalidProfile, maxQuality)
+		}
+		if p.MaxBitrate < 0 {
+			return fmt.Errorf("%w: max bitrate cannot be negative", ErrInvalidProfile)
+		}
+	}
+
+	if p.BufferSize < 0 {
+		return fmt.Errorf("%w: buffer size cannot be negative", ErrInvalidProfile)
+	}
+	if p.KeyframeInterval < 0 {
+		return fmt.Errorf("%w: keyframe interval cannot be negative", ErrInvalidProfile)
+	}
+	if !slices.Contains(presets, p.Preset) {
+		return fmt.Errorf("%w: unknown preset %q", ErrInvalidProfile, p.Preset)
+	}
+	if !slices.Contains(colorRanges, p.ColorRange) {
+		return fmt.Errorf("%w: unknown color range %q", ErrInvalidProfile, p.ColorRange)
+	}
+
+	if (p.Width == 0) != (p.Height == 0) {
+		return fmt.Errorf("%w: width and height must be set together", ErrInvalidProfile)
+	}
+	if p.Width < 0 || p.Height < 0 {
+		return fmt.Errorf("%w: resolution cannot be negative", ErrInvalidProfile)
+	}
+	if p.Width%2 != 0 || p.Height%2 != 0 {
+		// 4:2:0 chroma subsampling needs even dimensions
+		return fmt.Errorf("%w: resolution must be even", ErrInvalidProfile)
+	}
+
+	return nil
+}
+
+// ParseBitrate parses an ffmpeg style bitrate such as "8M", "2500k" or
+// "6000" (kbit/s) into kbit/s
+func ParseBitrate(value string) (int, error) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	if value == "" {
+		return 0, fmt.Errorf("empty bitrate")
+	}
+
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(value, "m"):
+		multiplier = 1000
+		value = strings.TrimSuffix(value, "m")
+	case strings.HasSuffix(value, "k"):
+		value = strings.TrimSuffix(value, "k")
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("invalid bitrate %q", value)
+	}
+
+	return int(number * multiplier), nil
+}
+
+// encoderFamily groups encoders that share the same set of ffmpeg options
+type encoderFamily string
+
+const (
+	familyX264    encoderFamily = "x264"
+	familyX265    encoderFamily = "x265"
+	familyNVENC   encoderFamily = "nvenc"
+	familyQSV     encoderFamily = "qsv"
+	familyAMF     encoderFamily = "amf"
+	familyVAAPI   encoderFamily = "vaapi"
+	familyGeneric encoderFamily = "generic"
+)
+
+// getEncoderFamily returns the family of the given ffmpeg encoder name
+func getEncoderFamily(encoder string) encoderFamily {
+	switch {
+	case strings.Contains(encoder, "libx264"):
+		return familyX264
+	case strings.Contains(encoder, "libx265"):
+		return familyX265
+	case strings.Contains(encoder, "nvenc"):
+		return familyNVENC
+	case strings.Contains(encoder, "qsv"):
+		return familyQSV
+	case strings.Contains(encoder, "amf"):
+		return familyAMF
+	case strings.Contains(encoder, "vaapi"):
+		return familyVAAPI
+	default:
+		return familyGeneric
+	}
+}
+
+// isHardwareEncoder reports whether the encoder runs on the GPU and expects
+// nv12 input
+func isHardwareEncoder(encoder string) bool {
+	switch getEncoderFamily(encoder) {
+	case familyNVENC, familyQSV, familyAMF:
+		return true
+	default:
+		return false
+	}
+}
+
+var familyPresets = map[encoderFamily]map[Preset]string{
+	familyX264:  {PresetFastest: "ultrafast", PresetFast: "veryfast", PresetBalanced: "medium", PresetQuality: "slow"},
+	familyX265:  {PresetFastest: "ultrafast", PresetFast: "veryfast", PresetBalanced: "medium", PresetQuality: "slow"},
+	familyNVENC: {PresetFastest: "p1", PresetFast: "p2", PresetBalanced: "p4", PresetQuality: "p6"},
+	familyQSV:   {PresetFastest: "veryfast", PresetFast: "faster", PresetBalanced: "medium", PresetQuality: "slower"},
+	familyAMF:   {PresetFastest: "speed", PresetFast: "speed", PresetBalanced: "balanced", PresetQuality: "quality"},
+	// VAAPI has no presets, the compression level is driver specific but
+	// lower values are slower on all common drivers
+	familyVAAPI:   {PresetFastest: "7", PresetFast: "5", PresetBalanced: "4", PresetQuality: "1"},
+	familyGeneric: {PresetFastest: "ultrafast", PresetFast: "veryfast", PresetBalanced: "medium", PresetQuality: "slow"},
+}
+
+// buildProfileArgs maps the profile onto the flags of the encoder family.
+// The profile is expected to have its defaults applied.
+func buildProfileArgs(encoder string, p Profile) []string {
+	family := getEncoderFamily(encoder)
+	preset := familyPresets[family][p.Preset]
+
+	bitrate := kbps(p.Bitrate)
+	maxBitrate := kbps(p.MaxBitrate)
+	bufferSize := kbps(p.BufferSize)
+	quality := strconv.Itoa(p.Quality)
+
+	var args []string
+
+	switch family {
+	case familyX264, familyX265:
+		args = append(args, "-preset", preset, "-tune", "zerolatency")
+		switch p.RateControl {
+		case RateControlCQ:
+			args = append(args, "-crf", quality)
+			if p.MaxBitrate > 0 {
+				args = append(args, "-maxrate", maxBitrate, "-bufsize", bufferSize)
+			}
+		case RateControlCBR:
+			args = append(args, "-b:v", bitrate, "-minrate", bitrate, "-maxrate", bitrate, "-bufsize", bufferSize)
+		case RateControlVBR:
+			args = append(args, "-b:v", bitrate, "-maxrate", maxBitrate, "-bufsize", bufferSize)
+		}
+		if family == familyX264 {
+			x264Params := fmt.Sprintf("repeat-headers=1:scenecut=0:keyint=%d:min-keyint=%d:no-mbtree:no-cabac:no-deblock",
+				p.KeyframeInterval, p.KeyframeInterval)
+			if p.RateControl == RateControlCBR {
+				x264Params += ":nal-hrd=cbr"
+			}
+			args = append(args,
+				"-profile:v", "baseline",
+				"-level:v", "3.1",
+				"-x264-params", x264Params,
+			)
+		} else {
+			args = append(args, "-x265-params",
+				fmt.Sprintf("scenecut=0:keyint=%d:min-keyint=%d:no-mbtree=1:repeat-headers=1",
+					p.KeyframeInterval, p.KeyframeInterval))
+		}
+
+	case familyNVENC:
+		args = append(args, "-preset", preset, "-tune", "ll")
+		switch p.RateControl {
+		case RateControlCQ:
+			args = append(args, "-rc", "vbr", "-cq", quality, "-b:v", "0")
+			if p.MaxBitrate > 0 {
+				args = append(args, "-maxrate", maxBitrate, "-bufsize", bufferSize)
+			}
+		case RateControlCBR:
+			args = append(args, "-rc", "cbr", "-b:v", bitrate, "-maxrate", bitrate, "-bufsize", bufferSize)
+		case RateControlVBR:
+			args = append(args, "-rc", "vbr", "-b:v", bitrate, "-maxrate", maxBitrate, "-bufsize", bufferSize)
+		}
+		args = append(args,
+			"-surfaces", "1",
+			"-spatial_aq", "0",
+			"-rc-lookahead", "0",
+			"-forced-idr", "1",
+			"-no-scenecut", "1",
+			"-delay", "0",
+		)
+		if strings.HasPrefix(encoder, "h264") {
+			args = append(args, "-bsf:v", "h264_metadata=aud=insert")
+		}
+
+	case familyQSV:
+		args = append(args, "-preset", preset, "-low_power", "1", "-async_depth", "1")
+		switch p.RateControl {
+		case RateControlCQ:
+			// ICQ mode
+			args = append(args, "-global_quality", quality)
+		case RateControlCBR:
+			// QSV selects CBR when maxrate equals the bitrate
+			args = append(args, "-b:v", bitrate, "-maxrate", bitrate, "-bufsize", bufferSize)
+		case RateControlVBR:
+			args = append(args, "-b:v", bitrate, "-maxrate", maxBitrate, "-bufsize", bufferSize)
+		}
+
+	case familyAMF:
+		args = append(args, "-quality", preset, "-usage", "ultralowlatency")
+		switch p.RateControl {
+		case RateControlCQ:
+			args = append(args, "-rc", "cqp", "-qp_i", quality, "-qp_p", quality)
+		case RateControlCBR:
+			args = append(args, "-rc", "cbr", "-b:v", bitrate, "-maxrate", bitrate, "-bufsize", bufferSize)
+		case RateControlVBR:
+			args = append(args, "-rc", "vbr_peak", "-b:v", bitrate, "-maxrate", maxBitrate, "-bufsize", bufferSize)
+		}
+
+	case familyVAAPI:
+		args = append(args, "-compression_level", preset)
+		switch p.RateControl {
+		case RateControlCQ:
+			args = append(args, "-rc_mode", "CQP", "-qp", quality)
+		case RateControlCBR:
+			args = append(args, "-rc_mode", "CBR", "-b:v", bitrate, "-maxrate", bitrate, "-bufsize", bufferSize)
+		case RateControlVBR:
+			args = append(args, "-rc_mode", "VBR", "-b:v", bitrate, "-maxrate", maxBitrate, "-bufsize", bufferSize)
+		}
+
+	default:
+		args = append(args, "-preset", preset)
+		switch p.RateControl {
+		case RateControlCQ:
+			args = append(args, "-q:v", quality)
+		default:
+			args = append(args, "-b:v", bitrate, "-maxrate", maxBitrate, "-bufsize", bufferSize)
+		}
+	}
+
+	args = append(args, "-g", strconv.Itoa(p.KeyframeInterval))
+	return append(args, "-color_range", string(p.ColorRange))
+}
+
+// kbps formats a kbit/s value as an ffmpeg bitrate
+func kbps(value int) string {
+	return fmt.Sprintf("%dk", value)
+}
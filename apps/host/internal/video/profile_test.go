@@ -0,0 +1,203 @@
+package video
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfile_WithDefaults(t *testing.T) {
+	profile := Profile{}.WithDefaults(60)
+
+	require.Equal(t, RateControlCBR, profile.RateControl)
+	require.Equal(t, defaultBitrate, profile.Bitrate)
+	require.Equal(t, defaultBitrate, profile.MaxBitrate)
+	require.Equal(t, defaultBitrate/4, profile.BufferSize)
+	require.Equal(t, 60, profile.KeyframeInterval)
+	require.Equal(t, PresetFast, profile.Preset)
+	require.Equal(t, ColorRangeLimited, profile.ColorRange)
+	require.NoError(t, profile.Validate())
+
+	cq := Profile{RateControl: RateControlCQ}.WithDefaults(30)
+	require.Equal(t, 23, cq.Quality)
+	require.Zero(t, cq.Bitrate)
+	require.NoError(t, cq.Validate())
+}
+
+func TestProfile_Validate(t *testing.T) {
+	valid := NewDefaultProfile().WithDefaults(30)
+
+	tests := []struct {
+		name   string
+		modify func(p *Profile)
+	}{
+		{name: "unknown rate control", modify: func(p *Profile) { p.RateControl = "abr" }},
+		{name: "zero bitrate", modify: func(p *Profile) { p.Bitrate = 0 }},
+		{name: "max bitrate below bitrate", modify: func(p *Profile) { p.RateControl = RateControlVBR; p.MaxBitrate = p.Bitrate - 1 }},
+		{name: "cbr with different max bitrate", modify: func(p *Profile) { p.MaxBitrate = p.Bitrate * 2 }},
+		{name: "quality out of range", modify: func(p *Profile) { p.RateControl = RateControlCQ; p.Quality = 60 }},
+		{name: "negative buffer size", modify: func(p *Profile) { p.BufferSize = -1 }},
+		{name: "negative keyframe interval", modify: func(p *Profile) { p.KeyframeInterval = -1 }},
+		{name: "unknown preset", modify: func(p *Profile) { p.Preset = "slowest" }},
+		{name: "unknown color range", modify: func(p *Profile) { p.ColorRange = "full" }},
+		{name: "width without height", modify: func(p *Profile) { p.Width = 1280 }},
+		{name: "odd resolution", modify: func(p *Profile) { p.Width, p.Height = 1281, 720 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := valid
+			tt.modify(&profile)
+			require.ErrorIs(t, profile.Validate(), ErrInvalidProfile)
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	config := &Config{Encoder: "libx264", FPS: 60}
+	require.NoError(t, config.Validate())
+	require.Equal(t, 60, config.KeyframeInterval)
+
+	require.ErrorIs(t, (&Config{FPS: 30}).Validate(), ErrInvalidProfile)
+	require.ErrorIs(t, (&Config{Encoder: "libx264", FPS: 0}).Validate(), ErrInvalidProfile)
+}
+
+func TestParseBitrate(t *testing.T) {
+	tests := []struct {
+		input       string
+		want        int
+		expectError bool
+	}{
+		{input: "8M", want: 8000},
+		{input: "2.5m", want: 2500},
+		{input: "1000k", want: 1000},
+		{input: "6000", want: 6000},
+		{input: "", expectError: true},
+		{input: "fast", expectError: true},
+		{input: "-1k", expectError: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseBitrate(tt.input)
+		if tt.expectError {
+			require.Error(t, err, tt.input)
+			continue
+		}
+		require.NoError(t, err, tt.input)
+		require.Equal(t, tt.want, got, tt.input)
+	}
+}
+
+func TestGetEncoderFamily(t *testing.T) {
+	require.Equal(t, familyX264, getEncoderFamily("libx264"))
+	require.Equal(t, familyX265, getEncoderFamily("libx265"))
+	require.Equal(t, familyNVENC, getEncoderFamily("hevc_nvenc"))
+	require.Equal(t, familyQSV, getEncoderFamily("h264_qsv"))
+	require.Equal(t, familyAMF, getEncoderFamily("h264_amf"))
+	require.Equal(t, familyVAAPI, getEncoderFamily("h264_vaapi"))
+	require.Equal(t, familyGeneric, getEncoderFamily("h264_videotoolbox"))
+}
+
+func TestBuildProfileArgs(t *testing.T) {
+	cbr := Profile{Bitrate: 6000, KeyframeInterval: 60}.WithDefaults(60)
+	vbr := Profile{RateControl: RateControlVBR, Bitrate: 4000, MaxBitrate: 8000, Preset: PresetQuality}.WithDefaults(30)
+	cq := Profile{RateControl: RateControlCQ, Quality: 20, ColorRange: ColorRangeFull}.WithDefaults(30)
+
+	tests := []struct {
+		name    string
+		encoder string
+		profile Profile
+		want    []string
+	}{
+		{
+			name:    "x264 cbr",
+			encoder: "libx264",
+			profile: cbr,
+			want:    []string{"-preset veryfast", "-b:v 6000k", "-minrate 6000k", "-maxrate 6000k", "-bufsize 1500k", "keyint=60:min-keyint=60", "nal-hrd=cbr", "-g 60", "-color_range tv"},
+		},
+		{
+			name:    "x265 crf",
+			encoder: "libx265",
+			profile: cq,
+			want:    []string{"-crf 20", "-x265-params scenecut=0:keyint=30", "-color_range pc"},
+		},
+		{
+			name:    "nvenc vbr",
+			encoder: "h264_nvenc",
+			profile: vbr,
+			want:    []string{"-preset p6", "-rc vbr", "-b:v 4000k", "-maxrate 8000k", "-bufsize 2000k", "-bsf:v h264_metadata=aud=insert", "-g 30"},
+		},
+		{
+			name:    "nvenc cq",
+			encoder: "hevc_nvenc",
+			profile: cq,
+			want:    []string{"-rc vbr", "-cq 20", "-b:v 0"},
+		},
+		{
+			name:    "qsv cbr",
+			encoder: "h264_qsv",
+			profile: cbr,
+			want:    []string{"-preset faster", "-b:v 6000k", "-maxrate 6000k"},
+		},
+		{
+			name:    "qsv icq",
+			encoder: "hevc_qsv",
+			profile: cq,
+			want:    []string{"-global_quality 20"},
+		},
+		{
+			name:    "amf vbr",
+			encoder: "h264_amf",
+			profile: vbr,
+			want:    []string{"-quality quality", "-rc vbr_peak", "-maxrate 8000k"},
+		},
+		{
+			name:    "vaapi cqp",
+			encoder: "h264_vaapi",
+			profile: cq,
+			want:    []string{"-compression_level 5", "-rc_mode CQP", "-qp 20"},
+		},
+		{
+			name:    "vaapi cbr",
+			encoder: "hevc_vaapi",
+			profile: cbr,
+			want:    []string{"-rc_mode CBR", "-b:v 6000k"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := strings.Join(buildProfileArgs(tt.encoder, tt.profile), " ")
+			for _, want := range tt.want {
+				require.Contains(t, args, want)
+			}
+		})
+	}
+
+	require.NotContains(t, strings.Join(buildProfileArgs("hevc_nvenc", vbr), " "), "h264_metadata")
+	require.NotContains(t, strings.Join(buildProfileArgs("libx264", vbr), " "), "nal-hrd")
+}
+
+func TestProfile_WithMaxBitrate(t *testing.T) {
+	cbr := NewDefaultProfile().WithDefaults(30)
+	capped := cbr.WithMaxBitrate(4000)
+	require.Equal(t, 4000, capped.Bitrate)
+	require.Equal(t, 4000, capped.MaxBitrate)
+	require.Equal(t, 1000, capped.BufferSize)
+	require.NoError(t, capped.Validate())
+	require.Equal(t, cbr, cbr.WithMaxBitrate(20000))
+
+	vbr := Profile{RateControl: RateControlVBR, Bitrate: 6000, MaxBitrate: 12000, BufferSize: 6000}.WithDefaults(30)
+	capped = vbr.WithMaxBitrate(8000)
+	require.Equal(t, 6000, capped.Bitrate)
+	require.Equal(t, 8000, capped.MaxBitrate)
+	require.Equal(t, 4000, capped.BufferSize)
+	require.NoError(t, capped.Validate())
+
+	cq := Profile{RateControl: RateControlCQ}.WithDefaults(30)
+	capped = cq.WithMaxBitrate(5000)
+	require.Equal(t, 5000, capped.MaxBitrate)
+	require.Equal(t, 1250, capped.BufferSize)
+	require.NoError(t, capped.Validate())
+}
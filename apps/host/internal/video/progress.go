@@ -0,0 +1,88 @@
+package video
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Progress is a single report of ffmpeg's `-progress` output
+type Progress struct {
+	Frame      int64         `json:"frame"`
+	FPS        float64       `json:"fps"`
+	Bitrate    float64       `json:"bitrate"` // kbit/s
+	TotalSize  int64         `json:"total_size"`
+	OutTime    time.Duration `json:"out_time"`
+	DupFrames  int64         `json:"dup_frames"`
+	DropFrames int64         `json:"drop_frames"`
+	Speed      float64       `json:"speed"`
+	Done       bool          `json:"done"`
+}
+
+// progressParser collects the key=value lines of one `-progress` block
+type progressParser struct {
+	current Progress
+}
+
+// parseLine consumes a single line and returns the finished report once
+// the line terminating a block was read. ok is false for lines that are not
+// part of the progress output.
+func (p *progressParser) parseLine(line string) (progress Progress, done, ok bool) {
+	key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+	if !found {
+		return Progress{}, false, false
+	}
+	value = strings.TrimSpace(value)
+
+	switch key {
+	case "frame":
+		p.current.Frame, _ = strconv.ParseInt(value, 10, 64)
+	case "fps":
+		p.current.FPS, _ = strconv.ParseFloat(value, 64)
+	case "bitrate":
+		p.current.Bitrate, _ = strconv.ParseFloat(strings.TrimSuffix(value, "kbits/s"), 64)
+	case "total_size":
+		p.current.TotalSize, _ = strconv.ParseInt(value, 10, 64)
+	case "out_time_us":
+		if us, err := strconv.ParseInt(value, 10, 64); err == nil {
+			p.current.OutTime = time.Duration(us) * time.Microsecond
+		}
+	case "dup_frames":
+		p.current.DupFrames, _ = strconv.ParseInt(value, 10, 64)
+	case "drop_frames":
+		p.current.DropFrames, _ = strconv.ParseInt(value, 10, 64)
+	case "speed":
+		p.current.Speed, _ = strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64)
+	case "progress":
+		// progress terminates every block
+		p.current.Done = value == "end"
+		progress = p.current
+		p.current = Progress{}
+		return progress, true, true
+	case "out_time_ms", "out_time":
+		// known keys that are not tracked
+	default:
+		if !strings.HasPrefix(key, "stream_") {
+			return Progress{}, false, false
+		}
+	}
+
+	return Progress{}, false, true
+}
+
+// parseProgress reads key=value blocks as written by `ffmpeg -progress` and
+// calls fn once per block. It returns when r is exhausted.
+func parseProgress(r io.Reader, fn func(Progress)) error {
+	var parser progressParser
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if progress, done, _ := parser.parseLine(scanner.Text()); done {
+			fn(progress)
+		}
+	}
+
+	return scanner.Err()
+}
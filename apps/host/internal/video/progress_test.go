@@ -0,0 +1,60 @@
+package video
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProgress(t *testing.T) {
+	output := `frame=30
+fps=29.87
+stream_0_0_q=23.0
+bitrate=4012.3kbits/s
+total_size=501234
+out_time_us=1000000
+out_time_ms=1000000
+out_time=00:00:01.000000
+dup_frames=2
+drop_frames=1
+speed=0.996x
+progress=continue
+frame=60
+fps=N/A
+bitrate=N/A
+total_size=N/A
+out_time_us=2000000
+dup_frames=2
+drop_frames=3
+speed=N/A
+progress=end
+`
+
+	var reports []Progress
+	err := parseProgress(strings.NewReader(output), func(p Progress) {
+		reports = append(reports, p)
+	})
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+
+	require.Equal(t, Progress{
+		Frame:      30,
+		FPS:        29.87,
+		Bitrate:    4012.3,
+		TotalSize:  501234,
+		OutTime:    time.Second,
+		DupFrames:  2,
+		DropFrames: 1,
+		Speed:      0.996,
+	}, reports[0])
+
+	require.Equal(t, Progress{
+		Frame:      60,
+		OutTime:    2 * time.Second,
+		DupFrames:  2,
+		DropFrames: 3,
+		Done:       true,
+	}, reports[1])
+}
@@ -0,0 +1,225 @@
+//go:build linux
+// +build linux
+
+package video
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/m1thrandir225/imperium/apps/host/internal/x11"
+)
+
+// Monitors are enumerated via the RandR extension (version 1.5 for
+// RRGetMonitors), using the X11 client of the x11 package.
+
+const (
+	randrQueryVersion              = 0
+	randrGetOutputInfo             = 9
+	randrGetCrtcInfo               = 20
+	randrGetScreenResourcesCurrent = 25
+	randrGetMonitors               = 42
+
+	randrModeFlagInterlace  = 0x10
+	randrModeFlagDoubleScan = 0x20
+)
+
+// randrConn is an X11 connection with the RandR extension initialized
+type randrConn struct {
+	*x11.Conn
+	opcode byte
+}
+
+// randrMode holds the timing fields of a RandR mode needed for the refresh rate
+type randrMode struct {
+	DotClock uint32
+	HTotal   uint16
+	VTotal   uint16
+	Flags    uint32
+}
+
+// getMonitorsFromRandR queries the X server for its RandR monitors
+func getMonitorsFromRandR() ([]*MonitorInfo, error) {
+	conn, err := x11.DialDisplay()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	c, err := initRandR(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	monitors, outputs, err := c.getMonitors()
+	if err != nil {
+		return nil, err
+	}
+
+	// Refresh rates are best effort, a failure here keeps the geometry
+	if modes, configTimestamp, err := c.getScreenModes(); err == nil {
+		for i, monitor := range monitors {
+			if len(outputs[i]) == 0 {
+				continue
+			}
+			if mode, ok := c.outputMode(outputs[i][0], configTimestamp, modes); ok {
+				monitor.RefreshRate = mode.refreshRate()
+			}
+		}
+	}
+
+	ensurePrimary(monitors)
+	return monitors, nil
+}
+
+// randrRequest builds a RandR request with the given minor opcode and
+// 32 bit arguments
+func (c *randrConn) randrRequest(minor byte, args ...uint32) []byte {
+	req := make([]byte, 4+len(args)*4)
+	req[0] = c.opcode
+	req[1] = minor
+	for i, arg := range args {
+		binary.LittleEndian.PutUint32(req[4+i*4:], arg)
+	}
+	return req
+}
+
+// initRandR looks up the RandR extension and checks for version 1.5
+func initRandR(conn *x11.Conn) (*randrConn, error) {
+	opcode, err := conn.QueryExtension("RANDR")
+	if err != nil {
+		return nil, err
+	}
+	c := &randrConn{Conn: conn, opcode: opcode}
+
+	reply, err := c.Request(c.randrRequest(randrQueryVersion, 1, 5))
+	if err != nil {
+		return nil, err
+	}
+	major := binary.LittleEndian.Uint32(reply[8:])
+	minor := binary.LittleEndian.Uint32(reply[12:])
+	if major < 1 || (major == 1 && minor < 5) {
+		return nil, fmt.Errorf("RandR %d.%d does not support monitors, need 1.5", major, minor)
+	}
+
+	return c, nil
+}
+
+// getMonitors returns the active monitors and their outputs
+func (c *randrConn) getMonitors() ([]*MonitorInfo, [][]uint32, error) {
+	reply, err := c.Request(c.randrRequest(randrGetMonitors, c.Root(), 1))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	count := int(binary.LittleEndian.Uint32(reply[12:]))
+	offset := 32
+
+	var monitors []*MonitorInfo
+	var outputs [][]uint32
+	for i := 0; i < count; i++ {
+		if offset+24 > len(reply) {
+			return nil, nil, fmt.Errorf("RandR monitors reply too short")
+		}
+		m := reply[offset:]
+		nameAtom := binary.LittleEndian.Uint32(m[0:])
+		numOutputs := int(binary.LittleEndian.Uint16(m[6:]))
+
+		monitor := &MonitorInfo{
+			Width:     int(binary.LittleEndian.Uint16(m[12:])),
+			Height:    int(binary.LittleEndian.Uint16(m[14:])),
+			OffsetX:   int(int16(binary.LittleEndian.Uint16(m[8:]))),
+			OffsetY:   int(int16(binary.LittleEndian.Uint16(m[10:]))),
+			IsPrimary: m[4] != 0,
+		}
+		offset += 24
+
+		if offset+numOutputs*4 > len(reply) {
+			return nil, nil, fmt.Errorf("RandR monitors reply too short")
+		}
+		monitorOutputs := make([]uint32, numOutputs)
+		for o := range monitorOutputs {
+			monitorOutputs[o] = binary.LittleEndian.Uint32(reply[offset+o*4:])
+		}
+		offset += numOutputs * 4
+
+		if name, err := c.AtomName(nameAtom); err == nil {
+			monitor.Name = name
+		}
+
+		monitors = append(monitors, monitor)
+		outputs = append(outputs, monitorOutputs)
+	}
+
+	return monitors, outputs, nil
+}
+
+// getScreenModes returns the modes of the screen keyed by id together with
+// the config timestamp needed for output and crtc queries
+func (c *randrConn) getScreenModes() (map[uint32]randrMode, uint32, error) {
+	reply, err := c.Request(c.randrRequest(randrGetScreenResourcesCurrent, c.Root()))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	configTimestamp := binary.LittleEndian.Uint32(reply[12:])
+	numCrtcs := int(binary.LittleEndian.Uint16(reply[16:]))
+	numOutputs := int(binary.LittleEndian.Uint16(reply[18:]))
+	numModes := int(binary.LittleEndian.Uint16(reply[20:]))
+
+	offset := 32 + (numCrtcs+numOutputs)*4
+	if offset+numModes*32 > len(reply) {
+		return nil, 0, fmt.Errorf("RandR screen resources reply too short")
+	}
+
+	modes := make(map[uint32]randrMode, numModes)
+	for i := 0; i < numModes; i++ {
+		m := reply[offset+i*32:]
+		modes[binary.LittleEndian.Uint32(m[0:])] = randrMode{
+			DotClock: binary.LittleEndian.Uint32(m[8:]),
+			HTotal:   binary.LittleEndian.Uint16(m[16:]),
+			VTotal:   binary.LittleEndian.Uint16(m[24:]),
+			Flags:    binary.LittleEndian.Uint32(m[28:]),
+		}
+	}
+
+	return modes, configTimestamp, nil
+}
+
+// outputMode returns the mode currently driving the given output
+func (c *randrConn) outputMode(output, configTimestamp uint32, modes map[uint32]randrMode) (randrMode, bool) {
+	reply, err := c.Request(c.randrRequest(randrGetOutputInfo, output, configTimestamp))
+	if err != nil {
+		return randrMode{}, false
+	}
+	crtc := binary.LittleEndian.Uint32(reply[12:])
+	if crtc == 0 {
+		return randrMode{}, false
+	}
+
+	reply, err = c.Request(c.randrRequest(randrGetCrtcInfo, crtc, configTimestamp))
+	if err != nil {
+		return randrMode{}, false
+	}
+
+	mode, ok := modes[binary.LittleEndian.Uint32(reply[20:])]
+	return mode, ok
+}
+
+// refreshRate computes the vertical refresh rate of the mode the same way
+// xrandr does
+func (m randrMode) refreshRate() float64 {
+	vTotal := float64(m.VTotal)
+	if m.Flags&randrModeFlagDoubleScan != 0 {
+		vTotal *= 2
+	}
+	if m.Flags&randrModeFlagInterlace != 0 {
+		vTotal /= 2
+	}
+
+	if m.HTotal == 0 || vTotal == 0 {
+		return 0
+	}
+
+	return roundRefreshRate(float64(m.DotClock) / (float64(m.HTotal) * vTotal))
+}
@@ -1,12 +1,15 @@
 package video
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,12 +17,37 @@ import (
 type Recorder struct {
 	ffmpeg *FFMPEGWrapper
 	config *Config
+
+	mu               sync.Mutex
+	supervisor       *Supervisor
+	source           CaptureSource
+	frames           *annexBFrameReader
+	fallbackEncoders []string
+	onEvent          func(ProcessEvent)
+
+	// paramsMu is separate from mu as the supervisor reads the parameters
+	// while building the arguments of a restart
+	paramsMu sync.Mutex
+	params   StreamParams
+
+	// showOverlay is set while the stats overlay is shown, overlay holds
+	// its stats file until the recording stops
+	showOverlay atomic.Bool
+	overlay     atomic.Pointer[statsOverlay]
 }
 
 // NewRecorder returns a new Recorder instance based on the given config
 func NewRecorder(config *Config) (*Recorder, error) {
+	if config == nil {
+		config = NewDefaultConfig()
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	path := "ffmpeg"
-	if config != nil && config.FFMPEGPath != "" {
+	if config.FFMPEGPath != "" {
 		path = config.FFMPEGPath
 	}
 
@@ -29,196 +57,408 @@ func NewRecorder(config *Config) (*Recorder, error) {
 	}
 
 	return &Recorder{
-		config: config,
-		ffmpeg: ffmpegWrapper,
+		config:           config,
+		ffmpeg:           ffmpegWrapper,
+		fallbackEncoders: config.FallbackEncoders,
 	}, nil
 }
 
-func (r *Recorder) buildBaseArgs() []string {
+func buildBaseArgs(config *Config) []string {
 	return []string{
-		"-framerate", fmt.Sprintf("%d", r.config.FPS),
+		"-framerate", fmt.Sprintf("%d", config.FPS),
 		"-probesize", "42M",
 		"-thread_queue_size", "4096",
 	}
 }
 
-func (r *Recorder) buildOutputArgs(outputPath string) []string {
-	return []string{
-		"-c:v", r.config.Encoder,
-		"-preset", "medium",
-		"-pix_fmt", "yuv420p",
-		"-y", // OVERWRITE IF EXISTS
-		outputPath,
+// buildStdOutputArgs writes the raw Annex-B stream of the codec to stdout
+func buildStdOutputArgs(config *Config) []string {
+	format := "h264"
+	if codecForEncoder(config.Encoder) == CodecH265 {
+		format = "hevc"
 	}
-}
 
-func (r *Recorder) buildStdOutputArgs() []string {
 	return []string{
-		"-f", "h264",
+		"-an",
+		"-f", format,
 		"-",
 	}
 }
 
-func (r *Recorder) convertRGBToBT709() []string {
+// convertRGBToBT709 returns the filter arguments converting an RGB capture
+// to BT.709 YUV, filters are applied before scaling
+func convertRGBToBT709(config *Config, filters ...string) []string {
+	if scale := buildScaleFilter(config); scale != "" {
+		filters = append(filters, scale)
+	}
+	filters = append(filters, fmt.Sprintf("colorspace=all=bt709:iall=bt709:range=%s,format=yuv420p", config.ColorRange))
+
 	return []string{
-		"-vf", "colorspace=all=bt709:iall=bt709:range=tv,format=yuv420p",
-		"-colorspace", "bt709", "-color_trc", "bt709", "-color_primaries", "bt709", "-color_range", "tv",
+		"-vf", strings.Join(filters, ","),
+		"-colorspace", "bt709", "-color_trc", "bt709", "-color_primaries", "bt709", "-color_range", string(config.ColorRange),
+	}
+}
+
+// buildScaleFilter returns the scale filter for the output resolution of
+// the profile, or an empty string when the capture resolution is kept
+func buildScaleFilter(config *Config) string {
+	if config.Width == 0 || config.Height == 0 {
+		return ""
 	}
+	// fit into the resolution without distorting the image
+	return fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease:force_divisible_by=2", config.Width, config.Height)
 }
 
-func (r *Recorder) buildCommonLowLatencyArgs() []string {
+func buildCommonLowLatencyArgs(config *Config) []string {
 	return []string{
-		"-an",
-		"-c:v", r.config.Encoder,
+		"-c:v", config.Encoder,
 		"-pix_fmt", "yuv420p", // This will be overridden for hardware encoders
 		"-bf", "0",
 		"-b_strategy", "0",
 		"-sc_threshold", "0",
 		"-fflags", "nobuffer",
 		"-flags", "low_delay",
-		"-g", fmt.Sprintf("%d", r.config.FPS), // ~1s GOP
-		"-force_key_frames", "expr:gte(t,n_forced*1)",
+		"-force_key_frames", fmt.Sprintf("expr:gte(n,n_forced*%d)", config.KeyframeInterval),
 		"-fps_mode", "cfr", // Use CFR instead of passthrough
-		"-r", fmt.Sprintf("%d", r.config.FPS), // Set target framerate
+		"-r", fmt.Sprintf("%d", config.FPS), // Set target framerate
 	}
 }
 
-func (r *Recorder) buildEncoderArgs(encoder string) []string {
-	if strings.Contains(encoder, "libx264") {
-		return []string{
-			"-preset", "veryfast",
-			"-tune", "zerolatency",
-			"-crf", "18", // Higher quality for games
-			"-profile:v", "baseline",
-			"-level:v", "3.1",
-			"-x264-params", fmt.Sprintf("repeat-headers=1:scenecut=0:keyint=%d:min-keyint=%d:no-mbtree:no-cabac:no-deblock", r.config.FPS, r.config.FPS),
-		}
-	} else if strings.Contains(encoder, "nvenc") {
-		return []string{
-			"-preset", "p1",
-			"-tune", "ll",
-			"-bf", "0",
-			"-rc", "cbr",
-			"-b:v", "8M",
-			"-maxrate", "8M",
-			"-bufsize", "2M",
-			"-surfaces", "1",
-			"-spatial_aq", "0",
-			"-rc-lookahead", "0",
-			"-forced-idr", "1",
-			"-no-scenecut", "1",
-			"-delay", "0",
-			"-bsf:v", "h264_metadata=aud=insert",
-		}
-	} else if strings.Contains(encoder, "libx265") {
-		return []string{
-			"-preset", "ultrafast",
-			"-tune", "zerolatency",
-			"-crf", "20",
-			"-x265-params", "no-scenecut:keyint=60:min-keyint=60:no-mbtree:no-cabac:no-deblock",
+// buildSourceArgs returns the ffmpeg arguments that encode the source with
+// the profile of the config and write the stream to stdout. The filters are
+// applied after those of the source, like the stats overlay.
+func buildSourceArgs(source CaptureSource, config *Config, filters ...string) ([]string, error) {
+	args, err := source.InputArgs(config)
+	if err != nil {
+		return nil, err
+	}
+	for _, filter := range filters {
+		args = appendFilter(args, filter)
+	}
+
+	vaapi := getEncoderFamily(config.Encoder) == familyVAAPI
+	if vaapi {
+		// VAAPI encoders take frames in GPU memory, they are uploaded to
+		// the render node after all software filters
+		args = append([]string{"-vaapi_device", vaapiDevice}, appendFilter(args, "format=nv12,hwupload")...)
+	}
+
+	args = append(args, buildCommonLowLatencyArgs(config)...)
+	args = append(args, buildProfileArgs(config.Encoder, config.Profile)...)
+
+	switch {
+	case isHardwareEncoder(config.Encoder):
+		args = append(args, "-pix_fmt", "nv12")
+	case vaapi:
+		args = append(args, "-pix_fmt", "vaapi")
+	}
+
+	return append(args, buildStdOutputArgs(config)...), nil
+}
+
+// Start encodes the source and returns its frames, it implements Encoder
+func (r *Recorder) Start(source CaptureSource) (FrameReader, error) {
+	stream, err := r.record(source)
+	if err != nil {
+		return nil, err
+	}
+
+	frames := newAnnexBFrameReader(stream, r.Codec(), r.FPS())
+	r.mu.Lock()
+	r.source = source
+	r.frames = frames
+	r.mu.Unlock()
+	return frames, nil
+}
+
+// Reconfigure applies new stream parameters to the running recording. The
+// ffmpeg process is restarted with them behind the same output stream, a
+// new process always starts with an IDR frame.
+func (r *Recorder) Reconfigure(requested StreamParams) (StreamParams, error) {
+	r.mu.Lock()
+	source, supervisor, frames := r.source, r.supervisor, r.frames
+	r.mu.Unlock()
+
+	if source == nil || supervisor == nil {
+		return StreamParams{}, ErrNotRecording
+	}
+
+	effective := r.Configure(source, requested)
+	if frames != nil {
+		frames.setFPS(r.FPS())
+	}
+	supervisor.Restart()
+	return effective, nil
+}
+
+// Configure clamps the requested parameters to the config and the source,
+// they are used by every recording until Configure is called again
+func (r *Recorder) Configure(source CaptureSource, requested StreamParams) StreamParams {
+	var sourceWidth, sourceHeight int
+	if sized, ok := source.(sizedSource); ok {
+		width, height, err := sized.Size(r.config)
+		if err != nil {
+			log.Printf("Failed to get the resolution of %s: %v", source.Name(), err)
 		}
-	} else {
-		return []string{
-			"-preset", "medium",
-			"-bf", "0",
-			"-b:v", "8M",
-			"-maxrate", "10M",
-			"-bufsize", "10M",
+		sourceWidth, sourceHeight = width, height
+	}
+
+	effective := clampStreamParams(requested, streamLimits(r.config, sourceWidth, sourceHeight))
+	applied := effective
+	if sourceWidth > 0 && sourceHeight > 0 && effective.Width > 0 {
+		effective.Width, effective.Height = FitResolution(sourceWidth, sourceHeight, effective.Width, effective.Height)
+		if effective.Width == evenDown(sourceWidth) && effective.Height == evenDown(sourceHeight) {
+			// no need to scale
+			applied.Width, applied.Height = 0, 0
 		}
 	}
-}
 
-// RecordWindow TODO: add linux/macos support for window recording
-func (r *Recorder) RecordWindow(windowTitle string, outputPath *string) (io.ReadCloser, error) {
-	args := r.buildBaseArgs()
+	r.paramsMu.Lock()
+	r.params = applied
+	r.paramsMu.Unlock()
+
+	log.Printf("Stream parameters for %s: requested %s, effective %s", source.Name(), requested, effective)
+	return effective
+}
 
-	switch runtime.GOOS {
-	case "windows":
-		// Use GDI capture with optimizations for games
-		args = append(args, "-f", "gdigrab")
-		args = append(args, "-i", fmt.Sprintf("title=%s", windowTitle))
-		args = append(args, "-draw_mouse", "0")
-		args = append(args, "-show_region", "1")
+// Stop stops the recording, it implements Encoder
+func (r *Recorder) Stop() error {
+	return r.StopRecording()
+}
 
-		// Add some optimizations for better game capture
-		args = append(args, "-fflags", "nobuffer+fastseek")
-		args = append(args, "-flags", "low_delay")
+// Codec returns the codec of the configured encoder
+func (r *Recorder) Codec() Codec {
+	return codecForEncoder(r.config.Encoder)
+}
 
-	default:
-		return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+// FPS returns the frame rate of the recording
+func (r *Recorder) FPS() int {
+	if params := r.streamParams(); params.FPS > 0 {
+		return params.FPS
 	}
+	return r.GetFPS()
+}
 
-	args = append(args, r.convertRGBToBT709()...)
-	args = append(args, r.buildCommonLowLatencyArgs()...)
-	args = append(args, r.buildEncoderArgs(r.config.Encoder)...)
-	args = append(args, r.buildStdOutputArgs()...)
-	return r.ffmpeg.ExecuteWithStdout(args...)
+// streamParams returns the parameters set by Configure
+func (r *Recorder) streamParams() StreamParams {
+	r.paramsMu.Lock()
+	defer r.paramsMu.Unlock()
+	return r.params
+}
+
+// RecordWindow returns the raw stream of the given window
+func (r *Recorder) RecordWindow(windowTitle string, outputPath *string) (io.ReadCloser, error) {
+	if r.config.Source == SourceModeTestPattern {
+		return r.RecordTestPattern(outputPath)
+	}
+	return r.record(NewWindowSource(windowTitle))
 }
 
 // RecordScreen unified method - auto-selects capture based on encoder
 func (r *Recorder) RecordScreen(outputPath *string) (io.ReadCloser, error) {
-	args := r.buildBaseArgs()
+	if r.config.Source == SourceModeTestPattern {
+		return r.RecordTestPattern(outputPath)
+	}
+	return r.record(NewScreenSource())
+}
+
+// record encodes the source under a Supervisor and returns the raw stream
+func (r *Recorder) record(source CaptureSource) (io.ReadCloser, error) {
+	log.Printf("Recording %s with %s", source.Name(), r.config.Encoder)
+	stream, err := r.supervise(func(config *Config) ([]string, error) {
+		if !r.showOverlay.Load() {
+			return buildSourceArgs(source, config)
+		}
+		overlay, err := r.statsOverlay()
+		if err != nil {
+			log.Printf("Recording without the stats overlay: %v", err)
+			return buildSourceArgs(source, config)
+		}
+		return buildSourceArgs(source, config, buildStatsOverlayFilter(config, overlay.path))
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	switch runtime.GOOS {
-	case "windows":
-		// Auto-select capture method based on encoder
-		if strings.Contains(r.config.Encoder, "nvenc") || strings.Contains(r.config.Encoder, "qsv") || strings.Contains(r.config.Encoder, "amf") {
+	if followed, ok := source.(followedSource); ok {
+		r.mu.Lock()
+		supervisor := r.supervisor
+		r.mu.Unlock()
+		go followed.Follow(supervisor.Done(), supervisor.Restart)
+	}
+	return stream, nil
+}
 
-			w, h := 1920, 1080
-			if mi, err := GetPrimaryMonitorInfo(); err == nil && mi.Width > 0 && mi.Height > 0 {
-				w, h = mi.Width, mi.Height
-				fmt.Printf("Using gfxcapture with primary monitor: %dx%d\n", w, h)
-			}
+// supervise starts ffmpeg under a Supervisor. build is called with a copy
+// of the config for the encoder in use, so a fallback encoder also gets its
+// matching capture method and flags.
+func (r *Recorder) supervise(build func(config *Config) ([]string, error)) (io.ReadCloser, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.supervisor != nil {
+		_ = r.supervisor.Stop()
+		r.supervisor = nil
+	}
 
-			filter := fmt.Sprintf(
-				"gfxcapture=monitor_idx=0:width=%d:height=%d:resize_mode=scale_aspect:output_fmt=8bit,hwdownload,format=bgra,format=nv12",
-				w, h,
-			)
-			args = append(args, "-filter_complex", filter)
-			args = append(args, "-filter_threads", "2")
-			args = append(args, "-filter_complex_threads", "2")
-
-		} else {
-
-			monitorInfo, err := GetPrimaryMonitorInfo()
-			if err != nil {
-				fmt.Printf("Warning: Could not detect primary monitor, using full desktop: %v\n", err)
-				args = append(args, "-f", "gdigrab")
-				args = append(args, "-i", "desktop")
-			} else {
-				args = append(args, "-f", "gdigrab")
-				args = append(args, "-i", "desktop")
-				cropFilter := fmt.Sprintf("crop=%d:%d:%d:%d",
-					monitorInfo.Width,
-					monitorInfo.Height,
-					monitorInfo.OffsetX,
-					monitorInfo.OffsetY)
-				args = append(args, "-vf", cropFilter)
-				fmt.Printf("Using gdigrab with primary monitor: %dx%d at offset (%d,%d)\n",
-					monitorInfo.Width, monitorInfo.Height, monitorInfo.OffsetX, monitorInfo.OffsetY)
+	// restarts pick up the parameters set by Reconfigure
+	onEvent := r.onEvent
+	supervisor := NewSupervisor(r.ffmpeg, r.config.Encoder, r.getFallbackEncoders(),
+		func(encoder string) ([]string, error) {
+			return build(r.configForEncoder(encoder, r.streamParams()))
+		},
+		func(event ProcessEvent) {
+			if overlay := r.overlay.Load(); overlay != nil && event.Type == ProcessProgress && r.showOverlay.Load() {
+				if err := overlay.update(event); err != nil {
+					log.Printf("Failed to update the stats overlay: %v", err)
+				}
 			}
+			if onEvent != nil {
+				onEvent(event)
+			}
+		},
+	)
+
+	stream, err := supervisor.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	r.supervisor = supervisor
+	return stream, nil
+}
+
+// configForEncoder returns a copy of the config with the stream
+// parameters applied for the given encoder
+func (r *Recorder) configForEncoder(encoder string, params StreamParams) *Config {
+	config := applyStreamParams(r.config, params)
+	config.Encoder = encoder
+	return config
+}
+
+// getFallbackEncoders returns the encoders tried when the configured one
+// cannot be initialized. Unless set otherwise it is the software encoder of
+// the same codec, so the frames keep their codec after a fallback.
+func (r *Recorder) getFallbackEncoders() []string {
+	if r.fallbackEncoders != nil {
+		return r.fallbackEncoders
+	}
+	if r.Codec() == CodecH265 {
+		return []string{"libx265"}
+	}
+	return []string{"libx264"}
+}
+
+// SetFallbackEncoders sets the encoders tried in order when the configured
+// encoder cannot be initialized
+func (r *Recorder) SetFallbackEncoders(encoders []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallbackEncoders = encoders
+}
+
+// SetStatsOverlay shows or hides the encoder stats in the video, a running
+// recording is restarted behind the same output stream
+func (r *Recorder) SetStatsOverlay(enabled bool) error {
+	if enabled {
+		if _, err := r.statsOverlay(); err != nil {
+			return fmt.Errorf("failed to create the stats overlay: %w", err)
 		}
+	}
+	if r.showOverlay.Swap(enabled) == enabled {
+		return nil
+	}
+
+	r.mu.Lock()
+	supervisor := r.supervisor
+	r.mu.Unlock()
+	if supervisor != nil {
+		supervisor.Restart()
+	}
+	return nil
+}
+
+// Snapshot grabs a single image of the source with the capture method of
+// the encoder in use, it implements Encoder
+func (r *Recorder) Snapshot(ctx context.Context, source CaptureSource, opts SnapshotOptions) ([]byte, error) {
+	return snapshot(ctx, r.ffmpeg, r.configForEncoder(r.ActiveEncoder(), StreamParams{}), source, opts)
+}
 
-		args = append(args, "-fflags", "fastseek+flush_packets")
-		args = append(args, "-flags", "global_header")
+// StatsOverlay reports whether the encoder stats are shown in the video
+func (r *Recorder) StatsOverlay() bool {
+	return r.showOverlay.Load()
+}
 
-	default:
-		return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+// statsOverlay returns the stats file of the overlay, it is created on
+// first use
+func (r *Recorder) statsOverlay() (*statsOverlay, error) {
+	if overlay := r.overlay.Load(); overlay != nil {
+		return overlay, nil
 	}
 
-	args = append(args, r.buildCommonLowLatencyArgs()...)
-	args = append(args, r.buildEncoderArgs(r.config.Encoder)...)
+	overlay, err := newStatsOverlay()
+	if err != nil {
+		return nil, err
+	}
+	if !r.overlay.CompareAndSwap(nil, overlay) {
+		overlay.close()
+	}
+	return r.overlay.Load(), nil
+}
 
-	if strings.Contains(r.config.Encoder, "nvenc") || strings.Contains(r.config.Encoder, "qsv") || strings.Contains(r.config.Encoder, "amf") {
-		args = append(args, "-pix_fmt", "nv12")
+// SetEventHandler sets the handler for the lifecycle events of the
+// supervised ffmpeg process
+func (r *Recorder) SetEventHandler(fn func(ProcessEvent)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onEvent = fn
+}
+
+// EventHandler returns the handler set with SetEventHandler
+func (r *Recorder) EventHandler() func(ProcessEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.onEvent
+}
+
+// ActiveEncoder returns the encoder of the running recording, which differs
+// from the configured one after a fallback
+func (r *Recorder) ActiveEncoder() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.supervisor != nil {
+		return r.supervisor.Encoder()
 	}
+	return r.config.Encoder
+}
 
-	args = append(args, r.buildStdOutputArgs()...)
-	return r.ffmpeg.ExecuteWithStdout(args...)
+// Progress returns the last progress report of the running recording
+func (r *Recorder) Progress() Progress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.supervisor != nil {
+		return r.supervisor.Progress()
+	}
+	return Progress{}
 }
 
 func (r *Recorder) StopRecording() error {
-	if r.ffmpeg != nil {
+	r.mu.Lock()
+	supervisor := r.supervisor
+	r.supervisor = nil
+	r.source = nil
+	r.frames = nil
+	r.mu.Unlock()
+
+	// ffmpeg reads the stats file until it stopped
+	if overlay := r.overlay.Swap(nil); overlay != nil {
+		defer overlay.close()
+	}
+
+	if supervisor != nil {
+		return supervisor.Stop()
+	}
+	if r.ffmpeg != nil && r.ffmpeg.IsRunning() {
 		return r.ffmpeg.Stop()
 	}
 	return nil
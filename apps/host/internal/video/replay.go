@@ -0,0 +1,173 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// ReplayEncoder replays an H.264 or MP4 file in real time without
+// re-encoding it. It only accepts a *FileSource.
+type ReplayEncoder struct {
+	ffmpeg *FFMPEGWrapper
+	fps    int
+
+	mu         sync.Mutex
+	supervisor *Supervisor
+	onEvent    func(ProcessEvent)
+}
+
+// NewReplayEncoder returns a ReplayEncoder that uses the ffmpeg and frame
+// rate of the config
+func NewReplayEncoder(config *Config) (*ReplayEncoder, error) {
+	if config == nil {
+		config = NewDefaultConfig()
+	}
+
+	path := "ffmpeg"
+	if config.FFMPEGPath != "" {
+		path = config.FFMPEGPath
+	}
+
+	ffmpegWrapper, err := NewFFMPEGWrapper(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fps := config.FPS
+	if fps <= 0 {
+		fps = 30
+	}
+
+	return &ReplayEncoder{
+		ffmpeg: ffmpegWrapper,
+		fps:    fps,
+	}, nil
+}
+
+// Start replays the file of the source
+func (e *ReplayEncoder) Start(source CaptureSource) (FrameReader, error) {
+	file, ok := source.(*FileSource)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSource, source.Name())
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.supervisor != nil {
+		_ = e.supervisor.Stop()
+		e.supervisor = nil
+	}
+
+	log.Printf("Replaying %s", file.Name())
+	supervisor := NewSupervisor(e.ffmpeg, "copy", nil,
+		func(string) ([]string, error) {
+			return e.buildReplayArgs(file)
+		},
+		e.onEvent,
+	)
+
+	stream, err := supervisor.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	e.supervisor = supervisor
+	return NewAnnexBFrameReader(stream, CodecH264, e.fps), nil
+}
+
+// buildReplayArgs copies the video stream of the file to stdout as Annex-B
+func (e *ReplayEncoder) buildReplayArgs(file *FileSource) ([]string, error) {
+	args, err := file.InputArgs(&Config{FPS: e.fps})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(args,
+		"-c:v", "copy",
+		"-bsf:v", "h264_mp4toannexb",
+		"-an",
+		"-f", "h264",
+		"-",
+	), nil
+}
+
+// Configure returns the frame rate of the replay, the file is sent as is so
+// the requested parameters cannot be applied
+func (e *ReplayEncoder) Configure(source CaptureSource, requested StreamParams) StreamParams {
+	if requested != (StreamParams{}) {
+		log.Printf("Ignoring the requested stream parameters %s, %s is replayed as is", requested, source.Name())
+	}
+	return StreamParams{FPS: e.fps}
+}
+
+// Reconfigure cannot change the replayed file, it only checks that a replay
+// is running and returns its parameters
+func (e *ReplayEncoder) Reconfigure(requested StreamParams) (StreamParams, error) {
+	e.mu.Lock()
+	running := e.supervisor != nil
+	e.mu.Unlock()
+
+	if !running {
+		return StreamParams{}, ErrNotRecording
+	}
+	log.Printf("Ignoring the requested stream parameters %s, the file is replayed as is", requested)
+	return StreamParams{FPS: e.fps}, nil
+}
+
+// SetStatsOverlay cannot draw into the replayed file, which is not encoded
+// again
+func (e *ReplayEncoder) SetStatsOverlay(enabled bool) error {
+	if !enabled {
+		return nil
+	}
+	return ErrOverlayNotSupported
+}
+
+// Snapshot grabs a single image of the source
+func (e *ReplayEncoder) Snapshot(ctx context.Context, source CaptureSource, opts SnapshotOptions) ([]byte, error) {
+	return snapshot(ctx, e.ffmpeg, &Config{Encoder: "copy", FPS: e.fps}, source, opts)
+}
+
+// Stop stops the replay
+func (e *ReplayEncoder) Stop() error {
+	e.mu.Lock()
+	supervisor := e.supervisor
+	e.supervisor = nil
+	e.mu.Unlock()
+
+	if supervisor != nil {
+		return supervisor.Stop()
+	}
+	return nil
+}
+
+func (e *ReplayEncoder) Codec() Codec {
+	return CodecH264
+}
+
+func (e *ReplayEncoder) FPS() int {
+	return e.fps
+}
+
+// ActiveEncoder returns "copy", the file is not encoded again
+func (e *ReplayEncoder) ActiveEncoder() string {
+	return "copy"
+}
+
+// SetEventHandler sets the handler for the lifecycle events of the
+// supervised ffmpeg process
+func (e *ReplayEncoder) SetEventHandler(fn func(ProcessEvent)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onEvent = fn
+}
+
+// EventHandler returns the handler set with SetEventHandler
+func (e *ReplayEncoder) EventHandler() func(ProcessEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.onEvent
+}
@@ -0,0 +1,75 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ImageFormat is the format of a snapshot
+type ImageFormat string
+
+const (
+	ImageJPEG ImageFormat = "jpeg"
+	ImagePNG  ImageFormat = "png"
+)
+
+// SnapshotOptions select the format and the size of a snapshot
+type SnapshotOptions struct {
+	Format ImageFormat
+	// Width and Height fit the image into the size, zero keeps the
+	// resolution of the source
+	Width  int
+	Height int
+	// Quality is the JPEG quality from 2 (best) to 31, zero is 5
+	Quality int
+}
+
+// snapshot grabs a single frame of the source with ffmpeg, the source is
+// opened with the same capture method the encoder of the config uses
+func snapshot(ctx context.Context, ffmpeg *FFMPEGWrapper, config *Config, source CaptureSource, opts SnapshotOptions) ([]byte, error) {
+	args, err := buildSnapshotArgs(config, source, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, stderr, err := ffmpeg.Run(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot of %s failed: %w: %s", source.Name(), err, strings.TrimSpace(string(stderr)))
+	}
+	if len(stdout) == 0 {
+		return nil, fmt.Errorf("snapshot of %s is empty", source.Name())
+	}
+	return stdout, nil
+}
+
+// buildSnapshotArgs returns the ffmpeg arguments that write one frame of
+// the source as an image to stdout
+func buildSnapshotArgs(config *Config, source CaptureSource, opts SnapshotOptions) ([]string, error) {
+	applied := *config
+	applied.Width, applied.Height = opts.Width, opts.Height
+
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	input, err := source.InputArgs(&applied)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, input...)
+	args = append(args, "-an", "-frames:v", "1", "-f", "image2pipe")
+
+	switch opts.Format {
+	case ImageJPEG:
+		quality := opts.Quality
+		if quality == 0 {
+			quality = 5
+		}
+		// mjpeg needs full range YUV
+		args = append(args, "-c:v", "mjpeg", "-pix_fmt", "yuvj420p", "-q:v", fmt.Sprintf("%d", quality))
+	case ImagePNG:
+		args = append(args, "-c:v", "png", "-pix_fmt", "rgb24")
+	default:
+		return nil, fmt.Errorf("unsupported image format %q", opts.Format)
+	}
+
+	return append(args, "-"), nil
+}
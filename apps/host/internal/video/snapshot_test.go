@@ -0,0 +1,52 @@
+package video
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildSnapshotArgs(t *testing.T) {
+	config := NewDefaultConfig()
+
+	args, err := buildSnapshotArgs(config, NewTestPatternSource(), SnapshotOptions{Format: ImageJPEG, Width: 320, Height: 180})
+	require.NoError(t, err)
+	require.Contains(t, args, "testsrc2=size=320x180:rate=30,"+
+		"drawtext=text='%{localtime} %{pts\\:hms}':x=4:y=4:fontsize=9:fontcolor=white:box=1:boxcolor=black@0.6:boxborderw=8,"+
+		"drawtext=text='frame %{frame_num}':x=4:y=18:fontsize=9:fontcolor=white:box=1:boxcolor=black@0.6:boxborderw=8")
+	require.Equal(t, []string{"-an", "-frames:v", "1", "-f", "image2pipe", "-c:v", "mjpeg", "-pix_fmt", "yuvj420p", "-q:v", "5", "-"}, args[len(args)-12:])
+	// the config of the encoder is not changed
+	require.Zero(t, config.Width)
+
+	args, err = buildSnapshotArgs(config, NewTestPatternSource(), SnapshotOptions{Format: ImagePNG})
+	require.NoError(t, err)
+	require.Equal(t, []string{"-c:v", "png", "-pix_fmt", "rgb24", "-"}, args[len(args)-5:])
+
+	_, err = buildSnapshotArgs(config, NewTestPatternSource(), SnapshotOptions{Format: "bmp"})
+	require.Error(t, err)
+}
+
+func TestRecorder_Snapshot(t *testing.T) {
+	ffmpeg := newFakeFFmpeg(t)
+	script := "#!/bin/sh\nprintf 'image'\n"
+	require.NoError(t, os.WriteFile(ffmpeg.path, []byte(script), 0o755))
+
+	config := NewDefaultConfig()
+	require.NoError(t, config.Validate())
+	recorder := &Recorder{config: config, ffmpeg: ffmpeg}
+
+	image, err := recorder.Snapshot(context.Background(), NewTestPatternSource(), SnapshotOptions{Format: ImagePNG})
+	require.NoError(t, err)
+	require.Equal(t, "image", string(image))
+
+	script = "#!/bin/sh\necho 'no capture device' >&2\nexit 1\n"
+	require.NoError(t, os.WriteFile(ffmpeg.path, []byte(script), 0o755))
+	_, err = recorder.Snapshot(context.Background(), NewTestPatternSource(), SnapshotOptions{Format: ImagePNG})
+	require.ErrorContains(t, err, "no capture device")
+
+	_, err = recorder.Snapshot(context.Background(), NewFileSource(filepath.Join(t.TempDir(), "missing.mp4"), false), SnapshotOptions{Format: ImagePNG})
+	require.ErrorIs(t, err, ErrInvalidPath)
+}
@@ -0,0 +1,133 @@
+package video
+
+import "fmt"
+
+const (
+	minStreamFPS       = 5
+	minStreamBitrate   = 250 // kbit/s
+	minStreamDimension = 64
+)
+
+// StreamParams are the output parameters of a stream. Clients request them
+// when starting a session, zero values leave the choice to the host.
+type StreamParams struct {
+	Width      int `json:"width,omitempty"`
+	Height     int `json:"height,omitempty"`
+	FPS        int `json:"fps,omitempty"`
+	MaxBitrate int `json:"max_bitrate,omitempty"` // kbit/s
+}
+
+func (p StreamParams) String() string {
+	resolution := "native"
+	if p.Width > 0 && p.Height > 0 {
+		resolution = fmt.Sprintf("%dx%d", p.Width, p.Height)
+	}
+	return fmt.Sprintf("%s@%dfps, max %d kbit/s", resolution, p.FPS, p.MaxBitrate)
+}
+
+// sizedSource is implemented by capture sources that know the resolution
+// they capture at
+type sizedSource interface {
+	Size(config *Config) (width, height int, err error)
+}
+
+// streamLimits returns the limits of the host for the config: the
+// configured frame rate, the bitrate cap of the profile, and the profile
+// resolution or else the resolution of the source
+func streamLimits(config *Config, sourceWidth, sourceHeight int) StreamParams {
+	limits := StreamParams{
+		Width:      sourceWidth,
+		Height:     sourceHeight,
+		FPS:        config.FPS,
+		MaxBitrate: config.MaxBitrate,
+	}
+	if config.Width > 0 && config.Height > 0 {
+		limits.Width, limits.Height = config.Width, config.Height
+	}
+	if limits.MaxBitrate == 0 {
+		limits.MaxBitrate = config.Bitrate
+	}
+	if limits.MaxBitrate == 0 {
+		// constant quality without a cap
+		limits.MaxBitrate = maxProfileBitrate
+	}
+	return limits
+}
+
+// clampStreamParams clamps the requested parameters to the limits, unset
+// values are taken from the limits. A requested resolution is treated as a
+// bounding box that is shrunk, keeping its aspect ratio, to fit the limits.
+func clampStreamParams(requested, limits StreamParams) StreamParams {
+	effective := StreamParams{
+		FPS:        clampOrDefault(requested.FPS, minStreamFPS, limits.FPS),
+		MaxBitrate: clampOrDefault(requested.MaxBitrate, minStreamBitrate, limits.MaxBitrate),
+		Width:      limits.Width,
+		Height:     limits.Height,
+	}
+
+	if requested.Width <= 0 || requested.Height <= 0 {
+		return effective
+	}
+
+	width, height := requested.Width, requested.Height
+	if limits.Width > 0 && limits.Height > 0 {
+		width, height = FitResolution(width, height, limits.Width, limits.Height)
+	}
+	effective.Width = max(evenDown(width), minStreamDimension)
+	effective.Height = max(evenDown(height), minStreamDimension)
+	return effective
+}
+
+// clampOrDefault returns value clamped to [low, limit], or limit when value
+// is not set. The limit wins when it is lower than low.
+func clampOrDefault(value, low, limit int) int {
+	if value <= 0 || value > limit {
+		return limit
+	}
+	return min(max(value, low), limit)
+}
+
+// FitResolution returns the largest resolution with the aspect ratio of
+// width x height that fits into boxWidth x boxHeight without upscaling. The
+// result is rounded down to even dimensions.
+func FitResolution(width, height, boxWidth, boxHeight int) (int, int) {
+	if width <= 0 || height <= 0 || boxWidth <= 0 || boxHeight <= 0 {
+		return evenDown(width), evenDown(height)
+	}
+	if width <= boxWidth && height <= boxHeight {
+		return evenDown(width), evenDown(height)
+	}
+
+	// compare width/boxWidth with height/boxHeight without floats
+	if width*boxHeight >= height*boxWidth {
+		return evenDown(boxWidth), evenDown(height * boxWidth / width)
+	}
+	return evenDown(width * boxHeight / height), evenDown(boxHeight)
+}
+
+// evenDown rounds down to an even number, 4:2:0 needs even dimensions
+func evenDown(value int) int {
+	return value &^ 1
+}
+
+// applyStreamParams returns a copy of the config that encodes with the
+// stream parameters. Zero parameters keep the config as is.
+func applyStreamParams(config *Config, params StreamParams) *Config {
+	applied := *config
+	if params == (StreamParams{}) {
+		return &applied
+	}
+
+	if params.FPS > 0 && params.FPS != applied.FPS {
+		// keep the keyframe interval in seconds
+		applied.KeyframeInterval = max(applied.KeyframeInterval*params.FPS/applied.FPS, 1)
+		applied.FPS = params.FPS
+	}
+	if params.MaxBitrate > 0 {
+		applied.Profile = applied.Profile.WithMaxBitrate(params.MaxBitrate)
+	}
+	if params.Width > 0 && params.Height > 0 {
+		applied.Width, applied.Height = params.Width, params.Height
+	}
+	return &applied
+}
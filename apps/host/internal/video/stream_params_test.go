@@ -0,0 +1,171 @@
+package video
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFitResolution(t *testing.T) {
+	tests := []struct {
+		name                string
+		width, height       int
+		boxWidth, boxHeight int
+		wantW, wantH        int
+	}{
+		{name: "same aspect", width: 2560, height: 1440, boxWidth: 1280, boxHeight: 720, wantW: 1280, wantH: 720},
+		{name: "wider source", width: 3440, height: 1440, boxWidth: 1280, boxHeight: 720, wantW: 1280, wantH: 534},
+		{name: "taller source", width: 2560, height: 1600, boxWidth: 1280, boxHeight: 720, wantW: 1152, wantH: 720},
+		{name: "no upscaling", width: 1280, height: 720, boxWidth: 1920, boxHeight: 1080, wantW: 1280, wantH: 720},
+		{name: "odd result rounded down", width: 1366, height: 768, boxWidth: 1000, boxHeight: 1000, wantW: 1000, wantH: 562},
+		{name: "no box", width: 1920, height: 1080, wantW: 1920, wantH: 1080},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h := FitResolution(tt.width, tt.height, tt.boxWidth, tt.boxHeight)
+			require.Equal(t, tt.wantW, w)
+			require.Equal(t, tt.wantH, h)
+		})
+	}
+}
+
+func TestClampStreamParams(t *testing.T) {
+	limits := StreamParams{Width: 2560, Height: 1440, FPS: 60, MaxBitrate: 20000}
+
+	tests := []struct {
+		name      string
+		requested StreamParams
+		want      StreamParams
+	}{
+		{
+			name: "nothing requested",
+			want: limits,
+		},
+		{
+			name:      "within limits",
+			requested: StreamParams{Width: 1280, Height: 720, FPS: 30, MaxBitrate: 5000},
+			want:      StreamParams{Width: 1280, Height: 720, FPS: 30, MaxBitrate: 5000},
+		},
+		{
+			name:      "above limits",
+			requested: StreamParams{Width: 3840, Height: 2160, FPS: 144, MaxBitrate: 100000},
+			want:      StreamParams{Width: 2560, Height: 1440, FPS: 60, MaxBitrate: 20000},
+		},
+		{
+			name:      "box shrunk keeping its aspect",
+			requested: StreamParams{Width: 5120, Height: 1440},
+			want:      StreamParams{Width: 2560, Height: 720, FPS: 60, MaxBitrate: 20000},
+		},
+		{
+			name:      "below minimums",
+			requested: StreamParams{Width: 16, Height: 9, FPS: 1, MaxBitrate: 10},
+			want:      StreamParams{Width: minStreamDimension, Height: minStreamDimension, FPS: minStreamFPS, MaxBitrate: minStreamBitrate},
+		},
+		{
+			name:      "only width requested",
+			requested: StreamParams{Width: 1280},
+			want:      limits,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, clampStreamParams(tt.requested, limits))
+		})
+	}
+}
+
+func TestStreamLimits(t *testing.T) {
+	config := NewDefaultConfig()
+	require.NoError(t, config.Validate())
+
+	require.Equal(t, StreamParams{Width: 1920, Height: 1080, FPS: 30, MaxBitrate: defaultBitrate},
+		streamLimits(config, 1920, 1080))
+
+	config.Width, config.Height = 1280, 720
+	config.RateControl, config.Bitrate, config.MaxBitrate = RateControlCQ, 0, 0
+	require.Equal(t, StreamParams{Width: 1280, Height: 720, FPS: 30, MaxBitrate: maxProfileBitrate},
+		streamLimits(config, 1920, 1080))
+}
+
+func TestRecorder_Configure(t *testing.T) {
+	config := NewDefaultConfig()
+	config.FPS = 60
+	require.NoError(t, config.Validate())
+	recorder := &Recorder{config: config}
+
+	source := NewTestPatternSource()
+	effective := recorder.Configure(source, StreamParams{Width: 1280, Height: 800, FPS: 30, MaxBitrate: 4000})
+	require.Equal(t, StreamParams{Width: 1280, Height: 720, FPS: 30, MaxBitrate: 4000}, effective)
+	require.Equal(t, 30, recorder.FPS())
+
+	applied := recorder.configForEncoder("libx264", recorder.params)
+	require.Equal(t, 30, applied.FPS)
+	require.Equal(t, 30, applied.KeyframeInterval)
+	require.Equal(t, 4000, applied.Bitrate)
+	require.Equal(t, 4000, applied.MaxBitrate)
+	require.Equal(t, 1000, applied.BufferSize)
+	require.Equal(t, 1280, applied.Width)
+	require.Equal(t, 800, applied.Height)
+
+	args, err := buildSourceArgs(source, applied)
+	require.NoError(t, err)
+	joined := strings.Join(args, " ")
+	require.Contains(t, joined, "testsrc2=size=1280x720:rate=30")
+	require.Contains(t, joined, "-b:v 4000k")
+
+	// the config itself is not changed
+	require.Equal(t, 60, config.FPS)
+	require.Equal(t, defaultBitrate, config.Bitrate)
+
+	// the native resolution is not scaled
+	effective = recorder.Configure(source, StreamParams{})
+	require.Equal(t, StreamParams{Width: 1920, Height: 1080, FPS: 60, MaxBitrate: defaultBitrate}, effective)
+	require.Zero(t, recorder.params.Width)
+	require.Equal(t, 60, recorder.FPS())
+}
+
+func TestRecorder_Reconfigure(t *testing.T) {
+	ffmpeg := newFakeFFmpeg(t)
+	argsLog := filepath.Join(t.TempDir(), "args.log")
+	t.Setenv("ARGS_LOG", argsLog)
+
+	// replace the script with one that logs its arguments and quits on "q"
+	script := "#!/bin/sh\necho \"$@\" >> \"$ARGS_LOG\"\nread q\n"
+	require.NoError(t, os.WriteFile(ffmpeg.path, []byte(script), 0o755))
+
+	config := NewDefaultConfig()
+	require.NoError(t, config.Validate())
+	recorder := &Recorder{config: config, ffmpeg: ffmpeg}
+
+	_, err := recorder.Reconfigure(StreamParams{FPS: 15})
+	require.ErrorIs(t, err, ErrNotRecording)
+
+	source := NewTestPatternSource()
+	recorder.Configure(source, StreamParams{})
+	_, err = recorder.Start(source)
+	require.NoError(t, err)
+	defer recorder.Stop()
+
+	effective, err := recorder.Reconfigure(StreamParams{FPS: 15, MaxBitrate: 2000})
+	require.NoError(t, err)
+	require.Equal(t, 15, effective.FPS)
+	require.Equal(t, 2000, effective.MaxBitrate)
+	require.Equal(t, 15, recorder.FPS())
+
+	var runs []string
+	require.Eventually(t, func() bool {
+		data, _ := os.ReadFile(argsLog)
+		runs = strings.Split(strings.TrimSpace(string(data)), "\n")
+		return len(runs) == 2
+	}, 2*processStopTimeout, 10*time.Millisecond)
+
+	require.Contains(t, runs[0], "-r 30")
+	require.Contains(t, runs[1], "-r 15")
+	require.Contains(t, runs[1], "-b:v 2000k")
+}
@@ -0,0 +1,538 @@
+package video
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	supervisorMaxRestarts = 5
+	supervisorMinBackoff  = 500 * time.Millisecond
+	supervisorMaxBackoff  = 10 * time.Second
+	// supervisorStableAfter resets the restart counter once a process ran
+	// this long without failing
+	supervisorStableAfter = 30 * time.Second
+	// stderrTailLines is the number of stderr lines kept to classify a failure
+	stderrTailLines = 20
+	// encoderStartupTimeout is how long a process may run without encoding
+	// a frame. Encoders that fail in this time count as not initialized, so
+	// the next fallback encoder is tried instead of showing nothing.
+	encoderStartupTimeout = 8 * time.Second
+)
+
+// ProcessEventType is the type of a supervised ffmpeg lifecycle event
+type ProcessEventType string
+
+const (
+	ProcessStarted         ProcessEventType = "started"
+	ProcessProgress        ProcessEventType = "progress"
+	ProcessExited          ProcessEventType = "exited"
+	ProcessRestarting      ProcessEventType = "restarting"
+	ProcessEncoderFallback ProcessEventType = "encoder_fallback"
+	ProcessFailed          ProcessEventType = "failed"
+	ProcessStopped         ProcessEventType = "stopped"
+)
+
+// FailureKind classifies why an ffmpeg process exited
+type FailureKind string
+
+const (
+	FailureNone FailureKind = ""
+	// FailureDeviceLost means the capture or GPU device went away, the
+	// process is restarted with the same encoder
+	FailureDeviceLost FailureKind = "device_lost"
+	// FailureEncoderInit means the encoder could not be opened, the
+	// process is restarted with the next fallback encoder
+	FailureEncoderInit FailureKind = "encoder_init"
+	FailureUnknown     FailureKind = "unknown"
+)
+
+// ProcessEvent describes a lifecycle change of a supervised ffmpeg process
+type ProcessEvent struct {
+	Type      ProcessEventType `json:"type"`
+	Encoder   string           `json:"encoder"`
+	Attempt   int              `json:"attempt"`
+	Progress  Progress         `json:"progress"`
+	Failure   FailureKind      `json:"failure,omitempty"`
+	Err       error            `json:"-"`
+	RestartIn time.Duration    `json:"restart_in,omitempty"`
+}
+
+var failurePatterns = []struct {
+	kind     FailureKind
+	patterns []string
+}{
+	{
+		kind: FailureEncoderInit,
+		patterns: []string{
+			"error while opening encoder",
+			"could not open encoder",
+			"error initializing output stream",
+			"unknown encoder",
+			"openencodesessionex failed",
+			"no capable devices found",
+			"no nvenc capable devices",
+			"cannot load nvcuda",
+			"cannot load libnvidia-encode",
+			"driver does not support the required nvenc api version",
+			"error creating a mfx session",
+			"error initializing an internal mfx session",
+			"failed to initialise vaapi connection",
+			"no usable encoding profile found",
+			"amf failed to initialise",
+		},
+	},
+	{
+		kind: FailureDeviceLost,
+		patterns: []string{
+			"device lost",
+			"device removed",
+			"device_removed",
+			"device_hung",
+			"dxgi_error_access_lost",
+			"cuda_error_illegal_address",
+			"cuda_error_launch_failed",
+			"input/output error",
+			"failed to capture",
+		},
+	},
+}
+
+// classifyFailure returns the failure kind for the last stderr lines of a
+// process that exited with an error
+func classifyFailure(stderrTail []string) FailureKind {
+	for _, line := range slices.Backward(stderrTail) {
+		line = strings.ToLower(line)
+		for _, failure := range failurePatterns {
+			for _, pattern := range failure.patterns {
+				if strings.Contains(line, pattern) {
+					return failure.kind
+				}
+			}
+		}
+	}
+	return FailureUnknown
+}
+
+// restartBackoff returns the delay before the given restart attempt
+func restartBackoff(attempt int) time.Duration {
+	delay := supervisorMinBackoff
+	for i := 1; i < attempt && delay < supervisorMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, supervisorMaxBackoff)
+}
+
+// Supervisor runs an ffmpeg process, parses its progress, and restarts it
+// when it dies. The output of all processes is joined into a single stream.
+type Supervisor struct {
+	ffmpeg         *FFMPEGWrapper
+	buildArgs      func(encoder string) ([]string, error)
+	onEvent        func(ProcessEvent)
+	startupTimeout time.Duration
+
+	mu               sync.Mutex
+	encoder          string
+	fallbacks        []string
+	process          *Process
+	progress         Progress
+	stopped          bool
+	restartRequested bool // the current process is stopped to be restarted
+	stopCh           chan struct{}
+}
+
+// NewSupervisor returns a supervisor that starts ffmpeg with the arguments
+// returned by buildArgs. fallbacks are tried in order when the encoder
+// cannot be initialized, onEvent may be nil.
+func NewSupervisor(
+	ffmpeg *FFMPEGWrapper,
+	encoder string,
+	fallbacks []string,
+	buildArgs func(encoder string) ([]string, error),
+	onEvent func(ProcessEvent),
+) *Supervisor {
+	return &Supervisor{
+		ffmpeg:         ffmpeg,
+		buildArgs:      buildArgs,
+		onEvent:        onEvent,
+		startupTimeout: encoderStartupTimeout,
+		encoder:        encoder,
+		fallbacks:      slices.DeleteFunc(slices.Clone(fallbacks), func(e string) bool { return e == encoder }),
+		stopCh:         make(chan struct{}),
+	}
+}
+
+// Start starts the first ffmpeg process and returns the joined output
+// stream. Closing the stream stops the supervisor.
+func (s *Supervisor) Start() (io.ReadCloser, error) {
+	process, err := s.startProcess()
+	if err != nil {
+		return nil, err
+	}
+
+	reader, writer := io.Pipe()
+	go s.run(process, writer)
+
+	return &supervisedStream{
+		reader:     reader,
+		supervisor: s,
+	}, nil
+}
+
+// supervisedStream implements io.ReadCloser over the joined output
+type supervisedStream struct {
+	reader     *io.PipeReader
+	supervisor *Supervisor
+}
+
+func (s *supervisedStream) Read(p []byte) (int, error) {
+	return s.reader.Read(p)
+}
+
+// Close closes the reader first so a process blocked on writing its output
+// can quit, then stops the supervisor
+func (s *supervisedStream) Close() error {
+	_ = s.reader.Close()
+	return s.supervisor.Stop()
+}
+
+// Encoder returns the encoder of the current process
+func (s *Supervisor) Encoder() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.encoder
+}
+
+// Progress returns the last progress report of the current process
+func (s *Supervisor) Progress() Progress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.progress
+}
+
+// Stop stops the current process and prevents further restarts
+func (s *Supervisor) Stop() error {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+	s.stopped = true
+	close(s.stopCh)
+	process := s.process
+	s.mu.Unlock()
+
+	if process == nil {
+		return nil
+	}
+
+	err := process.Stop()
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		// ffmpeg exits with an error when it was killed or the output closed
+		return nil
+	}
+	return err
+}
+
+// Done returns a channel that is closed once the supervisor was stopped
+func (s *Supervisor) Done() <-chan struct{} {
+	return s.stopCh
+}
+
+// Restart stops the current process and starts a new one with freshly
+// built arguments, e.g. after the captured window was resized. The output
+// stream stays open.
+func (s *Supervisor) Restart() {
+	s.mu.Lock()
+	if s.stopped || s.process == nil {
+		s.mu.Unlock()
+		return
+	}
+	s.restartRequested = true
+	process := s.process
+	s.mu.Unlock()
+
+	go func() {
+		_ = process.Stop()
+	}()
+}
+
+// takeRestart reports whether a restart was requested and clears the request
+func (s *Supervisor) takeRestart() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requested := s.restartRequested
+	s.restartRequested = false
+	return requested
+}
+
+// startProcess builds the arguments for the current encoder and starts
+// ffmpeg with progress reporting on stderr
+func (s *Supervisor) startProcess() (*Process, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return nil, fmt.Errorf("supervisor stopped")
+	}
+
+	args, err := s.buildArgs(s.encoder)
+	if err != nil {
+		return nil, err
+	}
+
+	args = append([]string{
+		"-hide_banner",
+		"-loglevel", "warning",
+		"-nostats",
+		"-progress", "pipe:2",
+	}, args...)
+
+	process, err := s.ffmpeg.Start(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	s.process = process
+	s.progress = Progress{}
+	return process, nil
+}
+
+// run copies the output of every process to writer and restarts failed
+// processes until the supervisor is stopped or gives up
+func (s *Supervisor) run(process *Process, writer *io.PipeWriter) {
+	attempt := 0
+
+	for {
+		startedAt := time.Now()
+
+		// a process that encodes no frame in time is stopped, and one that
+		// reports an encoder error is stopped right away instead of waiting
+		// for ffmpeg to give up
+		var encoding, stalled atomic.Bool
+		current := process
+		watchdog := time.AfterFunc(s.startupTimeout, func() {
+			stalled.Store(true)
+			log.Printf("ffmpeg encoded no frame with %s in %s, stopping it", s.Encoder(), s.startupTimeout)
+			go current.Stop()
+		})
+
+		stderrTail := make(chan []string, 1)
+		go func() {
+			stderrTail <- s.watchStderr(process.Stderr,
+				func() {
+					encoding.Store(true)
+					watchdog.Stop()
+				},
+				func() {
+					if !encoding.Load() {
+						go current.Stop()
+					}
+				},
+			)
+		}()
+
+		s.emit(ProcessEvent{Type: ProcessStarted, Attempt: attempt})
+
+		// a failing write means the consumer closed the stream
+		_, copyErr := io.Copy(writer, process.Stdout)
+		process.Stdout.Close()
+		if copyErr != nil {
+			_ = process.Stop()
+		}
+
+		exitErr := process.Wait()
+		tail := <-stderrTail
+		watchdog.Stop()
+
+		if s.isStopped() {
+			s.emit(ProcessEvent{Type: ProcessStopped})
+			writer.Close()
+			return
+		}
+
+		if s.takeRestart() {
+			s.emit(ProcessEvent{Type: ProcessRestarting, Attempt: attempt})
+			next, err := s.startProcess()
+			if err == nil {
+				process = next
+				continue
+			}
+			if s.isStopped() {
+				s.emit(ProcessEvent{Type: ProcessStopped})
+				writer.Close()
+				return
+			}
+			log.Printf("failed to restart ffmpeg: %v", err)
+			exitErr = err
+		}
+
+		if exitErr == nil && stalled.Load() {
+			exitErr = ErrEncoderStalled
+		}
+
+		if exitErr == nil {
+			// the input ended, e.g. a file or a fixed number of frames
+			s.emit(ProcessEvent{Type: ProcessExited})
+			writer.Close()
+			return
+		}
+
+		failure := classifyFailure(tail)
+		if failure == FailureUnknown && !encoding.Load() && (stalled.Load() || time.Since(startedAt) < s.startupTimeout) {
+			// the encoder never produced a frame, e.g. a driver that accepts
+			// the session and fails on the first frame
+			failure = FailureEncoderInit
+		}
+		s.emit(ProcessEvent{Type: ProcessExited, Failure: failure, Err: exitErr})
+		log.Printf("ffmpeg exited with %v (%s)", exitErr, failure)
+
+		if time.Since(startedAt) > supervisorStableAfter {
+			attempt = 0
+		}
+
+		var err error
+		process, attempt, err = s.restart(failure, attempt, exitErr)
+		if err != nil {
+			s.emit(ProcessEvent{Type: ProcessFailed, Attempt: attempt, Err: err})
+			writer.CloseWithError(err)
+			return
+		}
+		if process == nil {
+			s.emit(ProcessEvent{Type: ProcessStopped})
+			writer.Close()
+			return
+		}
+	}
+}
+
+// restart waits for the backoff and starts the next process, switching to
+// the next fallback encoder when the encoder could not be initialized. A
+// nil process without error means the supervisor was stopped meanwhile.
+func (s *Supervisor) restart(failure FailureKind, attempt int, cause error) (*Process, int, error) {
+	for {
+		if failure == FailureEncoderInit && s.nextEncoder() {
+			attempt = 0
+		} else {
+			attempt++
+		}
+
+		if attempt > supervisorMaxRestarts {
+			return nil, attempt, fmt.Errorf("ffmpeg failed %d times, last error: %w", attempt, cause)
+		}
+
+		delay := restartBackoff(attempt)
+		s.emit(ProcessEvent{Type: ProcessRestarting, Attempt: attempt, Failure: failure, RestartIn: delay})
+
+		select {
+		case <-time.After(delay):
+		case <-s.stopCh:
+			return nil, attempt, nil
+		}
+
+		process, err := s.startProcess()
+		if err == nil {
+			return process, attempt, nil
+		}
+		if s.isStopped() {
+			return nil, attempt, nil
+		}
+		if errors.Is(err, ErrMonitorNotFound) {
+			// retrying does not bring the monitor back
+			return nil, attempt, err
+		}
+
+		log.Printf("failed to restart ffmpeg: %v", err)
+		failure, cause = FailureUnknown, err
+	}
+}
+
+// nextEncoder switches to the next fallback encoder, it returns false when
+// there is none left
+func (s *Supervisor) nextEncoder() bool {
+	s.mu.Lock()
+	if len(s.fallbacks) == 0 {
+		s.mu.Unlock()
+		return false
+	}
+
+	previous := s.encoder
+	s.encoder = s.fallbacks[0]
+	s.fallbacks = s.fallbacks[1:]
+	s.mu.Unlock()
+
+	log.Printf("encoder %s failed to initialize, falling back to %s", previous, s.Encoder())
+	s.emit(ProcessEvent{Type: ProcessEncoderFallback})
+	return true
+}
+
+// watchStderr parses the progress reports of a process and logs all other
+// output. onEncoding is called for every progress report with encoded
+// frames, onEncoderError for every line reporting that the encoder failed.
+// It returns the last stderr lines once the process closed stderr.
+func (s *Supervisor) watchStderr(stderr io.ReadCloser, onEncoding, onEncoderError func()) []string {
+	defer stderr.Close()
+
+	var (
+		parser progressParser
+		tail   []string
+	)
+
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		progress, done, ok := parser.parseLine(line)
+		if ok {
+			if done {
+				s.mu.Lock()
+				s.progress = progress
+				s.mu.Unlock()
+				if progress.Frame > 0 {
+					onEncoding()
+				}
+				s.emit(ProcessEvent{Type: ProcessProgress, Progress: progress})
+			}
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if classifyFailure([]string{line}) == FailureEncoderInit {
+			onEncoderError()
+		}
+
+		log.Printf("ffmpeg: %s", line)
+		tail = append(tail, line)
+		if len(tail) > stderrTailLines {
+			tail = tail[1:]
+		}
+	}
+
+	return tail
+}
+
+func (s *Supervisor) isStopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped
+}
+
+// emit sends the event to the event handler with the current encoder set
+func (s *Supervisor) emit(event ProcessEvent) {
+	if s.onEvent == nil {
+		return
+	}
+	event.Encoder = s.Encoder()
+	s.onEvent(event)
+}
@@ -0,0 +1,352 @@
+package video
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFFmpegScript fails the first run with a lost device when a marker
+// file is missing, refuses to open h264_nvenc and otherwise reports
+// progress and writes the encoder name to stdout
+const fakeFFmpegScript = `#!/bin/sh
+enc=""
+while [ $# -gt 0 ]; do
+	case "$1" in
+		-c:v) enc="$2"; shift ;;
+	esac
+	shift
+done
+if [ "$enc" = "h264_nvenc" ]; then
+	echo "[h264_nvenc @ 0x1] OpenEncodeSessionEx failed: unsupported device (2): (no details)" >&2
+	echo "Error while opening encoder for output stream #0:0" >&2
+	exit 1
+fi
+if [ -n "$MARKER" ] && [ ! -f "$MARKER" ]; then
+	touch "$MARKER"
+	printf 'encoded-by-%s-' "$enc"
+	echo "[gdigrab @ 0x1] Failed to capture image: Device removed" >&2
+	exit 1
+fi
+printf 'frame=10\nfps=30.0\nbitrate=1000.0kbits/s\nspeed=1.0x\nprogress=continue\n' >&2
+printf 'encoded-by-%s' "$enc"
+`
+
+func newFakeFFmpeg(t *testing.T) *FFMPEGWrapper {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg needs a POSIX shell")
+	}
+
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	require.NoError(t, os.WriteFile(path, []byte(fakeFFmpegScript), 0o755))
+
+	wrapper, err := NewFFMPEGWrapper(path)
+	require.NoError(t, err)
+	return wrapper
+}
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []ProcessEvent
+}
+
+func (r *eventRecorder) record(evt ProcessEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, evt)
+}
+
+func (r *eventRecorder) types() []ProcessEventType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	types := make([]ProcessEventType, 0, len(r.events))
+	for _, evt := range r.events {
+		types = append(types, evt.Type)
+	}
+	return types
+}
+
+func (r *eventRecorder) find(eventType ProcessEventType) (ProcessEvent, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, evt := range r.events {
+		if evt.Type == eventType {
+			return evt, true
+		}
+	}
+	return ProcessEvent{}, false
+}
+
+func encoderArgs(encoder string) ([]string, error) {
+	return []string{"-c:v", encoder}, nil
+}
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		tail []string
+		want FailureKind
+	}{
+		{
+			name: "nvenc init",
+			tail: []string{"[h264_nvenc @ 0x55] OpenEncodeSessionEx failed: out of memory (10)", "Error while opening encoder for output stream #0:0"},
+			want: FailureEncoderInit,
+		},
+		{
+			name: "qsv session",
+			tail: []string{"[h264_qsv @ 0x1] Error creating a MFX session: -9."},
+			want: FailureEncoderInit,
+		},
+		{
+			name: "device removed",
+			tail: []string{"frame dropped", "[gfxcapture @ 0x1] DXGI_ERROR_DEVICE_REMOVED"},
+			want: FailureDeviceLost,
+		},
+		{
+			name: "x11 connection lost",
+			tail: []string{"[x11grab @ 0x1] Error reading frame: Input/output error"},
+			want: FailureDeviceLost,
+		},
+		{
+			name: "last line wins",
+			tail: []string{"Could not open encoder before", "Device lost"},
+			want: FailureDeviceLost,
+		},
+		{
+			name: "unknown",
+			tail: []string{"Conversion failed!"},
+			want: FailureUnknown,
+		},
+		{
+			name: "empty",
+			want: FailureUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, classifyFailure(tt.tail))
+		})
+	}
+}
+
+func TestRestartBackoff(t *testing.T) {
+	require.Equal(t, supervisorMinBackoff, restartBackoff(0))
+	require.Equal(t, supervisorMinBackoff, restartBackoff(1))
+	require.Equal(t, 2*supervisorMinBackoff, restartBackoff(2))
+	require.Equal(t, 8*supervisorMinBackoff, restartBackoff(4))
+	require.Equal(t, supervisorMaxBackoff, restartBackoff(50))
+}
+
+func TestSupervisor_EncoderFallback(t *testing.T) {
+	ffmpeg := newFakeFFmpeg(t)
+	t.Setenv("MARKER", "")
+
+	events := &eventRecorder{}
+	supervisor := NewSupervisor(ffmpeg, "h264_nvenc", []string{"h264_nvenc", "libx264"}, encoderArgs, events.record)
+
+	stream, err := supervisor.Start()
+	require.NoError(t, err)
+
+	output, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+
+	require.Equal(t, "encoded-by-libx264", string(output))
+	require.Equal(t, "libx264", supervisor.Encoder())
+
+	exited, ok := events.find(ProcessExited)
+	require.True(t, ok)
+	require.Equal(t, FailureEncoderInit, exited.Failure)
+	require.Equal(t, "h264_nvenc", exited.Encoder)
+
+	fallback, ok := events.find(ProcessEncoderFallback)
+	require.True(t, ok)
+	require.Equal(t, "libx264", fallback.Encoder)
+
+	progress, ok := events.find(ProcessProgress)
+	require.True(t, ok)
+	require.Equal(t, int64(10), progress.Progress.Frame)
+	require.Equal(t, 30.0, progress.Progress.FPS)
+	require.Equal(t, 1000.0, progress.Progress.Bitrate)
+	require.Equal(t, int64(10), supervisor.Progress().Frame)
+}
+
+func TestSupervisor_StalledEncoderFallback(t *testing.T) {
+	ffmpeg := newFakeFFmpeg(t)
+
+	// h264_qsv starts but never encodes a frame, h264_vaapi exits without
+	// an error ffmpeg reports for encoders
+	script := `#!/bin/sh
+while [ $# -gt 1 ] && [ "$1" != "-c:v" ]; do shift; done
+case "$2" in
+	h264_qsv) read q; exit 0 ;;
+	h264_vaapi) echo "Conversion failed!" >&2; exit 1 ;;
+esac
+printf 'frame=1\nprogress=continue\n' >&2
+printf 'encoded-by-%s' "$2"
+`
+	require.NoError(t, os.WriteFile(ffmpeg.path, []byte(script), 0o755))
+
+	events := &eventRecorder{}
+	supervisor := NewSupervisor(ffmpeg, "h264_qsv", []string{"h264_vaapi", "libx264"}, encoderArgs, events.record)
+	supervisor.startupTimeout = 200 * time.Millisecond
+
+	stream, err := supervisor.Start()
+	require.NoError(t, err)
+
+	output, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+
+	require.Equal(t, "encoded-by-libx264", string(output))
+	require.Equal(t, "libx264", supervisor.Encoder())
+
+	var exits []ProcessEvent
+	for _, evt := range events.events {
+		if evt.Type == ProcessExited && evt.Failure != FailureNone {
+			exits = append(exits, evt)
+		}
+	}
+	require.Len(t, exits, 2)
+	require.Equal(t, "h264_qsv", exits[0].Encoder)
+	require.ErrorIs(t, exits[0].Err, ErrEncoderStalled)
+	require.Equal(t, FailureEncoderInit, exits[0].Failure)
+	require.Equal(t, "h264_vaapi", exits[1].Encoder)
+	require.Equal(t, FailureEncoderInit, exits[1].Failure)
+}
+
+func TestSupervisor_RestartAfterDeviceLost(t *testing.T) {
+	ffmpeg := newFakeFFmpeg(t)
+	t.Setenv("MARKER", filepath.Join(t.TempDir(), "failed-once"))
+
+	events := &eventRecorder{}
+	supervisor := NewSupervisor(ffmpeg, "libx264", nil, encoderArgs, events.record)
+
+	stream, err := supervisor.Start()
+	require.NoError(t, err)
+
+	output, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+
+	// the output of both processes ends up in one stream
+	require.Equal(t, "encoded-by-libx264-encoded-by-libx264", string(output))
+
+	restarting, ok := events.find(ProcessRestarting)
+	require.True(t, ok)
+	require.Equal(t, FailureDeviceLost, restarting.Failure)
+	require.Equal(t, 1, restarting.Attempt)
+	require.Equal(t, supervisorMinBackoff, restarting.RestartIn)
+
+	_, ok = events.find(ProcessEncoderFallback)
+	require.False(t, ok)
+}
+
+func TestSupervisor_Stop(t *testing.T) {
+	ffmpeg := newFakeFFmpeg(t)
+
+	events := &eventRecorder{}
+	supervisor := NewSupervisor(ffmpeg, "libx264", nil, encoderArgs, events.record)
+
+	// replace the script with one that only exits once stdin is closed
+	require.NoError(t, os.WriteFile(ffmpeg.path, []byte("#!/bin/sh\nread q\n"), 0o755))
+
+	stream, err := supervisor.Start()
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.ReadAll(stream)
+		close(done)
+	}()
+
+	require.NoError(t, stream.Close())
+
+	select {
+	case <-done:
+	case <-time.After(processStopTimeout):
+		t.Fatal("stream did not end after Close")
+	}
+
+	require.Eventually(t, func() bool {
+		_, ok := events.find(ProcessStopped)
+		return ok
+	}, time.Second, 10*time.Millisecond)
+	require.NotContains(t, events.types(), ProcessRestarting)
+}
+
+func TestSupervisor_MonitorGone(t *testing.T) {
+	ffmpeg := newFakeFFmpeg(t)
+	t.Setenv("MARKER", filepath.Join(t.TempDir(), "failed-once"))
+
+	calls := 0
+	buildArgs := func(encoder string) ([]string, error) {
+		calls++
+		if calls > 1 {
+			return nil, fmt.Errorf("%w: no monitor with index 1", ErrMonitorNotFound)
+		}
+		return encoderArgs(encoder)
+	}
+
+	events := &eventRecorder{}
+	supervisor := NewSupervisor(ffmpeg, "libx264", nil, buildArgs, events.record)
+
+	stream, err := supervisor.Start()
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(stream)
+	require.ErrorIs(t, err, ErrMonitorNotFound)
+	require.NoError(t, stream.Close())
+
+	failed, ok := events.find(ProcessFailed)
+	require.True(t, ok)
+	require.ErrorIs(t, failed.Err, ErrMonitorNotFound)
+	require.Equal(t, 2, calls)
+}
+
+func TestSupervisor_Restart(t *testing.T) {
+	ffmpeg := newFakeFFmpeg(t)
+
+	events := &eventRecorder{}
+	supervisor := NewSupervisor(ffmpeg, "libx264", nil, encoderArgs, events.record)
+
+	// replace the script with one that writes a marker and quits on "q"
+	require.NoError(t, os.WriteFile(ffmpeg.path, []byte("#!/bin/sh\nprintf 'run-'\nread q\n"), 0o755))
+
+	stream, err := supervisor.Start()
+	require.NoError(t, err)
+
+	output := make(chan string, 1)
+	go func() {
+		data, _ := io.ReadAll(stream)
+		output <- string(data)
+	}()
+
+	supervisor.Restart()
+	require.Eventually(t, func() bool {
+		started := 0
+		for _, eventType := range events.types() {
+			if eventType == ProcessStarted {
+				started++
+			}
+		}
+		return started == 2
+	}, processStopTimeout, 10*time.Millisecond)
+
+	require.NoError(t, stream.Close())
+	require.Equal(t, "run-run-", <-output)
+
+	restarting, ok := events.find(ProcessRestarting)
+	require.True(t, ok)
+	require.Equal(t, FailureNone, restarting.Failure)
+	require.Zero(t, restarting.RestartIn)
+}
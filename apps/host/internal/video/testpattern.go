@@ -0,0 +1,76 @@
+package video
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	testPatternWidth     = 1920
+	testPatternHeight    = 1080
+	testPatternFrequency = 440 // Hz
+	testPatternRate      = 48000
+)
+
+// RecordTestPattern encodes a testsrc2 pattern with a burnt-in wall clock,
+// stream timestamp and frame counter. The sine wave audio source is only
+// muxed when writing to outputPath, in that case no stream is returned and
+// the recording runs until StopRecording. The stdout stream is video only.
+func (r *Recorder) RecordTestPattern(outputPath *string) (io.ReadCloser, error) {
+	if outputPath != nil {
+		if err := r.ffmpeg.Execute(buildTestPatternFileArgs(r.config, *outputPath)...); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	return r.record(NewTestPatternSource())
+}
+
+// buildTestPatternFileArgs returns the ffmpeg arguments that write the
+// test pattern with a sine wave audio track to the given file
+func buildTestPatternFileArgs(config *Config, outputPath string) []string {
+	args := []string{
+		"-re",
+		"-thread_queue_size", "4096",
+		"-f", "lavfi",
+		"-i", buildTestPatternSource(config),
+		"-re",
+		"-f", "lavfi",
+		"-i", fmt.Sprintf("sine=frequency=%d:sample_rate=%d", testPatternFrequency, testPatternRate),
+	}
+
+	args = append(args, buildCommonLowLatencyArgs(config)...)
+	args = append(args, buildProfileArgs(config.Encoder, config.Profile)...)
+
+	if isHardwareEncoder(config.Encoder) {
+		args = append(args, "-pix_fmt", "nv12")
+	}
+
+	return append(args,
+		"-map", "0:v",
+		"-map", "1:a",
+		"-c:a", "aac",
+		"-b:a", "128k",
+		"-y", // OVERWRITE IF EXISTS
+		outputPath,
+	)
+}
+
+// buildTestPatternSource returns the lavfi graph of the test pattern
+func buildTestPatternSource(config *Config) string {
+	width, height := testPatternWidth, testPatternHeight
+	if config.Width > 0 && config.Height > 0 {
+		width, height = FitResolution(width, height, config.Width, config.Height)
+	}
+
+	fontSize := height / 20
+	textStyle := fmt.Sprintf("fontsize=%d:fontcolor=white:box=1:boxcolor=black@0.6:boxborderw=8", fontSize)
+
+	return strings.Join([]string{
+		fmt.Sprintf("testsrc2=size=%dx%d:rate=%d", width, height, config.FPS),
+		fmt.Sprintf("drawtext=text='%%{localtime} %%{pts\\:hms}':x=%d:y=%d:%s", fontSize/2, fontSize/2, textStyle),
+		fmt.Sprintf("drawtext=text='frame %%{frame_num}':x=%d:y=%d:%s", fontSize/2, fontSize*2, textStyle),
+	}, ",")
+}
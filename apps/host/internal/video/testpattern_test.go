@@ -0,0 +1,54 @@
+package video
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceModeFromEnv(t *testing.T) {
+	t.Setenv(CaptureSourceEnv, "")
+	require.Equal(t, SourceModeScreen, SourceModeFromEnv(SourceModeScreen))
+
+	t.Setenv(CaptureSourceEnv, " TestPattern ")
+	require.Equal(t, SourceModeTestPattern, SourceModeFromEnv(SourceModeScreen))
+}
+
+func TestConfig_ValidateSource(t *testing.T) {
+	config := NewDefaultConfig()
+	config.SetSource(SourceModeTestPattern)
+	require.NoError(t, config.Validate())
+
+	config.SetSource("")
+	require.NoError(t, config.Validate())
+
+	config.SetSource("webcam")
+	require.ErrorIs(t, config.Validate(), ErrInvalidCaptureSource)
+}
+
+func TestBuildTestPatternArgs(t *testing.T) {
+	config := NewDefaultConfig()
+	config.SetSource(SourceModeTestPattern)
+	require.NoError(t, config.Validate())
+
+	args, err := buildSourceArgs(NewTestPatternSource(), config)
+	require.NoError(t, err)
+	joined := strings.Join(args, " ")
+
+	require.Contains(t, joined, "-f lavfi -i testsrc2=size=1920x1080:rate=30,drawtext=")
+	require.Contains(t, joined, "%{localtime} %{pts\\:hms}")
+	require.Contains(t, joined, "frame %{frame_num}")
+	require.Contains(t, joined, "-map 0:v")
+	require.NotContains(t, joined, "sine=")
+	require.Equal(t, []string{"-an", "-f", "h264", "-"}, args[len(args)-4:])
+
+	fileArgs := strings.Join(buildTestPatternFileArgs(config, "/tmp/pattern.mp4"), " ")
+	require.Contains(t, fileArgs, "-f lavfi -i sine=frequency=440:sample_rate=48000")
+	require.Contains(t, fileArgs, "-map 1:a -c:a aac")
+	require.NotContains(t, fileArgs, "-an")
+	require.True(t, strings.HasSuffix(fileArgs, "-y /tmp/pattern.mp4"))
+
+	config.Width, config.Height = 1280, 720
+	require.Contains(t, buildTestPatternSource(config), "testsrc2=size=1280x720:rate=30")
+}
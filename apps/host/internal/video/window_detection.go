@@ -0,0 +1,95 @@
+package video
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// windowPollInterval is how often a window is looked up while waiting
+	// for it to appear
+	windowPollInterval = 250 * time.Millisecond
+	// windowFollowInterval is how often a captured window is checked for
+	// size changes
+	windowFollowInterval = time.Second
+)
+
+// WindowInfo describes a top-level window, the position is relative to the
+// virtual desktop
+type WindowInfo struct {
+	ID      uint64 `json:"id"`
+	PID     uint32 `json:"pid"`
+	Title   string `json:"title"`
+	OffsetX int    `json:"offset_x"`
+	OffsetY int    `json:"offset_y"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+}
+
+// FindProcessWindow returns the main window of the process or one of its
+// child processes, launchers often start the actual program as a child. It
+// waits up to timeout for the window to appear.
+func FindProcessWindow(pid uint32, timeout time.Duration) (*WindowInfo, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		window, err := findProcessWindow(pid)
+		if err == nil {
+			return window, nil
+		}
+		if !errors.Is(err, ErrWindowNotFound) || time.Now().After(deadline) {
+			return nil, err
+		}
+		time.Sleep(windowPollInterval)
+	}
+}
+
+// GetWindowInfo returns the current title and geometry of the window, it
+// returns ErrWindowNotFound once the window was closed
+func GetWindowInfo(id uint64) (*WindowInfo, error) {
+	return getWindowInfo(id)
+}
+
+// ListWindows returns the visible top-level windows
+func ListWindows() ([]*WindowInfo, error) {
+	return listWindows()
+}
+
+// matchWindows returns the windows whose title contains title, ignoring
+// case
+func matchWindows(windows []*WindowInfo, title string) []*WindowInfo {
+	title = strings.ToLower(strings.TrimSpace(title))
+	if title == "" {
+		return nil
+	}
+
+	var matches []*WindowInfo
+	for _, window := range windows {
+		if strings.Contains(strings.ToLower(window.Title), title) {
+			matches = append(matches, window)
+		}
+	}
+	return matches
+}
+
+// selectProcessWindow returns the largest window that belongs to one of the
+// processes, splash screens and tool windows are usually smaller than the
+// main window
+func selectProcessWindow(windows []*WindowInfo, pids map[uint32]bool, pid uint32) (*WindowInfo, error) {
+	var best *WindowInfo
+	for _, window := range windows {
+		if !pids[window.PID] || window.Width <= 1 || window.Height <= 1 {
+			continue
+		}
+		if best == nil || window.Width*window.Height > best.Width*best.Height {
+			best = window
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("%w: no window for process %d", ErrWindowNotFound, pid)
+	}
+	return best, nil
+}
@@ -0,0 +1,16 @@
+//go:build darwin
+// +build darwin
+
+package video
+
+func findProcessWindow(pid uint32) (*WindowInfo, error) {
+	return nil, ErrOSNotSupported
+}
+
+func getWindowInfo(id uint64) (*WindowInfo, error) {
+	return nil, ErrOSNotSupported
+}
+
+func listWindows() ([]*WindowInfo, error) {
+	return nil, ErrOSNotSupported
+}
@@ -0,0 +1,264 @@
+//go:build linux
+// +build linux
+
+package video
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/m1thrandir225/imperium/apps/host/internal/x11"
+)
+
+// Windows are looked up through the EWMH properties the window manager sets
+// on the root window (_NET_CLIENT_LIST) and the client windows (_NET_WM_PID,
+// _NET_WM_NAME), using the X11 client of the x11 package.
+
+const (
+	x11OpGetWindowAttributes  = 3
+	x11OpGetGeometry          = 14
+	x11OpTranslateCoordinates = 40
+
+	x11MapStateViewable = 2
+)
+
+// procRoot is the proc filesystem, replaced in tests
+var procRoot = "/proc"
+
+// findProcessWindow returns the largest viewable client window of the
+// process or one of its descendants
+func findProcessWindow(pid uint32) (*WindowInfo, error) {
+	c, err := x11.DialDisplay()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	pidAtom, err := c.InternAtom("_NET_WM_PID")
+	if err != nil {
+		return nil, err
+	}
+
+	clients, err := clientList(c)
+	if err != nil {
+		return nil, err
+	}
+
+	pids := processTree(procRoot, pid)
+
+	var windows []*WindowInfo
+	for _, id := range clients {
+		windowPID, err := cardinalProperty(c, id, pidAtom)
+		if err != nil || !pids[windowPID] {
+			continue
+		}
+
+		window, err := windowInfo(c, id)
+		if err != nil {
+			continue
+		}
+		window.PID = windowPID
+		windows = append(windows, window)
+	}
+
+	return selectProcessWindow(windows, pids, pid)
+}
+
+// listWindows returns the viewable client windows
+func listWindows() ([]*WindowInfo, error) {
+	c, err := x11.DialDisplay()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	clients, err := clientList(c)
+	if err != nil {
+		return nil, err
+	}
+
+	pidAtom, pidErr := c.InternAtom("_NET_WM_PID")
+
+	var windows []*WindowInfo
+	for _, id := range clients {
+		window, err := windowInfo(c, id)
+		if err != nil {
+			continue
+		}
+		if pidErr == nil {
+			window.PID, _ = cardinalProperty(c, id, pidAtom)
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+func getWindowInfo(id uint64) (*WindowInfo, error) {
+	c, err := x11.DialDisplay()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	window, err := windowInfo(c, uint32(id))
+	if err != nil {
+		return nil, err
+	}
+
+	if pidAtom, err := c.InternAtom("_NET_WM_PID"); err == nil {
+		if pid, err := cardinalProperty(c, uint32(id), pidAtom); err == nil {
+			window.PID = pid
+		}
+	}
+	return window, nil
+}
+
+// clientList returns the client windows managed by the window manager
+func clientList(c *x11.Conn) ([]uint32, error) {
+	atom, err := c.InternAtom("_NET_CLIENT_LIST")
+	if err != nil {
+		return nil, fmt.Errorf("window manager does not support EWMH: %w", err)
+	}
+
+	format, value, err := c.GetProperty(c.Root(), atom, x11.AtomWindow)
+	if err != nil {
+		return nil, err
+	}
+	return x11.Cardinals(format, value), nil
+}
+
+// cardinalProperty returns the first value of a CARDINAL property
+func cardinalProperty(c *x11.Conn, window, property uint32) (uint32, error) {
+	format, value, err := c.GetProperty(window, property, x11.AtomCardinal)
+	if err != nil {
+		return 0, err
+	}
+	values := x11.Cardinals(format, value)
+	if len(values) == 0 {
+		return 0, fmt.Errorf("X11 window 0x%x has no property %d", window, property)
+	}
+	return values[0], nil
+}
+
+// windowTitle returns _NET_WM_NAME, or WM_NAME for windows without it
+func windowTitle(c *x11.Conn, window uint32) string {
+	if name, err := c.InternAtom("_NET_WM_NAME"); err == nil {
+		if utf8, err := c.InternAtom("UTF8_STRING"); err == nil {
+			if _, value, err := c.GetProperty(window, name, utf8); err == nil && len(value) > 0 {
+				return string(value)
+			}
+		}
+	}
+
+	if _, value, err := c.GetProperty(window, x11.AtomWMName, x11.AtomString); err == nil {
+		return string(value)
+	}
+	return ""
+}
+
+// windowInfo returns the title and the geometry of a viewable window in
+// root window coordinates
+func windowInfo(c *x11.Conn, window uint32) (*WindowInfo, error) {
+	req := make([]byte, 8)
+	req[0] = x11OpGetWindowAttributes
+	binary.LittleEndian.PutUint32(req[4:], window)
+
+	reply, err := c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 0x%x: %v", ErrWindowNotFound, window, err)
+	}
+	if reply[26] != x11MapStateViewable {
+		return nil, fmt.Errorf("%w: 0x%x is not viewable", ErrWindowNotFound, window)
+	}
+
+	req = make([]byte, 8)
+	req[0] = x11OpGetGeometry
+	binary.LittleEndian.PutUint32(req[4:], window)
+
+	reply, err = c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 0x%x: %v", ErrWindowNotFound, window, err)
+	}
+	width := int(binary.LittleEndian.Uint16(reply[16:]))
+	height := int(binary.LittleEndian.Uint16(reply[18:]))
+
+	// the geometry is relative to the parent, which is a frame of the
+	// window manager for reparented windows
+	req = make([]byte, 16)
+	req[0] = x11OpTranslateCoordinates
+	binary.LittleEndian.PutUint32(req[4:], window)
+	binary.LittleEndian.PutUint32(req[8:], c.Root())
+
+	reply, err = c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 0x%x: %v", ErrWindowNotFound, window, err)
+	}
+
+	return &WindowInfo{
+		ID:      uint64(window),
+		Title:   windowTitle(c, window),
+		OffsetX: int(int16(binary.LittleEndian.Uint16(reply[12:]))),
+		OffsetY: int(int16(binary.LittleEndian.Uint16(reply[14:]))),
+		Width:   width,
+		Height:  height,
+	}, nil
+}
+
+// processTree returns the process and all its descendants
+func processTree(root string, pid uint32) map[uint32]bool {
+	children := make(map[uint32][]uint32)
+
+	entries, _ := os.ReadDir(root)
+	for _, entry := range entries {
+		child, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(root, entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		if parent, ok := parseParentPID(string(data)); ok {
+			children[parent] = append(children[parent], uint32(child))
+		}
+	}
+
+	tree := map[uint32]bool{pid: true}
+	queue := []uint32{pid}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, child := range children[current] {
+			if !tree[child] {
+				tree[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	return tree
+}
+
+// parseParentPID returns the parent pid from /proc/<pid>/stat. The command
+// name may contain spaces and parentheses, the fields after the last ")" are
+// the state and the parent pid.
+func parseParentPID(stat string) (uint32, bool) {
+	idx := strings.LastIndex(stat, ")")
+	if idx < 0 {
+		return 0, false
+	}
+
+	fields := strings.Fields(stat[idx+1:])
+	if len(fields) < 2 {
+		return 0, false
+	}
+
+	parent, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(parent), true
+}
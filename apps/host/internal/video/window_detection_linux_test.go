@@ -0,0 +1,66 @@
+//go:build linux
+// +build linux
+
+package video
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseParentPID(t *testing.T) {
+	tests := []struct {
+		name   string
+		stat   string
+		want   uint32
+		wantOK bool
+	}{
+		{name: "plain", stat: "1234 (game) S 1000 1234 1234 0 -1", want: 1000, wantOK: true},
+		{name: "spaces and parens in name", stat: "42 (Web Content (x)) R 7 42 42", want: 7, wantOK: true},
+		{name: "truncated", stat: "42 (game) S", wantOK: false},
+		{name: "garbage", stat: "not a stat line", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseParentPID(tt.stat)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseParentPID() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestProcessTree(t *testing.T) {
+	root := t.TempDir()
+	processes := map[string]string{
+		"100": "100 (launcher) S 1",
+		"101": "101 (sh) S 100",
+		"102": "102 (game) S 101",
+		"200": "200 (other) S 1",
+		"201": "201 (child) S 200",
+	}
+	for pid, stat := range processes {
+		if err := os.MkdirAll(filepath.Join(root, pid), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, pid, "stat"), []byte(stat), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(root, "self"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[uint32]bool{100: true, 101: true, 102: true}
+	if got := processTree(root, 100); !reflect.DeepEqual(got, want) {
+		t.Errorf("processTree() = %v, want %v", got, want)
+	}
+
+	// a process that exited is still its own tree
+	if got := processTree(root, 300); !reflect.DeepEqual(got, map[uint32]bool{300: true}) {
+		t.Errorf("processTree() = %v, want only 300", got)
+	}
+}
@@ -0,0 +1,89 @@
+package video
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSelectProcessWindow(t *testing.T) {
+	windows := []*WindowInfo{
+		{ID: 1, PID: 10, Title: "Splash", Width: 640, Height: 360},
+		{ID: 2, PID: 11, Title: "Game", Width: 1920, Height: 1080},
+		{ID: 3, PID: 99, Title: "Browser", Width: 2560, Height: 1440},
+		{ID: 4, PID: 10, Title: "Hidden", Width: 1, Height: 1},
+	}
+
+	got, err := selectProcessWindow(windows, map[uint32]bool{10: true, 11: true}, 10)
+	if err != nil {
+		t.Fatalf("selectProcessWindow() error = %v", err)
+	}
+	if got.ID != 2 {
+		t.Errorf("selectProcessWindow() = window %d, want 2", got.ID)
+	}
+
+	_, err = selectProcessWindow(windows, map[uint32]bool{50: true}, 50)
+	if !errors.Is(err, ErrWindowNotFound) {
+		t.Errorf("selectProcessWindow() error = %v, want ErrWindowNotFound", err)
+	}
+}
+
+func TestWithWindow(t *testing.T) {
+	window := &WindowInfo{ID: 0x1200003, Title: "Game", Width: 1280, Height: 720}
+
+	screen := NewMonitorSource("1")
+	source, ok := WithWindow(screen, window).(windowSource)
+	if !ok {
+		t.Fatalf("WithWindow() did not return a window source")
+	}
+	if source.id != window.ID || source.fallback != screen {
+		t.Errorf("WithWindow() = %+v", source)
+	}
+
+	pattern := NewTestPatternSource()
+	if got := WithWindow(pattern, window); got != pattern {
+		t.Errorf("WithWindow() replaced the test pattern with %v", got)
+	}
+	if got := WithWindow(screen, nil); got != screen {
+		t.Errorf("WithWindow() without a window = %v", got)
+	}
+}
+
+func TestWindowSource_Fallback(t *testing.T) {
+	// without a display the window cannot be found on any platform
+	t.Setenv("DISPLAY", "")
+
+	config := NewDefaultConfig()
+	fallback := NewTestPatternSource()
+	source := NewProcessWindowSource(&WindowInfo{ID: 1, Title: "Gone"}, fallback)
+
+	got, err := source.InputArgs(config)
+	if err != nil {
+		t.Fatalf("InputArgs() error = %v", err)
+	}
+	want, _ := fallback.InputArgs(config)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("InputArgs() = %v, want the fallback %v", got, want)
+	}
+
+	width, height, err := source.(sizedSource).Size(config)
+	if err != nil || width != testPatternWidth || height != testPatternHeight {
+		t.Errorf("Size() = %d, %d, %v, want the fallback size", width, height, err)
+	}
+}
+
+func TestMatchWindows(t *testing.T) {
+	windows := []*WindowInfo{
+		{ID: 1, Title: "Discord"},
+		{ID: 2, Title: "#general - discord"},
+		{ID: 3, Title: "Game"},
+	}
+
+	got := matchWindows(windows, " DISCORD ")
+	if len(got) != 2 || got[0].ID != 1 || got[1].ID != 2 {
+		t.Errorf("matchWindows() = %v, want windows 1 and 2", got)
+	}
+	if got := matchWindows(windows, ""); got != nil {
+		t.Errorf("matchWindows() with an empty title = %v, want none", got)
+	}
+}
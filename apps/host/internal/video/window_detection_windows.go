@@ -0,0 +1,124 @@
+//go:build windows
+// +build windows
+
+package video
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procEnumWindows              = user32.NewProc("EnumWindows")
+	procGetWindowThreadProcessId = user32.NewProc("GetWindowThreadProcessId")
+	procGetWindowTextW           = user32.NewProc("GetWindowTextW")
+	procGetWindowRect            = user32.NewProc("GetWindowRect")
+	procIsWindow                 = user32.NewProc("IsWindow")
+	procIsWindowVisible          = user32.NewProc("IsWindowVisible")
+	procIsIconic                 = user32.NewProc("IsIconic")
+)
+
+// findProcessWindow returns the largest visible top-level window of the
+// process or one of its descendants
+func findProcessWindow(pid uint32) (*WindowInfo, error) {
+	pids := processTree(pid)
+
+	var windows []*WindowInfo
+	cb := syscall.NewCallback(func(hwnd syscall.Handle, lparam uintptr) uintptr {
+		var processID uint32
+		procGetWindowThreadProcessId.Call(uintptr(hwnd), uintptr(unsafe.Pointer(&processID)))
+		if !pids[processID] {
+			return 1 // continue
+		}
+
+		if window, err := getWindowInfo(uint64(hwnd)); err == nil && window.Title != "" {
+			windows = append(windows, window)
+		}
+		return 1 // continue
+	})
+
+	procEnumWindows.Call(cb, 0)
+
+	return selectProcessWindow(windows, pids, pid)
+}
+
+// listWindows returns the visible top-level windows with a title
+func listWindows() ([]*WindowInfo, error) {
+	var windows []*WindowInfo
+	cb := syscall.NewCallback(func(hwnd syscall.Handle, lparam uintptr) uintptr {
+		if window, err := getWindowInfo(uint64(hwnd)); err == nil && window.Title != "" {
+			windows = append(windows, window)
+		}
+		return 1 // continue
+	})
+
+	procEnumWindows.Call(cb, 0)
+	return windows, nil
+}
+
+func getWindowInfo(id uint64) (*WindowInfo, error) {
+	hwnd := uintptr(id)
+
+	if ret, _, _ := procIsWindow.Call(hwnd); ret == 0 {
+		return nil, fmt.Errorf("%w: 0x%x", ErrWindowNotFound, id)
+	}
+	if ret, _, _ := procIsWindowVisible.Call(hwnd); ret == 0 {
+		return nil, fmt.Errorf("%w: 0x%x is not visible", ErrWindowNotFound, id)
+	}
+	if ret, _, _ := procIsIconic.Call(hwnd); ret != 0 {
+		return nil, fmt.Errorf("%w: 0x%x is minimized", ErrWindowNotFound, id)
+	}
+
+	var rect RECT
+	if ret, _, err := procGetWindowRect.Call(hwnd, uintptr(unsafe.Pointer(&rect))); ret == 0 {
+		return nil, fmt.Errorf("%w: 0x%x: %v", ErrWindowNotFound, id, err)
+	}
+
+	var processID uint32
+	procGetWindowThreadProcessId.Call(hwnd, uintptr(unsafe.Pointer(&processID)))
+
+	buf := make([]uint16, 256)
+	procGetWindowTextW.Call(hwnd, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+
+	return &WindowInfo{
+		ID:      id,
+		PID:     processID,
+		Title:   syscall.UTF16ToString(buf),
+		OffsetX: int(rect.Left),
+		OffsetY: int(rect.Top),
+		Width:   int(rect.Right - rect.Left),
+		Height:  int(rect.Bottom - rect.Top),
+	}, nil
+}
+
+// processTree returns the process and all its descendants
+func processTree(pid uint32) map[uint32]bool {
+	tree := map[uint32]bool{pid: true}
+
+	snapshot, err := syscall.CreateToolhelp32Snapshot(syscall.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return tree
+	}
+	defer syscall.CloseHandle(snapshot)
+
+	children := make(map[uint32][]uint32)
+	var entry syscall.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	for err = syscall.Process32First(snapshot, &entry); err == nil; err = syscall.Process32Next(snapshot, &entry) {
+		children[entry.ParentProcessID] = append(children[entry.ParentProcessID], entry.ProcessID)
+	}
+
+	queue := []uint32{pid}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, child := range children[current] {
+			if !tree[child] {
+				tree[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	return tree
+}
@@ -10,9 +10,10 @@
 package mockwebrtc
 
 import (
-	io "io"
 	reflect "reflect"
 
+	input "github.com/m1thrandir225/imperium/apps/host/internal/input"
+	video "github.com/m1thrandir225/imperium/apps/host/internal/video"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -69,14 +70,76 @@ func (mr *MockStreamerMockRecorder) HandleOffer(offerSDP any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleOffer", reflect.TypeOf((*MockStreamer)(nil).HandleOffer), offerSDP)
 }
 
+// SendControl mocks base method.
+func (m *MockStreamer) SendControl(message []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendControl", message)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendControl indicates an expected call of SendControl.
+func (mr *MockStreamerMockRecorder) SendControl(message any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendControl", reflect.TypeOf((*MockStreamer)(nil).SendControl), message)
+}
+
+// SetControlHandler mocks base method.
+func (m *MockStreamer) SetControlHandler(fn func([]byte) []byte) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetControlHandler", fn)
+}
+
+// SetControlHandler indicates an expected call of SetControlHandler.
+func (mr *MockStreamerMockRecorder) SetControlHandler(fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetControlHandler", reflect.TypeOf((*MockStreamer)(nil).SetControlHandler), fn)
+}
+
+// SetDisconnectHandler mocks base method.
+func (m *MockStreamer) SetDisconnectHandler(fn func(string)) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetDisconnectHandler", fn)
+}
+
+// SetDisconnectHandler indicates an expected call of SetDisconnectHandler.
+func (mr *MockStreamerMockRecorder) SetDisconnectHandler(fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDisconnectHandler", reflect.TypeOf((*MockStreamer)(nil).SetDisconnectHandler), fn)
+}
+
+// SetInputHandler mocks base method.
+func (m *MockStreamer) SetInputHandler(fn func(input.InputCommand)) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetInputHandler", fn)
+}
+
+// SetInputHandler indicates an expected call of SetInputHandler.
+func (mr *MockStreamerMockRecorder) SetInputHandler(fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetInputHandler", reflect.TypeOf((*MockStreamer)(nil).SetInputHandler), fn)
+}
+
 // StartStream mocks base method.
-func (m *MockStreamer) StartStream(stream io.ReadCloser, fps int) {
+func (m *MockStreamer) StartStream(frames video.FrameReader) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "StartStream", stream, fps)
+	m.ctrl.Call(m, "StartStream", frames)
 }
 
 // StartStream indicates an expected call of StartStream.
-func (mr *MockStreamerMockRecorder) StartStream(stream, fps any) *gomock.Call {
+func (mr *MockStreamerMockRecorder) StartStream(frames any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartStream", reflect.TypeOf((*MockStreamer)(nil).StartStream), frames)
+}
+
+// SwitchStream mocks base method.
+func (m *MockStreamer) SwitchStream(frames video.FrameReader) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SwitchStream", frames)
+}
+
+// SwitchStream indicates an expected call of SwitchStream.
+func (mr *MockStreamerMockRecorder) SwitchStream(frames any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartStream", reflect.TypeOf((*MockStreamer)(nil).StartStream), stream, fps)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SwitchStream", reflect.TypeOf((*MockStreamer)(nil).SwitchStream), frames)
 }
@@ -3,6 +3,7 @@
 package webrtc
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,14 +11,40 @@ import (
 	"time"
 
 	"github.com/m1thrandir225/imperium/apps/host/internal/input"
+	"github.com/m1thrandir225/imperium/apps/host/internal/video"
 	"github.com/pion/rtp"
 	"github.com/pion/rtp/codecs"
 	pionwebrtc "github.com/pion/webrtc/v3"
-	"github.com/pion/webrtc/v3/pkg/media/h264reader"
+)
+
+const (
+	// videoClockRate is the RTP clock rate of video tracks
+	videoClockRate = 90000
+	// inputChannelLabel is the label of the data channel of the input
+	inputChannelLabel = "input"
+	// controlChannelLabel is the label of the data channel of the control
+	// messages
+	controlChannelLabel = "control"
 )
 
 type Streamer interface {
-	StartStream(stream io.ReadCloser, fps int)
+	StartStream(frames video.FrameReader)
+	// SwitchStream continues the stream with the frames of another encoder
+	// on the same track, the previous frame reader is closed
+	SwitchStream(frames video.FrameReader)
+	// SetControlHandler sets the handler of the control commands received
+	// on the control data channel, the returned reply is sent back unless
+	// it is nil, e.g. when the handler replies later with SendControl
+	SetControlHandler(fn func(message []byte) []byte)
+	// SetInputHandler sets the handler of the input commands decoded from
+	// the input data channel, they are injected directly while none is set
+	SetInputHandler(fn func(cmd input.InputCommand))
+	// SetDisconnectHandler sets the handler called when the input data
+	// channel closes or ICE fails, the client cannot release its input anymore
+	SetDisconnectHandler(fn func(reason string))
+	// SendControl sends a control message to the client, unprompted by a
+	// command, e.g. when the encoder changed
+	SendControl(message []byte) error
 	HandleOffer(offerSDP string) (string, error)
 	Close() error
 }
@@ -27,11 +54,35 @@ type streamer struct {
 	videoTrack       *pionwebrtc.TrackLocalStaticRTP
 	videoPayloadType uint8
 
-	readyOnce  sync.Once
-	readyCh    chan struct{}
-	iceReadyCh chan struct{}
+	readyOnce    sync.Once
+	readyCh      chan struct{}
+	iceReadyOnce sync.Once
+	iceReadyCh   chan struct{}
+
+	framesMu sync.Mutex
+	frames   video.FrameReader
+
+	controlMu    sync.Mutex
+	onControl    func(message []byte) []byte
+	onInput      func(cmd input.InputCommand)
+	onDisconnect func(reason string)
+
+	// inputChannel carries the binary input frames, unordered and without
+	// retransmits
+	inputChannel *pionwebrtc.DataChannel
+	// controlChannel carries the JSON control messages, reliable and
+	// ordered
+	controlChannel *pionwebrtc.DataChannel
+
+	// decoder decodes the input of the data channel in the negotiated
+	// protocol version
+	decoder *input.Decoder
 }
 
+// ErrDataChannelNotOpen is returned when a message is sent before the
+// client opened the control data channel or after it was closed
+var ErrDataChannelNotOpen = errors.New("data channel is not open")
+
 func NewStreamer() (Streamer, error) {
 	cfg := pionwebrtc.Configuration{
 		ICEServers: []pionwebrtc.ICEServer{
@@ -46,7 +97,7 @@ func NewStreamer() (Streamer, error) {
 	videoTrack, err := pionwebrtc.NewTrackLocalStaticRTP(
 		pionwebrtc.RTPCodecCapability{
 			MimeType:    pionwebrtc.MimeTypeH264,
-			ClockRate:   90000,
+			ClockRate:   videoClockRate,
 			SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1",
 		},
 		"video",
@@ -71,63 +122,124 @@ func NewStreamer() (Streamer, error) {
 		}
 	}()
 
+	// input is sent as it happens, a lost or late frame is not resent
 	ordered := false
 	maxRetrans := uint16(0)
-	dataChannel, err := pc.CreateDataChannel("input", &pionwebrtc.DataChannelInit{
+	inputChannel, err := pc.CreateDataChannel(inputChannelLabel, &pionwebrtc.DataChannelInit{
 		Ordered:        &ordered,
 		MaxRetransmits: &maxRetrans,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("create data channel: %w", err)
+		return nil, fmt.Errorf("create input data channel: %w", err)
+	}
+
+	// control commands, their replies and the messages of the host must
+	// all arrive and in order
+	controlChannel, err := pc.CreateDataChannel(controlChannelLabel, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create control data channel: %w", err)
+	}
+
+	streamer := &streamer{
+		pc:               pc,
+		videoTrack:       videoTrack,
+		videoPayloadType: 96,
+		readyCh:          make(chan struct{}),
+		iceReadyCh:       make(chan struct{}),
+		inputChannel:     inputChannel,
+		controlChannel:   controlChannel,
+		decoder:          input.NewDecoder(),
 	}
 
 	var msgCount uint64
 
-	dataChannel.OnOpen(func() {
+	inputChannel.OnOpen(func() {
 		log.Printf("input dc: open label=%q id=%d negotiated=%v readyState=%s",
-			dataChannel.Label(), dataChannel.ID(), dataChannel.Negotiated(), dataChannel.ReadyState())
+			inputChannel.Label(), inputChannel.ID(), inputChannel.Negotiated(), inputChannel.ReadyState())
 	})
 
-	dataChannel.OnClose(func() {
-		log.Printf("input dc: close label=%q", dataChannel.Label())
+	inputChannel.OnClose(func() {
+		log.Printf("input dc: close label=%q", inputChannel.Label())
+		streamer.handleDisconnect("the input data channel closed")
 	})
 
-	dataChannel.OnBufferedAmountLow(func() {
-		log.Printf("input dc: bufferedAmountLow=%d", dataChannel.BufferedAmount())
+	inputChannel.OnBufferedAmountLow(func() {
+		log.Printf("input dc: bufferedAmountLow=%d", inputChannel.BufferedAmount())
 	})
 
-	dataChannel.OnMessage(func(msg pionwebrtc.DataChannelMessage) {
+	inputChannel.OnMessage(func(msg pionwebrtc.DataChannelMessage) {
 		msgCount++
 		if msg.IsString {
 			log.Printf("input dc: #%d wrong type=string len=%d (expect binary)", msgCount, len(msg.Data))
 			return
 		}
 
-		if cmd, ok := input.DecodeInputCommand(msg.Data); ok {
+		if cmd, ok := streamer.decoder.Decode(msg.Data); ok {
 			log.Printf("input dc: #%d decoded cmd=%+v", msgCount, cmd)
-			input.HandleCommand(cmd)
+			streamer.handleInput(cmd)
 		} else {
-			log.Printf("Wrong message type. Expected binary.")
+			log.Printf("input dc: #%d dropped malformed or stale frame len=%d", msgCount, len(msg.Data))
 		}
 	})
 
-	streamer := &streamer{
-		pc:               pc,
-		videoTrack:       videoTrack,
-		videoPayloadType: 96,
-		readyCh:          make(chan struct{}),
-		iceReadyCh:       make(chan struct{}),
-	}
+	controlChannel.OnOpen(func() {
+		log.Printf("control dc: open label=%q id=%d", controlChannel.Label(), controlChannel.ID())
+
+		// offer the input protocol versions, clients that do not know the
+		// message keep sending v1
+		if err := controlChannel.SendText(string(input.ProtocolHello())); err != nil {
+			log.Printf("control dc: failed to send the input protocol versions: %v", err)
+		}
+	})
+
+	controlChannel.OnClose(func() {
+		log.Printf("control dc: close label=%q", controlChannel.Label())
+	})
+
+	controlChannel.OnMessage(func(msg pionwebrtc.DataChannelMessage) {
+		if !msg.IsString {
+			log.Printf("control dc: wrong type=binary len=%d (expect string)", len(msg.Data))
+			return
+		}
+
+		// text messages negotiate the input protocol or are control
+		// commands
+		if reply, ok := streamer.decoder.Negotiate(msg.Data); ok {
+			log.Printf("control dc: input protocol v%d", streamer.decoder.Version())
+			if err := controlChannel.SendText(string(reply)); err != nil {
+				log.Printf("control dc: failed to confirm the input protocol: %v", err)
+			}
+			return
+		}
+		reply, ok := streamer.handleControl(msg.Data)
+		if !ok {
+			log.Printf("control dc: no handler for control command len=%d", len(msg.Data))
+			return
+		}
+		if reply == nil {
+			return
+		}
+		if err := controlChannel.SendText(string(reply)); err != nil {
+			log.Printf("control dc: failed to reply to control command: %v", err)
+		}
+	})
 
 	pc.OnICEConnectionStateChange(func(state pionwebrtc.ICEConnectionState) {
 		log.Printf("ICE state: %s", state.String())
 
-		// Signal when ICE connection is established
-		if state == pionwebrtc.ICEConnectionStateConnected {
+		switch state {
+		case pionwebrtc.ICEConnectionStateConnected:
+			// Signal when ICE connection is established, it is established
+			// again after a disconnect
 			log.Printf("ICE connection established, ready to stream video")
-			close(streamer.iceReadyCh)
-		} else if state == pionwebrtc.ICEConnectionStateFailed {
+			streamer.iceReadyOnce.Do(func() { close(streamer.iceReadyCh) })
+		case pionwebrtc.ICEConnectionStateDisconnected:
+			// the client may reconnect, its input is released right away
+			// instead of after the failure timeout
+			streamer.handleDisconnect("the ICE connection was lost")
+		case pionwebrtc.ICEConnectionStateFailed:
 			log.Printf("ICE connection failed - may need TURN server")
+			streamer.handleDisconnect("the ICE connection failed")
 		}
 	})
 
@@ -138,46 +250,149 @@ func NewStreamer() (Streamer, error) {
 	return streamer, nil
 }
 
-func (s *streamer) StartStream(stream io.ReadCloser, fps int) {
-	go s.pumpStream(stream, fps)
+func (s *streamer) StartStream(frames video.FrameReader) {
+	s.framesMu.Lock()
+	s.frames = frames
+	s.framesMu.Unlock()
+
+	go s.pumpStream(frames)
 }
 
-func (s *streamer) pumpStream(stream io.ReadCloser, fps int) {
-	defer stream.Close()
+func (s *streamer) SwitchStream(frames video.FrameReader) {
+	s.framesMu.Lock()
+	previous := s.frames
+	s.frames = frames
+	s.framesMu.Unlock()
 
-	log.Printf("Waiting for ice ready channel")
-	<-s.iceReadyCh
-	log.Printf("Ice ready channel closed")
+	// unblocks the pump, which picks up the new frames
+	if previous != nil {
+		_ = previous.Close()
+	}
+}
 
-	reader, err := h264reader.NewReader(stream)
-	if err != nil {
-		log.Printf("h264 reader: %v", err)
+func (s *streamer) SetControlHandler(fn func(message []byte) []byte) {
+	s.controlMu.Lock()
+	defer s.controlMu.Unlock()
+	s.onControl = fn
+}
+
+func (s *streamer) SetInputHandler(fn func(cmd input.InputCommand)) {
+	s.controlMu.Lock()
+	defer s.controlMu.Unlock()
+	s.onInput = fn
+}
+
+func (s *streamer) SetDisconnectHandler(fn func(reason string)) {
+	s.controlMu.Lock()
+	defer s.controlMu.Unlock()
+	s.onDisconnect = fn
+}
+
+// handleDisconnect tells the handler that the client is gone
+func (s *streamer) handleDisconnect(reason string) {
+	s.controlMu.Lock()
+	fn := s.onDisconnect
+	s.controlMu.Unlock()
+
+	if fn != nil {
+		fn(reason)
+	}
+}
+
+// handleInput passes an input command to the handler, or injects it when
+// no handler is set
+func (s *streamer) handleInput(cmd input.InputCommand) {
+	s.controlMu.Lock()
+	fn := s.onInput
+	s.controlMu.Unlock()
+
+	if fn == nil {
+		input.HandleCommand(cmd)
 		return
 	}
+	fn(cmd)
+}
+
+// handleControl passes a control command to the handler, ok is false when
+// no handler is set
+func (s *streamer) handleControl(message []byte) (reply []byte, ok bool) {
+	s.controlMu.Lock()
+	fn := s.onControl
+	s.controlMu.Unlock()
+
+	if fn == nil {
+		return nil, false
+	}
+	return fn(message), true
+}
+
+// SendControl sends the message as text on the control data channel, like
+// the replies to control commands
+func (s *streamer) SendControl(message []byte) error {
+	if s.controlChannel == nil || s.controlChannel.ReadyState() != pionwebrtc.DataChannelStateOpen {
+		return ErrDataChannelNotOpen
+	}
+	return s.controlChannel.SendText(string(message))
+}
+
+// currentFrames returns the frames set by StartStream or SwitchStream
+func (s *streamer) currentFrames() video.FrameReader {
+	s.framesMu.Lock()
+	defer s.framesMu.Unlock()
+	return s.frames
+}
+
+func (s *streamer) pumpStream(frames video.FrameReader) {
+	defer func() {
+		_ = s.currentFrames().Close()
+	}()
+
+	log.Printf("Waiting for ice ready channel")
+	<-s.iceReadyCh
+	log.Printf("Ice ready channel closed")
 
 	pay := &codecs.H264Payloader{}
 	seq := rtp.NewRandomSequencer()
 	// 1200 bytes keep us under typical 1500 MTU with headers
-	pktizer := rtp.NewPacketizer(1200, s.videoPayloadType, 0, pay, seq, 90000)
+	pktizer := rtp.NewPacketizer(1200, s.videoPayloadType, 0, pay, seq, videoClockRate)
 
-	// If you know real FPS, set frameDuration accordingly
-	frameDuration := time.Second / time.Duration(fps)
-	tsStep := uint32(90000 / uint32(fps))
-	var ts uint32
+	log.Printf("Starting video stream")
 
-	log.Printf("Starting video stream at %d FPS", fps)
+	// the packetizer keeps the sequence numbers and the clock the
+	// timestamps continuous when the frames are switched
+	clock := &rtpClock{}
+	waitKeyframe := false
 
 	for {
-		nal, err := reader.NextNAL()
+		frame, err := frames.ReadFrame()
 		if err != nil {
+			if next := s.currentFrames(); next != frames {
+				log.Printf("Switching video stream")
+				frames = next
+				clock.switchStream()
+				waitKeyframe = true
+				continue
+			}
 			if err != io.EOF {
-				log.Printf("read NAL: %v", err)
+				log.Printf("read frame: %v", err)
 			}
 			return
 		}
 
-		// Packetize this NAL; payloader will fragment as FU-A when needed
-		pkts := pktizer.Packetize(nal.Data, uint32(frameDuration))
+		// a decoder cannot continue with the predicted frames of another
+		// encoder, the new stream starts with its first IDR frame
+		if waitKeyframe {
+			if !frame.Keyframe {
+				continue
+			}
+			waitKeyframe = false
+		}
+
+		// All NAL units of a frame share its timestamp, the payloader
+		// fragments them as FU-A when needed and the last packet carries
+		// the marker bit
+		ts := clock.timestamp(frame.PTS)
+		pkts := pktizer.Packetize(frame.Data, 0)
 		for _, p := range pkts {
 			p.Timestamp = ts
 			if err := s.videoTrack.WriteRTP(p); err != nil {
@@ -185,9 +400,43 @@ func (s *streamer) pumpStream(stream io.ReadCloser, fps int) {
 				return
 			}
 		}
+	}
+}
+
+// rtpTimestamp converts a presentation timestamp to the 90 kHz video clock
+func rtpTimestamp(pts time.Duration) uint32 {
+	return uint32(pts * videoClockRate / time.Second)
+}
+
+// rtpClock maps the presentation timestamps of consecutive frame readers,
+// which each start at zero, to one continuous RTP timeline
+type rtpClock struct {
+	base     uint32 // RTP timestamp of PTS zero of the current reader
+	last     uint32 // RTP timestamp of the last frame
+	duration uint32 // RTP duration of the last frame
+	started  bool   // a frame of the current reader was timestamped
+}
+
+// timestamp returns the RTP timestamp of a frame of the current reader
+func (c *rtpClock) timestamp(pts time.Duration) uint32 {
+	ts := c.base + rtpTimestamp(pts)
+	if c.started && ts != c.last {
+		c.duration = ts - c.last
+	}
+	c.started = true
+	c.last = ts
+	return ts
+}
 
-		ts += tsStep
+// switchStream continues the timeline one frame after the last frame
+func (c *rtpClock) switchStream() {
+	duration := c.duration
+	if duration == 0 {
+		duration = videoClockRate / 30
 	}
+	c.base = c.last + duration
+	c.last = c.base
+	c.started = false
 }
 
 // pumpAudioStream currently not used, as unable to get audio stream from the host.
@@ -0,0 +1,73 @@
+package webrtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m1thrandir225/imperium/apps/host/internal/input"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRTPClock(t *testing.T) {
+	clock := &rtpClock{}
+
+	require.Equal(t, uint32(0), clock.timestamp(0))
+	require.Equal(t, uint32(1800), clock.timestamp(20*time.Millisecond))
+	require.Equal(t, uint32(3600), clock.timestamp(40*time.Millisecond))
+
+	// the next reader starts at zero again and continues one frame later
+	clock.switchStream()
+	require.Equal(t, uint32(5400), clock.timestamp(0))
+	require.Equal(t, uint32(9900), clock.timestamp(50*time.Millisecond))
+
+	clock.switchStream()
+	require.Equal(t, uint32(14400), clock.timestamp(0))
+}
+
+func TestRTPClock_SwitchBeforeFirstFrame(t *testing.T) {
+	clock := &rtpClock{}
+	clock.switchStream()
+	require.Equal(t, uint32(videoClockRate/30), clock.timestamp(0))
+}
+
+func TestStreamer_HandleControl(t *testing.T) {
+	s := &streamer{}
+
+	_, ok := s.handleControl([]byte(`{"type":"stats_overlay"}`))
+	require.False(t, ok)
+
+	s.SetControlHandler(func(message []byte) []byte {
+		return append([]byte("reply to "), message...)
+	})
+	reply, ok := s.handleControl([]byte("ping"))
+	require.True(t, ok)
+	require.Equal(t, "reply to ping", string(reply))
+}
+
+func TestStreamer_SendControl(t *testing.T) {
+	s := &streamer{}
+	require.ErrorIs(t, s.SendControl([]byte(`{"type":"encoder_changed"}`)), ErrDataChannelNotOpen)
+}
+
+func TestStreamer_HandleInput(t *testing.T) {
+	s := &streamer{}
+
+	var handled []input.InputCommand
+	s.SetInputHandler(func(cmd input.InputCommand) {
+		handled = append(handled, cmd)
+	})
+	s.handleInput(input.InputCommand{Type: "mouse", Action: "move_relative", X: 3, Y: -2})
+	require.Equal(t, []input.InputCommand{{Type: "mouse", Action: "move_relative", X: 3, Y: -2}}, handled)
+}
+
+func TestStreamer_HandleDisconnect(t *testing.T) {
+	s := &streamer{}
+	s.handleDisconnect("no handler")
+
+	var reasons []string
+	s.SetDisconnectHandler(func(reason string) {
+		reasons = append(reasons, reason)
+	})
+	s.handleDisconnect("the input data channel closed")
+	require.Equal(t, []string{"the input data channel closed"}, reasons)
+}
@@ -0,0 +1,264 @@
+//go:build linux
+// +build linux
+
+package x11
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	opInternAtom     = 16
+	opGetAtomName    = 17
+	opGetProperty    = 20
+	opQueryExtension = 98
+
+	// maxPropertyLength is the maximum property length read, in 32 bit units
+	maxPropertyLength = 1 << 16
+
+	// Timeout bounds the connection setup and every request
+	Timeout = 2 * time.Second
+)
+
+// Conn is a minimal, synchronous X11 client connection
+type Conn struct {
+	conn net.Conn
+	root uint32
+}
+
+// DialDisplay connects to the X server of $DISPLAY
+func DialDisplay() (*Conn, error) {
+	display, err := ParseDisplay(os.Getenv("DISPLAY"))
+	if err != nil {
+		return nil, err
+	}
+	return Dial(display)
+}
+
+// Dial connects and authenticates to the X server of the given display
+func Dial(display Display) (*Conn, error) {
+	var conn net.Conn
+	var err error
+	if display.Host == "" {
+		conn, err = net.DialTimeout("unix", fmt.Sprintf("/tmp/.X11-unix/X%d", display.Number), Timeout)
+	} else {
+		conn, err = net.DialTimeout("tcp", net.JoinHostPort(display.Host, strconv.Itoa(6000+display.Number)), Timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to X server: %w", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(Timeout))
+
+	c := &Conn{conn: conn}
+	if err := c.setup(display); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Root returns the root window of the screen of the display
+func (c *Conn) Root() uint32 {
+	return c.root
+}
+
+// Close closes the connection
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// setup performs the connection handshake and stores the root window
+func (c *Conn) setup(display Display) error {
+	authName, authData := readXauthority(display)
+
+	req := make([]byte, 12)
+	req[0] = 'l'
+	binary.LittleEndian.PutUint16(req[2:], 11)
+	binary.LittleEndian.PutUint16(req[6:], uint16(len(authName)))
+	binary.LittleEndian.PutUint16(req[8:], uint16(len(authData)))
+	req = append(req, pad4([]byte(authName))...)
+	req = append(req, pad4(authData)...)
+
+	if _, err := c.conn.Write(req); err != nil {
+		return fmt.Errorf("failed to send X11 setup: %w", err)
+	}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return fmt.Errorf("failed to read X11 setup reply: %w", err)
+	}
+	data := make([]byte, int(binary.LittleEndian.Uint16(header[6:]))*4)
+	if _, err := io.ReadFull(c.conn, data); err != nil {
+		return fmt.Errorf("failed to read X11 setup reply: %w", err)
+	}
+
+	if header[0] != 1 {
+		reason := data
+		if header[0] == 0 && int(header[1]) <= len(data) {
+			reason = data[:header[1]]
+		}
+		return fmt.Errorf("X11 connection refused: %s", strings.TrimSpace(string(reason)))
+	}
+
+	root, err := parseSetupRoot(data, display.Screen)
+	if err != nil {
+		return err
+	}
+	c.root = root
+	return nil
+}
+
+// readXauthority looks up the MIT-MAGIC-COOKIE-1 entry for the display
+func readXauthority(display Display) (string, []byte) {
+	path := os.Getenv("XAUTHORITY")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", nil
+		}
+		path = filepath.Join(home, ".Xauthority")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil
+	}
+
+	return findXauthCookie(data, strconv.Itoa(display.Number))
+}
+
+// Request sends a request and waits for its reply. The length field of the
+// request is filled in.
+func (c *Conn) Request(req []byte) ([]byte, error) {
+	binary.LittleEndian.PutUint16(req[2:], uint16(len(req)/4))
+	if _, err := c.conn.Write(req); err != nil {
+		return nil, fmt.Errorf("failed to send X11 request: %w", err)
+	}
+
+	for {
+		reply := make([]byte, 32)
+		if _, err := io.ReadFull(c.conn, reply); err != nil {
+			return nil, fmt.Errorf("failed to read X11 reply: %w", err)
+		}
+
+		switch reply[0] {
+		case 0:
+			return nil, fmt.Errorf("X11 request failed with error code %d", reply[1])
+		case 1:
+			extra := make([]byte, int(binary.LittleEndian.Uint32(reply[4:]))*4)
+			if _, err := io.ReadFull(c.conn, extra); err != nil {
+				return nil, fmt.Errorf("failed to read X11 reply: %w", err)
+			}
+			return append(reply, extra...), nil
+		default:
+			// events are not selected, skip anything unexpected
+			continue
+		}
+	}
+}
+
+// Send sends a request that has no reply
+func (c *Conn) Send(req []byte) error {
+	binary.LittleEndian.PutUint16(req[2:], uint16(len(req)/4))
+	_ = c.conn.SetWriteDeadline(time.Now().Add(Timeout))
+	if _, err := c.conn.Write(req); err != nil {
+		return fmt.Errorf("failed to send X11 request: %w", err)
+	}
+	return nil
+}
+
+// DiscardReplies is for connections that only Send from here on, it reads
+// everything the server sends and passes the error codes to onError until
+// the connection is closed
+func (c *Conn) DiscardReplies(onError func(code byte)) {
+	_ = c.conn.SetDeadline(time.Time{})
+	go func() {
+		packet := make([]byte, 32)
+		for {
+			if _, err := io.ReadFull(c.conn, packet); err != nil {
+				return
+			}
+			if packet[0] == 0 {
+				onError(packet[1])
+			}
+		}
+	}()
+}
+
+// QueryExtension returns the major opcode of an extension
+func (c *Conn) QueryExtension(name string) (byte, error) {
+	req := make([]byte, 8)
+	req[0] = opQueryExtension
+	binary.LittleEndian.PutUint16(req[4:], uint16(len(name)))
+	req = append(req, pad4([]byte(name))...)
+
+	reply, err := c.Request(req)
+	if err != nil {
+		return 0, err
+	}
+	if reply[8] == 0 {
+		return 0, fmt.Errorf("X server does not support %s", name)
+	}
+	return reply[9], nil
+}
+
+// InternAtom returns the atom of an existing name
+func (c *Conn) InternAtom(name string) (uint32, error) {
+	req := make([]byte, 8)
+	req[0] = opInternAtom
+	req[1] = 1 // only-if-exists
+	binary.LittleEndian.PutUint16(req[4:], uint16(len(name)))
+	req = append(req, pad4([]byte(name))...)
+
+	reply, err := c.Request(req)
+	if err != nil {
+		return 0, err
+	}
+	atom := binary.LittleEndian.Uint32(reply[8:])
+	if atom == 0 {
+		return 0, fmt.Errorf("X11 atom %s does not exist", name)
+	}
+	return atom, nil
+}
+
+// AtomName resolves an atom to its name
+func (c *Conn) AtomName(atom uint32) (string, error) {
+	req := make([]byte, 8)
+	req[0] = opGetAtomName
+	binary.LittleEndian.PutUint32(req[4:], atom)
+
+	reply, err := c.Request(req)
+	if err != nil {
+		return "", err
+	}
+	n := int(binary.LittleEndian.Uint16(reply[8:]))
+	if 32+n > len(reply) {
+		return "", fmt.Errorf("atom name reply too short")
+	}
+	return string(reply[32 : 32+n]), nil
+}
+
+// GetProperty returns the format and the value of a window property, a
+// missing property has format 0
+func (c *Conn) GetProperty(window, property, propertyType uint32) (byte, []byte, error) {
+	req := make([]byte, 24)
+	req[0] = opGetProperty
+	binary.LittleEndian.PutUint32(req[4:], window)
+	binary.LittleEndian.PutUint32(req[8:], property)
+	binary.LittleEndian.PutUint32(req[12:], propertyType)
+	binary.LittleEndian.PutUint32(req[20:], maxPropertyLength)
+
+	reply, err := c.Request(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	return parsePropertyReply(reply)
+}
@@ -0,0 +1,172 @@
+// Package x11 implements the small part of the X11 wire protocol the host
+// needs to talk to the X server, without linking against libX11.
+package x11
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Predefined atoms of the core protocol
+const (
+	AtomCardinal = 6
+	AtomString   = 31
+	AtomWindow   = 33
+	AtomWMName   = 39
+)
+
+// ErrNoDisplay is returned when $DISPLAY is not set
+var ErrNoDisplay = errors.New("DISPLAY is not set")
+
+// Display is a parsed DISPLAY value
+type Display struct {
+	Host   string
+	Number int
+	Screen int
+}
+
+// ParseDisplay parses values like ":0", ":1.0", "unix:0" or "host:0.1"
+func ParseDisplay(value string) (Display, error) {
+	if value == "" {
+		return Display{}, ErrNoDisplay
+	}
+
+	idx := strings.LastIndex(value, ":")
+	if idx < 0 {
+		return Display{}, fmt.Errorf("invalid DISPLAY value: %q", value)
+	}
+
+	display := Display{Host: value[:idx]}
+	number, screen, hasScreen := strings.Cut(value[idx+1:], ".")
+
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 0 {
+		return Display{}, fmt.Errorf("invalid DISPLAY value: %q", value)
+	}
+	display.Number = n
+
+	if hasScreen {
+		s, err := strconv.Atoi(screen)
+		if err != nil || s < 0 {
+			return Display{}, fmt.Errorf("invalid DISPLAY value: %q", value)
+		}
+		display.Screen = s
+	}
+
+	if display.Host == "unix" {
+		display.Host = ""
+	}
+
+	return display, nil
+}
+
+// parseSetupRoot returns the root window of the given screen from the
+// additional data of a successful setup reply
+func parseSetupRoot(data []byte, screen int) (uint32, error) {
+	if len(data) < 32 {
+		return 0, fmt.Errorf("X11 setup reply too short")
+	}
+
+	vendorLen := int(binary.LittleEndian.Uint16(data[16:]))
+	numScreens := int(data[20])
+	numFormats := int(data[21])
+
+	if screen >= numScreens {
+		return 0, fmt.Errorf("X11 screen %d not found", screen)
+	}
+
+	offset := 32 + (vendorLen+3)&^3 + numFormats*8
+	for i := 0; ; i++ {
+		if offset+40 > len(data) {
+			return 0, fmt.Errorf("X11 setup reply too short")
+		}
+		if i == screen {
+			return binary.LittleEndian.Uint32(data[offset:]), nil
+		}
+
+		numDepths := int(data[offset+39])
+		offset += 40
+		for d := 0; d < numDepths; d++ {
+			if offset+8 > len(data) {
+				return 0, fmt.Errorf("X11 setup reply too short")
+			}
+			numVisuals := int(binary.LittleEndian.Uint16(data[offset+2:]))
+			offset += 8 + numVisuals*24
+		}
+	}
+}
+
+// findXauthCookie parses an Xauthority file and returns the cookie for the
+// given display number
+func findXauthCookie(data []byte, number string) (string, []byte) {
+	r := bytes.NewReader(data)
+
+	readField := func() ([]byte, bool) {
+		var n uint16
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, false
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, false
+		}
+		return buf, true
+	}
+
+	for {
+		var family uint16
+		if err := binary.Read(r, binary.BigEndian, &family); err != nil {
+			return "", nil
+		}
+		_, ok1 := readField() // address
+		num, ok2 := readField()
+		name, ok3 := readField()
+		cookie, ok4 := readField()
+		if !ok1 || !ok2 || !ok3 || !ok4 {
+			return "", nil
+		}
+
+		if string(name) == "MIT-MAGIC-COOKIE-1" && (len(num) == 0 || string(num) == number) {
+			return string(name), cookie
+		}
+	}
+}
+
+// parsePropertyReply returns the format and the value of a GetProperty reply
+func parsePropertyReply(reply []byte) (byte, []byte, error) {
+	if len(reply) < 32 {
+		return 0, nil, fmt.Errorf("X11 property reply too short")
+	}
+
+	format := reply[1]
+	length := int(binary.LittleEndian.Uint32(reply[16:])) * int(format) / 8
+	if 32+length > len(reply) {
+		return 0, nil, fmt.Errorf("X11 property reply too short")
+	}
+	return format, reply[32 : 32+length], nil
+}
+
+// Cardinals decodes a 32 bit property value
+func Cardinals(format byte, value []byte) []uint32 {
+	if format != 32 {
+		return nil
+	}
+	values := make([]uint32, len(value)/4)
+	for i := range values {
+		values[i] = binary.LittleEndian.Uint32(value[i*4:])
+	}
+	return values
+}
+
+// pad4 pads b with zero bytes to a multiple of four
+func pad4(b []byte) []byte {
+	if rem := len(b) % 4; rem != 0 {
+		return append(b, make([]byte, 4-rem)...)
+	}
+	return b
+}
@@ -0,0 +1,96 @@
+package x11
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestParseX11Display(t *testing.T) {
+	tests := []struct {
+		input       string
+		want        Display
+		expectError bool
+	}{
+		{input: ":0", want: Display{Number: 0}},
+		{input: ":1.2", want: Display{Number: 1, Screen: 2}},
+		{input: "unix:0", want: Display{Number: 0}},
+		{input: "localhost:10.0", want: Display{Host: "localhost", Number: 10}},
+		{input: "", expectError: true},
+		{input: "0", expectError: true},
+		{input: ":x", expectError: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDisplay(tt.input)
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("ParseDisplay(%q): expected an error", tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDisplay(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDisplay(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestFindXauthCookie(t *testing.T) {
+	entry := func(number, name string, cookie []byte) []byte {
+		var b []byte
+		b = binary.BigEndian.AppendUint16(b, 256)
+		for _, field := range [][]byte{[]byte("host"), []byte(number), []byte(name), cookie} {
+			b = binary.BigEndian.AppendUint16(b, uint16(len(field)))
+			b = append(b, field...)
+		}
+		return b
+	}
+
+	var data []byte
+	data = append(data, entry("0", "XDM-AUTHORIZATION-1", []byte{9, 9})...)
+	data = append(data, entry("1", "MIT-MAGIC-COOKIE-1", []byte{1, 2, 3})...)
+	data = append(data, entry("0", "MIT-MAGIC-COOKIE-1", []byte{4, 5, 6})...)
+
+	name, cookie := findXauthCookie(data, "0")
+	if name != "MIT-MAGIC-COOKIE-1" || string(cookie) != string([]byte{4, 5, 6}) {
+		t.Errorf("findXauthCookie() = %q %v, want the display 0 cookie", name, cookie)
+	}
+
+	if name, _ := findXauthCookie(data, "7"); name != "" {
+		t.Errorf("Expected no cookie for display 7, got %q", name)
+	}
+
+	if name, _ := findXauthCookie(data[:5], "0"); name != "" {
+		t.Errorf("Expected no cookie for a truncated file, got %q", name)
+	}
+}
+
+func TestParsePropertyReply(t *testing.T) {
+	reply := make([]byte, 32)
+	reply[0] = 1
+	reply[1] = 32
+	binary.LittleEndian.PutUint32(reply[16:], 2)
+	reply = binary.LittleEndian.AppendUint32(reply, 0x1200003)
+	reply = binary.LittleEndian.AppendUint32(reply, 0x1400007)
+
+	format, value, err := parsePropertyReply(reply)
+	if err != nil {
+		t.Fatalf("parsePropertyReply() error = %v", err)
+	}
+	if got := Cardinals(format, value); !reflect.DeepEqual(got, []uint32{0x1200003, 0x1400007}) {
+		t.Errorf("Cardinals() = %x", got)
+	}
+
+	// 8 bit properties are strings
+	if got := Cardinals(8, []byte("game")); got != nil {
+		t.Errorf("Cardinals() = %v for a string", got)
+	}
+
+	if _, _, err := parsePropertyReply(reply[:36]); err == nil {
+		t.Error("parsePropertyReply() expected error for a truncated reply")
+	}
+}